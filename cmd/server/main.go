@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/ex0rcist/metflix/internal/logging"
 	"github.com/ex0rcist/metflix/internal/server"
@@ -17,6 +18,14 @@ func main() {
 	logging.Setup()
 	fmt.Printf("Build version: %s\nBuild date: %s\nBuild commit: %s\n", buildVersion, buildDate, buildCommit)
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := server.Migrate(os.Args[2:], os.Stdout); err != nil {
+			logging.LogFatal(err)
+		}
+
+		return
+	}
+
 	srv, err := server.New()
 	if err != nil {
 		logging.LogFatal(err)
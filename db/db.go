@@ -0,0 +1,12 @@
+// Package db contains SQL migrations of the Postgres storage.
+package db
+
+import "embed"
+
+// Migrations compiled into the server binary, files are under "migrate" directory.
+//
+//go:embed migrate/*.sql
+var Migrations embed.FS
+
+// Directory of migrations within Migrations.
+const MigrationsDir = "migrate"
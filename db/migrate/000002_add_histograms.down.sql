@@ -0,0 +1,4 @@
+-- enum values can't be dropped, so 'histogram' stays in metricKind
+DELETE FROM metrics WHERE kind = 'histogram';
+
+ALTER TABLE metrics DROP COLUMN IF EXISTS histogram;
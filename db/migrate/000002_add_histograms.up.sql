@@ -0,0 +1,3 @@
+ALTER TYPE metricKind ADD VALUE IF NOT EXISTS 'histogram';
+
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS histogram jsonb;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS metrics_name_idx;
+DROP INDEX IF EXISTS metrics_labels_idx;
+
+-- labeled series can't be represented without labels column
+DELETE FROM metrics WHERE labels <> '{}'::jsonb;
+ALTER TABLE metrics DROP COLUMN IF EXISTS labels;
+ALTER TABLE metrics ALTER COLUMN id TYPE varchar(255);
@@ -0,0 +1,5 @@
+ALTER TABLE metrics ALTER COLUMN id TYPE text;
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}'::jsonb;
+
+CREATE INDEX IF NOT EXISTS metrics_labels_idx ON metrics USING GIN (labels);
+CREATE INDEX IF NOT EXISTS metrics_name_idx ON metrics (name);
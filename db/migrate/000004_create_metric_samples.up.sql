@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS metric_samples(
+    id        text not null,
+    ts        timestamptz not null,
+    kind      metricKind not null,
+    value     double precision not null,
+    histogram jsonb
+);
+
+CREATE INDEX IF NOT EXISTS metric_samples_id_ts_idx ON metric_samples (id, ts);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS metric_rollups(
+    id         text not null,
+    resolution integer not null,
+    ts         timestamptz not null,
+    min        double precision not null,
+    max        double precision not null,
+    sum        double precision not null,
+    count      bigint not null,
+    primary key (id, resolution, ts)
+);
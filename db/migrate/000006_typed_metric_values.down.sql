@@ -0,0 +1,6 @@
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_typed_value_check;
+
+UPDATE metrics SET value = delta WHERE kind = 'counter';
+
+ALTER TABLE metrics ALTER COLUMN value SET NOT NULL;
+ALTER TABLE metrics DROP COLUMN IF EXISTS delta;
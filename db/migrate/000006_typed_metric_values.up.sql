@@ -0,0 +1,10 @@
+-- counters are stored exactly in delta, gauges in value, histograms keep sum in value
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS delta bigint;
+ALTER TABLE metrics ALTER COLUMN value DROP NOT NULL;
+
+UPDATE metrics SET delta = value::bigint, value = NULL WHERE kind = 'counter';
+
+ALTER TABLE metrics ADD CONSTRAINT metrics_typed_value_check CHECK (
+    (kind = 'counter' AND delta IS NOT NULL AND value IS NULL) OR
+    (kind <> 'counter' AND delta IS NULL AND value IS NOT NULL)
+);
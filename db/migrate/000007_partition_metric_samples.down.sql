@@ -0,0 +1,13 @@
+CREATE TABLE metric_samples_unpartitioned(
+    id        text not null,
+    ts        timestamptz not null,
+    kind      metricKind not null,
+    value     double precision not null,
+    histogram jsonb
+);
+
+INSERT INTO metric_samples_unpartitioned SELECT id, ts, kind, value, histogram FROM metric_samples;
+DROP TABLE metric_samples;
+
+ALTER TABLE metric_samples_unpartitioned RENAME TO metric_samples;
+CREATE INDEX IF NOT EXISTS metric_samples_id_ts_idx ON metric_samples (id, ts);
@@ -0,0 +1,19 @@
+ALTER TABLE metric_samples RENAME TO metric_samples_unpartitioned;
+ALTER INDEX metric_samples_id_ts_idx RENAME TO metric_samples_unpartitioned_id_ts_idx;
+
+-- daily partitions metric_samples_pYYYYMMDD are created and dropped by the server
+CREATE TABLE metric_samples(
+    id        text not null,
+    ts        timestamptz not null,
+    kind      metricKind not null,
+    value     double precision not null,
+    histogram jsonb
+) PARTITION BY RANGE (ts);
+
+CREATE INDEX metric_samples_id_ts_idx ON metric_samples (id, ts);
+
+-- samples outside of daily partitions, moved to a daily partition when it is created
+CREATE TABLE metric_samples_default PARTITION OF metric_samples DEFAULT;
+
+INSERT INTO metric_samples SELECT id, ts, kind, value, histogram FROM metric_samples_unpartitioned;
+DROP TABLE metric_samples_unpartitioned;
@@ -28,6 +28,181 @@ const docTemplate = `{
                 ],
                 "summary": "Yet another homepage",
                 "operationId": "homepage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Show only metrics with given name.",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Show only metrics of given type.",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Show only metrics having label, in ` + "`" + `key=value` + "`" + ` format.",
+                        "name": "label",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/query_range": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Get history of metric aggregated by steps",
+                "operationId": "metrics_query_range",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Metrics name.",
+                        "name": "name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metrics type (e.g. ` + "`" + `counter` + "`" + `, ` + "`" + `gauge` + "`" + `).",
+                        "name": "kind",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Series label, in ` + "`" + `key=value` + "`" + ` format.",
+                        "name": "label",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Range start: unix timestamp or RFC3339, defaults to one hour before ` + "`" + `to` + "`" + `.",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Range end: unix timestamp or RFC3339, defaults to now.",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Step width: duration (e.g. ` + "`" + `30s` + "`" + `) or number of seconds, defaults to ` + "`" + `1m` + "`" + `.",
+                        "name": "step",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Aggregation within step: ` + "`" + `avg` + "`" + ` (default), ` + "`" + `min` + "`" + `, ` + "`" + `max` + "`" + `, ` + "`" + `sum` + "`" + ` or ` + "`" + `last` + "`" + `.",
+                        "name": "agg",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Source data: ` + "`" + `raw` + "`" + ` samples (default), ` + "`" + `1m` + "`" + ` or ` + "`" + `1h` + "`" + ` rollups kept by retention.",
+                        "name": "resolution",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.QueryRangeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stream": {
+            "get": {
+                "description": "Server-Sent Events stream, each ` + "`" + `metric` + "`" + ` event carries metrics.MetricExchange JSON of the stored value.\nReconnecting clients resume from ` + "`" + `Last-Event-ID` + "`" + ` while the events are kept in server memory.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Live stream of metric changes",
+                "operationId": "metrics_stream",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Stream only metrics with given name.",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Stream only metrics of given type.",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Stream only metrics having label, in ` + "`" + `key=value` + "`" + ` format.",
+                        "name": "label",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the last received event.",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -35,6 +210,132 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/write": {
+            "post": {
+                "consumes": [
+                    "application/x-protobuf"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Receive metrics via Prometheus remote write protocol",
+                "operationId": "metrics_remote_write",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Must be ` + "`" + `snappy` + "`" + `.",
+                        "name": "Content-Encoding",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Healthcheck"
+                ],
+                "summary": "Verify server process is alive",
+                "operationId": "health_liveness",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.HealthReport"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/services.HealthReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Get all metrics in Prometheus text format",
+                "operationId": "metrics_prometheus",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Export only metrics with given name.",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Export only metrics of given type.",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Export only metrics having label, in ` + "`" + `key=value` + "`" + ` format.",
+                        "name": "label",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -70,6 +371,32 @@ const docTemplate = `{
                 }
             }
         },
+        "/readyz": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Healthcheck"
+                ],
+                "summary": "Verify server is ready to serve requests: storage is reachable and consistent",
+                "operationId": "health_readiness",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.HealthReport"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/services.HealthReport"
+                        }
+                    }
+                }
+            }
+        },
         "/update": {
             "post": {
                 "consumes": [
@@ -126,7 +453,7 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Metrics type (e.g. ` + "`" + `counter` + "`" + `, ` + "`" + `gauge` + "`" + `).",
+                        "description": "Metrics type (e.g. ` + "`" + `counter` + "`" + `, ` + "`" + `gauge` + "`" + `, ` + "`" + `histogram` + "`" + `).",
                         "name": "type",
                         "in": "path",
                         "required": true
@@ -140,7 +467,7 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Metrics value, must be convertable to ` + "`" + `int64` + "`" + ` or ` + "`" + `float64` + "`" + `.",
+                        "description": "Metrics value, must be convertable to ` + "`" + `int64` + "`" + ` or ` + "`" + `float64` + "`" + `. Histogram value is a single observation.",
                         "name": "value",
                         "in": "path",
                         "required": true
@@ -217,6 +544,60 @@ const docTemplate = `{
                 }
             }
         },
+        "/v1/metrics": {
+            "post": {
+                "consumes": [
+                    "application/x-protobuf",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/x-protobuf",
+                    "application/json"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Receive metrics via OpenTelemetry protocol (OTLP/HTTP)",
+                "operationId": "metrics_otlp",
+                "parameters": [
+                    {
+                        "description": "ExportMetricsServiceRequest encoded as protobuf or JSON.",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ExportMetricsServiceResponse, rejected data points are reported via partial_success.",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/value": {
             "post": {
                 "consumes": [
@@ -232,7 +613,7 @@ const docTemplate = `{
                 "operationId": "metrics_json_info",
                 "parameters": [
                     {
-                        "description": "Request parameters: ` + "`" + `id` + "`" + ` and ` + "`" + `type` + "`" + ` are required.",
+                        "description": "Request parameters: ` + "`" + `id` + "`" + ` and ` + "`" + `type` + "`" + ` are required, ` + "`" + `labels` + "`" + ` select labeled series.",
                         "name": "request",
                         "in": "body",
                         "required": true,
@@ -282,7 +663,7 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Metrics type (e.g. ` + "`" + `counter` + "`" + `, ` + "`" + `gauge` + "`" + `).",
+                        "description": "Metrics type (e.g. ` + "`" + `counter` + "`" + `, ` + "`" + `gauge` + "`" + `, ` + "`" + `histogram` + "`" + `).",
                         "name": "type",
                         "in": "path",
                         "required": true
@@ -322,18 +703,167 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "/write": {
+            "post": {
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Push metrics in InfluxDB line protocol",
+                "operationId": "metrics_influx_write",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Timestamp precision: ` + "`" + `ns` + "`" + ` (default), ` + "`" + `us` + "`" + `, ` + "`" + `ms` + "`" + `, ` + "`" + `s` + "`" + `, ` + "`" + `m` + "`" + ` or ` + "`" + `h` + "`" + `.",
+                        "name": "precision",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Lines in format ` + "`" + `measurement[,tag=value...] field=value[,field=value...] [timestamp]` + "`" + `.",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Some lines were rejected, valid lines are stored.",
+                        "schema": {
+                            "$ref": "#/definitions/httpserver.InfluxWriteResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
+        "httpserver.InfluxLineError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "line": {
+                    "description": "1-based line number",
+                    "type": "integer"
+                }
+            }
+        },
+        "httpserver.InfluxWriteResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/httpserver.InfluxLineError"
+                    }
+                },
+                "stored": {
+                    "description": "number of accepted lines",
+                    "type": "integer"
+                }
+            }
+        },
+        "httpserver.QueryRangeResponse": {
+            "type": "object",
+            "properties": {
+                "agg": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "labels": {
+                    "$ref": "#/definitions/metrics.Labels"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.Point"
+                    }
+                },
+                "resolution": {
+                    "type": "string"
+                },
+                "step": {
+                    "type": "number"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "metrics.Histogram": {
+            "type": "object",
+            "properties": {
+                "bounds": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "counts": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "sum": {
+                    "type": "number"
+                }
+            }
+        },
+        "metrics.Labels": {
+            "type": "object",
+            "additionalProperties": {
+                "type": "string"
+            }
+        },
         "metrics.MetricExchange": {
             "type": "object",
             "properties": {
                 "delta": {
                     "type": "integer"
                 },
+                "histogram": {
+                    "$ref": "#/definitions/metrics.Histogram"
+                },
                 "id": {
                     "type": "string"
                 },
+                "labels": {
+                    "$ref": "#/definitions/metrics.Labels"
+                },
                 "type": {
                     "type": "string"
                 },
@@ -341,6 +871,51 @@ const docTemplate = `{
                     "type": "number"
                 }
             }
+        },
+        "services.CheckResult": {
+            "type": "object",
+            "properties": {
+                "last_error": {
+                    "type": "string"
+                },
+                "last_error_at": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.HealthReport": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.CheckResult"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.Point": {
+            "type": "object",
+            "properties": {
+                "ts": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "number"
+                }
+            }
         }
     },
     "tags": [
@@ -3,9 +3,11 @@ package exporter
 import (
 	"context"
 	"errors"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 
@@ -17,6 +19,9 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func TestHTTPExporter(t *testing.T) {
@@ -153,6 +158,7 @@ func TestGRPCExporter(t *testing.T) {
 	assert.NoError(err)
 
 	assert.Equal(0, len(exporter.buffer))
+	assert.True(exporter.noStream, "server without streaming support should switch exporter to unary requests")
 
 	// test error()
 	exporter.err = errors.New("test error")
@@ -164,6 +170,110 @@ func TestGRPCExporter(t *testing.T) {
 	assert.Nil(exporter.err)
 }
 
+func TestGRPCExporterStream(t *testing.T) {
+	baseURL := entities.Address("localhost:50052")
+
+	srv := &TestStreamMetricsServer{batches: make(chan *grpcapi.StreamUpdateRequest, 10)}
+
+	server := grpc.NewServer()
+	grpcapi.RegisterMetricsServer(server, srv)
+
+	lis, err := net.Listen("tcp", baseURL.String())
+	require.NoError(t, err)
+
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	defer server.Stop()
+
+	exporter := NewGRPCExporter(&baseURL, nil)
+
+	for i := 0; i < 2; i++ {
+		exporter.Add("test_counter", metrics.Counter(10))
+		require.NoError(t, exporter.Send())
+
+		batch := <-srv.batches
+		require.Equal(t, "test_counter", batch.Data[0].Id)
+	}
+
+	require.False(t, exporter.noStream)
+	require.NotNil(t, exporter.stream)
+
+	require.NoError(t, exporter.Close())
+	require.Equal(t, 1, srv.streams, "batches should be sent into single stream")
+}
+
+func TestGRPCExporterStreamResend(t *testing.T) {
+	baseURL := entities.Address("localhost:50053")
+
+	srv := &TestFailingStreamMetricsServer{
+		batches:   make(chan *grpcapi.StreamUpdateRequest, 10),
+		unary:     make(chan *grpcapi.BatchUpdateRequest, 10),
+		unaryIDs:  make(chan string, 10),
+		streamIDs: make(chan string, 10),
+	}
+
+	server := grpc.NewServer()
+	grpcapi.RegisterMetricsServer(server, srv)
+
+	lis, err := net.Listen("tcp", baseURL.String())
+	require.NoError(t, err)
+
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	defer server.Stop()
+
+	exporter := NewGRPCExporter(&baseURL, nil)
+
+	exporter.Add("stored", metrics.Counter(1))
+	require.NoError(t, exporter.Send())
+	require.Equal(t, "stored", (<-srv.batches).Data[0].Id)
+
+	// send succeeds, but server fails to store batch and terminates stream
+	exporter.Add("fail", metrics.Counter(2))
+	require.NoError(t, exporter.Send())
+	require.Equal(t, "fail", (<-srv.batches).Data[0].Id)
+
+	<-exporter.streamDone
+
+	// batches not acknowledged are resent along with new one
+	exporter.Add("next", metrics.Counter(3))
+	require.NoError(t, exporter.Send())
+
+	require.Equal(t, "fail", (<-srv.unary).Data[0].Id)
+	require.Equal(t, "next", (<-srv.unary).Data[0].Id)
+	require.Len(t, srv.unary, 0)
+
+	// resent batch carries its number in the stream, so server can skip it if already stored
+	require.Equal(t, grpcapi.BatchID(<-srv.streamIDs, 2), <-srv.unaryIDs)
+	require.Empty(t, <-srv.unaryIDs)
+
+	require.Nil(t, exporter.stream, "terminated stream should be dropped")
+	require.NoError(t, exporter.Close())
+}
+
+func TestPendingBatches(t *testing.T) {
+	batches := []*grpcapi.StreamUpdateRequest{{}, {}, {}}
+
+	p := newPendingBatches("stream")
+	for _, b := range batches {
+		p.add(b)
+	}
+
+	p.ack(1)
+	p.ack(1) // repeated ack changes nothing
+	p.ack(0)
+
+	require.Equal(t, []streamBatch{{id: "stream/2", req: batches[1]}, {id: "stream/3", req: batches[2]}}, p.take())
+	require.Empty(t, p.take())
+
+	// ack for more than sent is clamped
+	p.add(batches[0])
+	p.ack(10)
+	require.Empty(t, p.take())
+}
+
 func mockSigner(signature string) security.Signer {
 	signer := new(security.MockSigner)
 	signer.On("CalculateSignature", mock.Anything).Return(signature, nil)
@@ -196,6 +306,82 @@ func (s *TestMetricsServer) BatchUpdate(ctx context.Context, req *grpcapi.BatchU
 	return &grpcapi.BatchUpdateResponse{}, nil
 }
 
+type TestStreamMetricsServer struct {
+	grpcapi.UnimplementedMetricsServer
+
+	batches chan *grpcapi.StreamUpdateRequest
+	streams int
+}
+
+func (s *TestStreamMetricsServer) StreamUpdate(stream grpc.BidiStreamingServer[grpcapi.StreamUpdateRequest, grpcapi.StreamUpdateAck]) error {
+	s.streams++
+
+	if err := stream.SendHeader(metadata.Pairs(grpcapi.StreamReadyHeader, "1")); err != nil {
+		return err
+	}
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.Send(&grpcapi.StreamUpdateAck{Batches: 2})
+		}
+
+		if err != nil {
+			return err
+		}
+
+		s.batches <- req
+	}
+}
+
+// Stream server acknowledging stored batches and failing on batch of metric "fail".
+type TestFailingStreamMetricsServer struct {
+	grpcapi.UnimplementedMetricsServer
+
+	batches   chan *grpcapi.StreamUpdateRequest
+	unary     chan *grpcapi.BatchUpdateRequest
+	unaryIDs  chan string
+	streamIDs chan string
+}
+
+func (s *TestFailingStreamMetricsServer) StreamUpdate(stream grpc.BidiStreamingServer[grpcapi.StreamUpdateRequest, grpcapi.StreamUpdateAck]) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.streamIDs <- md.Get(grpcapi.StreamIDHeader)[0]
+
+	if err := stream.SendHeader(metadata.Pairs(grpcapi.StreamReadyHeader, "1")); err != nil {
+		return err
+	}
+
+	var stored uint64
+
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		s.batches <- req
+
+		if req.Data[0].Id == "fail" {
+			if err := stream.Send(&grpcapi.StreamUpdateAck{Batches: stored}); err != nil {
+				return err
+			}
+
+			return status.Error(codes.Internal, "failed to store batch")
+		}
+
+		stored++
+	}
+}
+
+func (s *TestFailingStreamMetricsServer) BatchUpdate(ctx context.Context, req *grpcapi.BatchUpdateRequest) (*grpcapi.BatchUpdateResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.unaryIDs <- strings.Join(md.Get(grpcapi.BatchIDHeader), ",")
+	s.unary <- req
+
+	return &grpcapi.BatchUpdateResponse{}, nil
+}
+
 func newGRPCTestServer(t *testing.T, bind string, wg *sync.WaitGroup) func() {
 	server := grpc.NewServer()
 	grpcapi.RegisterMetricsServer(server, &TestMetricsServer{wg: wg})
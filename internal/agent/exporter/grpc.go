@@ -3,7 +3,11 @@ package exporter
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/internal/logging"
@@ -12,6 +16,7 @@ import (
 	"github.com/ex0rcist/metflix/pkg/grpcapi"
 	"github.com/ex0rcist/metflix/pkg/metrics"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/metadata"
@@ -19,7 +24,11 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-// GRPCExporter sends collected metrics to metrics collector in single batch request.
+// Time to wait for final acknowledgement when closing the stream.
+const streamCloseTimeout = 5 * time.Second
+
+// GRPCExporter sends collected metrics to metrics collector over long-living stream,
+// falling back to single batch requests if server does not support streaming.
 type GRPCExporter struct {
 	baseURL   *entities.Address
 	publicKey security.PublicKey
@@ -27,6 +36,13 @@ type GRPCExporter struct {
 	conn   *grpc.ClientConn
 	buffer []*grpcapi.MetricExchange
 	err    error
+
+	stream       grpcapi.Metrics_StreamUpdateClient
+	streamCancel context.CancelFunc
+	streamDone   chan struct{}   // closed when stream is terminated
+	streamErr    error           // reason of termination, valid after streamDone is closed
+	pending      *pendingBatches // batches awaiting acknowledgement on current stream
+	noStream     bool            // server does not support streaming
 }
 
 // Construct new GRPCEXporter.
@@ -46,6 +62,8 @@ func (e *GRPCExporter) Add(name string, value metrics.Metric) Exporter {
 		req = grpcapi.NewUpdateCounterMex(name, v)
 	case metrics.Gauge:
 		req = grpcapi.NewUpdateGaugeMex(name, v)
+	case metrics.Histogram:
+		req = grpcapi.NewUpdateHistogramMex(name, v)
 	default:
 		e.err = entities.ErrMetricUnknown
 		return e
@@ -92,40 +110,258 @@ func (e *GRPCExporter) Send() error {
 
 	ctx := setupLoggerCtx(md.Get("x-request-id")[0])
 
-	ctx = metadata.NewOutgoingContext(ctx, md)
-	client := grpcapi.NewMetricsClient(e.conn)
-
+	batch := &grpcapi.StreamUpdateRequest{Data: e.buffer}
 	if e.publicKey != nil {
 		metricsBytes, err := proto.Marshal(&grpcapi.BatchUpdateRequest{Data: e.buffer})
 		if err != nil {
 			return err
 		}
 
-		payload, err := security.Encrypt(bytes.NewReader(metricsBytes), e.publicKey)
+		encrypted, err := security.Encrypt(bytes.NewReader(metricsBytes), e.publicKey)
 		if err != nil {
 			return err
 		}
 
-		logging.LogDebugCtx(ctx, fmt.Sprintf("sending gRPC %s to %s...", "BatchUpdateEncryptedRequest", e.baseURL.String()))
+		// plain data must not be sent along with encrypted one
+		batch = &grpcapi.StreamUpdateRequest{EncryptedData: encrypted.Bytes()}
+	}
 
-		req := &grpcapi.BatchUpdateEncryptedRequest{EncryptedData: payload.Bytes()}
-		_, e.err = client.BatchUpdateEncrypted(ctx, req)
+	batches := []streamBatch{{req: batch}}
 
-		logResponseFromErr(ctx, e.err)
-	} else {
-		logging.LogDebugCtx(ctx, fmt.Sprintf("sending gRPC %s to %s...", "BatchUpdateRequest", e.baseURL.String()))
+	if !e.noStream {
+		unacked, err := e.sendStream(ctx, md, batch)
+		if err == nil {
+			// batch is kept until acknowledged, to be resent if stream dies
+			e.Reset()
+			return nil
+		}
 
-		req := &grpcapi.BatchUpdateRequest{Data: e.buffer}
-		_, e.err = client.BatchUpdate(ctx, req)
+		if status.Code(err) == codes.Unimplemented {
+			logging.LogInfoCtx(ctx, "server does not support streaming, falling back to unary requests")
+			e.noStream = true
+		} else {
+			logging.LogErrorCtx(ctx, err, fmt.Sprintf("gRPC stream failed, sending %d unacknowledged batches in unary requests", len(unacked)))
+		}
 
-		logResponseFromErr(ctx, e.err)
+		batches = unacked
 	}
 
+	err = e.sendUnary(ctx, md, batches)
 	e.Reset()
 
+	return err
+}
+
+// Send batches in unary requests, one by one. Batches sent into stream before carry their ID,
+// so server skips the ones it has already stored.
+func (e *GRPCExporter) sendUnary(ctx context.Context, md metadata.MD, batches []streamBatch) error {
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	client := grpcapi.NewMetricsClient(e.conn)
+
+	var errs []error
+
+	for _, batch := range batches {
+		var err error
+
+		callCtx := ctx
+		if len(batch.id) > 0 {
+			callCtx = metadata.AppendToOutgoingContext(ctx, grpcapi.BatchIDHeader, batch.id)
+		}
+
+		if len(batch.req.EncryptedData) > 0 {
+			logging.LogDebugCtx(ctx, fmt.Sprintf("sending gRPC %s to %s...", "BatchUpdateEncryptedRequest", e.baseURL.String()))
+
+			_, err = client.BatchUpdateEncrypted(callCtx, &grpcapi.BatchUpdateEncryptedRequest{EncryptedData: batch.req.EncryptedData})
+		} else {
+			logging.LogDebugCtx(ctx, fmt.Sprintf("sending gRPC %s to %s...", "BatchUpdateRequest", e.baseURL.String()))
+
+			_, err = client.BatchUpdate(callCtx, &grpcapi.BatchUpdateRequest{Data: batch.req.Data})
+		}
+
+		logResponseFromErr(ctx, err)
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Push batch into the stream, opening it if needed. On failure stream is dropped, so next call reopens it,
+// and batches not acknowledged by server are returned, including given one.
+func (e *GRPCExporter) sendStream(ctx context.Context, md metadata.MD, req *grpcapi.StreamUpdateRequest) ([]streamBatch, error) {
+	if e.stream != nil {
+		select {
+		case <-e.streamDone:
+			err := e.streamErr
+			if err == nil {
+				err = status.Error(codes.Unavailable, "stream terminated")
+			}
+
+			return append(e.dropStream(), streamBatch{req: req}), err
+		default:
+		}
+	}
+
+	if e.stream == nil {
+		if err := e.openStream(md); err != nil {
+			return []streamBatch{{req: req}}, err
+		}
+	}
+
+	logging.LogDebugCtx(ctx, fmt.Sprintf("sending batch into gRPC stream to %s...", e.baseURL.String()))
+
+	e.pending.add(req)
+
+	if err := e.stream.Send(req); err != nil {
+		// real error is returned by Recv, wait for ack reader to get it
+		if errors.Is(err, io.EOF) {
+			<-e.streamDone
+
+			err = e.streamErr
+			if err == nil {
+				err = status.Error(codes.Unavailable, "stream closed by server")
+			}
+		}
+
+		return e.dropStream(), err
+	}
+
+	return nil, nil
+}
+
+func (e *GRPCExporter) openStream(md metadata.MD) error {
+	streamID := utils.GenerateRequestID()
+
+	md = metadata.Join(md, metadata.Pairs(grpcapi.StreamIDHeader, streamID))
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(context.Background(), md))
+
+	stream, err := grpcapi.NewMetricsClient(e.conn).StreamUpdate(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	header, err := stream.Header()
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	// stream was not accepted, e.g. method is not implemented: status is returned by Recv
+	if len(header.Get(grpcapi.StreamReadyHeader)) == 0 {
+		_, err = stream.Recv()
+		cancel()
+
+		if err == nil || errors.Is(err, io.EOF) {
+			err = status.Error(codes.Unimplemented, "stream was not accepted")
+		}
+
+		return err
+	}
+
+	e.stream = stream
+	e.streamCancel = cancel
+	e.streamDone = make(chan struct{})
+	e.streamErr = nil
+	e.pending = newPendingBatches(streamID)
+
+	go e.readAcks(stream, e.streamDone, e.pending)
+
 	return nil
 }
 
+// Release acknowledged batches until stream is terminated.
+func (e *GRPCExporter) readAcks(stream grpcapi.Metrics_StreamUpdateClient, done chan struct{}, pending *pendingBatches) {
+	defer close(done)
+
+	for {
+		ack, err := stream.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				e.streamErr = err
+			}
+
+			return
+		}
+
+		logging.LogDebug(fmt.Sprintf("gRPC stream ack: batches=%d; records=%d", ack.Batches, ack.Records))
+
+		pending.ack(ack.Batches)
+	}
+}
+
+// Terminate stream, returning batches it didn't acknowledge.
+func (e *GRPCExporter) dropStream() []streamBatch {
+	if e.stream == nil {
+		return nil
+	}
+
+	e.streamCancel()
+	<-e.streamDone // no acks are processed after that
+
+	unacked := e.pending.take()
+
+	e.stream = nil
+	e.streamCancel = nil
+	e.pending = nil
+
+	return unacked
+}
+
+// Batch to send, ID is set if it was sent into stream.
+type streamBatch struct {
+	id  string
+	req *grpcapi.StreamUpdateRequest
+}
+
+// Batches sent into the stream but not acknowledged by server yet.
+type pendingBatches struct {
+	sync.Mutex
+	streamID string
+	batches  []streamBatch
+	acked    uint64 // number of batches acknowledged on the stream
+}
+
+func newPendingBatches(streamID string) *pendingBatches {
+	return &pendingBatches{streamID: streamID}
+}
+
+func (p *pendingBatches) add(batch *grpcapi.StreamUpdateRequest) {
+	p.Lock()
+	defer p.Unlock()
+
+	// server numbers batches of the stream the same way
+	id := grpcapi.BatchID(p.streamID, p.acked+uint64(len(p.batches))+1)
+	p.batches = append(p.batches, streamBatch{id: id, req: batch})
+}
+
+// Release batches covered by ack, which carries number of batches stored since stream was opened.
+func (p *pendingBatches) ack(batches uint64) {
+	p.Lock()
+	defer p.Unlock()
+
+	if batches <= p.acked {
+		return
+	}
+
+	n := min(batches-p.acked, uint64(len(p.batches)))
+
+	p.batches = p.batches[n:]
+	p.acked += n
+}
+
+func (p *pendingBatches) take() []streamBatch {
+	p.Lock()
+	defer p.Unlock()
+
+	batches := p.batches
+	p.batches = nil
+
+	return batches
+}
+
 func logResponseFromErr(ctx context.Context, err error) {
 	st, _ := status.FromError(err)
 	logging.LogDebugCtx(ctx, fmt.Sprintf("got response status=%s", st.Code()))
@@ -141,11 +377,37 @@ func (e *GRPCExporter) Close() error {
 		return nil
 	}
 
+	if e.stream != nil {
+		if err := e.stream.CloseSend(); err == nil {
+			// let server store pending batches and send final ack
+			select {
+			case <-e.streamDone:
+			case <-time.After(streamCloseTimeout):
+			}
+		}
+
+		if unacked := e.dropStream(); len(unacked) > 0 {
+			md, err := e.prepareMetadata()
+			if err != nil {
+				logging.LogError(err)
+			}
+
+			ctx := setupLoggerCtx(md.Get("x-request-id")[0])
+			logging.LogInfoCtx(ctx, fmt.Sprintf("resending %d unacknowledged batches in unary requests", len(unacked)))
+
+			if err := e.sendUnary(ctx, md, unacked); err != nil {
+				logging.LogErrorCtx(ctx, err, "failed to resend unacknowledged batches")
+			}
+		}
+	}
+
 	return e.conn.Close()
 }
 
+// Build outgoing metadata. Request ID is always set, even when real IP lookup fails.
 func (e *GRPCExporter) prepareMetadata() (metadata.MD, error) {
 	md := metadata.New(map[string]string{})
+	md.Set("x-request-id", utils.GenerateRequestID())
 
 	clientIP, err := utils.GetOutboundIP()
 	if err != nil {
@@ -153,7 +415,6 @@ func (e *GRPCExporter) prepareMetadata() (metadata.MD, error) {
 	}
 
 	md.Set("x-real-ip", clientIP.String())
-	md.Set("x-request-id", utils.GenerateRequestID())
 
 	return md, nil
 }
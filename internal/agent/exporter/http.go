@@ -72,6 +72,8 @@ func (e *HTTPExporter) Add(name string, value metrics.Metric) Exporter {
 
 	case metrics.KindGauge:
 		mex = metrics.NewUpdateGaugeMex(name, value.(metrics.Gauge))
+	case metrics.KindHistogram:
+		mex = metrics.NewUpdateHistogramMex(name, value.(metrics.Histogram))
 
 	default:
 		logging.LogError(entities.ErrMetricReport, "unknown metric")
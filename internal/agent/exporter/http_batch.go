@@ -60,6 +60,8 @@ func (e *HTTPBatchExporter) Add(name string, value metrics.Metric) Exporter {
 
 	case metrics.KindGauge:
 		mex = metrics.NewUpdateGaugeMex(name, value.(metrics.Gauge))
+	case metrics.KindHistogram:
+		mex = metrics.NewUpdateHistogramMex(name, value.(metrics.Histogram))
 
 	default:
 		e.err = entities.ErrMetricUnknown
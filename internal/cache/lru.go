@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// Least recently used cache with expiration: entries not touched for ttl are dropped,
+// as well as least recently used ones over the limit. Not safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	limit int
+	ttl   time.Duration
+	items map[K]*list.Element
+	order *list.List // most recently used first
+
+	now func() time.Time
+}
+
+type lruEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	touched time.Time
+}
+
+// LRU constructor, zero limit or ttl disables corresponding eviction.
+func NewLRU[K comparable, V any](limit int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		limit: limit,
+		ttl:   ttl,
+		items: make(map[K]*list.Element),
+		order: list.New(),
+		now:   time.Now,
+	}
+}
+
+// Get value of the key and mark it as recently used.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.expire()
+
+	elem, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	c.touch(elem)
+
+	return elem.Value.(*lruEntry[K, V]).value, true
+}
+
+// Set value of the key and mark it as recently used.
+func (c *LRU[K, V]) Set(key K, value V) {
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*lruEntry[K, V]).value = value
+		c.touch(elem)
+	} else {
+		c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, touched: c.now()})
+	}
+
+	c.expire()
+}
+
+// Delete the key.
+func (c *LRU[K, V]) Delete(key K) {
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+}
+
+// Number of entries, expired ones included until next access.
+func (c *LRU[K, V]) Len() int {
+	return len(c.items)
+}
+
+func (c *LRU[K, V]) touch(elem *list.Element) {
+	elem.Value.(*lruEntry[K, V]).touched = c.now()
+	c.order.MoveToFront(elem)
+}
+
+// Drop entries from the least recently used end while they are over the limit or expired.
+func (c *LRU[K, V]) expire() {
+	now := c.now()
+
+	for elem := c.order.Back(); elem != nil; elem = c.order.Back() {
+		overLimit := c.limit > 0 && len(c.items) > c.limit
+		expired := c.ttl > 0 && now.Sub(elem.Value.(*lruEntry[K, V]).touched) >= c.ttl
+
+		if !overLimit && !expired {
+			return
+		}
+
+		c.remove(elem)
+	}
+}
+
+func (c *LRU[K, V]) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry[K, V]).key)
+}
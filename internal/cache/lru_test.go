@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU_Limit(t *testing.T) {
+	c := NewLRU[string, int](2, 0)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	_, ok := c.Get("a") // "b" is least recently used now
+	require.True(t, ok)
+
+	c.Set("c", 3)
+	require.Equal(t, 2, c.Len())
+
+	_, ok = c.Get("b")
+	require.False(t, ok)
+
+	value, ok := c.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 1, value)
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	require.False(t, ok)
+}
+
+func TestLRU_TTL(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	c := NewLRU[string, int](0, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	now = now.Add(40 * time.Second)
+	c.Set("b", 3) // touched, expires later than "a"
+
+	now = now.Add(30 * time.Second)
+	_, ok := c.Get("a")
+	require.False(t, ok)
+
+	value, ok := c.Get("b")
+	require.True(t, ok)
+	require.Equal(t, 3, value)
+
+	now = now.Add(time.Minute)
+	_, ok = c.Get("b")
+	require.False(t, ok)
+	require.Zero(t, c.Len())
+}
@@ -16,6 +16,7 @@ type Compressor struct {
 	context          context.Context
 	encoder          *gzip.Writer
 	supportedContent map[string]struct{}
+	wroteHeader      bool
 }
 
 // Constructor.
@@ -34,25 +35,52 @@ func NewCompressor(w http.ResponseWriter, ctx context.Context) *Compressor {
 
 // Write body to response.
 func (c *Compressor) Write(resp []byte) (int, error) {
-	contentType := c.Header().Get("Content-Type")
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
 
-	if _, ok := c.supportedContent[contentType]; !ok {
-		logging.LogDebugCtx(c.context, "compression not supported for "+contentType)
+	if c.encoder == nil {
 		return c.ResponseWriter.Write(resp)
 	}
 
-	if c.encoder == nil {
+	return c.encoder.Write(resp)
+}
+
+// Write status code, compression is decided here since headers can't be changed later.
+func (c *Compressor) WriteHeader(statusCode int) {
+	if c.wroteHeader {
+		return
+	}
+
+	c.wroteHeader = true
+
+	if c.canCompress(statusCode) {
 		encoder, err := gzip.NewWriterLevel(c.ResponseWriter, gzip.BestSpeed)
 		if err != nil {
 			logging.LogErrorCtx(c.context, err)
-			return c.ResponseWriter.Write(resp)
+		} else {
+			c.encoder = encoder
+
+			c.Header().Set("Content-Encoding", "gzip")
+			c.Header().Del("Content-Length")
 		}
-		c.encoder = encoder
 	}
 
-	c.Header().Set("Content-Encoding", "gzip")
+	c.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (c *Compressor) canCompress(statusCode int) bool {
+	if statusCode < http.StatusOK || statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		return false // response has no body
+	}
 
-	return c.encoder.Write(resp)
+	contentType := c.Header().Get("Content-Type")
+	if _, ok := c.supportedContent[contentType]; !ok {
+		logging.LogDebugCtx(c.context, "compression not supported for "+contentType)
+		return false
+	}
+
+	return true
 }
 
 // Close encoder.
@@ -3,6 +3,7 @@ package compression
 import (
 	"bytes"
 	"context"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
@@ -111,3 +112,63 @@ func TestCompressor_Close(t *testing.T) {
 		t.Fatalf("expected encoder to be nil after close")
 	}
 }
+
+func TestCompressor_WriteHeader(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	ctx := context.Background()
+	compressor := NewCompressor(recorder, ctx)
+	compressor.Header().Set("Content-Type", "application/json")
+
+	compressor.WriteHeader(http.StatusBadRequest)
+
+	_, err := compressor.Write([]byte(`{"error": "test"}`))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	compressor.Close()
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	if recorder.Result().Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected Content-Encoding to be sent with status")
+	}
+}
+
+func TestCompressor_WriteHeader_NoBody(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	ctx := context.Background()
+	compressor := NewCompressor(recorder, ctx)
+	compressor.Header().Set("Content-Type", "application/json")
+
+	compressor.WriteHeader(http.StatusNoContent)
+	compressor.Close()
+
+	if recorder.Result().Header.Get("Content-Encoding") != "" {
+		t.Fatalf("expected no Content-Encoding for response without body")
+	}
+
+	if recorder.Body.Len() != 0 {
+		t.Fatalf("expected empty body, got %d bytes", recorder.Body.Len())
+	}
+}
+
+func TestCompressor_WriteHeader_UnsupportedContent(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	ctx := context.Background()
+	compressor := NewCompressor(recorder, ctx)
+	compressor.Header().Set("Content-Type", "text/plain")
+
+	compressor.WriteHeader(http.StatusOK)
+	compressor.Close()
+
+	if recorder.Result().Header.Get("Content-Encoding") != "" {
+		t.Fatalf("expected no Content-Encoding for unsupported content")
+	}
+
+	if compressor.encoder != nil {
+		t.Fatalf("expected no encoder for unsupported content")
+	}
+}
@@ -1,27 +1,46 @@
 package compression
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/snappy"
 
 	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/internal/logging"
 )
 
+// Supported request encodings.
+const (
+	EncodingGzip   = "gzip"
+	EncodingSnappy = "snappy" // block format, used by Prometheus remote write
+)
+
+// Upper limit for decoded size of snappy-encoded request.
+const maxSnappyDecodedLen = 32 << 20
+
 // Struct to handle encoded requests.
 type Decompressor struct {
 	request            *http.Request
-	reader             *gzip.Reader
+	reader             io.ReadCloser
 	context            context.Context
 	supportedEncodings map[string]struct{}
 }
 
-// Constructor.
+// Constructor, accepts gzip-encoded requests.
 func NewDecompressor(req *http.Request, ctx context.Context) *Decompressor {
-	supportedEncodings := map[string]struct{}{
-		"gzip": {}, // {} uses no memory
+	return NewDecompressorWith(req, ctx, EncodingGzip)
+}
+
+// Constructor accepting given encodings only.
+func NewDecompressorWith(req *http.Request, ctx context.Context, encodings ...string) *Decompressor {
+	supportedEncodings := make(map[string]struct{}, len(encodings))
+	for _, encoding := range encodings {
+		supportedEncodings[encoding] = struct{}{} // {} uses no memory
 	}
 
 	return &Decompressor{
@@ -50,12 +69,11 @@ func (d *Decompressor) Decompress() error {
 	}
 
 	if d.reader == nil {
-		reader, err := gzip.NewReader(d.request.Body)
+		reader, err := d.newReader(encoding)
 		if err != nil {
-			err := entities.ErrEncodingInternal
-			logging.LogErrorCtx(d.context, err, "failed to create gzip reader: "+err.Error())
+			logging.LogErrorCtx(d.context, entities.ErrEncodingInternal, "failed to create "+encoding+" reader: "+err.Error())
 
-			return err
+			return entities.ErrEncodingInternal
 		}
 
 		d.reader = reader
@@ -66,6 +84,40 @@ func (d *Decompressor) Decompress() error {
 	return nil
 }
 
+func (d *Decompressor) newReader(encoding string) (io.ReadCloser, error) {
+	if encoding == EncodingGzip {
+		return gzip.NewReader(d.request.Body)
+	}
+
+	// snappy block format can't be streamed, decode whole body at once
+	maxEncodedLen := snappy.MaxEncodedLen(maxSnappyDecodedLen)
+
+	compressed, err := io.ReadAll(io.LimitReader(d.request.Body, int64(maxEncodedLen)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(compressed) > maxEncodedLen {
+		return nil, fmt.Errorf("encoded body is too large: more than %d bytes", maxEncodedLen)
+	}
+
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	if size > maxSnappyDecodedLen {
+		return nil, fmt.Errorf("decoded body is too large: %d bytes", size)
+	}
+
+	decoded, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(decoded)), nil
+}
+
 // Close reader.
 func (d *Decompressor) Close() {
 	if d.reader == nil {
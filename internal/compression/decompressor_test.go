@@ -7,6 +7,7 @@ import (
 	"io"
 
 	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/snappy"
 
 	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/internal/logging"
@@ -137,3 +138,90 @@ func TestDecompressor_Decompress_ErrorOnInit(t *testing.T) {
 		t.Fatalf("expected %v, got %v", entities.ErrEncodingInternal, err)
 	}
 }
+
+func TestDecompressor_Decompress_Snappy(t *testing.T) {
+	data := []byte("test data")
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(snappy.Encode(nil, data)))
+	req.Header.Set("Content-Encoding", "snappy")
+
+	ctx := context.Background()
+	decompressor := NewDecompressorWith(req, ctx, EncodingGzip, EncodingSnappy)
+
+	err := decompressor.Decompress()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	decompressedData, err := io.ReadAll(decompressor.request.Body)
+	if err != nil {
+		t.Fatalf("expected no error reading decompressed data, got %v", err)
+	}
+
+	if !bytes.Equal(data, decompressedData) {
+		t.Fatalf("expected %s, got %s", data, decompressedData)
+	}
+
+	decompressor.Close()
+}
+
+func TestDecompressor_Decompress_InvalidSnappy(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("invalid snappy data")))
+	req.Header.Set("Content-Encoding", "snappy")
+
+	ctx := context.Background()
+	decompressor := NewDecompressorWith(req, ctx, EncodingGzip, EncodingSnappy)
+
+	err := decompressor.Decompress()
+	if !errors.Is(err, entities.ErrEncodingInternal) {
+		t.Fatalf("expected %v, got %v", entities.ErrEncodingInternal, err)
+	}
+}
+
+func TestDecompressor_Decompress_SnappyNotAccepted(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(snappy.Encode(nil, []byte("test data"))))
+	req.Header.Set("Content-Encoding", "snappy")
+
+	decompressor := NewDecompressor(req, context.Background())
+
+	err := decompressor.Decompress()
+	if !errors.Is(err, entities.ErrEncodingUnsupported) {
+		t.Fatalf("expected %v, got %v", entities.ErrEncodingUnsupported, err)
+	}
+}
+
+func TestDecompressor_Decompress_SnappyTooLarge(t *testing.T) {
+	body := &countingReader{limit: snappy.MaxEncodedLen(maxSnappyDecodedLen) + 100}
+
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Encoding", "snappy")
+
+	decompressor := NewDecompressorWith(req, context.Background(), EncodingSnappy)
+
+	err := decompressor.Decompress()
+	if !errors.Is(err, entities.ErrEncodingInternal) {
+		t.Fatalf("expected %v, got %v", entities.ErrEncodingInternal, err)
+	}
+
+	// body is not read past the limit
+	if body.read > snappy.MaxEncodedLen(maxSnappyDecodedLen)+1 {
+		t.Fatalf("expected body to be read up to the limit, got %d bytes read", body.read)
+	}
+}
+
+// Reader producing limit zero bytes, counting bytes read.
+type countingReader struct {
+	limit int
+	read  int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	if r.read >= r.limit {
+		return 0, io.EOF
+	}
+
+	n := min(len(p), r.limit-r.read)
+	clear(p[:n])
+	r.read += n
+
+	return n, nil
+}
@@ -21,11 +21,32 @@ var (
 	ErrMetricMissingValue    = errors.New("metric value is missing")
 	ErrMetricInvalidValue    = errors.New("metric value is invalid")
 	ErrMetricBatchIncomplete = errors.New("metrics batch has no records")
+	ErrMetricInvalidLabel    = errors.New("metric label is invalid")
+	ErrMetricBatchMalformed  = errors.New("metrics batch is malformed")
+
+	ErrHistogramBucketsMismatch = errors.New("histogram buckets mismatch")
+
+	/* Range queries */
+	ErrRangeInvalid            = errors.New("range query is invalid")
+	ErrRangeUnknownAggregation = errors.New("unknown range aggregation")
+	ErrRangeUnsupportedKind    = errors.New("metric type can't be aggregated")
 
 	/* Storage */
-	ErrStoragePush       = errors.New("failed to push record")
-	ErrStorageFetch      = errors.New("failed to get record")
-	ErrStorageUnpingable = errors.New("healthcheck is not supported")
+	ErrStoragePush        = errors.New("failed to push record")
+	ErrStorageFetch       = errors.New("failed to get record")
+	ErrStorageUnpingable  = errors.New("healthcheck is not supported")
+	ErrStorageClosed      = errors.New("storage is closed")
+	ErrMigrationsDirty    = errors.New("database migrations are dirty")
+	ErrMigrationsMissing  = errors.New("database migrations are not applied")
+	ErrMigrationsPending  = errors.New("database migrations are pending")
+	ErrBadRetentionPolicy = errors.New("bad retention policy")
+	ErrBadWALSyncPolicy   = errors.New("bad WAL fsync policy")
+	ErrSnapshotCorrupted  = errors.New("storage snapshot is corrupted")
+	ErrBadSnapshotFormat  = errors.New("bad snapshot format")
+	ErrBadMigrateCommand  = errors.New("bad migrate command")
+
+	/* Ingestion */
+	ErrBadGraphiteTemplate = errors.New("bad graphite template")
 
 	/* Encoding */
 	ErrEncodingInternal    = errors.New("internal encoding error")
@@ -0,0 +1,96 @@
+// Package graphite implements parsing of Graphite plaintext protocol.
+package graphite
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/internal/validators"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z\d_:\-]`)
+
+// Converts Graphite lines into gauge records.
+type Parser struct {
+	templates []Template
+}
+
+// Constructor. First template with matching filter is applied, paths without
+// matching template are converted into names by joining nodes with underscore.
+func NewParser(templates []Template) *Parser {
+	return &Parser{templates: templates}
+}
+
+// Parse line in format "path value [timestamp]". Missing or negative timestamp means now.
+func (p *Parser) ParseLine(line string) (storage.Record, error) {
+	var record storage.Record
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return record, fmt.Errorf("%w: expected path, value and timestamp", entities.ErrMetricBatchMalformed)
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return record, fmt.Errorf("%w: %q", entities.ErrMetricInvalidValue, fields[1])
+	}
+
+	timestamp := time.Now().UTC()
+	if len(fields) == 3 {
+		ts, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return record, fmt.Errorf("%w: bad timestamp %q", entities.ErrMetricBatchMalformed, fields[2])
+		}
+
+		if ts >= 0 {
+			timestamp = time.Unix(0, int64(ts*float64(time.Second))).UTC()
+		}
+	}
+
+	name, labels := p.mapPath(fields[0])
+
+	if err := validators.ValidateMetric(name, metrics.KindGauge); err != nil {
+		return record, fmt.Errorf("%w: %s", err, name)
+	}
+
+	if err := validators.ValidateLabels(labels); err != nil {
+		return record, err
+	}
+
+	record = storage.Record{Name: name, Labels: labels, Value: metrics.Gauge(value), Timestamp: timestamp}
+
+	return record, nil
+}
+
+func (p *Parser) mapPath(path string) (string, metrics.Labels) {
+	nodes := strings.Split(strings.Trim(path, "."), ".")
+	measurement := nodes
+
+	var labels metrics.Labels
+
+	for _, t := range p.templates {
+		if !t.Match(nodes) {
+			continue
+		}
+
+		measurement, labels = t.Apply(nodes)
+		if len(labels) == 0 {
+			labels = nil
+		}
+
+		break
+	}
+
+	for i, node := range measurement {
+		measurement[i] = invalidNameChars.ReplaceAllString(node, "_")
+	}
+
+	return strings.Join(measurement, "_"), labels
+}
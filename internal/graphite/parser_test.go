@@ -0,0 +1,87 @@
+package graphite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+func TestParser_ParseLine(t *testing.T) {
+	templates, err := ParseTemplates("servers.* .host.measurement*;dc.*.*.mem .region.host.measurement.measurement")
+	require.NoError(t, err)
+
+	parser := NewParser(templates)
+	ts := time.Unix(1700000000, 0).UTC()
+
+	tests := []struct {
+		name string
+		line string
+		want storage.Record
+	}{
+		{
+			name: "no template",
+			line: "app.requests.rate 12.5 1700000000",
+			want: storage.Record{Name: "app_requests_rate", Value: metrics.Gauge(12.5), Timestamp: ts},
+		},
+		{
+			name: "template with measurement*",
+			line: "servers.web01.cpu.load 0.75 1700000000",
+			want: storage.Record{Name: "cpu_load", Value: metrics.Gauge(0.75), Labels: metrics.Labels{"host": "web01"}, Timestamp: ts},
+		},
+		{
+			name: "first matching template wins",
+			line: "dc.eu.db1.mem.free 1024 1700000000",
+			want: storage.Record{Name: "mem_free", Value: metrics.Gauge(1024), Labels: metrics.Labels{"region": "eu", "host": "db1"}, Timestamp: ts},
+		},
+		{
+			name: "invalid chars are replaced",
+			line: "disk.sda1@home.used 3 1700000000",
+			want: storage.Record{Name: "disk_sda1_home_used", Value: metrics.Gauge(3), Timestamp: ts},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parser.ParseLine(tt.line)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParser_ParseLineNow(t *testing.T) {
+	parser := NewParser(nil)
+
+	for _, line := range []string{"load 1", "load 1 -1"} {
+		got, err := parser.ParseLine(line)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), got.Timestamp, time.Second)
+	}
+}
+
+func TestParser_ParseLineErrors(t *testing.T) {
+	parser := NewParser(nil)
+
+	tests := []struct {
+		line    string
+		wantErr error
+	}{
+		{line: "load", wantErr: entities.ErrMetricBatchMalformed},
+		{line: "load 1 2 3", wantErr: entities.ErrMetricBatchMalformed},
+		{line: "load abc 1700000000", wantErr: entities.ErrMetricInvalidValue},
+		{line: "load NaN 1700000000", wantErr: entities.ErrMetricInvalidValue},
+		{line: "load 1 yesterday", wantErr: entities.ErrMetricBatchMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			_, err := parser.ParseLine(tt.line)
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
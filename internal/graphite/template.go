@@ -0,0 +1,121 @@
+package graphite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+const (
+	partMeasurement    = "measurement"
+	partMeasurementAll = "measurement*"
+)
+
+// Rule to map dotted path onto metric name and labels, e.g. filter "servers.*" with
+// template ".host.measurement*" turns "servers.web01.cpu.load" into cpu_load{host="web01"}.
+//
+// Template parts are matched against path nodes by position: "measurement" nodes form the name,
+// "measurement*" consumes all remaining nodes, any other word is a label name and empty part skips node.
+type Template struct {
+	filter []string // nodes of filter pattern, "*" matches any single node
+	parts  []string
+}
+
+// Parse templates in format "[filter ]template" separated by ';'.
+func ParseTemplates(spec string) ([]Template, error) {
+	templates := make([]Template, 0)
+
+	for _, raw := range strings.Split(spec, ";") {
+		raw = strings.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+
+		t, err := parseTemplate(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		templates = append(templates, t)
+	}
+
+	return templates, nil
+}
+
+func parseTemplate(raw string) (Template, error) {
+	var t Template
+
+	fields := strings.Fields(raw)
+
+	switch len(fields) {
+	case 1:
+		t.parts = strings.Split(fields[0], ".")
+	case 2:
+		t.filter = strings.Split(fields[0], ".")
+		t.parts = strings.Split(fields[1], ".")
+	default:
+		return t, fmt.Errorf("%w: %q", entities.ErrBadGraphiteTemplate, raw)
+	}
+
+	hasMeasurement := false
+	for i, part := range t.parts {
+		switch part {
+		case partMeasurement:
+			hasMeasurement = true
+		case partMeasurementAll:
+			if i != len(t.parts)-1 {
+				return t, fmt.Errorf("%w: %s must be the last part in %q", entities.ErrBadGraphiteTemplate, partMeasurementAll, raw)
+			}
+
+			hasMeasurement = true
+		}
+	}
+
+	if !hasMeasurement {
+		return t, fmt.Errorf("%w: no measurement in %q", entities.ErrBadGraphiteTemplate, raw)
+	}
+
+	return t, nil
+}
+
+// Check whether path nodes match template filter. Template without filter matches everything.
+func (t Template) Match(nodes []string) bool {
+	if len(t.filter) > len(nodes) {
+		return false
+	}
+
+	for i, f := range t.filter {
+		if f != "*" && f != nodes[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Split path nodes into measurement nodes and labels.
+func (t Template) Apply(nodes []string) ([]string, metrics.Labels) {
+	measurement := make([]string, 0, len(nodes))
+	labels := make(metrics.Labels)
+
+	for i, node := range nodes {
+		if i >= len(t.parts) {
+			break
+		}
+
+		switch part := t.parts[i]; part {
+		case "":
+		case partMeasurement:
+			measurement = append(measurement, node)
+		case partMeasurementAll:
+			measurement = append(measurement, nodes[i:]...)
+			return measurement, labels
+		default:
+			labels[part] = node
+		}
+	}
+
+	return measurement, labels
+}
@@ -0,0 +1,46 @@
+package graphite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+)
+
+func TestParseTemplates(t *testing.T) {
+	templates, err := ParseTemplates("servers.* .host.measurement*; region.measurement ;")
+	require.NoError(t, err)
+	require.Equal(t, []Template{
+		{filter: []string{"servers", "*"}, parts: []string{"", "host", "measurement*"}},
+		{parts: []string{"region", "measurement"}},
+	}, templates)
+
+	templates, err = ParseTemplates("")
+	require.NoError(t, err)
+	require.Empty(t, templates)
+}
+
+func TestParseTemplates_Errors(t *testing.T) {
+	specs := []string{
+		"host.region",
+		"measurement*.host",
+		"a.* b.measurement extra",
+	}
+
+	for _, spec := range specs {
+		t.Run(spec, func(t *testing.T) {
+			_, err := ParseTemplates(spec)
+			require.ErrorIs(t, err, entities.ErrBadGraphiteTemplate)
+		})
+	}
+}
+
+func TestTemplate_Match(t *testing.T) {
+	tpl := Template{filter: []string{"servers", "*", "cpu"}}
+
+	require.True(t, tpl.Match([]string{"servers", "web01", "cpu", "load"}))
+	require.False(t, tpl.Match([]string{"servers", "web01", "mem", "free"}))
+	require.False(t, tpl.Match([]string{"servers", "web01"}))
+	require.True(t, Template{}.Match([]string{"anything"}))
+}
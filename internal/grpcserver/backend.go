@@ -4,10 +4,12 @@ import (
 	"net"
 
 	"github.com/ex0rcist/metflix/internal/grpcserver/interceptors"
+	"github.com/ex0rcist/metflix/internal/otlp"
 	"github.com/ex0rcist/metflix/internal/security"
 	"github.com/ex0rcist/metflix/internal/services"
 	"google.golang.org/grpc"
 	_ "google.golang.org/grpc/encoding/gzip"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type Backend struct {
@@ -16,8 +18,10 @@ type Backend struct {
 
 	server *grpc.Server
 
-	healthService services.HealthChecker
-	metricService services.MetricProvider
+	healthService  services.HealthChecker
+	healthReporter *HealthReporter
+	metricService  services.MetricProvider
+	otlpTranslator *otlp.Translator
 }
 
 // Backend constructor
@@ -38,6 +42,7 @@ func (b *Backend) setup() {
 
 	grpcOpts := []grpc.ServerOption{
 		grpc.ChainUnaryInterceptor(icep...),
+		grpc.ChainStreamInterceptor(b.prepareStreamInterceptors()...),
 	}
 
 	grpcServer := grpc.NewServer(grpcOpts...)
@@ -45,7 +50,19 @@ func (b *Backend) setup() {
 	b.server = grpcServer
 
 	RegisterhHealthServer(grpcServer, b.healthService)
+	if b.healthReporter != nil {
+		healthpb.RegisterHealthServer(grpcServer, b.healthReporter)
+	}
+
 	RegisterMetricsServer(grpcServer, b.metricService, b.privateKey)
+	if b.healthReporter != nil && b.metricService != nil {
+		b.healthReporter.registerMetrics()
+	}
+	if b.otlpTranslator == nil {
+		b.otlpTranslator = otlp.NewTranslator(b.metricService)
+	}
+
+	RegisterOTLPMetricsServer(grpcServer, b.otlpTranslator)
 }
 
 func (b *Backend) prepareInterceptors() []grpc.UnaryServerInterceptor {
@@ -56,6 +73,14 @@ func (b *Backend) prepareInterceptors() []grpc.UnaryServerInterceptor {
 	return iceps
 }
 
+func (b *Backend) prepareStreamInterceptors() []grpc.StreamServerInterceptor {
+	iceps := make([]grpc.StreamServerInterceptor, 0, 2)
+	iceps = append(iceps, interceptors.StreamRequestsInterceptor)
+	iceps = append(iceps, interceptors.StreamRequestsFilter(b.trustedSubnet))
+
+	return iceps
+}
+
 /* Options */
 
 type Option func(*Backend)
@@ -78,8 +103,22 @@ func WithHealthService(healthService services.HealthChecker) Option {
 	}
 }
 
+// Serve grpc.health.v1.Health using given reporter, its lifecycle is managed by caller.
+func WithHealthReporter(healthReporter *HealthReporter) Option {
+	return func(b *Backend) {
+		b.healthReporter = healthReporter
+	}
+}
+
 func WithMetricService(metricService services.MetricProvider) Option {
 	return func(b *Backend) {
 		b.metricService = metricService
 	}
 }
+
+// Serve OTLP using given translator, so its cumulative state is shared with OTLP/HTTP.
+func WithOTLPTranslator(translator *otlp.Translator) Option {
+	return func(b *Backend) {
+		b.otlpTranslator = translator
+	}
+}
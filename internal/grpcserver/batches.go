@@ -0,0 +1,126 @@
+package grpcserver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ex0rcist/metflix/pkg/grpcapi"
+	"google.golang.org/grpc/metadata"
+)
+
+// How long IDs of applied batches are remembered and how many of them at most.
+const (
+	appliedBatchesTTL   = 10 * time.Minute
+	appliedBatchesLimit = 100000
+)
+
+// IDs of recently applied batches. Client resends batches which acknowledgement it didn't get,
+// so batch already stored from the stream must be skipped, otherwise counters are incremented twice.
+type appliedBatches struct {
+	sync.Mutex
+	entries map[string]*appliedBatch
+	order   []*appliedBatch // by time of application, oldest first
+}
+
+type appliedBatch struct {
+	id   string
+	at   time.Time
+	done chan struct{} // closed when application is finished
+	err  error
+}
+
+func newAppliedBatches() *appliedBatches {
+	return &appliedBatches{entries: make(map[string]*appliedBatch)}
+}
+
+// Apply batch with given ID unless it was applied already, returns true in that case.
+// Concurrent calls with the same ID wait for the first one. Batch without ID is always applied.
+func (b *appliedBatches) apply(ctx context.Context, id string, apply func() error) (bool, error) {
+	if len(id) == 0 {
+		return false, apply()
+	}
+
+	for {
+		b.Lock()
+		b.expire(time.Now())
+
+		if entry, ok := b.entries[id]; ok {
+			b.Unlock()
+
+			select {
+			case <-entry.done:
+			case <-ctx.Done():
+				return false, ctx.Err()
+			}
+
+			if entry.err == nil {
+				return true, nil
+			}
+
+			continue // failed, try to apply again
+		}
+
+		entry := &appliedBatch{id: id, at: time.Now(), done: make(chan struct{})}
+		b.entries[id] = entry
+		b.order = append(b.order, entry)
+		b.Unlock()
+
+		err := apply()
+
+		b.Lock()
+		entry.err = err
+		if err != nil {
+			delete(b.entries, id)
+		}
+		close(entry.done)
+		b.Unlock()
+
+		return false, err
+	}
+}
+
+// Forget expired batches and oldest ones over the limit. Must be called with b locked.
+func (b *appliedBatches) expire(now time.Time) {
+	n := 0
+
+	for ; n < len(b.order); n++ {
+		entry := b.order[n]
+
+		if len(b.order)-n <= appliedBatchesLimit && now.Sub(entry.at) < appliedBatchesTTL {
+			break
+		}
+
+		select {
+		case <-entry.done:
+		default:
+			// still being applied, keep it and everything after
+			b.order = b.order[n:]
+			return
+		}
+
+		if b.entries[entry.id] == entry {
+			delete(b.entries, entry.id)
+		}
+	}
+
+	b.order = b.order[n:]
+}
+
+// ID of batch sent in unary request, empty if batch was not sent into stream before.
+func incomingBatchID(ctx context.Context) string {
+	return incomingHeader(ctx, grpcapi.BatchIDHeader)
+}
+
+func incomingHeader(ctx context.Context, name string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(name); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
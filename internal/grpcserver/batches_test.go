@@ -0,0 +1,100 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppliedBatches(t *testing.T) {
+	ctx := context.Background()
+	b := newAppliedBatches()
+
+	var applied int
+	apply := func() error {
+		applied++
+		return nil
+	}
+
+	duplicate, err := b.apply(ctx, "a/1", apply)
+	require.NoError(t, err)
+	require.False(t, duplicate)
+
+	duplicate, err = b.apply(ctx, "a/1", apply)
+	require.NoError(t, err)
+	require.True(t, duplicate)
+
+	// batches without ID are always applied
+	for i := 0; i < 2; i++ {
+		duplicate, err = b.apply(ctx, "", apply)
+		require.NoError(t, err)
+		require.False(t, duplicate)
+	}
+
+	require.Equal(t, 3, applied)
+}
+
+func TestAppliedBatchesFailure(t *testing.T) {
+	ctx := context.Background()
+	b := newAppliedBatches()
+
+	_, err := b.apply(ctx, "a/1", func() error { return entities.ErrStoragePush })
+	require.ErrorIs(t, err, entities.ErrStoragePush)
+
+	// failed batch is applied again
+	duplicate, err := b.apply(ctx, "a/1", func() error { return nil })
+	require.NoError(t, err)
+	require.False(t, duplicate)
+}
+
+func TestAppliedBatchesConcurrent(t *testing.T) {
+	ctx := context.Background()
+	b := newAppliedBatches()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		_, _ = b.apply(ctx, "a/1", func() error {
+			close(entered)
+			<-release
+			return nil
+		})
+	}()
+
+	<-entered
+
+	result := make(chan bool)
+	go func() {
+		duplicate, _ := b.apply(ctx, "a/1", func() error { return nil })
+		result <- duplicate
+	}()
+
+	// second call waits for the first one
+	select {
+	case <-result:
+		t.Fatal("expected concurrent apply to wait")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	require.True(t, <-result)
+}
+
+func TestAppliedBatchesExpire(t *testing.T) {
+	ctx := context.Background()
+	b := newAppliedBatches()
+
+	_, err := b.apply(ctx, "a/1", func() error { return nil })
+	require.NoError(t, err)
+
+	b.Lock()
+	b.expire(time.Now().Add(appliedBatchesTTL))
+	b.Unlock()
+
+	require.Empty(t, b.entries)
+	require.Empty(t, b.order)
+}
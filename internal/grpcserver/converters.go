@@ -17,22 +17,42 @@ func toRecord(req *grpcapi.MetricExchange) (storage.Record, error) {
 		return record, err
 	}
 
+	if err := validators.ValidateLabels(req.Labels); err != nil {
+		return record, err
+	}
+
 	switch req.Mtype {
 	case metrics.KindCounter:
 		record = storage.Record{Name: req.Id, Value: metrics.Counter(req.Delta)}
 	case metrics.KindGauge:
 		record = storage.Record{Name: req.Id, Value: metrics.Gauge(req.Value)}
+	case metrics.KindHistogram:
+		if req.Histogram == nil {
+			return record, entities.ErrMetricMissingValue
+		}
+
+		histogram := req.Histogram.ToMetric()
+		if err := histogram.Validate(); err != nil {
+			return record, err
+		}
+
+		record = storage.Record{Name: req.Id, Value: histogram}
 	default:
 		return record, entities.ErrMetricUnknown
 	}
 
+	if len(req.Labels) > 0 {
+		record.Labels = req.Labels
+	}
+
 	return record, nil
 }
 
 func toMetricExchange(record storage.Record) (*grpcapi.MetricExchange, error) {
 	req := &grpcapi.MetricExchange{
-		Id:    record.Name,
-		Mtype: record.Value.Kind(),
+		Id:     record.Name,
+		Mtype:  record.Value.Kind(),
+		Labels: record.Labels,
 	}
 
 	switch record.Value.Kind() {
@@ -43,6 +63,10 @@ func toMetricExchange(record storage.Record) (*grpcapi.MetricExchange, error) {
 	case metrics.KindGauge:
 		value, _ := record.Value.(metrics.Gauge)
 		req.Value = float64(value)
+
+	case metrics.KindHistogram:
+		histogram, _ := record.Value.(metrics.Histogram)
+		req.Histogram = grpcapi.NewHistogram(histogram)
 	}
 
 	return req, nil
@@ -0,0 +1,123 @@
+package grpcserver
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/internal/services"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Components reported via grpc.health.v1.Health, empty name stands for overall server status.
+const (
+	HealthComponentServer  = ""
+	HealthComponentStorage = "storage"
+	HealthComponentMetrics = "metflix.v1.Metrics" // Metrics API
+)
+
+const defaultHealthCheckInterval = 10 * time.Second
+
+// HealthReporter serves standard gRPC health protocol, statuses are refreshed periodically:
+// overall server status follows readiness (same as /readyz), storage follows storage checks
+// and Metrics API is serving while it's registered and storage is available.
+type HealthReporter struct {
+	*health.Server
+
+	healthService services.HealthChecker
+	interval      time.Duration
+
+	metricsRegistered atomic.Bool
+
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// HealthReporter constructor, every component is NOT_SERVING until Start().
+func NewHealthReporter(healthService services.HealthChecker, interval time.Duration) *HealthReporter {
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+
+	r := &HealthReporter{
+		Server:        health.NewServer(),
+		healthService: healthService,
+		interval:      interval,
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+
+	for _, component := range []string{HealthComponentServer, HealthComponentStorage, HealthComponentMetrics} {
+		r.SetServingStatus(component, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+
+	return r
+}
+
+// Start periodic checks in a goroutine.
+func (r *HealthReporter) Start() {
+	r.check()
+
+	go func() {
+		defer close(r.stopped)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.check()
+			}
+		}
+	}()
+}
+
+// Stop checks and report every component as NOT_SERVING, so clients stop routing requests here.
+func (r *HealthReporter) Shutdown() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		r.Server.Shutdown()
+	})
+}
+
+// Mark Metrics API as registered, so it's reported as serving along with storage.
+func (r *HealthReporter) registerMetrics() {
+	r.metricsRegistered.Store(true)
+}
+
+func (r *HealthReporter) check() {
+	ctx := context.Background()
+
+	r.SetServingStatus(HealthComponentServer, r.reportStatus(r.healthService.Readiness(ctx), "readiness"))
+
+	storageStatus := r.reportStatus(r.healthService.Storage(ctx), "storage")
+	r.SetServingStatus(HealthComponentStorage, storageStatus)
+
+	metricsStatus := healthpb.HealthCheckResponse_NOT_SERVING
+	if r.metricsRegistered.Load() {
+		metricsStatus = storageStatus // Metrics API is useless without storage
+	}
+
+	r.SetServingStatus(HealthComponentMetrics, metricsStatus)
+}
+
+func (r *HealthReporter) reportStatus(report services.HealthReport, kind string) healthpb.HealthCheckResponse_ServingStatus {
+	if report.Passed() {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+
+	for _, check := range report.Checks {
+		if check.Status != services.HealthPass {
+			logging.LogWarnF("%s check %s failed: %s", kind, check.Name, check.LastError)
+		}
+	}
+
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
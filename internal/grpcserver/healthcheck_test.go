@@ -0,0 +1,149 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestHealthCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		readiness services.HealthReport
+		storage   services.HealthReport
+		metrics   bool
+		expected  map[string]healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{
+			name:      "everything ready",
+			readiness: readyReport(),
+			storage:   readyReport(),
+			metrics:   true,
+			expected: map[string]healthpb.HealthCheckResponse_ServingStatus{
+				HealthComponentServer:  healthpb.HealthCheckResponse_SERVING,
+				HealthComponentStorage: healthpb.HealthCheckResponse_SERVING,
+				HealthComponentMetrics: healthpb.HealthCheckResponse_SERVING,
+			},
+		},
+		{
+			name:      "storage check failing",
+			readiness: readyReport(),
+			storage:   notReadyReport(),
+			metrics:   true,
+			expected: map[string]healthpb.HealthCheckResponse_ServingStatus{
+				HealthComponentServer:  healthpb.HealthCheckResponse_SERVING,
+				HealthComponentStorage: healthpb.HealthCheckResponse_NOT_SERVING,
+				HealthComponentMetrics: healthpb.HealthCheckResponse_NOT_SERVING,
+			},
+		},
+		{
+			name:      "not ready",
+			readiness: notReadyReport(),
+			storage:   readyReport(),
+			metrics:   true,
+			expected: map[string]healthpb.HealthCheckResponse_ServingStatus{
+				HealthComponentServer:  healthpb.HealthCheckResponse_NOT_SERVING,
+				HealthComponentStorage: healthpb.HealthCheckResponse_SERVING,
+				HealthComponentMetrics: healthpb.HealthCheckResponse_SERVING,
+			},
+		},
+		{
+			name:      "metrics service not registered",
+			readiness: readyReport(),
+			storage:   readyReport(),
+			expected: map[string]healthpb.HealthCheckResponse_ServingStatus{
+				HealthComponentServer:  healthpb.HealthCheckResponse_SERVING,
+				HealthComponentStorage: healthpb.HealthCheckResponse_SERVING,
+				HealthComponentMetrics: healthpb.HealthCheckResponse_NOT_SERVING,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := new(services.HealthCheckServiceMock)
+			m.On("Readiness", mock.Anything).Return(tt.readiness)
+			m.On("Storage", mock.Anything).Return(tt.storage)
+
+			reporter := NewHealthReporter(m, time.Hour)
+
+			opts := []Option{WithHealthReporter(reporter)}
+			if tt.metrics {
+				opts = append(opts, WithMetricService(new(services.MetricServiceMock)))
+			}
+
+			conn, closer := createTestServerWithOptions(t, opts...)
+			defer closer()
+
+			reporter.Start()
+			defer reporter.Shutdown()
+
+			client := healthpb.NewHealthClient(conn)
+
+			for component, expected := range tt.expected {
+				resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: component})
+				require.NoError(t, err)
+				require.Equal(t, expected, resp.Status, component)
+			}
+
+			_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown"})
+			require.Equal(t, codes.NotFound, status.Code(err))
+		})
+	}
+}
+
+func TestHealthWatch(t *testing.T) {
+	m := new(services.HealthCheckServiceMock)
+	m.On("Readiness", mock.Anything).Return(readyReport())
+	m.On("Storage", mock.Anything).Return(notReadyReport()).Once()
+	m.On("Storage", mock.Anything).Return(readyReport())
+
+	reporter := NewHealthReporter(m, 10*time.Millisecond)
+	reporter.Start()
+
+	conn, closer := createTestServerWithOptions(t, WithHealthReporter(reporter))
+	defer closer()
+
+	stream, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{Service: HealthComponentStorage})
+	require.NoError(t, err)
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	// storage recovers on the next check
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	// everything goes down on shutdown
+	reporter.Shutdown()
+
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+}
+
+func readyReport() services.HealthReport {
+	return services.HealthReport{
+		Status: services.HealthPass,
+		Checks: []services.CheckResult{{Name: "storage", Status: services.HealthPass}},
+	}
+}
+
+func notReadyReport() services.HealthReport {
+	return services.HealthReport{
+		Status: services.HealthFail,
+		Checks: []services.CheckResult{
+			{Name: "storage", Status: services.HealthPass},
+			{Name: "migrations", Status: services.HealthFail, LastError: "migrations pending"},
+		},
+	}
+}
@@ -20,7 +20,6 @@ func createTestServer(
 	prvKey security.PrivateKey,
 ) (*grpc.ClientConn, func()) {
 	t.Helper()
-	require := require.New(t)
 
 	if metrics == nil {
 		metrics = &services.MetricServiceMock{}
@@ -30,12 +29,19 @@ func createTestServer(
 		healthcheck = &services.HealthCheckServiceMock{}
 	}
 
-	lis := bufconn.Listen(1024 * 1024)
-	srv := NewBackend(
+	return createTestServerWithOptions(t,
 		WithHealthService(healthcheck),
 		WithMetricService(metrics),
 		WithPrivateKey(prvKey),
 	)
+}
+
+func createTestServerWithOptions(t *testing.T, opts ...Option) (*grpc.ClientConn, func()) {
+	t.Helper()
+	require := require.New(t)
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := NewBackend(opts...)
 
 	go func() {
 		require.NoError(srv.Serve(lis))
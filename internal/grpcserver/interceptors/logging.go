@@ -40,3 +40,41 @@ func UnaryRequestsInterceptor(ctx context.Context, req any, info *grpc.UnaryServ
 
 	return resp, err
 }
+
+// StreamRequestsInterceptor is grpc stream interceptor which logs opened and closed streams.
+func StreamRequestsInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+
+	logger := log.Logger.With().
+		Str("transport", "gRPC").
+		Logger()
+
+	ctx := logger.WithContext(ss.Context())
+	requestID, clientIP := extractMetaData(ctx)
+
+	logger.Info().
+		Str("rid", requestID).
+		Str("method", info.FullMethod).
+		Str("remote-addr", clientIP).
+		Msg("Stream opened")
+
+	err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	status, _ := status.FromError(err)
+
+	logger.Info().
+		Float64("elapsed", time.Since(start).Seconds()).
+		Str("status", status.Code().String()).
+		Msg("Stream closed")
+
+	return err
+}
+
+// Server stream with replaced context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
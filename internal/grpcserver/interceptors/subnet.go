@@ -14,20 +14,39 @@ import (
 // Interceptor to match if trusted subnet used
 func UnaryRequestsFilter(trustedSubnet *net.IPNet) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
-		if trustedSubnet == nil {
-			return handler(ctx, req)
+		if err := checkTrustedSubnet(ctx, trustedSubnet); err != nil {
+			return nil, err
 		}
 
-		_, rawIP := extractMetaData(ctx)
-		clientIP := net.ParseIP(rawIP)
-
-		if !trustedSubnet.Contains(clientIP) {
-			err := entities.ErrUntrustedSubnet
-			logging.LogErrorCtx(ctx, err)
+		return handler(ctx, req)
+	}
+}
 
-			return nil, status.Error(codes.PermissionDenied, err.Error())
+// Stream interceptor to match if trusted subnet used
+func StreamRequestsFilter(trustedSubnet *net.IPNet) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkTrustedSubnet(ss.Context(), trustedSubnet); err != nil {
+			return err
 		}
 
-		return handler(ctx, req)
+		return handler(srv, ss)
 	}
 }
+
+func checkTrustedSubnet(ctx context.Context, trustedSubnet *net.IPNet) error {
+	if trustedSubnet == nil {
+		return nil
+	}
+
+	_, rawIP := extractMetaData(ctx)
+	clientIP := net.ParseIP(rawIP)
+
+	if !trustedSubnet.Contains(clientIP) {
+		err := entities.ErrUntrustedSubnet
+		logging.LogErrorCtx(ctx, err)
+
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return nil
+}
@@ -3,10 +3,18 @@ package grpcserver
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/logging"
 	"github.com/ex0rcist/metflix/internal/security"
 	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/internal/validators"
 	"github.com/ex0rcist/metflix/pkg/grpcapi"
+	"github.com/ex0rcist/metflix/pkg/metrics"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -19,11 +27,12 @@ type MetricsServer struct {
 
 	privateKey    security.PrivateKey
 	metricService services.MetricProvider
+	batches       *appliedBatches
 }
 
 // RegisterMetricsServer creates new instance of gRPC serving Metrics API and attaches it to the server.
 func RegisterMetricsServer(server *grpc.Server, metricService services.MetricProvider, privateKey security.PrivateKey) {
-	s := &MetricsServer{metricService: metricService, privateKey: privateKey}
+	s := &MetricsServer{metricService: metricService, privateKey: privateKey, batches: newAppliedBatches()}
 
 	grpcapi.RegisterMetricsServer(server, s)
 }
@@ -35,7 +44,7 @@ func (s MetricsServer) BatchUpdate(ctx context.Context, req *grpcapi.BatchUpdate
 		return nil, status.Errorf(codes.InvalidArgument, "please use encrypted endpoint")
 	}
 
-	return s.batchUpdate(ctx, req)
+	return s.batchUpdate(ctx, req, incomingBatchID(ctx))
 }
 
 // BatchUpdateEncrypted decodes encrypted data and pushes list of metrics data.
@@ -51,18 +60,28 @@ func (s MetricsServer) BatchUpdateEncrypted(ctx context.Context, encReq *grpcapi
 		return nil, err
 	}
 
-	return s.batchUpdate(ctx, req)
+	return s.batchUpdate(ctx, req, incomingBatchID(ctx))
 }
 
-func (s MetricsServer) batchUpdate(ctx context.Context, req *grpcapi.BatchUpdateRequest) (*grpcapi.BatchUpdateResponse, error) {
+// Store batch, skipping it if batch with the same ID was already stored (empty response is returned then).
+func (s MetricsServer) batchUpdate(ctx context.Context, req *grpcapi.BatchUpdateRequest, batchID string) (*grpcapi.BatchUpdateResponse, error) {
 	records, err := toRecordsList(req)
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	records, err = s.metricService.PushList(ctx, records)
+	duplicate, err := s.batches.apply(ctx, batchID, func() (err error) {
+		records, err = s.metricService.PushList(ctx, records)
+		return err
+	})
+
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, status.Error(errToCode(err), err.Error())
+	}
+
+	if duplicate {
+		logging.LogInfoCtx(ctx, fmt.Sprintf("batch %s is already stored, skipping", batchID))
+		return &grpcapi.BatchUpdateResponse{}, nil
 	}
 
 	data, err := toMetricExchangeList(records)
@@ -72,3 +91,108 @@ func (s MetricsServer) batchUpdate(ctx context.Context, req *grpcapi.BatchUpdate
 
 	return &grpcapi.BatchUpdateResponse{Data: data}, nil
 }
+
+// Get returns current value of the metric.
+func (s MetricsServer) Get(ctx context.Context, req *grpcapi.GetRequest) (*grpcapi.GetResponse, error) {
+	if err := validateMetricRequest(req.Id, req.Mtype, req.Labels); err != nil {
+		return nil, status.Error(errToCode(err), err.Error())
+	}
+
+	record, err := s.metricService.Get(ctx, req.Id, req.Mtype, req.Labels)
+	if err != nil {
+		return nil, status.Error(errToCode(err), err.Error())
+	}
+
+	data, err := toMetricExchange(record)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &grpcapi.GetResponse{Data: data}, nil
+}
+
+// List returns page of metrics matching filter, ordered by name.
+func (s MetricsServer) List(ctx context.Context, req *grpcapi.ListRequest) (*grpcapi.ListResponse, error) {
+	if err := validators.ValidateLabels(req.Labels); err != nil {
+		return nil, status.Error(errToCode(err), err.Error())
+	}
+
+	pageSize := int(req.PageSize)
+	switch {
+	case pageSize < 0:
+		return nil, status.Error(codes.InvalidArgument, "page size must not be negative")
+	case pageSize == 0:
+		pageSize = defaultListPageSize
+	case pageSize > maxListPageSize:
+		pageSize = maxListPageSize
+	}
+
+	after, err := decodePageToken(req.PageToken)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	filter := storage.ListFilter{NamePrefix: req.NamePrefix, Kind: req.Mtype, Labels: req.Labels}
+
+	records, err := s.metricService.List(ctx, filter)
+	if err != nil {
+		return nil, status.Error(errToCode(err), err.Error())
+	}
+
+	// records are sorted by name and id, skip ones returned on previous pages
+	start := sort.Search(len(records), func(i int) bool {
+		return after.less(records[i])
+	})
+
+	records = records[start:]
+
+	resp := &grpcapi.ListResponse{}
+	if len(records) > pageSize {
+		records = records[:pageSize]
+		resp.NextPageToken = encodePageToken(records[pageSize-1])
+	}
+
+	resp.Data, err = toMetricExchangeList(records)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return resp, nil
+}
+
+// Delete removes the metric and its history.
+func (s MetricsServer) Delete(ctx context.Context, req *grpcapi.DeleteRequest) (*grpcapi.DeleteResponse, error) {
+	if err := validateMetricRequest(req.Id, req.Mtype, req.Labels); err != nil {
+		return nil, status.Error(errToCode(err), err.Error())
+	}
+
+	if err := s.metricService.Delete(ctx, req.Id, req.Mtype, req.Labels); err != nil {
+		return nil, status.Error(errToCode(err), err.Error())
+	}
+
+	return &grpcapi.DeleteResponse{}, nil
+}
+
+func validateMetricRequest(name, kind string, labels metrics.Labels) error {
+	if err := validators.ValidateMetric(name, kind); err != nil {
+		return err
+	}
+
+	return validators.ValidateLabels(labels)
+}
+
+func errToCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, entities.ErrRecordNotFound):
+		return codes.NotFound
+	case
+		errors.Is(err, entities.ErrMetricUnknown), errors.Is(err, entities.ErrMetricInvalidValue),
+		errors.Is(err, entities.ErrMetricMissingName), errors.Is(err, entities.ErrMetricInvalidName),
+		errors.Is(err, entities.ErrMetricLongName), errors.Is(err, entities.ErrMetricMissingValue),
+		errors.Is(err, entities.ErrMetricInvalidLabel), errors.Is(err, entities.ErrHistogramBucketsMismatch):
+
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
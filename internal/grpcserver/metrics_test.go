@@ -83,6 +83,30 @@ func TestBatchUpdate(t *testing.T) {
 				code: codes.InvalidArgument,
 			},
 		},
+		{
+			name: "Batch update keeps labels",
+			data: []*grpcapi.MetricExchange{
+				{Id: "Alloc", Mtype: metrics.KindGauge, Value: 1.5, Labels: map[string]string{"host": "a"}},
+			},
+			serviceRsp: []storage.Record{
+				{Name: "Alloc", Value: metrics.Gauge(1.5), Labels: metrics.Labels{"host": "a"}},
+			},
+			expected: expected{
+				code: codes.OK,
+				response: []*grpcapi.MetricExchange{
+					{Id: "Alloc", Mtype: metrics.KindGauge, Value: 1.5, Labels: map[string]string{"host": "a"}},
+				},
+			},
+		},
+		{
+			name: "Batch update fails on invalid labels",
+			data: []*grpcapi.MetricExchange{
+				{Id: "Alloc", Mtype: metrics.KindGauge, Value: 1.5, Labels: map[string]string{"bad label": "a"}},
+			},
+			expected: expected{
+				code: codes.InvalidArgument,
+			},
+		},
 		{
 			name:       "Batch update fails if service is broken",
 			data:       batchReq,
@@ -235,3 +259,144 @@ func encrypt(data []*grpcapi.MetricExchange, key security.PublicKey) ([]byte, er
 
 	return payload.Bytes(), nil
 }
+
+func TestGet(t *testing.T) {
+	tt := []struct {
+		name       string
+		req        *grpcapi.GetRequest
+		serviceRsp storage.Record
+		serviceErr error
+		code       codes.Code
+		response   *grpcapi.MetricExchange
+	}{
+		{
+			name:       "Successful get",
+			req:        &grpcapi.GetRequest{Id: "Alloc", Mtype: metrics.KindGauge, Labels: map[string]string{"host": "a"}},
+			serviceRsp: storage.Record{Name: "Alloc", Value: metrics.Gauge(1.5), Labels: metrics.Labels{"host": "a"}},
+			code:       codes.OK,
+			response:   &grpcapi.MetricExchange{Id: "Alloc", Mtype: metrics.KindGauge, Value: 1.5, Labels: map[string]string{"host": "a"}},
+		},
+		{
+			name:       "Get fails if metric not found",
+			req:        &grpcapi.GetRequest{Id: "Alloc", Mtype: metrics.KindGauge},
+			serviceErr: entities.ErrRecordNotFound,
+			code:       codes.NotFound,
+		},
+		{
+			name: "Get fails on unknown metric kind",
+			req:  &grpcapi.GetRequest{Id: "Alloc", Mtype: "unknown"},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "Get fails on invalid labels",
+			req:  &grpcapi.GetRequest{Id: "Alloc", Mtype: metrics.KindGauge, Labels: map[string]string{"bad label": "a"}},
+			code: codes.InvalidArgument,
+		},
+		{
+			name:       "Get fails if service is broken",
+			req:        &grpcapi.GetRequest{Id: "Alloc", Mtype: metrics.KindGauge},
+			serviceErr: entities.ErrUnexpected,
+			code:       codes.Internal,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(services.MetricServiceMock)
+			m.On("Get", tc.req.Id, tc.req.Mtype, mock.Anything).Return(tc.serviceRsp, tc.serviceErr)
+
+			conn, closer := createTestServer(t, m, nil, nil)
+			t.Cleanup(closer)
+
+			client := grpcapi.NewMetricsClient(conn)
+			resp, err := client.Get(context.Background(), tc.req)
+
+			require.Equal(t, tc.code, status.Code(err))
+
+			if tc.code == codes.OK {
+				require.True(t, proto.Equal(tc.response, resp.Data))
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	records := []storage.Record{
+		{Name: "HeapAlloc", Value: metrics.Gauge(1)},
+		{Name: "HeapIdle", Value: metrics.Gauge(2), Labels: metrics.Labels{"host": "a"}},
+		{Name: "HeapIdle", Value: metrics.Gauge(3), Labels: metrics.Labels{"host": "b"}},
+	}
+
+	m := new(services.MetricServiceMock)
+	m.On("List", storage.ListFilter{NamePrefix: "Heap", Kind: metrics.KindGauge}).Return(records, nil)
+
+	conn, closer := createTestServer(t, m, nil, nil)
+	t.Cleanup(closer)
+
+	client := grpcapi.NewMetricsClient(conn)
+	ctx := context.Background()
+
+	req := &grpcapi.ListRequest{NamePrefix: "Heap", Mtype: metrics.KindGauge, PageSize: 2}
+
+	resp, err := client.List(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 2)
+	require.Equal(t, "HeapAlloc", resp.Data[0].Id)
+	require.Equal(t, map[string]string{"host": "a"}, resp.Data[1].Labels)
+	require.NotEmpty(t, resp.NextPageToken)
+
+	req.PageToken = resp.NextPageToken
+
+	resp, err = client.List(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 1)
+	require.Equal(t, map[string]string{"host": "b"}, resp.Data[0].Labels)
+	require.Empty(t, resp.NextPageToken)
+
+	_, err = client.List(ctx, &grpcapi.ListRequest{PageToken: "garbage!"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.List(ctx, &grpcapi.ListRequest{PageSize: -1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestDelete(t *testing.T) {
+	tt := []struct {
+		name       string
+		req        *grpcapi.DeleteRequest
+		serviceErr error
+		code       codes.Code
+	}{
+		{
+			name: "Successful delete",
+			req:  &grpcapi.DeleteRequest{Id: "PollCount", Mtype: metrics.KindCounter},
+			code: codes.OK,
+		},
+		{
+			name:       "Delete fails if metric not found",
+			req:        &grpcapi.DeleteRequest{Id: "PollCount", Mtype: metrics.KindCounter},
+			serviceErr: entities.ErrRecordNotFound,
+			code:       codes.NotFound,
+		},
+		{
+			name: "Delete fails on invalid name",
+			req:  &grpcapi.DeleteRequest{Id: "Poll Count", Mtype: metrics.KindCounter},
+			code: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(services.MetricServiceMock)
+			m.On("Delete", tc.req.Id, tc.req.Mtype, mock.Anything).Return(tc.serviceErr)
+
+			conn, closer := createTestServer(t, m, nil, nil)
+			t.Cleanup(closer)
+
+			client := grpcapi.NewMetricsClient(conn)
+			_, err := client.Delete(context.Background(), tc.req)
+
+			require.Equal(t, tc.code, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package grpcserver
+
+import (
+	"context"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/ex0rcist/metflix/internal/otlp"
+)
+
+// OTLPMetricsServer receives metrics from OpenTelemetry SDKs and collectors.
+type OTLPMetricsServer struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+
+	translator *otlp.Translator
+}
+
+// RegisterOTLPMetricsServer creates new instance of gRPC serving OTLP MetricsService and attaches it to the server.
+func RegisterOTLPMetricsServer(server *grpc.Server, translator *otlp.Translator) {
+	s := &OTLPMetricsServer{translator: translator}
+
+	colmetricspb.RegisterMetricsServiceServer(server, s)
+}
+
+// Export stores received metrics, rejected data points are reported via partial success.
+func (s OTLPMetricsServer) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	resp, err := s.translator.Export(ctx, req)
+	if err != nil {
+		return nil, status.Error(errToCode(err), err.Error())
+	}
+
+	return resp, nil
+}
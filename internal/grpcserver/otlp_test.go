@@ -0,0 +1,73 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOTLPExport(t *testing.T) {
+	req := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: []*metricspb.Metric{
+				{
+					Name: "requests",
+					Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+						IsMonotonic:            true,
+						AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+						DataPoints: []*metricspb.NumberDataPoint{{
+							Value: &metricspb.NumberDataPoint_AsInt{AsInt: 5},
+						}},
+					}},
+				},
+				{
+					Name: "rpc.latency",
+					Data: &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+						DataPoints: []*metricspb.ExponentialHistogramDataPoint{{}},
+					}},
+				},
+			}}},
+		}},
+	}
+
+	tt := []struct {
+		name       string
+		serviceErr error
+		code       codes.Code
+	}{
+		{name: "Successful export with rejected points", code: codes.OK},
+		{name: "Export fails on service error", serviceErr: entities.ErrUnexpected, code: codes.Internal},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			metricService := &services.MetricServiceMock{}
+			metricService.
+				On("PushList", mock.Anything, []storage.Record{{Name: "requests", Value: metrics.Counter(5)}}).
+				Return([]storage.Record{}, tc.serviceErr)
+
+			conn, closer := createTestServer(t, metricService, nil, nil)
+			defer closer()
+
+			client := colmetricspb.NewMetricsServiceClient(conn)
+			resp, err := client.Export(context.Background(), req)
+
+			require.Equal(t, tc.code, status.Code(err))
+			if tc.code == codes.OK {
+				require.Equal(t, int64(1), resp.GetPartialSuccess().GetRejectedDataPoints())
+			}
+
+			metricService.AssertExpectations(t)
+		})
+	}
+}
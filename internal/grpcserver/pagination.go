@@ -0,0 +1,53 @@
+package grpcserver
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/ex0rcist/metflix/internal/storage"
+)
+
+const (
+	defaultListPageSize = 100
+	maxListPageSize     = 1000
+)
+
+var errBadPageToken = errors.New("invalid page token")
+
+// Position of the last record of returned page. Records are ordered by name, then by id.
+type pageCursor struct {
+	name string
+	id   string
+}
+
+// Check whether record goes after cursor position. Empty cursor precedes everything.
+func (c pageCursor) less(record storage.Record) bool {
+	if record.Name != c.name {
+		return record.Name > c.name
+	}
+
+	return record.CalculateRecordID() > c.id
+}
+
+func encodePageToken(record storage.Record) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(record.Name + "\n" + record.CalculateRecordID()))
+}
+
+func decodePageToken(token string) (pageCursor, error) {
+	if len(token) == 0 {
+		return pageCursor{}, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return pageCursor{}, errBadPageToken
+	}
+
+	name, id, ok := strings.Cut(string(data), "\n")
+	if !ok || len(name) == 0 {
+		return pageCursor{}, errBadPageToken
+	}
+
+	return pageCursor{name: name, id: id}, nil
+}
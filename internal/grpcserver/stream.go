@@ -0,0 +1,163 @@
+package grpcserver
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/internal/security"
+	"github.com/ex0rcist/metflix/pkg/grpcapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	streamAckEvery    = 10 // batches
+	streamAckInterval = 5 * time.Second
+)
+
+type streamMessage struct {
+	req *grpcapi.StreamUpdateRequest
+	err error
+}
+
+// StreamUpdate receives batches over long-living stream and stores them via PushList.
+// Stored batches are acknowledged every streamAckEvery batches or streamAckInterval, and once more on close
+// or failure of a batch. Batches are numbered within stream identified by grpcapi.StreamIDHeader,
+// so the ones resent by client in unary requests after losing acknowledgement are not stored twice.
+//
+// The method is bidirectional rather than client-streaming: client-streaming call can only respond once,
+// when the stream is over, while periodic acks let client release stored batches as it goes.
+func (s MetricsServer) StreamUpdate(stream grpc.BidiStreamingServer[grpcapi.StreamUpdateRequest, grpcapi.StreamUpdateAck]) error {
+	ctx := stream.Context()
+	streamID := incomingHeader(ctx, grpcapi.StreamIDHeader)
+
+	if err := stream.SendHeader(metadata.Pairs(grpcapi.StreamReadyHeader, "1")); err != nil {
+		return err
+	}
+
+	messages := make(chan streamMessage)
+	go func() {
+		defer close(messages)
+
+		for {
+			req, err := stream.Recv()
+
+			select {
+			case messages <- streamMessage{req: req, err: err}:
+			case <-ctx.Done():
+				return
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(streamAckInterval)
+	defer ticker.Stop()
+
+	var ack, acked grpcapi.StreamUpdateAck
+
+	sendAck := func() error {
+		if ack.Batches == acked.Batches {
+			return nil
+		}
+
+		acked.Batches, acked.Records = ack.Batches, ack.Records
+
+		return stream.Send(&acked)
+	}
+
+	// client resends batches not acknowledged, so report ones stored before failure
+	fail := func(err error) error {
+		if ackErr := sendAck(); ackErr != nil {
+			logging.LogErrorCtx(ctx, ackErr, "failed to acknowledge stored batches")
+		}
+
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+
+		case <-ticker.C:
+			if err := sendAck(); err != nil {
+				return err
+			}
+
+		case msg, ok := <-messages:
+			if !ok {
+				// reader exits on cancellation, closed channel carries no message
+				return status.FromContextError(ctx.Err()).Err()
+			}
+
+			if errors.Is(msg.err, io.EOF) {
+				return sendAck()
+			}
+
+			if msg.err != nil {
+				return msg.err
+			}
+
+			req, err := s.streamBatch(msg.req)
+			if err != nil {
+				return fail(err)
+			}
+
+			var batchID string
+			if len(streamID) > 0 {
+				batchID = grpcapi.BatchID(streamID, ack.Batches+1)
+			}
+
+			resp, err := s.batchUpdate(ctx, req, batchID)
+			if err != nil {
+				return fail(err)
+			}
+
+			ack.Batches++
+			ack.Records += uint64(len(resp.Data))
+
+			if ack.Batches-acked.Batches >= streamAckEvery {
+				if err := sendAck(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// Extract batch from stream message, decrypting it if server is configured with RSA encoding.
+func (s MetricsServer) streamBatch(msg *grpcapi.StreamUpdateRequest) (*grpcapi.BatchUpdateRequest, error) {
+	if s.privateKey == nil {
+		if len(msg.EncryptedData) > 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "server does not accept encrypted data")
+		}
+
+		return &grpcapi.BatchUpdateRequest{Data: msg.Data}, nil
+	}
+
+	if len(msg.EncryptedData) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "please use encrypted data")
+	}
+
+	buff, err := security.Decrypt(bytes.NewReader(msg.EncryptedData), s.privateKey)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	req := &grpcapi.BatchUpdateRequest{}
+	if err := proto.Unmarshal(buff.Bytes(), req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return req, nil
+}
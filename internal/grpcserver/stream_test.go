@@ -0,0 +1,227 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/security"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/internal/utils"
+	"github.com/ex0rcist/metflix/pkg/grpcapi"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestStreamUpdate(t *testing.T) {
+	m := new(services.MetricServiceMock)
+	m.On("PushList", mock.Anything, []storage.Record{{Name: "PollCount", Value: metrics.Counter(1)}}).
+		Return([]storage.Record{{Name: "PollCount", Value: metrics.Counter(1)}}, nil)
+
+	conn, closer := createTestServer(t, m, nil, nil)
+	t.Cleanup(closer)
+
+	stream, err := grpcapi.NewMetricsClient(conn).StreamUpdate(context.Background())
+	require.NoError(t, err)
+
+	header, err := stream.Header()
+	require.NoError(t, err)
+	require.NotEmpty(t, header.Get(grpcapi.StreamReadyHeader))
+
+	req := &grpcapi.StreamUpdateRequest{Data: []*grpcapi.MetricExchange{grpcapi.NewUpdateCounterMex("PollCount", 1)}}
+
+	for i := 0; i < streamAckEvery+2; i++ {
+		require.NoError(t, stream.Send(req))
+	}
+
+	// periodic ack
+	ack, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(streamAckEvery), ack.Batches)
+	require.Equal(t, uint64(streamAckEvery), ack.Records)
+
+	// final ack on close
+	require.NoError(t, stream.CloseSend())
+
+	ack, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(streamAckEvery+2), ack.Batches)
+
+	_, err = stream.Recv()
+	require.ErrorIs(t, err, io.EOF)
+
+	m.AssertNumberOfCalls(t, "PushList", streamAckEvery+2)
+}
+
+func TestStreamUpdateErrors(t *testing.T) {
+	root, _ := utils.GetProjectRoot()
+	prvKey, _ := security.NewPrivateKey(entities.FilePath(filepath.Join(root, "example_key.pem")))
+
+	tt := []struct {
+		name   string
+		req    *grpcapi.StreamUpdateRequest
+		prvKey security.PrivateKey
+		code   codes.Code
+	}{
+		{
+			name: "Stream fails on empty batch",
+			req:  &grpcapi.StreamUpdateRequest{},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "Stream fails on unknown metric kind",
+			req:  &grpcapi.StreamUpdateRequest{Data: []*grpcapi.MetricExchange{{Id: "xxx", Mtype: "unknown"}}},
+			code: codes.InvalidArgument,
+		},
+		{
+			name:   "Stream on RSA-protected server requires encrypted data",
+			req:    &grpcapi.StreamUpdateRequest{Data: []*grpcapi.MetricExchange{grpcapi.NewUpdateCounterMex("PollCount", 1)}},
+			prvKey: prvKey,
+			code:   codes.InvalidArgument,
+		},
+		{
+			name: "Stream fails on encrypted data if server has no key",
+			req:  &grpcapi.StreamUpdateRequest{EncryptedData: []byte("secret")},
+			code: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, closer := createTestServer(t, nil, nil, tc.prvKey)
+			t.Cleanup(closer)
+
+			stream, err := grpcapi.NewMetricsClient(conn).StreamUpdate(context.Background())
+			require.NoError(t, err)
+
+			require.NoError(t, stream.Send(tc.req))
+
+			_, err = stream.Recv()
+			require.Equal(t, tc.code, status.Code(err))
+		})
+	}
+}
+
+func TestStreamUpdateClientCancel(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	m := new(services.MetricServiceMock)
+	m.On("PushList", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			entered <- struct{}{}
+			<-release
+		}).
+		Return([]storage.Record{{Name: "PollCount", Value: metrics.Counter(1)}}, nil)
+
+	conn, closer := createTestServer(t, m, nil, nil)
+	t.Cleanup(closer)
+
+	client := grpcapi.NewMetricsClient(conn)
+	req := &grpcapi.StreamUpdateRequest{Data: []*grpcapi.MetricExchange{grpcapi.NewUpdateCounterMex("PollCount", 1)}}
+
+	// handler is busy storing batch while client cancels, so on return it selects
+	// between cancelled context and reader's closed channel; repeat to hit both
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		stream, err := client.StreamUpdate(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(req))
+
+		<-entered
+		cancel()
+		time.Sleep(10 * time.Millisecond) // let reader observe cancellation
+		release <- struct{}{}
+
+		_, err = stream.Recv()
+		require.Equal(t, codes.Canceled, status.Code(err))
+	}
+
+	// server survived cancellations
+	go func() {
+		<-entered
+		release <- struct{}{}
+	}()
+
+	stream, err := client.StreamUpdate(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(req))
+	require.NoError(t, stream.CloseSend())
+
+	ack, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), ack.Batches)
+}
+
+func TestStreamUpdateAcksBeforeFailure(t *testing.T) {
+	m := new(services.MetricServiceMock)
+	m.On("PushList", mock.Anything, mock.Anything).
+		Return([]storage.Record{{Name: "PollCount", Value: metrics.Counter(1)}}, nil).Once()
+	m.On("PushList", mock.Anything, mock.Anything).Return([]storage.Record{}, entities.ErrUnexpected).Once()
+
+	conn, closer := createTestServer(t, m, nil, nil)
+	t.Cleanup(closer)
+
+	stream, err := grpcapi.NewMetricsClient(conn).StreamUpdate(context.Background())
+	require.NoError(t, err)
+
+	req := &grpcapi.StreamUpdateRequest{Data: []*grpcapi.MetricExchange{grpcapi.NewUpdateCounterMex("PollCount", 1)}}
+	require.NoError(t, stream.Send(req))
+	require.NoError(t, stream.Send(req))
+
+	// batch stored before failure is acknowledged, so client doesn't resend it
+	ack, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), ack.Batches)
+
+	_, err = stream.Recv()
+	require.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestStreamUpdateResendIsSkipped(t *testing.T) {
+	m := new(services.MetricServiceMock)
+	m.On("PushList", mock.Anything, mock.Anything).
+		Return([]storage.Record{{Name: "PollCount", Value: metrics.Counter(1)}}, nil)
+
+	conn, closer := createTestServer(t, m, nil, nil)
+	t.Cleanup(closer)
+
+	client := grpcapi.NewMetricsClient(conn)
+	data := []*grpcapi.MetricExchange{grpcapi.NewUpdateCounterMex("PollCount", 1)}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), grpcapi.StreamIDHeader, "stream")
+
+	stream, err := client.StreamUpdate(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&grpcapi.StreamUpdateRequest{Data: data}))
+	require.NoError(t, stream.CloseSend())
+
+	ack, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), ack.Batches)
+
+	// client lost the ack and resends batch in unary request
+	ctx = metadata.AppendToOutgoingContext(context.Background(), grpcapi.BatchIDHeader, grpcapi.BatchID("stream", 1))
+
+	resp, err := client.BatchUpdate(ctx, &grpcapi.BatchUpdateRequest{Data: data})
+	require.NoError(t, err)
+	require.Empty(t, resp.Data)
+
+	// batch which was not sent into stream is stored
+	ctx = metadata.AppendToOutgoingContext(context.Background(), grpcapi.BatchIDHeader, grpcapi.BatchID("stream", 2))
+
+	resp, err = client.BatchUpdate(ctx, &grpcapi.BatchUpdateRequest{Data: data})
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 1)
+
+	m.AssertNumberOfCalls(t, "PushList", 2)
+}
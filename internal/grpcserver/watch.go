@@ -0,0 +1,63 @@
+package grpcserver
+
+import (
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/internal/validators"
+	"github.com/ex0rcist/metflix/pkg/grpcapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Watch streams stored values of metrics matching filter as they change.
+// Subscribers which don't keep up with updates are disconnected with ResourceExhausted.
+func (s MetricsServer) Watch(req *grpcapi.WatchRequest, stream grpc.ServerStreamingServer[grpcapi.MetricExchange]) error {
+	if err := validateWatchRequest(req); err != nil {
+		return status.Error(errToCode(err), err.Error())
+	}
+
+	ctx := stream.Context()
+
+	sub := s.metricService.Subscribe(storage.ListFilter{
+		Name:       req.Id,
+		NamePrefix: req.NamePrefix,
+		Kind:       req.Mtype,
+		Labels:     req.Labels,
+	})
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+
+		case change, ok := <-sub.C():
+			if !ok {
+				if sub.Dropped() {
+					return status.Error(codes.ResourceExhausted, "subscriber is too slow")
+				}
+
+				return nil
+			}
+
+			data, err := toMetricExchange(change.Record)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+
+			if err := stream.Send(data); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func validateWatchRequest(req *grpcapi.WatchRequest) error {
+	if len(req.Mtype) > 0 {
+		if err := validators.ValidateMetricKind(req.Mtype); err != nil {
+			return err
+		}
+	}
+
+	return validators.ValidateLabels(req.Labels)
+}
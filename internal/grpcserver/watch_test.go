@@ -0,0 +1,108 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/grpcapi"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWatch(t *testing.T) {
+	service := services.NewMetricService(storage.NewMemStorage())
+	filter := storage.ListFilter{NamePrefix: "Poll", Kind: metrics.KindCounter}
+
+	m := new(services.MetricServiceMock)
+	m.On("Subscribe", filter).Return(service.Subscribe(filter))
+
+	conn, closer := createTestServer(t, m, nil, nil)
+	t.Cleanup(closer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := grpcapi.NewMetricsClient(conn).Watch(ctx, &grpcapi.WatchRequest{NamePrefix: "Poll", Mtype: metrics.KindCounter})
+	require.NoError(t, err)
+
+	_, err = service.PushList(ctx, []storage.Record{
+		{Name: "PollCount", Value: metrics.Gauge(1)},
+		{Name: "PollCount", Value: metrics.Counter(1)},
+		{Name: "Alloc", Value: metrics.Counter(1)},
+	})
+	require.NoError(t, err)
+
+	_, err = service.Push(ctx, storage.Record{Name: "PollCount", Value: metrics.Counter(2)})
+	require.NoError(t, err)
+
+	mex, err := stream.Recv()
+	require.NoError(t, err)
+	require.True(t, proto.Equal(grpcapi.NewUpdateCounterMex("PollCount", 1), mex))
+
+	mex, err = stream.Recv()
+	require.NoError(t, err)
+	require.True(t, proto.Equal(grpcapi.NewUpdateCounterMex("PollCount", 3), mex))
+
+	cancel()
+
+	_, err = stream.Recv()
+	require.Equal(t, codes.Canceled, status.Code(err))
+}
+
+func TestWatchDropsSlowSubscriber(t *testing.T) {
+	service := services.NewMetricService(storage.NewMemStorage())
+	sub := service.Subscribe(storage.ListFilter{})
+
+	m := new(services.MetricServiceMock)
+	m.On("Subscribe", storage.ListFilter{}).Return(sub)
+
+	// overflow subscription before anything is consumed
+	for i := 0; !sub.Dropped(); i++ {
+		_, err := service.Push(context.Background(), storage.Record{Name: fmt.Sprintf("m%d", i), Value: metrics.Gauge(1)})
+		require.NoError(t, err)
+	}
+
+	conn, closer := createTestServer(t, m, nil, nil)
+	t.Cleanup(closer)
+
+	stream, err := grpcapi.NewMetricsClient(conn).Watch(context.Background(), &grpcapi.WatchRequest{})
+	require.NoError(t, err)
+
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestWatchInvalidRequest(t *testing.T) {
+	tt := []struct {
+		name string
+		req  *grpcapi.WatchRequest
+	}{
+		{name: "unknown kind", req: &grpcapi.WatchRequest{Mtype: "unknown"}},
+		{name: "invalid labels", req: &grpcapi.WatchRequest{Labels: map[string]string{"0bad": "a"}}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, closer := createTestServer(t, nil, nil, nil)
+			t.Cleanup(closer)
+
+			stream, err := grpcapi.NewMetricsClient(conn).Watch(context.Background(), tc.req)
+			require.NoError(t, err)
+
+			_, err = stream.Recv()
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
@@ -72,52 +72,94 @@ func (b *Backend) registerMiddlewares() {
 		func(next http.Handler) http.Handler {
 			return middleware.FilterUntrustedRequest(next, b.trustedSubnet)
 		},
+	}
+
+	b.router.Use(middlewares...)
+}
 
-		middleware.DecompressRequest,
+// Middlewares buffering or transforming whole response body, not applicable to event streams.
+func (b *Backend) responseMiddlewares() []func(http.Handler) http.Handler {
+	return []func(http.Handler) http.Handler{
 		middleware.CompressResponse,
 
 		func(next http.Handler) http.Handler {
 			return middleware.SignResponse(next, b.signSecret)
 		},
 	}
-
-	b.router.Use(middlewares...)
 }
 
 func (b *Backend) registerEndpoints() {
-	b.registerMetricsEndpoints()
-	b.registerHealthEndpoint()
+	b.router.Group(func(r chi.Router) {
+		r.Use(b.responseMiddlewares()...)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.DecompressRequest)
+
+			b.registerMetricsEndpoints(r)
+			b.registerHealthEndpoint(r)
+
+			// setup documentation
+			r.Get("/swagger/*", httpSwagger.WrapHandler)
+		})
+
+		b.registerRemoteWriteEndpoint(r)
+	})
+
+	b.registerStreamEndpoints()
 
 	// setup default 404
 	b.router.NotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound) // no default body
 	}))
-
-	// setup documentation
-	b.router.Get("/swagger/*", httpSwagger.WrapHandler)
 }
 
-func (b *Backend) registerMetricsEndpoints() {
+func (b *Backend) registerMetricsEndpoints(r chi.Router) {
 	if b.metricResource == nil {
 		return
 	}
 
-	b.router.Get("/", b.metricResource.Homepage)
+	r.Get("/", b.metricResource.Homepage)
+
+	r.Post("/update/{metricKind}/{metricName}/{metricValue}", b.metricResource.UpdateMetric)
+	r.Post("/update", b.metricResource.UpdateMetricJSON)
+	r.Post("/updates", b.metricResource.UpdateMetricsBatch)
 
-	b.router.Post("/update/{metricKind}/{metricName}/{metricValue}", b.metricResource.UpdateMetric)
-	b.router.Post("/update", b.metricResource.UpdateMetricJSON)
-	b.router.Post("/updates", b.metricResource.UpdateMetricsBatch)
+	r.Get("/value/{metricKind}/{metricName}", b.metricResource.GetMetric)
+	r.Post("/value", b.metricResource.GetMetricJSON)
 
-	b.router.Get("/value/{metricKind}/{metricName}", b.metricResource.GetMetric)
-	b.router.Post("/value", b.metricResource.GetMetricJSON)
+	r.Get("/api/v1/query_range", b.metricResource.QueryRange)
+
+	r.Get("/metrics", b.metricResource.PrometheusMetrics)
+	r.Post("/write", b.metricResource.InfluxWrite)
+	r.Post("/v1/metrics", b.metricResource.OTLPMetrics)
+}
+
+// Prometheus sends snappy-encoded requests, other endpoints don't accept this encoding.
+func (b *Backend) registerRemoteWriteEndpoint(r chi.Router) {
+	if b.metricResource == nil {
+		return
+	}
+
+	r.With(middleware.DecompressSnappyRequest).Post("/api/v1/write", b.metricResource.RemoteWrite)
 }
 
-func (b *Backend) registerHealthEndpoint() {
+func (b *Backend) registerHealthEndpoint(r chi.Router) {
 	if b.healthResource == nil {
 		return
 	}
 
-	b.router.Get("/ping", b.healthResource.Ping)
+	r.Get("/ping", b.healthResource.Ping)
+	r.Get("/healthz", b.healthResource.Liveness)
+	r.Get("/readyz", b.healthResource.Readiness)
+}
+
+// Event streams are written directly, bypassing response compression and signing.
+func (b *Backend) registerStreamEndpoints() {
+	if b.metricResource == nil {
+		return
+	}
+
+	b.router.With(middleware.DecompressRequest).Get("/api/v1/stream", b.metricResource.Stream)
 }
 
 /* Options */
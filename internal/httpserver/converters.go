@@ -17,6 +17,10 @@ func toRecord(mex *metrics.MetricExchange) (storage.Record, error) {
 		return record, err
 	}
 
+	if err := validators.ValidateLabels(mex.Labels); err != nil {
+		return record, err
+	}
+
 	switch mex.MType {
 	case metrics.KindCounter:
 		if mex.Delta == nil {
@@ -30,15 +34,29 @@ func toRecord(mex *metrics.MetricExchange) (storage.Record, error) {
 		}
 
 		record = storage.Record{Name: mex.ID, Value: *mex.Value}
+	case metrics.KindHistogram:
+		if mex.Histogram == nil {
+			return record, entities.ErrMetricMissingValue
+		}
+
+		if err := mex.Histogram.Validate(); err != nil {
+			return record, err
+		}
+
+		record = storage.Record{Name: mex.ID, Value: *mex.Histogram}
 	default:
 		return record, entities.ErrMetricUnknown
 	}
 
+	if len(mex.Labels) > 0 {
+		record.Labels = mex.Labels
+	}
+
 	return record, nil
 }
 
 func toMetricExchange(record storage.Record) (*metrics.MetricExchange, error) {
-	req := &metrics.MetricExchange{ID: record.Name, MType: record.Value.Kind()}
+	req := &metrics.MetricExchange{ID: record.Name, MType: record.Value.Kind(), Labels: record.Labels}
 
 	switch record.Value.Kind() {
 	case metrics.KindCounter:
@@ -48,6 +66,10 @@ func toMetricExchange(record storage.Record) (*metrics.MetricExchange, error) {
 	case metrics.KindGauge:
 		value, _ := record.Value.(metrics.Gauge)
 		req.Value = &value
+
+	case metrics.KindHistogram:
+		histogram, _ := record.Value.(metrics.Histogram)
+		req.Histogram = &histogram
 	}
 
 	return req, nil
@@ -1,10 +1,12 @@
 package httpserver
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 
 	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/logging"
 	"github.com/ex0rcist/metflix/internal/services"
 )
 
@@ -43,3 +45,41 @@ func (res HealthResource) Ping(w http.ResponseWriter, r *http.Request) {
 
 	writeErrorResponse(ctx, w, http.StatusInternalServerError, err)
 }
+
+// Liveness godoc
+// @Tags Healthcheck
+// @Router /healthz [get]
+// @Summary Verify server process is alive
+// @ID health_liveness
+// @Produce json
+// @Success 200 {object} services.HealthReport
+// @Failure 503 {object} services.HealthReport
+func (res HealthResource) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, r, res.healthService.Liveness(r.Context()))
+}
+
+// Readiness godoc
+// @Tags Healthcheck
+// @Router /readyz [get]
+// @Summary Verify server is ready to serve requests: storage is reachable and consistent
+// @ID health_readiness
+// @Produce json
+// @Success 200 {object} services.HealthReport
+// @Failure 503 {object} services.HealthReport
+func (res HealthResource) Readiness(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, r, res.healthService.Readiness(r.Context()))
+}
+
+func writeHealthReport(w http.ResponseWriter, r *http.Request, report services.HealthReport) {
+	code := http.StatusOK
+	if !report.Passed() {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logging.LogErrorCtx(r.Context(), err)
+	}
+}
@@ -2,6 +2,7 @@ package httpserver
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/internal/otlp"
 	"github.com/ex0rcist/metflix/internal/services"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -55,6 +57,45 @@ func TestPing(t *testing.T) {
 	}
 }
 
+func TestHealthReports(t *testing.T) {
+	passed := services.HealthReport{
+		Status: services.HealthPass,
+		Checks: []services.CheckResult{{Name: "memory", Status: services.HealthPass, Latency: 0.01}},
+	}
+	failed := services.HealthReport{
+		Status: services.HealthFail,
+		Checks: []services.CheckResult{{Name: "database", Status: services.HealthFail, LastError: "connection refused"}},
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		method string
+		report services.HealthReport
+		code   int
+	}{
+		{name: "alive", path: "/healthz", method: "Liveness", report: passed, code: http.StatusOK},
+		{name: "ready", path: "/readyz", method: "Readiness", report: passed, code: http.StatusOK},
+		{name: "not ready", path: "/readyz", method: "Readiness", report: failed, code: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _, healthMock := createHealthTestBackend()
+			healthMock.On(tt.method, mock.Anything).Return(tt.report)
+
+			code, contentType, body := testHealthRequest(t, router, http.MethodGet, tt.path, nil)
+
+			require.Equal(t, tt.code, code)
+			require.Equal(t, "application/json", contentType)
+
+			var report services.HealthReport
+			require.NoError(t, json.Unmarshal(body, &report))
+			require.Equal(t, tt.report, report)
+		})
+	}
+}
+
 func testHealthRequest(t *testing.T, router http.Handler, method, path string, payload []byte) (int, string, []byte) {
 	ts := httptest.NewServer(router)
 	defer ts.Close()
@@ -86,7 +127,7 @@ func createHealthTestBackend() (http.Handler, *services.MetricServiceMock, *serv
 	healthMock := NewHealthResource(healthServiceMock)
 
 	metricServiceMock := &services.MetricServiceMock{}
-	metricMock := NewMetricResource(metricServiceMock)
+	metricMock := NewMetricResource(metricServiceMock, otlp.NewTranslator(metricServiceMock))
 
 	handler := NewBackend(
 		WithHealthResource(healthMock),
@@ -0,0 +1,113 @@
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+)
+
+// Rejected line of Influx write request.
+type InfluxLineError struct {
+	Line  int    `json:"line"` // 1-based line number
+	Error string `json:"error"`
+}
+
+// Response of partially failed Influx write request.
+type InfluxWriteResponse struct {
+	Error  string            `json:"error"`
+	Lines  []InfluxLineError `json:"lines"`
+	Stored int               `json:"stored"` // number of accepted lines
+}
+
+// InfluxWrite godoc
+// @Tags Metrics
+// @Router /write [post]
+// @Summary Push metrics in InfluxDB line protocol
+// @ID metrics_influx_write
+// @Accept plain
+// @Produce json
+// @Param precision query string false "Timestamp precision: `ns` (default), `us`, `ms`, `s`, `m` or `h`."
+// @Param data body string true "Lines in format `measurement[,tag=value...] field=value[,field=value...] [timestamp]`."
+// @Success 204
+// @Failure 400 {object} InfluxWriteResponse "Some lines were rejected, valid lines are stored."
+// @Failure 500 {string} string http.StatusInternalServerError
+func (r MetricResource) InfluxWrite(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	precision, ok := influxPrecisions[req.URL.Query().Get("precision")]
+	if !ok {
+		writeErrorResponse(ctx, rw, http.StatusBadRequest, fmt.Errorf("%w: unknown precision", entities.ErrMetricBatchMalformed))
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxIngestSize+1))
+	if err != nil {
+		writeErrorResponse(ctx, rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(body) > maxIngestSize {
+		writeErrorResponse(ctx, rw, http.StatusRequestEntityTooLarge, entities.ErrMetricBatchMalformed)
+		return
+	}
+
+	records := make([]storage.Record, 0)
+	failed := make([]InfluxLineError, 0)
+	stored := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxIngestSize)
+
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		lineRecords, err := parseInfluxLine(line, precision)
+		if err != nil {
+			failed = append(failed, InfluxLineError{Line: lineNo, Error: err.Error()})
+			continue
+		}
+
+		records = append(records, lineRecords...)
+		stored++
+	}
+
+	// points of the same series are kept as separate samples
+	for _, round := range services.SeriesRounds(records) {
+		if _, err = r.metricService.PushList(ctx, round); err != nil {
+			writeErrorResponse(ctx, rw, errToStatus(err), err)
+			return
+		}
+	}
+
+	if len(failed) == 0 {
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	logging.LogWarnCtx(ctx, fmt.Sprintf("influx write: %d lines stored, %d rejected", stored, len(failed)))
+
+	resp := InfluxWriteResponse{
+		Error:  fmt.Sprintf("partial write: %d lines rejected", len(failed)),
+		Lines:  failed,
+		Stored: stored,
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusBadRequest)
+
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		logging.LogErrorCtx(ctx, err)
+	}
+}
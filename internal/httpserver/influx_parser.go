@@ -0,0 +1,195 @@
+package httpserver
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/internal/validators"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+// Timestamp precisions supported by Influx line protocol.
+var influxPrecisions = map[string]time.Duration{
+	"":   time.Nanosecond,
+	"n":  time.Nanosecond,
+	"ns": time.Nanosecond,
+	"u":  time.Microsecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// Parse single line of Influx line protocol:
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+//
+// Every numeric field becomes separate record named measurement_field (or just measurement for "value" field).
+// Integer fields (with "i" suffix) are counters, other numbers and booleans are gauges, strings are skipped.
+func parseInfluxLine(line string, precision time.Duration) ([]storage.Record, error) {
+	sections := splitUnescaped(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, fmt.Errorf("%w: expected measurement, fields and optional timestamp", entities.ErrMetricBatchMalformed)
+	}
+
+	measurement, labels, err := parseInfluxKey(sections[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var timestamp time.Time
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil || ts > math.MaxInt64/int64(precision) || ts < math.MinInt64/int64(precision) {
+			return nil, fmt.Errorf("%w: bad timestamp %q", entities.ErrMetricBatchMalformed, sections[2])
+		}
+
+		timestamp = time.Unix(0, ts*int64(precision)).UTC()
+	}
+
+	records := make([]storage.Record, 0)
+
+	for _, field := range splitUnescaped(sections[1], ',', true) {
+		key, raw, ok := cutUnescaped(field, '=')
+		if !ok || len(key) == 0 || len(raw) == 0 {
+			return nil, fmt.Errorf("%w: bad field %q", entities.ErrMetricBatchMalformed, field)
+		}
+
+		value, err := parseInfluxFieldValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %q", err, unescapeInflux(key))
+		}
+
+		if value == nil { // string field
+			continue
+		}
+
+		name := measurement
+		if key = unescapeInflux(key); key != "value" {
+			name += "_" + key
+		}
+
+		if err := validators.ValidateMetric(name, value.Kind()); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, name)
+		}
+
+		records = append(records, storage.Record{Name: name, Value: value, Labels: labels, Timestamp: timestamp})
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: no numeric fields", entities.ErrMetricMissingValue)
+	}
+
+	return records, nil
+}
+
+func parseInfluxKey(key string) (string, metrics.Labels, error) {
+	parts := splitUnescaped(key, ',', false)
+
+	measurement := unescapeInflux(parts[0])
+	if len(measurement) == 0 {
+		return "", nil, fmt.Errorf("%w: missing measurement", entities.ErrMetricMissingName)
+	}
+
+	var labels metrics.Labels
+	for _, tag := range parts[1:] {
+		k, v, ok := cutUnescaped(tag, '=')
+		if !ok {
+			return "", nil, fmt.Errorf("%w: bad tag %q", entities.ErrMetricBatchMalformed, tag)
+		}
+
+		if labels == nil {
+			labels = make(metrics.Labels, len(parts)-1)
+		}
+
+		labels[unescapeInflux(k)] = unescapeInflux(v)
+	}
+
+	if err := validators.ValidateLabels(labels); err != nil {
+		return "", nil, err
+	}
+
+	return measurement, labels, nil
+}
+
+// Returns nil metric for string fields.
+func parseInfluxFieldValue(raw string) (metrics.Metric, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		if len(raw) < 2 || !strings.HasSuffix(raw, `"`) {
+			return nil, entities.ErrMetricInvalidValue
+		}
+
+		return nil, nil
+	case strings.HasSuffix(raw, "i"):
+		return metrics.ToCounter(strings.TrimSuffix(raw, "i"))
+	case strings.HasSuffix(raw, "u"):
+		value, err := strconv.ParseUint(strings.TrimSuffix(raw, "u"), 10, 64)
+		if err != nil {
+			return nil, entities.ErrMetricInvalidValue
+		}
+
+		return metrics.Gauge(value), nil
+	}
+
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return metrics.Gauge(1), nil
+	case "f", "F", "false", "False", "FALSE":
+		return metrics.Gauge(0), nil
+	}
+
+	value, err := metrics.ToGauge(raw)
+	if err != nil || math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) {
+		return nil, entities.ErrMetricInvalidValue
+	}
+
+	return value, nil
+}
+
+// Split s by sep, ignoring escaped separators and (optionally) separators inside double quotes.
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	parts := make([]string, 0)
+	inQuotes := false
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++ // skip escaped char
+		case c == '"' && quotes:
+			inQuotes = !inQuotes
+		case c == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// Cut s around first unescaped sep.
+func cutUnescaped(s string, sep byte) (string, string, bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+
+	return s, "", false
+}
+
+var influxUnescaper = strings.NewReplacer(`\,`, `,`, `\ `, ` `, `\=`, `=`, `\"`, `"`, `\\`, `\`)
+
+func unescapeInflux(s string) string {
+	return influxUnescaper.Replace(s)
+}
@@ -0,0 +1,235 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+func TestParseInfluxLine(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		line      string
+		precision time.Duration
+		want      []storage.Record
+		wantErr   error
+	}{
+		{
+			name:      "fields of all kinds",
+			line:      `cpu,host=a,region=eu usage=0.5,requests=12i,up=true,note="skipped, really" 1704103200000000000`,
+			precision: time.Nanosecond,
+			want: []storage.Record{
+				{Name: "cpu_usage", Value: metrics.Gauge(0.5), Labels: metrics.Labels{"host": "a", "region": "eu"}, Timestamp: ts},
+				{Name: "cpu_requests", Value: metrics.Counter(12), Labels: metrics.Labels{"host": "a", "region": "eu"}, Timestamp: ts},
+				{Name: "cpu_up", Value: metrics.Gauge(1), Labels: metrics.Labels{"host": "a", "region": "eu"}, Timestamp: ts},
+			},
+		},
+		{
+			name:      "value field, no tags, second precision",
+			line:      "temperature value=21.5 1704103200",
+			precision: time.Second,
+			want:      []storage.Record{{Name: "temperature", Value: metrics.Gauge(21.5), Timestamp: ts}},
+		},
+		{
+			name: "escaped tag value and no timestamp",
+			line: `disk,path=/var\ log free=10u`,
+			want: []storage.Record{{Name: "disk_free", Value: metrics.Gauge(10), Labels: metrics.Labels{"path": "/var log"}}},
+		},
+		{
+			name:    "missing fields",
+			line:    "cpu,host=a",
+			wantErr: entities.ErrMetricBatchMalformed,
+		},
+		{
+			name:    "bad integer",
+			line:    "cpu requests=1.5i",
+			wantErr: entities.ErrMetricInvalidValue,
+		},
+		{
+			name:    "bad timestamp",
+			line:    "cpu usage=1 yesterday",
+			wantErr: entities.ErrMetricBatchMalformed,
+		},
+		{
+			name:      "timestamp overflows precision",
+			line:      "cpu usage=1 9223372036855",
+			precision: time.Millisecond,
+			wantErr:   entities.ErrMetricBatchMalformed,
+		},
+		{
+			name:      "negative timestamp overflows precision",
+			line:      "cpu usage=1 -9223372037",
+			precision: time.Second,
+			wantErr:   entities.ErrMetricBatchMalformed,
+		},
+		{
+			name:    "NaN field",
+			line:    "cpu usage=NaN",
+			wantErr: entities.ErrMetricInvalidValue,
+		},
+		{
+			name:    "infinite field",
+			line:    "cpu usage=-Inf",
+			wantErr: entities.ErrMetricInvalidValue,
+		},
+		{
+			name:    "only string fields",
+			line:    `cpu note="text"`,
+			wantErr: entities.ErrMetricMissingValue,
+		},
+		{
+			name:    "bad tag",
+			line:    "cpu,host usage=1",
+			wantErr: entities.ErrMetricBatchMalformed,
+		},
+		{
+			name:    "invalid label name",
+			line:    "cpu,1host=a usage=1",
+			wantErr: entities.ErrMetricInvalidLabel,
+		},
+		{
+			name:    "invalid metric name",
+			line:    "cpu us!age=1",
+			wantErr: entities.ErrMetricInvalidName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			precision := tt.precision
+			if precision == 0 {
+				precision = time.Nanosecond
+			}
+
+			got, err := parseInfluxLine(tt.line, precision)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInfluxWrite(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	type result struct {
+		code int
+		body *InfluxWriteResponse
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		payload string
+		mock    func(m *services.MetricServiceMock)
+		want    result
+	}{
+		{
+			name:    "Should push all lines",
+			path:    "/write?precision=s",
+			payload: "# comment\ncpu,host=a usage=0.5 1704103200\n\ncpu,host=a requests=3i 1704103200\n",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("PushList", mock.Anything, []storage.Record{
+					{Name: "cpu_usage", Value: metrics.Gauge(0.5), Labels: metrics.Labels{"host": "a"}, Timestamp: ts},
+					{Name: "cpu_requests", Value: metrics.Counter(3), Labels: metrics.Labels{"host": "a"}, Timestamp: ts},
+				}).Return([]storage.Record{}, nil)
+			},
+			want: result{code: http.StatusNoContent},
+		},
+		{
+			name:    "Should push points of the same series separately in time order",
+			path:    "/write?precision=s",
+			payload: "cpu usage=0.7 1704103260\ncpu usage=0.5 1704103200\nmem used=1 1704103200\n",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("PushList", mock.Anything, []storage.Record{
+					{Name: "cpu_usage", Value: metrics.Gauge(0.5), Timestamp: ts},
+					{Name: "mem_used", Value: metrics.Gauge(1), Timestamp: ts},
+				}).Return([]storage.Record{}, nil).Once()
+				m.On("PushList", mock.Anything, []storage.Record{
+					{Name: "cpu_usage", Value: metrics.Gauge(0.7), Timestamp: ts.Add(time.Minute)},
+				}).Return([]storage.Record{}, nil).Once()
+			},
+			want: result{code: http.StatusNoContent},
+		},
+		{
+			name:    "Should keep valid lines and report bad ones",
+			path:    "/write?precision=s",
+			payload: "cpu usage=0.5 1704103200\ncpu usage=oops\ncpu\n",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("PushList", mock.Anything, []storage.Record{
+					{Name: "cpu_usage", Value: metrics.Gauge(0.5), Timestamp: ts},
+				}).Return([]storage.Record{}, nil)
+			},
+			want: result{code: http.StatusBadRequest, body: &InfluxWriteResponse{
+				Error: "partial write: 2 lines rejected",
+				Lines: []InfluxLineError{
+					{Line: 2, Error: `metric value is invalid: field "usage"`},
+					{Line: 3, Error: "metrics batch is malformed: expected measurement, fields and optional timestamp"},
+				},
+				Stored: 1,
+			}},
+		},
+		{
+			name:    "Should not push when all lines are bad",
+			path:    "/write",
+			payload: "cpu\n",
+			want: result{code: http.StatusBadRequest, body: &InfluxWriteResponse{
+				Error: "partial write: 1 lines rejected",
+				Lines: []InfluxLineError{
+					{Line: 1, Error: "metrics batch is malformed: expected measurement, fields and optional timestamp"},
+				},
+			}},
+		},
+		{
+			name:    "Should fail on unknown precision",
+			path:    "/write?precision=days",
+			payload: "cpu usage=1\n",
+			want:    result{code: http.StatusBadRequest},
+		},
+		{
+			name:    "Should fail on service error",
+			path:    "/write",
+			payload: "cpu usage=1\n",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("PushList", mock.Anything, mock.Anything).Return([]storage.Record{}, entities.ErrUnexpected)
+			},
+			want: result{code: http.StatusInternalServerError},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, sm, _ := createMetricTestBackend()
+
+			if tt.mock != nil {
+				tt.mock(sm)
+			}
+
+			code, _, body := testRequest(t, router, http.MethodPost, tt.path, []byte(tt.payload))
+			require.Equal(t, tt.want.code, code)
+
+			if tt.want.body != nil {
+				resp := new(InfluxWriteResponse)
+				require.NoError(t, json.Unmarshal(body, resp))
+				require.Equal(t, tt.want.body, resp)
+			}
+
+			sm.AssertExpectations(t)
+		})
+	}
+}
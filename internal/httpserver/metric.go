@@ -6,10 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/internal/otlp"
 	"github.com/ex0rcist/metflix/internal/profiler"
 	"github.com/ex0rcist/metflix/internal/services"
 	"github.com/ex0rcist/metflix/internal/storage"
@@ -17,14 +22,25 @@ import (
 	"github.com/ex0rcist/metflix/pkg/metrics"
 )
 
+// Upper limit for decoded body of bulk ingestion requests.
+const maxIngestSize = 32 << 20
+
 type MetricResource struct {
 	metricService services.MetricProvider
+	cumulative    *cumulativeCounters
+	otlp          *otlp.Translator
+	streams       *streamsCloser
+	heartbeat     time.Duration
 }
 
-// Constructor.
-func NewMetricResource(metricService services.MetricProvider) *MetricResource {
+// Constructor. OTLP translator keeps cumulative state, so it must be shared with other OTLP transports.
+func NewMetricResource(metricService services.MetricProvider, otlpTranslator *otlp.Translator) *MetricResource {
 	return &MetricResource{
 		metricService: metricService,
+		cumulative:    newCumulativeCounters(),
+		otlp:          otlpTranslator,
+		streams:       newStreamsCloser(),
+		heartbeat:     defaultStreamHeartbeat,
 	}
 }
 
@@ -40,14 +56,24 @@ func writeErrorResponse(ctx context.Context, w http.ResponseWriter, code int, er
 // @Summary Yet another homepage
 // @ID homepage
 // @Produce text/html
+// @Param name query string false "Show only metrics with given name."
+// @Param kind query string false "Show only metrics of given type."
+// @Param label query []string false "Show only metrics having label, in `key=value` format." collectionFormat(multi)
 // @Success 200 {string} string
+// @Failure 400 {string} string http.StatusBadRequest
 // @Failure 500 {string} string http.StatusInternalServerError
 func (r MetricResource) Homepage(rw http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
 	body := fmt.Sprintln("mainpage here.")
 
-	records, err := r.metricService.List(ctx)
+	filter, err := parseListFilter(req)
+	if err != nil {
+		writeErrorResponse(ctx, rw, errToStatus(err), err)
+		return
+	}
+
+	records, err := r.metricService.List(ctx, filter)
 	if err != nil {
 		writeErrorResponse(ctx, rw, errToStatus(err), err)
 		return
@@ -56,7 +82,7 @@ func (r MetricResource) Homepage(rw http.ResponseWriter, req *http.Request) {
 		body += fmt.Sprintln("metrics list:")
 
 		for _, record := range records {
-			body += fmt.Sprintf("%s => %s: %v\n", record.Name, record.Value.Kind(), record.Value)
+			body += fmt.Sprintf("%s%s => %s: %v\n", record.Name, record.Labels, record.Value.Kind(), record.Value)
 		}
 	}
 
@@ -74,9 +100,9 @@ func (r MetricResource) Homepage(rw http.ResponseWriter, req *http.Request) {
 // @Summary Push metric data.
 // @ID metrics_update
 // @Produce plain
-// @Param type path string true "Metrics type (e.g. `counter`, `gauge`)."
+// @Param type path string true "Metrics type (e.g. `counter`, `gauge`, `histogram`)."
 // @Param name path string true "Metrics name."
-// @Param value path string true "Metrics value, must be convertable to `int64` or `float64`."
+// @Param value path string true "Metrics value, must be convertable to `int64` or `float64`. Histogram value is a single observation."
 // @Success 200 {string} string
 // @Failure 400 {string} string http.StatusBadRequest
 // @Failure 500 {string} string http.StatusInternalServerError
@@ -107,6 +133,25 @@ func (r MetricResource) UpdateMetric(rw http.ResponseWriter, req *http.Request)
 		}
 
 		mex.Value = &value
+	case metrics.KindHistogram:
+		value, err := metrics.ToGauge(rawValue)
+		if err != nil {
+			writeErrorResponse(ctx, rw, errToStatus(err), err)
+			return
+		}
+
+		// histogram would silently ignore it
+		if math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) {
+			err = entities.ErrMetricInvalidValue
+			writeErrorResponse(ctx, rw, errToStatus(err), err)
+			return
+		}
+
+		// single observation with default buckets
+		histogram := metrics.NewHistogram()
+		histogram.Observe(float64(value))
+
+		mex.Histogram = &histogram
 	}
 
 	record, err := toRecord(&mex)
@@ -117,7 +162,7 @@ func (r MetricResource) UpdateMetric(rw http.ResponseWriter, req *http.Request)
 
 	newRecord, err := r.metricService.Push(ctx, record)
 	if err != nil {
-		writeErrorResponse(ctx, rw, http.StatusInternalServerError, err)
+		writeErrorResponse(ctx, rw, errToStatus(err), err)
 		return
 	}
 
@@ -160,7 +205,7 @@ func (r MetricResource) UpdateMetricJSON(rw http.ResponseWriter, req *http.Reque
 
 	newRecord, err := r.metricService.Push(ctx, record)
 	if err != nil {
-		writeErrorResponse(ctx, rw, http.StatusInternalServerError, err)
+		writeErrorResponse(ctx, rw, errToStatus(err), err)
 		return
 	}
 
@@ -203,7 +248,7 @@ func (r MetricResource) UpdateMetricsBatch(rw http.ResponseWriter, req *http.Req
 
 	recorded, err := r.metricService.PushList(ctx, records)
 	if err != nil {
-		writeErrorResponse(ctx, rw, http.StatusInternalServerError, err)
+		writeErrorResponse(ctx, rw, errToStatus(err), err)
 		return
 	}
 
@@ -229,7 +274,7 @@ func (r MetricResource) UpdateMetricsBatch(rw http.ResponseWriter, req *http.Req
 // @Summary Get metric's value as string
 // @ID metrics_info
 // @Produce plain
-// @Param type path string true "Metrics type (e.g. `counter`, `gauge`)."
+// @Param type path string true "Metrics type (e.g. `counter`, `gauge`, `histogram`)."
 // @Param name path string true "Metrics name."
 // @Success 200 {string} string
 // @Failure 400 {string} string http.StatusBadRequest
@@ -247,7 +292,7 @@ func (r MetricResource) GetMetric(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	var record storage.Record
-	record, err := r.metricService.Get(ctx, metricName, metricKind)
+	record, err := r.metricService.Get(ctx, metricName, metricKind, nil)
 	if err != nil {
 		writeErrorResponse(ctx, rw, errToStatus(err), err)
 		return
@@ -271,7 +316,7 @@ func (r MetricResource) GetMetric(rw http.ResponseWriter, req *http.Request) {
 // @ID metrics_json_info
 // @Accept  json
 // @Produce json
-// @Param request body metrics.MetricExchange true "Request parameters: `id` and `type` are required."
+// @Param request body metrics.MetricExchange true "Request parameters: `id` and `type` are required, `labels` select labeled series."
 // @Success 200 {object} metrics.MetricExchange
 // @Failure 400 {string} string http.StatusBadRequest
 // @Failure 404 {string} string http.StatusNotFound
@@ -290,7 +335,12 @@ func (r MetricResource) GetMetricJSON(rw http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	record, err := r.metricService.Get(ctx, mex.ID, mex.MType)
+	if err := validators.ValidateLabels(mex.Labels); err != nil {
+		writeErrorResponse(ctx, rw, errToStatus(err), err)
+		return
+	}
+
+	record, err := r.metricService.Get(ctx, mex.ID, mex.MType, mex.Labels)
 	if err != nil {
 		writeErrorResponse(ctx, rw, errToStatus(err), err)
 		return
@@ -334,18 +384,61 @@ func parseJSONMetricsList(r *http.Request) ([]storage.Record, error) {
 	return records, nil
 }
 
+func parseListFilter(r *http.Request) (storage.ListFilter, error) {
+	query := r.URL.Query()
+
+	filter := storage.ListFilter{
+		Name: query.Get("name"),
+		Kind: query.Get("kind"),
+	}
+
+	labels, err := parseLabelsQuery(query)
+	if err != nil {
+		return filter, err
+	}
+
+	filter.Labels = labels
+
+	return filter, nil
+}
+
+// Parse repeated label=key=value query params.
+func parseLabelsQuery(query url.Values) (metrics.Labels, error) {
+	var labels metrics.Labels
+
+	for _, pair := range query["label"] {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, entities.ErrMetricInvalidLabel
+		}
+
+		if labels == nil {
+			labels = make(metrics.Labels)
+		}
+
+		labels[name] = value
+	}
+
+	if err := validators.ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
 func errToStatus(err error) int {
-	switch err {
-	case entities.ErrRecordNotFound, entities.ErrMetricMissingName:
+	switch {
+	case errors.Is(err, entities.ErrRecordNotFound), errors.Is(err, entities.ErrMetricMissingName):
 		return http.StatusNotFound
 	case
-		entities.ErrMetricUnknown, entities.ErrMetricInvalidValue,
-		entities.ErrMetricInvalidName, entities.ErrMetricLongName,
-		entities.ErrMetricMissingValue:
+		errors.Is(err, entities.ErrMetricUnknown), errors.Is(err, entities.ErrMetricInvalidValue),
+		errors.Is(err, entities.ErrMetricInvalidName), errors.Is(err, entities.ErrMetricLongName),
+		errors.Is(err, entities.ErrMetricMissingValue), errors.Is(err, entities.ErrHistogramBucketsMismatch),
+		errors.Is(err, entities.ErrMetricInvalidLabel), errors.Is(err, entities.ErrRangeInvalid),
+		errors.Is(err, entities.ErrRangeUnknownAggregation), errors.Is(err, entities.ErrRangeUnsupportedKind),
+		errors.Is(err, entities.ErrMetricBatchMalformed):
 
 		return http.StatusBadRequest
-	case entities.ErrUnexpected:
-		return http.StatusInternalServerError
 	default:
 		return http.StatusInternalServerError
 	}
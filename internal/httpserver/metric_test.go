@@ -10,6 +10,7 @@ import (
 
 	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/internal/otlp"
 	"github.com/ex0rcist/metflix/internal/services"
 	"github.com/ex0rcist/metflix/internal/storage"
 	"github.com/ex0rcist/metflix/pkg/metrics"
@@ -29,6 +30,7 @@ func TestHomepage(t *testing.T) {
 	tests := []struct {
 		name    string
 		path    string
+		filter  storage.ListFilter
 		metrics []storage.Record
 		want    result
 	}{
@@ -46,11 +48,25 @@ func TestHomepage(t *testing.T) {
 			},
 			want: result{code: http.StatusOK, contains: []string{"mainpage here.", "metrics list", "test1 => counter: 1", "test2 => gauge: 2.3"}},
 		},
+		{
+			name:   "filtered by name and labels",
+			path:   "/?name=test1&kind=counter&label=host=a&label=env=prod",
+			filter: storage.ListFilter{Name: "test1", Kind: metrics.KindCounter, Labels: metrics.Labels{"host": "a", "env": "prod"}},
+			metrics: []storage.Record{
+				{Name: "test1", Value: metrics.Counter(1), Labels: metrics.Labels{"host": "a", "env": "prod"}},
+			},
+			want: result{code: http.StatusOK, contains: []string{`test1{env="prod",host="a"} => counter: 1`}},
+		},
+		{
+			name: "invalid label filter",
+			path: "/?label=host",
+			want: result{code: http.StatusBadRequest},
+		},
 	}
 
 	for _, tt := range tests {
 		router, sm, _ := createMetricTestBackend()
-		sm.On("List").Return(tt.metrics, nil)
+		sm.On("List", tt.filter).Return(tt.metrics, nil)
 
 		t.Run(tt.name, func(t *testing.T) {
 			code, _, body := testRequest(t, router, http.MethodGet, tt.path, nil)
@@ -87,7 +103,7 @@ func TestUpdateMetric(t *testing.T) {
 			name: "Should push counter",
 			path: "/update/counter/test/42",
 			mock: func(m *services.MetricServiceMock) {
-				m.On("Get", "test", metrics.KindCounter).Return(storage.Record{}, nil)
+				m.On("Get", "test", metrics.KindCounter, metrics.Labels(nil)).Return(storage.Record{}, nil)
 				m.On("Push", mock.AnythingOfType("Record")).Return(storage.Record{Name: "test", Value: metrics.Counter(42)}, nil)
 			},
 			want: result{code: http.StatusOK, body: "42"},
@@ -96,7 +112,7 @@ func TestUpdateMetric(t *testing.T) {
 			name: "Should push counter with existing value",
 			path: "/update/counter/test/42",
 			mock: func(m *services.MetricServiceMock) {
-				m.On("Get", "test", metrics.KindCounter).Return(storage.Record{Name: "test", Value: metrics.Counter(21)}, nil)
+				m.On("Get", "test", metrics.KindCounter, metrics.Labels(nil)).Return(storage.Record{Name: "test", Value: metrics.Counter(21)}, nil)
 				m.On("Push", mock.AnythingOfType("Record")).Return(storage.Record{Name: "test", Value: metrics.Counter(42)}, nil)
 			},
 			want: result{code: http.StatusOK, body: "42"},
@@ -109,6 +125,32 @@ func TestUpdateMetric(t *testing.T) {
 			},
 			want: result{code: http.StatusOK, body: "42.42"},
 		},
+		{
+			name: "Should push histogram observation",
+			path: "/update/histogram/test/0.3",
+			mock: func(m *services.MetricServiceMock) {
+				expected := metrics.NewHistogram()
+				expected.Observe(0.3)
+
+				m.On("Push", storage.Record{Name: "test", Value: expected}).Return(storage.Record{Name: "test", Value: expected}, nil)
+			},
+			want: result{code: http.StatusOK, body: `{"bounds":[0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5,5,10],"counts":[0,0,0,0,0,0,1,0,0,0,0,0],"count":1,"sum":0.3}`},
+		},
+		{
+			name: "Should fail on histogram with invalid value",
+			path: "/update/histogram/test/x",
+			want: result{code: http.StatusBadRequest},
+		},
+		{
+			name: "Should fail on histogram with infinite value",
+			path: "/update/histogram/test/Inf",
+			want: result{code: http.StatusBadRequest},
+		},
+		{
+			name: "Should fail on histogram with NaN value",
+			path: "/update/histogram/test/NaN",
+			want: result{code: http.StatusBadRequest},
+		},
 		{
 			name: "Should fail on invalid kind",
 			path: "/update/xxx/test/1",
@@ -172,7 +214,7 @@ func TestUpdateJSONMetric(t *testing.T) {
 			name: "Should push counter",
 			mex:  metrics.NewUpdateCounterMex("test", 42),
 			mock: func(m *services.MetricServiceMock) {
-				m.On("Get", "test", metrics.KindCounter).Return(storage.Record{}, nil)
+				m.On("Get", "test", metrics.KindCounter, metrics.Labels(nil)).Return(storage.Record{}, nil)
 				m.On("Push", mock.AnythingOfType("Record")).Return(storage.Record{Name: "test", Value: metrics.Counter(42)}, nil)
 			},
 			want: result{code: http.StatusOK},
@@ -185,6 +227,27 @@ func TestUpdateJSONMetric(t *testing.T) {
 			},
 			want: result{code: http.StatusOK},
 		},
+		{
+			name: "Should push histogram",
+			mex:  metrics.NewUpdateHistogramMex("test", metrics.NewHistogram(1, 2)),
+			mock: func(m *services.MetricServiceMock) {
+				m.On("Push", mock.AnythingOfType("Record")).Return(storage.Record{Name: "test", Value: metrics.NewHistogram(1, 2)}, nil)
+			},
+			want: result{code: http.StatusOK},
+		},
+		{
+			name: "Should fail on inconsistent histogram",
+			mex:  metrics.NewUpdateHistogramMex("test", metrics.Histogram{Bounds: []float64{1}, Counts: []uint64{1}}),
+			want: result{code: http.StatusBadRequest},
+		},
+		{
+			name: "Should fail on histogram with other buckets",
+			mex:  metrics.NewUpdateHistogramMex("test", metrics.NewHistogram(1, 2)),
+			mock: func(m *services.MetricServiceMock) {
+				m.On("Push", mock.AnythingOfType("Record")).Return(storage.Record{}, entities.ErrHistogramBucketsMismatch)
+			},
+			want: result{code: http.StatusBadRequest},
+		},
 		{
 			name: "Should fail on unknown metric kind",
 			mex:  metrics.MetricExchange{ID: "42", MType: "test"},
@@ -336,7 +399,7 @@ func TestGetMetric(t *testing.T) {
 			name: "get counter",
 			path: "/value/counter/test",
 			mock: func(m *services.MetricServiceMock) {
-				m.On("Get", "test", metrics.KindCounter).Return(storage.Record{Name: "test", Value: metrics.Counter(42)}, nil)
+				m.On("Get", "test", metrics.KindCounter, metrics.Labels(nil)).Return(storage.Record{Name: "test", Value: metrics.Counter(42)}, nil)
 			},
 			want: result{code: http.StatusOK, body: "42"},
 		},
@@ -344,7 +407,7 @@ func TestGetMetric(t *testing.T) {
 			name: "get gauge",
 			path: "/value/gauge/test",
 			mock: func(m *services.MetricServiceMock) {
-				m.On("Get", "test", metrics.KindGauge).Return(storage.Record{Name: "test", Value: metrics.Gauge(42.42)}, nil)
+				m.On("Get", "test", metrics.KindGauge, metrics.Labels(nil)).Return(storage.Record{Name: "test", Value: metrics.Gauge(42.42)}, nil)
 			},
 			want: result{code: http.StatusOK, body: "42.42"},
 		},
@@ -402,7 +465,7 @@ func TestGetMetricJSON(t *testing.T) {
 			name: "Should get counter",
 			mex:  metrics.NewGetCounterMex("test"),
 			mock: func(m *services.MetricServiceMock) {
-				m.On("Get", "test", metrics.KindCounter).Return(storage.Record{Name: "test", Value: metrics.Counter(42)}, nil)
+				m.On("Get", "test", metrics.KindCounter, metrics.Labels(nil)).Return(storage.Record{Name: "test", Value: metrics.Counter(42)}, nil)
 			},
 			expected: result{
 				code: http.StatusOK,
@@ -413,13 +476,29 @@ func TestGetMetricJSON(t *testing.T) {
 			name: "Should get gauge",
 			mex:  metrics.NewGetGaugeMex("test"),
 			mock: func(m *services.MetricServiceMock) {
-				m.On("Get", "test", metrics.KindGauge).Return(storage.Record{Name: "test", Value: metrics.Gauge(42.42)}, nil)
+				m.On("Get", "test", metrics.KindGauge, metrics.Labels(nil)).Return(storage.Record{Name: "test", Value: metrics.Gauge(42.42)}, nil)
 			},
 			expected: result{
 				code: http.StatusOK,
 				body: metrics.NewUpdateGaugeMex("test", 42.42),
 			},
 		},
+		{
+			name: "Should get labeled gauge",
+			mex:  withLabels(metrics.NewGetGaugeMex("test"), metrics.Labels{"host": "a"}),
+			mock: func(m *services.MetricServiceMock) {
+				m.On("Get", "test", metrics.KindGauge, metrics.Labels{"host": "a"}).Return(storage.Record{Name: "test", Value: metrics.Gauge(42.42), Labels: metrics.Labels{"host": "a"}}, nil)
+			},
+			expected: result{
+				code: http.StatusOK,
+				body: withLabels(metrics.NewUpdateGaugeMex("test", 42.42), metrics.Labels{"host": "a"}),
+			},
+		},
+		{
+			name:     "Should fail on invalid labels",
+			mex:      metrics.MetricExchange{ID: "test", MType: metrics.KindGauge, Labels: metrics.Labels{"bad label": "a"}},
+			expected: result{code: http.StatusBadRequest},
+		},
 		{
 			name: "Should fail on unknown metric kind",
 			mex:  metrics.MetricExchange{ID: "test", MType: "unknown"},
@@ -431,7 +510,7 @@ func TestGetMetricJSON(t *testing.T) {
 			name: "Should fail on unknown counter",
 			mex:  metrics.NewGetCounterMex("test"),
 			mock: func(m *services.MetricServiceMock) {
-				m.On("Get", "test", metrics.KindCounter).Return(storage.Record{}, entities.ErrRecordNotFound)
+				m.On("Get", "test", metrics.KindCounter, metrics.Labels(nil)).Return(storage.Record{}, entities.ErrRecordNotFound)
 			},
 			expected: result{
 				code: http.StatusNotFound,
@@ -441,7 +520,7 @@ func TestGetMetricJSON(t *testing.T) {
 			name: "Should fail on unknown gauge",
 			mex:  metrics.NewGetGaugeMex("test"),
 			mock: func(m *services.MetricServiceMock) {
-				m.On("Get", "test", metrics.KindGauge).Return(storage.Record{}, entities.ErrRecordNotFound)
+				m.On("Get", "test", metrics.KindGauge, metrics.Labels(nil)).Return(storage.Record{}, entities.ErrRecordNotFound)
 			},
 			expected: result{
 				code: http.StatusNotFound,
@@ -465,7 +544,7 @@ func TestGetMetricJSON(t *testing.T) {
 			name: "Should fail on broken service",
 			mex:  metrics.NewGetGaugeMex("test"),
 			mock: func(m *services.MetricServiceMock) {
-				m.On("Get", "test", metrics.KindGauge).Return(storage.Record{}, entities.ErrUnexpected)
+				m.On("Get", "test", metrics.KindGauge, metrics.Labels(nil)).Return(storage.Record{}, entities.ErrUnexpected)
 			},
 			expected: result{
 				code: http.StatusInternalServerError,
@@ -534,7 +613,7 @@ func createMetricTestBackend() (http.Handler, *services.MetricServiceMock, *serv
 	healthMock := NewHealthResource(healthServiceMock)
 
 	metricServiceMock := &services.MetricServiceMock{}
-	metricMock := NewMetricResource(metricServiceMock)
+	metricMock := NewMetricResource(metricServiceMock, otlp.NewTranslator(metricServiceMock))
 
 	handler := NewBackend(
 		WithHealthResource(healthMock),
@@ -543,3 +622,8 @@ func createMetricTestBackend() (http.Handler, *services.MetricServiceMock, *serv
 
 	return handler, metricServiceMock, healthServiceMock
 }
+
+func withLabels(mex metrics.MetricExchange, labels metrics.Labels) metrics.MetricExchange {
+	mex.Labels = labels
+	return mex
+}
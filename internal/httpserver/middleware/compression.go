@@ -12,6 +12,15 @@ import (
 
 // Decompress request if possible
 func DecompressRequest(next http.Handler) http.Handler {
+	return decompressRequest(next, compression.EncodingGzip)
+}
+
+// Decompress request encoded with gzip or snappy, for Prometheus remote write
+func DecompressSnappyRequest(next http.Handler) http.Handler {
+	return decompressRequest(next, compression.EncodingGzip, compression.EncodingSnappy)
+}
+
+func decompressRequest(next http.Handler, encodings ...string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
@@ -21,7 +30,7 @@ func DecompressRequest(next http.Handler) http.Handler {
 			return
 		}
 
-		decompressor := compression.NewDecompressor(r, ctx)
+		decompressor := compression.NewDecompressorWith(r, ctx, encodings...)
 		defer decompressor.Close()
 
 		err := decompressor.Decompress()
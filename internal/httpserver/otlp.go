@@ -0,0 +1,86 @@
+package httpserver
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/logging"
+)
+
+const (
+	otlpProtobufContentType = "application/x-protobuf"
+	otlpJSONContentType     = "application/json"
+)
+
+// OTLPMetrics godoc
+// @Tags Metrics
+// @Router /v1/metrics [post]
+// @Summary Receive metrics via OpenTelemetry protocol (OTLP/HTTP)
+// @ID metrics_otlp
+// @Accept application/x-protobuf,json
+// @Produce application/x-protobuf,json
+// @Param data body string true "ExportMetricsServiceRequest encoded as protobuf or JSON."
+// @Success 200 {string} string "ExportMetricsServiceResponse, rejected data points are reported via partial_success."
+// @Failure 400 {string} string http.StatusBadRequest
+// @Failure 415 {string} string http.StatusUnsupportedMediaType
+// @Failure 500 {string} string http.StatusInternalServerError
+func (r MetricResource) OTLPMetrics(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	contentType := otlpProtobufContentType
+	if header := req.Header.Get("Content-Type"); len(header) > 0 {
+		contentType, _, _ = mime.ParseMediaType(header)
+	}
+
+	var (
+		unmarshal func([]byte, proto.Message) error
+		marshal   func(proto.Message) ([]byte, error)
+	)
+
+	switch contentType {
+	case otlpProtobufContentType:
+		unmarshal, marshal = proto.Unmarshal, proto.Marshal
+	case otlpJSONContentType:
+		unmarshal, marshal = protojson.Unmarshal, protojson.Marshal
+	default:
+		writeErrorResponse(ctx, rw, http.StatusUnsupportedMediaType, entities.ErrEncodingUnsupported)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxIngestSize+1))
+	if err != nil {
+		writeErrorResponse(ctx, rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	exportReq := new(colmetricspb.ExportMetricsServiceRequest)
+	if len(body) > maxIngestSize || unmarshal(body, exportReq) != nil {
+		writeErrorResponse(ctx, rw, http.StatusBadRequest, entities.ErrMetricBatchMalformed)
+		return
+	}
+
+	exportResp, err := r.otlp.Export(ctx, exportReq)
+	if err != nil {
+		writeErrorResponse(ctx, rw, errToStatus(err), err)
+		return
+	}
+
+	data, err := marshal(exportResp)
+	if err != nil {
+		writeErrorResponse(ctx, rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", contentType)
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err := rw.Write(data); err != nil {
+		logging.LogErrorCtx(ctx, err)
+	}
+}
@@ -0,0 +1,116 @@
+package httpserver
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+func otlpRequest(t *testing.T, router http.Handler, contentType string, payload []byte) (int, string, []byte) {
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/metrics", bytes.NewReader(payload))
+	require.NoError(t, err)
+
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp.StatusCode, resp.Header.Get("Content-Type"), body
+}
+
+func otlpGaugeRequest(name string, value float64) *colmetricspb.ExportMetricsServiceRequest {
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: []*metricspb.Metric{{
+				Name: name,
+				Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{{
+					Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+				}}}},
+			}}}},
+		}},
+	}
+}
+
+func TestOTLPMetrics(t *testing.T) {
+	pushed := []storage.Record{{Name: "temperature", Value: metrics.Gauge(36.6)}}
+
+	protoPayload, err := proto.Marshal(otlpGaugeRequest("temperature", 36.6))
+	require.NoError(t, err)
+
+	jsonPayload, err := protojson.Marshal(otlpGaugeRequest("temperature", 36.6))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		contentType string
+		payload     []byte
+		unmarshal   func([]byte, proto.Message) error
+	}{
+		{name: "protobuf", contentType: "application/x-protobuf", payload: protoPayload, unmarshal: proto.Unmarshal},
+		{name: "json", contentType: "application/json; charset=utf-8", payload: jsonPayload, unmarshal: protojson.Unmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, sm, _ := createMetricTestBackend()
+			sm.On("PushList", mock.Anything, pushed).Return(pushed, nil)
+
+			code, contentType, body := otlpRequest(t, router, tt.contentType, tt.payload)
+			require.Equal(t, http.StatusOK, code)
+			require.Contains(t, tt.contentType, contentType)
+
+			resp := new(colmetricspb.ExportMetricsServiceResponse)
+			require.NoError(t, tt.unmarshal(body, resp))
+			require.Nil(t, resp.GetPartialSuccess())
+
+			sm.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOTLPMetrics_Errors(t *testing.T) {
+	payload, err := proto.Marshal(otlpGaugeRequest("temperature", 1))
+	require.NoError(t, err)
+
+	t.Run("unsupported content type", func(t *testing.T) {
+		router, _, _ := createMetricTestBackend()
+
+		code, _, _ := otlpRequest(t, router, "text/plain", payload)
+		require.Equal(t, http.StatusUnsupportedMediaType, code)
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		router, _, _ := createMetricTestBackend()
+
+		code, _, _ := otlpRequest(t, router, "application/x-protobuf", []byte("not a protobuf"))
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		router, sm, _ := createMetricTestBackend()
+		sm.On("PushList", mock.Anything, mock.Anything).Return([]storage.Record{}, entities.ErrUnexpected)
+
+		code, _, _ := otlpRequest(t, router, "application/x-protobuf", payload)
+		require.Equal(t, http.StatusInternalServerError, code)
+	})
+}
@@ -0,0 +1,182 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+// Content type of Prometheus text exposition format.
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// PrometheusMetrics godoc
+// @Tags Metrics
+// @Router /metrics [get]
+// @Summary Get all metrics in Prometheus text format
+// @ID metrics_prometheus
+// @Produce plain
+// @Param name query string false "Export only metrics with given name."
+// @Param kind query string false "Export only metrics of given type."
+// @Param label query []string false "Export only metrics having label, in `key=value` format." collectionFormat(multi)
+// @Success 200 {string} string
+// @Failure 400 {string} string http.StatusBadRequest
+// @Failure 500 {string} string http.StatusInternalServerError
+func (r MetricResource) PrometheusMetrics(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	filter, err := parseListFilter(req)
+	if err != nil {
+		writeErrorResponse(ctx, rw, errToStatus(err), err)
+		return
+	}
+
+	records, err := r.metricService.List(ctx, filter)
+	if err != nil {
+		writeErrorResponse(ctx, rw, errToStatus(err), err)
+		return
+	}
+
+	body := new(bytes.Buffer)
+
+	for _, family := range prometheusFamilies(ctx, records) {
+		if md, ok := r.metricService.Metadata(family.records[0].Name); ok && len(md.Help) > 0 {
+			fmt.Fprintf(body, "# HELP %s %s\n", family.name, helpEscaper.Replace(md.Help))
+		}
+
+		fmt.Fprintf(body, "# TYPE %s %s\n", family.name, family.kind)
+
+		for _, record := range family.records {
+			writePrometheusSeries(body, family.name, record)
+		}
+	}
+
+	rw.Header().Set("Content-Type", prometheusContentType)
+
+	if _, err = rw.Write(body.Bytes()); err != nil {
+		logging.LogErrorCtx(ctx, err)
+	}
+}
+
+// Series exported under one metric name.
+type prometheusFamily struct {
+	name    string
+	kind    string
+	records []storage.Record
+}
+
+// Group records by exported name in order of appearance. Prometheus rejects scrape declaring the name twice,
+// so series of other kind with the same name (allowed, as kind is part of record ID) are skipped.
+func prometheusFamilies(ctx context.Context, records []storage.Record) []*prometheusFamily {
+	families := make([]*prometheusFamily, 0)
+	byName := make(map[string]*prometheusFamily)
+	skipped := make(map[string]struct{})
+
+	for _, record := range records {
+		name := prometheusName(record.Name)
+
+		family, ok := byName[name]
+		if !ok {
+			family = &prometheusFamily{name: name, kind: record.Value.Kind()}
+			byName[name] = family
+			families = append(families, family)
+		}
+
+		if kind := record.Value.Kind(); kind != family.kind {
+			if _, ok := skipped[name+" "+kind]; !ok {
+				skipped[name+" "+kind] = struct{}{}
+				logging.LogWarnCtx(ctx, fmt.Sprintf("skipping %s series of %s: already exported as %s", kind, name, family.kind))
+			}
+
+			continue
+		}
+
+		family.records = append(family.records, record)
+	}
+
+	return families
+}
+
+func writePrometheusSeries(body *bytes.Buffer, name string, record storage.Record) {
+	switch v := record.Value.(type) {
+	case metrics.Counter:
+		fmt.Fprintf(body, "%s%s %d\n", name, prometheusLabels(record.Labels, "", ""), int64(v))
+	case metrics.Gauge:
+		fmt.Fprintf(body, "%s%s %s\n", name, prometheusLabels(record.Labels, "", ""), prometheusFloat(float64(v)))
+	case metrics.Histogram:
+		var cumulative uint64
+
+		for i, bound := range v.Bounds {
+			cumulative += v.Counts[i]
+			fmt.Fprintf(body, "%s_bucket%s %d\n", name, prometheusLabels(record.Labels, "le", prometheusFloat(bound)), cumulative)
+		}
+
+		fmt.Fprintf(body, "%s_bucket%s %d\n", name, prometheusLabels(record.Labels, "le", "+Inf"), v.Count)
+		fmt.Fprintf(body, "%s_sum%s %s\n", name, prometheusLabels(record.Labels, "", ""), prometheusFloat(v.Sum))
+		fmt.Fprintf(body, "%s_count%s %d\n", name, prometheusLabels(record.Labels, "", ""), v.Count)
+	}
+}
+
+// Render labels as {k="v",...}, optionally appending one extra label (e.g. histogram "le").
+func prometheusLabels(labels metrics.Labels, extraKey, extraValue string) string {
+	if len(labels) == 0 && len(extraKey) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels)+1)
+	for _, k := range labels.Keys() {
+		pairs = append(pairs, k+`="`+labelValueEscaper.Replace(labels[k])+`"`)
+	}
+
+	if len(extraKey) > 0 {
+		pairs = append(pairs, extraKey+`="`+extraValue+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Metric names allow [a-zA-Z0-9_:] only and must not start with digit.
+func prometheusName(name string) string {
+	var b strings.Builder
+
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+
+	return b.String()
+}
+
+func prometheusFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
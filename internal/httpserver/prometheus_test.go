@@ -0,0 +1,134 @@
+package httpserver
+
+import (
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	histogram := metrics.NewHistogram(0.1, 1)
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(3)
+
+	type result struct {
+		code int
+		body string
+	}
+
+	tests := []struct {
+		name string
+		path string
+		mock func(m *services.MetricServiceMock)
+		want result
+	}{
+		{
+			name: "Should render all kinds",
+			path: "/metrics",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("List", storage.ListFilter{}).Return([]storage.Record{
+					{Name: "Alloc", Value: metrics.Gauge(1.5), Labels: metrics.Labels{"host": "a"}},
+					{Name: "Alloc", Value: metrics.Gauge(2), Labels: metrics.Labels{"host": "b\"c"}},
+					{Name: "PollCount", Value: metrics.Counter(42)},
+					{Name: "Latency", Value: histogram},
+					{Name: "cpu.usage-1", Value: metrics.Gauge(math.Inf(1))},
+				}, nil)
+				m.On("Metadata", "Alloc").Return(metrics.Metadata{Help: "Bytes of allocated heap objects."}, true)
+				m.On("Metadata", "PollCount").Return(metrics.Metadata{Help: "Multi\nline"}, true)
+				m.On("Metadata", mock.Anything).Return(metrics.Metadata{}, false)
+			},
+			want: result{code: http.StatusOK, body: `# HELP Alloc Bytes of allocated heap objects.
+# TYPE Alloc gauge
+Alloc{host="a"} 1.5
+Alloc{host="b\"c"} 2
+# HELP PollCount Multi\nline
+# TYPE PollCount counter
+PollCount 42
+# TYPE Latency histogram
+Latency_bucket{le="0.1"} 1
+Latency_bucket{le="1"} 2
+Latency_bucket{le="+Inf"} 3
+Latency_sum 3.55
+Latency_count 3
+# TYPE cpu_usage_1 gauge
+cpu_usage_1 +Inf
+`},
+		},
+		{
+			name: "Should apply filter",
+			path: "/metrics?kind=counter",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("List", storage.ListFilter{Kind: metrics.KindCounter}).Return([]storage.Record{
+					{Name: "PollCount", Value: metrics.Counter(1), Labels: metrics.Labels{"host": "a"}},
+				}, nil)
+				m.On("Metadata", "PollCount").Return(metrics.Metadata{}, false)
+			},
+			want: result{code: http.StatusOK, body: "# TYPE PollCount counter\nPollCount{host=\"a\"} 1\n"},
+		},
+		{
+			name: "Should export one family per name",
+			path: "/metrics",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("List", storage.ListFilter{}).Return([]storage.Record{
+					{Name: "jobs", Value: metrics.Counter(3)},
+					{Name: "jobs", Value: metrics.Gauge(1.5)},
+					{Name: "queue.size", Value: metrics.Gauge(1), Labels: metrics.Labels{"host": "a"}},
+					{Name: "queue_size", Value: metrics.Gauge(2), Labels: metrics.Labels{"host": "b"}},
+					{Name: "queue_size", Value: metrics.Counter(7)},
+				}, nil)
+				m.On("Metadata", mock.Anything).Return(metrics.Metadata{}, false)
+			},
+			want: result{code: http.StatusOK, body: `# TYPE jobs counter
+jobs 3
+# TYPE queue_size gauge
+queue_size{host="a"} 1
+queue_size{host="b"} 2
+`},
+		},
+		{
+			name: "Should render empty list",
+			path: "/metrics",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("List", storage.ListFilter{}).Return([]storage.Record{}, nil)
+			},
+			want: result{code: http.StatusOK, body: ""},
+		},
+		{
+			name: "Should fail on service error",
+			path: "/metrics",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("List", storage.ListFilter{}).Return([]storage.Record{}, entities.ErrUnexpected)
+			},
+			want: result{code: http.StatusInternalServerError},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, sm, _ := createMetricTestBackend()
+			tt.mock(sm)
+
+			code, contentType, body := testRequest(t, router, http.MethodGet, tt.path, nil)
+			require.Equal(t, tt.want.code, code)
+
+			if tt.want.code == http.StatusOK {
+				require.Equal(t, prometheusContentType, contentType)
+				require.Equal(t, tt.want.body, string(body))
+			}
+		})
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	require.Equal(t, "Alloc", prometheusName("Alloc"))
+	require.Equal(t, "cpu_usage_1", prometheusName("cpu.usage-1"))
+	require.Equal(t, "_1m_load", prometheusName("1m_load"))
+}
@@ -0,0 +1,198 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/internal/validators"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+// Range query defaults and limits.
+const (
+	defaultRangeWindow = time.Hour
+	defaultRangeStep   = time.Minute
+	maxRangePoints     = 11000
+)
+
+// Data resolutions available for range queries.
+var rangeResolutions = map[string]time.Duration{
+	"raw": storage.ResolutionRaw,
+	"1m":  storage.ResolutionMinute,
+	"1h":  storage.ResolutionHour,
+}
+
+// Response of range query.
+type QueryRangeResponse struct {
+	Name       string           `json:"name"`
+	Kind       string           `json:"type"`
+	Labels     metrics.Labels   `json:"labels,omitempty"`
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+	Step       float64          `json:"step"`
+	Agg        string           `json:"agg"`
+	Resolution string           `json:"resolution"`
+	Points     []services.Point `json:"points"`
+}
+
+// QueryRange godoc
+// @Tags Metrics
+// @Router /api/v1/query_range [get]
+// @Summary Get history of metric aggregated by steps
+// @ID metrics_query_range
+// @Produce json
+// @Param name query string true "Metrics name."
+// @Param kind query string true "Metrics type (e.g. `counter`, `gauge`)."
+// @Param label query []string false "Series label, in `key=value` format." collectionFormat(multi)
+// @Param from query string false "Range start: unix timestamp or RFC3339, defaults to one hour before `to`."
+// @Param to query string false "Range end: unix timestamp or RFC3339, defaults to now."
+// @Param step query string false "Step width: duration (e.g. `30s`) or number of seconds, defaults to `1m`."
+// @Param agg query string false "Aggregation within step: `avg` (default), `min`, `max`, `sum` or `last`."
+// @Param resolution query string false "Source data: `raw` samples (default), `1m` or `1h` rollups kept by retention."
+// @Success 200 {object} QueryRangeResponse
+// @Failure 400 {string} string http.StatusBadRequest
+// @Failure 500 {string} string http.StatusInternalServerError
+func (r MetricResource) QueryRange(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	resp, step, err := parseRangeQuery(query)
+	if err != nil {
+		writeErrorResponse(ctx, rw, errToStatus(err), err)
+		return
+	}
+
+	resp.Points, err = r.queryPoints(ctx, resp, step)
+	if err != nil {
+		writeErrorResponse(ctx, rw, errToStatus(err), err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		writeErrorResponse(ctx, rw, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func (r MetricResource) queryPoints(ctx context.Context, query *QueryRangeResponse, step time.Duration) ([]services.Point, error) {
+	resolution := rangeResolutions[query.Resolution]
+
+	if resolution == storage.ResolutionRaw {
+		samples, err := r.metricService.Range(ctx, query.Name, query.Kind, query.Labels, query.From, query.To)
+		if err != nil {
+			return nil, err
+		}
+
+		return services.Aggregate(samples, query.From, step, query.Agg)
+	}
+
+	rollups, err := r.metricService.RangeRollups(ctx, query.Name, query.Kind, query.Labels, resolution, query.From, query.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return services.AggregateRollups(rollups, query.From, step, query.Agg)
+}
+
+func parseRangeQuery(query url.Values) (*QueryRangeResponse, time.Duration, error) {
+	resp := &QueryRangeResponse{
+		Name:       query.Get("name"),
+		Kind:       query.Get("kind"),
+		Agg:        query.Get("agg"),
+		Resolution: query.Get("resolution"),
+	}
+
+	if err := validators.ValidateMetric(resp.Name, resp.Kind); err != nil {
+		return nil, 0, err
+	}
+
+	labels, err := parseLabelsQuery(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp.Labels = labels
+
+	if len(resp.Agg) == 0 {
+		resp.Agg = services.AggAvg
+	}
+
+	if len(resp.Resolution) == 0 {
+		resp.Resolution = "raw"
+	}
+
+	if _, ok := rangeResolutions[resp.Resolution]; !ok {
+		return nil, 0, entities.ErrRangeInvalid
+	}
+
+	resp.To = time.Now()
+	if raw := query.Get("to"); len(raw) > 0 {
+		if resp.To, err = parseRangeTime(raw); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	resp.From = resp.To.Add(-defaultRangeWindow)
+	if raw := query.Get("from"); len(raw) > 0 {
+		if resp.From, err = parseRangeTime(raw); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	step := defaultRangeStep
+	if raw := query.Get("step"); len(raw) > 0 {
+		if step, err = parseRangeStep(raw); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if resp.To.Before(resp.From) || resp.To.Sub(resp.From)/step > maxRangePoints {
+		return nil, 0, entities.ErrRangeInvalid
+	}
+
+	resp.Step = step.Seconds()
+
+	return resp, step, nil
+}
+
+// Accepts unix timestamp (with optional fraction) or RFC3339.
+func parseRangeTime(raw string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(raw, 64); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second))).UTC(), nil
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return time.Time{}, entities.ErrRangeInvalid
+	}
+
+	return ts, nil
+}
+
+// Accepts Go duration or number of seconds, must be positive.
+func parseRangeStep(raw string) (time.Duration, error) {
+	step, err := time.ParseDuration(raw)
+	if err != nil {
+		seconds, fErr := strconv.ParseFloat(raw, 64)
+		if fErr != nil {
+			return 0, entities.ErrRangeInvalid
+		}
+
+		step = time.Duration(seconds * float64(time.Second))
+	}
+
+	if step <= 0 {
+		return 0, entities.ErrRangeInvalid
+	}
+
+	return step, nil
+}
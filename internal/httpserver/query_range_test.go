@@ -0,0 +1,178 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryRange(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Minute)
+
+	samples := []storage.Sample{
+		{Timestamp: from, Value: metrics.Gauge(1)},
+		{Timestamp: from.Add(30 * time.Second), Value: metrics.Gauge(3)},
+		{Timestamp: from.Add(2 * time.Minute), Value: metrics.Gauge(10)},
+	}
+
+	rollups := []storage.Rollup{
+		{Timestamp: from, Min: 1, Max: 3, Sum: 4, Count: 2},
+		{Timestamp: from.Add(2 * time.Minute), Min: 10, Max: 10, Sum: 10, Count: 1},
+	}
+
+	type result struct {
+		code int
+		body *QueryRangeResponse
+	}
+
+	tests := []struct {
+		name string
+		path string
+		mock func(m *services.MetricServiceMock)
+		want result
+	}{
+		{
+			name: "Should aggregate with defaults",
+			path: "/api/v1/query_range?name=Alloc&kind=gauge&from=1704103200&to=2024-01-01T10:10:00Z",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("Range", "Alloc", metrics.KindGauge, metrics.Labels(nil), from, to).Return(samples, nil)
+			},
+			want: result{code: http.StatusOK, body: &QueryRangeResponse{
+				Name: "Alloc", Kind: metrics.KindGauge, From: from, To: to, Step: 60, Agg: services.AggAvg, Resolution: "raw",
+				Points: []services.Point{
+					{Timestamp: from, Value: 2},
+					{Timestamp: from.Add(2 * time.Minute), Value: 10},
+				},
+			}},
+		},
+		{
+			name: "Should aggregate labeled series with custom step",
+			path: "/api/v1/query_range?name=Alloc&kind=gauge&label=host=a&from=1704103200&to=1704103800&step=15&agg=max",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("Range", "Alloc", metrics.KindGauge, metrics.Labels{"host": "a"}, from, to).Return(samples, nil)
+			},
+			want: result{code: http.StatusOK, body: &QueryRangeResponse{
+				Name: "Alloc", Kind: metrics.KindGauge, Labels: metrics.Labels{"host": "a"}, From: from, To: to, Step: 15, Agg: services.AggMax, Resolution: "raw",
+				Points: []services.Point{
+					{Timestamp: from, Value: 1},
+					{Timestamp: from.Add(30 * time.Second), Value: 3},
+					{Timestamp: from.Add(2 * time.Minute), Value: 10},
+				},
+			}},
+		},
+		{
+			name: "Should return empty points list",
+			path: "/api/v1/query_range?name=Alloc&kind=gauge&from=1704103200&to=1704103800&step=1m",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("Range", "Alloc", metrics.KindGauge, metrics.Labels(nil), from, to).Return([]storage.Sample{}, nil)
+			},
+			want: result{code: http.StatusOK, body: &QueryRangeResponse{
+				Name: "Alloc", Kind: metrics.KindGauge, From: from, To: to, Step: 60, Agg: services.AggAvg, Resolution: "raw",
+				Points: []services.Point{},
+			}},
+		},
+		{
+			name: "Should aggregate minute rollups",
+			path: "/api/v1/query_range?name=Alloc&kind=gauge&from=1704103200&to=1704103800&step=5m&resolution=1m",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("RangeRollups", "Alloc", metrics.KindGauge, metrics.Labels(nil), storage.ResolutionMinute, from, to).Return(rollups, nil)
+			},
+			want: result{code: http.StatusOK, body: &QueryRangeResponse{
+				Name: "Alloc", Kind: metrics.KindGauge, From: from, To: to, Step: 300, Agg: services.AggAvg, Resolution: "1m",
+				Points: []services.Point{
+					{Timestamp: from, Value: 14.0 / 3},
+				},
+			}},
+		},
+		{
+			name: "Should fail on unknown resolution",
+			path: "/api/v1/query_range?name=Alloc&kind=gauge&resolution=1d",
+			want: result{code: http.StatusBadRequest},
+		},
+		{
+			name: "Should fail on invalid name",
+			path: "/api/v1/query_range?name=inva!id&kind=gauge",
+			want: result{code: http.StatusBadRequest},
+		},
+		{
+			name: "Should fail on unknown kind",
+			path: "/api/v1/query_range?name=Alloc&kind=unknown",
+			want: result{code: http.StatusBadRequest},
+		},
+		{
+			name: "Should fail on invalid time",
+			path: "/api/v1/query_range?name=Alloc&kind=gauge&from=yesterday",
+			want: result{code: http.StatusBadRequest},
+		},
+		{
+			name: "Should fail on negative step",
+			path: "/api/v1/query_range?name=Alloc&kind=gauge&step=-1s",
+			want: result{code: http.StatusBadRequest},
+		},
+		{
+			name: "Should fail on inverted range",
+			path: "/api/v1/query_range?name=Alloc&kind=gauge&from=1704103800&to=1704103200",
+			want: result{code: http.StatusBadRequest},
+		},
+		{
+			name: "Should fail on too many points",
+			path: "/api/v1/query_range?name=Alloc&kind=gauge&from=0&to=1704103200&step=1s",
+			want: result{code: http.StatusBadRequest},
+		},
+		{
+			name: "Should fail on unknown aggregation",
+			path: "/api/v1/query_range?name=Alloc&kind=gauge&from=1704103200&to=1704103800&agg=median",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("Range", "Alloc", metrics.KindGauge, metrics.Labels(nil), from, to).Return(samples, nil)
+			},
+			want: result{code: http.StatusBadRequest},
+		},
+		{
+			name: "Should fail on histograms",
+			path: "/api/v1/query_range?name=Latency&kind=histogram&from=1704103200&to=1704103800",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("Range", "Latency", metrics.KindHistogram, metrics.Labels(nil), from, to).
+					Return([]storage.Sample{{Timestamp: from, Value: metrics.NewHistogram()}}, nil)
+			},
+			want: result{code: http.StatusBadRequest},
+		},
+		{
+			name: "Should fail on service error",
+			path: "/api/v1/query_range?name=Alloc&kind=gauge",
+			mock: func(m *services.MetricServiceMock) {
+				m.On("Range", "Alloc", metrics.KindGauge, metrics.Labels(nil), mock.Anything, mock.Anything).Return(nil, entities.ErrUnexpected)
+			},
+			want: result{code: http.StatusInternalServerError},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, sm, _ := createMetricTestBackend()
+
+			if tt.mock != nil {
+				tt.mock(sm)
+			}
+
+			code, contentType, body := testRequest(t, router, http.MethodGet, tt.path, nil)
+			require.Equal(t, tt.want.code, code)
+
+			if tt.want.body != nil {
+				require.Equal(t, "application/json", contentType)
+
+				resp := new(QueryRangeResponse)
+				require.NoError(t, json.Unmarshal(body, resp))
+				require.Equal(t, tt.want.body, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ex0rcist/metflix/internal/cache"
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/internal/validators"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/ex0rcist/metflix/pkg/prompb"
+)
+
+// How long cumulative value of a counter is kept without samples and how many counters at most.
+// Forgotten counter that is already stored is re-baselined on its next sample.
+const (
+	cumulativeCountersTTL   = time.Hour
+	cumulativeCountersLimit = 1000000
+)
+
+// Last cumulative values of Prometheus counters, used to turn them into metflix increments.
+// Values are advanced before increments are pushed, so concurrent requests don't count the same
+// increase twice, and rolled back if push fails.
+type cumulativeCounters struct {
+	sync.Mutex
+	values *cache.LRU[string, int64]
+}
+
+func newCumulativeCounters() *cumulativeCounters {
+	return &cumulativeCounters{values: cache.NewLRU[string, int64](cumulativeCountersLimit, cumulativeCountersTTL)}
+}
+
+// Advance cumulative value of the counter, returning increment and change to roll back.
+// Baseline of unknown counter is current value if it's already stored, zero otherwise.
+// Must be called with c locked.
+func (c *cumulativeCounters) advance(id string, current int64, stored bool) (int64, cumulativeChange) {
+	last, ok := c.values.Get(id)
+	change := cumulativeChange{id: id, prev: last, hadPrev: ok, current: current}
+
+	if !ok && stored {
+		last = current // series stored before restart or forgotten: increments since then are unknown
+	}
+
+	c.values.Set(id, current)
+
+	return counterIncrement(last, current), change
+}
+
+// Roll back changes in reverse order, skipping counters advanced by other requests since then.
+func (c *cumulativeCounters) rollback(changes []cumulativeChange) {
+	c.Lock()
+	defer c.Unlock()
+
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+
+		if value, ok := c.values.Get(change.id); !ok || value != change.current {
+			continue
+		}
+
+		if change.hadPrev {
+			c.values.Set(change.id, change.prev)
+		} else {
+			c.values.Delete(change.id)
+		}
+	}
+}
+
+type cumulativeChange struct {
+	id      string
+	prev    int64
+	hadPrev bool
+	current int64
+}
+
+// Series with finite samples sorted by timestamp.
+type remoteWriteSeries struct {
+	name    string
+	labels  metrics.Labels
+	id      string // id of the counter
+	counter bool
+	samples []*prompb.Sample
+}
+
+// Samples pushed together: k-th round holds k-th sample of every series, so each sample is stored
+// separately instead of being merged with other samples of the same series.
+type remoteWriteRound struct {
+	records []storage.Record
+	changes []cumulativeChange // cumulative values of counters advanced by the round
+}
+
+// RemoteWrite godoc
+// @Tags Metrics
+// @Router /api/v1/write [post]
+// @Summary Receive metrics via Prometheus remote write protocol
+// @ID metrics_remote_write
+// @Accept application/x-protobuf
+// @Param Content-Encoding header string true "Must be `snappy`."
+// @Success 204
+// @Failure 400 {string} string http.StatusBadRequest
+// @Failure 500 {string} string http.StatusInternalServerError
+func (r MetricResource) RemoteWrite(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxIngestSize+1))
+	if err != nil {
+		writeErrorResponse(ctx, rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeReq := new(prompb.WriteRequest)
+	if len(body) > maxIngestSize || proto.Unmarshal(body, writeReq) != nil {
+		writeErrorResponse(ctx, rw, http.StatusBadRequest, entities.ErrMetricBatchMalformed)
+		return
+	}
+
+	// metadata usually comes in separate requests, remember it for later series
+	for _, md := range writeReq.GetMetadata() {
+		if len(md.GetMetricFamilyName()) == 0 {
+			continue
+		}
+
+		r.metricService.Describe(md.GetMetricFamilyName(), metrics.Metadata{
+			Kind: remoteWriteKind(md.GetType()),
+			Help: md.GetHelp(),
+		})
+	}
+
+	if err := validateRemoteWriteSeries(writeReq.GetTimeseries()); err != nil {
+		writeErrorResponse(ctx, rw, http.StatusBadRequest, err)
+		return
+	}
+
+	series := r.remoteWriteSeries(writeReq.GetTimeseries())
+
+	stored, err := r.storedCounters(ctx, series)
+	if err != nil {
+		writeErrorResponse(ctx, rw, errToStatus(err), err)
+		return
+	}
+
+	rounds := r.remoteWriteRounds(series, stored)
+
+	for k, round := range rounds {
+		if _, err = r.metricService.PushList(ctx, round.records); err != nil {
+			// stored rounds are not counted again on retry
+			var changes []cumulativeChange
+			for _, failed := range rounds[k:] {
+				changes = append(changes, failed.changes...)
+			}
+
+			r.cumulative.rollback(changes)
+
+			writeErrorResponse(ctx, rw, errToStatus(err), err)
+
+			return
+		}
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func validateRemoteWriteSeries(series []*prompb.TimeSeries) error {
+	for _, ts := range series {
+		name, labels := remoteWriteLabels(ts.GetLabels())
+
+		if err := validators.ValidateMetric(name, metrics.KindGauge); err != nil {
+			return fmt.Errorf("%w: series %s%s", err, name, labels)
+		}
+
+		if err := validators.ValidateLabels(labels); err != nil {
+			return fmt.Errorf("%w: series %s%s", err, name, labels)
+		}
+	}
+
+	return nil
+}
+
+// Prepare time series: resolve kinds, drop non-finite samples (including staleness markers), sort samples.
+func (r MetricResource) remoteWriteSeries(series []*prompb.TimeSeries) []remoteWriteSeries {
+	result := make([]remoteWriteSeries, 0, len(series))
+
+	for _, ts := range series {
+		name, labels := remoteWriteLabels(ts.GetLabels())
+
+		samples := make([]*prompb.Sample, 0, len(ts.GetSamples()))
+		for _, sample := range ts.GetSamples() {
+			if value := sample.GetValue(); !math.IsNaN(value) && !math.IsInf(value, 0) {
+				samples = append(samples, sample)
+			}
+		}
+
+		sort.SliceStable(samples, func(i, j int) bool { return samples[i].GetTimestamp() < samples[j].GetTimestamp() })
+
+		result = append(result, remoteWriteSeries{
+			name:    name,
+			labels:  labels,
+			id:      storage.CalculateRecordID(name, metrics.KindCounter, labels),
+			counter: r.remoteWriteKindOf(name) == metrics.KindCounter,
+			samples: samples,
+		})
+	}
+
+	return result
+}
+
+// Find which counters without cumulative value are already stored. Storage is queried without lock.
+func (r MetricResource) storedCounters(ctx context.Context, series []remoteWriteSeries) (map[string]bool, error) {
+	unknown := make([]remoteWriteSeries, 0)
+
+	r.cumulative.Lock()
+	for _, s := range series {
+		if _, ok := r.cumulative.values.Get(s.id); s.counter && len(s.samples) > 0 && !ok {
+			unknown = append(unknown, s)
+		}
+	}
+	r.cumulative.Unlock()
+
+	stored := make(map[string]bool, len(unknown))
+
+	for _, s := range unknown {
+		if _, ok := stored[s.id]; ok {
+			continue
+		}
+
+		exists, err := r.counterExists(ctx, s.name, s.labels)
+		if err != nil {
+			return nil, err
+		}
+
+		stored[s.id] = exists
+	}
+
+	return stored, nil
+}
+
+// Convert series to rounds of records, advancing cumulative values of counters.
+func (r MetricResource) remoteWriteRounds(series []remoteWriteSeries, stored map[string]bool) []remoteWriteRound {
+	var rounds []remoteWriteRound
+
+	r.cumulative.Lock()
+	defer r.cumulative.Unlock()
+
+	for _, s := range series {
+		for k, sample := range s.samples {
+			if k == len(rounds) {
+				rounds = append(rounds, remoteWriteRound{})
+			}
+
+			round := &rounds[k]
+			record := storage.Record{Name: s.name, Labels: s.labels, Timestamp: time.UnixMilli(sample.GetTimestamp()).UTC()}
+
+			if !s.counter {
+				record.Value = metrics.Gauge(sample.GetValue())
+				round.records = append(round.records, record)
+
+				continue
+			}
+
+			increment, change := r.cumulative.advance(s.id, int64(math.Floor(sample.GetValue())), stored[s.id])
+
+			record.Value = metrics.Counter(increment)
+			round.records = append(round.records, record)
+			round.changes = append(round.changes, change)
+		}
+	}
+
+	return rounds
+}
+
+// Kind of the series according to known metadata, gauge by default.
+func (r MetricResource) remoteWriteKindOf(name string) string {
+	if md, ok := r.metricService.Metadata(name); ok && len(md.Kind) > 0 {
+		return md.Kind
+	}
+
+	// OpenMetrics family names have no _total suffix
+	if family, ok := strings.CutSuffix(name, "_total"); ok {
+		if md, ok := r.metricService.Metadata(family); ok && len(md.Kind) > 0 {
+			return md.Kind
+		}
+	}
+
+	return metrics.KindGauge
+}
+
+func (r MetricResource) counterExists(ctx context.Context, name string, labels metrics.Labels) (bool, error) {
+	_, err := r.metricService.Get(ctx, name, metrics.KindCounter, labels)
+
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, entities.ErrRecordNotFound):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Increment between two cumulative values, decrease means counter reset.
+func counterIncrement(last, current int64) int64 {
+	if current < last {
+		return current
+	}
+
+	return current - last
+}
+
+func remoteWriteLabels(pairs []*prompb.Label) (string, metrics.Labels) {
+	var (
+		name   string
+		labels metrics.Labels
+	)
+
+	for _, l := range pairs {
+		if l.GetName() == "__name__" {
+			name = l.GetValue()
+			continue
+		}
+
+		if labels == nil {
+			labels = make(metrics.Labels, len(pairs))
+		}
+
+		labels[l.GetName()] = l.GetValue()
+	}
+
+	return name, labels
+}
+
+func remoteWriteKind(kind prompb.MetricMetadata_MetricType) string {
+	switch kind {
+	case prompb.MetricMetadata_COUNTER:
+		return metrics.KindCounter
+	case prompb.MetricMetadata_GAUGE:
+		return metrics.KindGauge
+	default:
+		return "" // histograms and summaries arrive as separate plain series
+	}
+}
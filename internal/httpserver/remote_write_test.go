@@ -0,0 +1,330 @@
+package httpserver
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/ex0rcist/metflix/pkg/prompb"
+)
+
+func remoteWriteRequest(t *testing.T, router http.Handler, payload []byte) int {
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/write", bytes.NewReader(snappy.Encode(nil, payload)))
+	require.NoError(t, err)
+
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	return resp.StatusCode
+}
+
+func marshalWriteRequest(t *testing.T, req *prompb.WriteRequest) []byte {
+	data, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	return data
+}
+
+func promSeries(name string, labels map[string]string, samples ...*prompb.Sample) *prompb.TimeSeries {
+	ts := &prompb.TimeSeries{Labels: []*prompb.Label{{Name: "__name__", Value: name}}, Samples: samples}
+	for k, v := range labels {
+		ts.Labels = append(ts.Labels, &prompb.Label{Name: k, Value: v})
+	}
+
+	return ts
+}
+
+func TestRemoteWrite(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	ms := ts.UnixMilli()
+
+	router, sm, _ := createMetricTestBackend()
+
+	sm.On("Describe", "http_requests", metrics.Metadata{Kind: metrics.KindCounter, Help: "Requests served."}).Once()
+	sm.On("Metadata", "http_requests_total").Return(metrics.Metadata{}, false)
+	sm.On("Metadata", "http_requests").Return(metrics.Metadata{Kind: metrics.KindCounter}, true)
+	sm.On("Metadata", "temperature").Return(metrics.Metadata{}, false)
+	sm.On("Get", "http_requests_total", metrics.KindCounter, metrics.Labels{"code": "200"}).
+		Return(storage.Record{}, entities.ErrRecordNotFound).Once()
+
+	// every sample of the series is pushed separately, so none is merged away
+	sm.On("PushList", mock.Anything, []storage.Record{
+		{Name: "http_requests_total", Labels: metrics.Labels{"code": "200"}, Value: metrics.Counter(10), Timestamp: ts},
+		{Name: "temperature", Labels: metrics.Labels{"room": "a"}, Value: metrics.Gauge(21.5), Timestamp: ts},
+	}).Return([]storage.Record{}, nil).Once()
+	sm.On("PushList", mock.Anything, []storage.Record{
+		{Name: "http_requests_total", Labels: metrics.Labels{"code": "200"}, Value: metrics.Counter(5), Timestamp: ts.Add(time.Second)},
+	}).Return([]storage.Record{}, nil).Once()
+
+	code := remoteWriteRequest(t, router, marshalWriteRequest(t, &prompb.WriteRequest{
+		Metadata: []*prompb.MetricMetadata{
+			{Type: prompb.MetricMetadata_COUNTER, MetricFamilyName: "http_requests", Help: "Requests served."},
+		},
+		Timeseries: []*prompb.TimeSeries{
+			promSeries("http_requests_total", map[string]string{"code": "200"},
+				&prompb.Sample{Value: 15.7, Timestamp: ms + 1000},
+				&prompb.Sample{Value: 10, Timestamp: ms},
+			),
+			promSeries("temperature", map[string]string{"room": "a"},
+				&prompb.Sample{Value: 21.5, Timestamp: ms},
+				&prompb.Sample{Value: math.NaN(), Timestamp: ms + 1000}, // stale marker
+			),
+		},
+	}))
+	require.Equal(t, http.StatusNoContent, code)
+
+	// counter reset is detected against remembered cumulative value, no storage lookup needed
+	sm.On("PushList", mock.Anything, []storage.Record{
+		{Name: "http_requests_total", Labels: metrics.Labels{"code": "200"}, Value: metrics.Counter(3), Timestamp: ts.Add(time.Minute)},
+	}).Return([]storage.Record{}, nil).Once()
+
+	code = remoteWriteRequest(t, router, marshalWriteRequest(t, &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			promSeries("http_requests_total", map[string]string{"code": "200"}, &prompb.Sample{Value: 3, Timestamp: ms + 60000}),
+		},
+	}))
+	require.Equal(t, http.StatusNoContent, code)
+
+	sm.AssertExpectations(t)
+}
+
+func TestRemoteWrite_StoredCounterBaseline(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	router, sm, _ := createMetricTestBackend()
+
+	sm.On("Metadata", "jobs_total").Return(metrics.Metadata{Kind: metrics.KindCounter}, true)
+	sm.On("Get", "jobs_total", metrics.KindCounter, metrics.Labels(nil)).
+		Return(storage.Record{Name: "jobs_total", Value: metrics.Counter(100)}, nil).Once()
+	sm.On("PushList", mock.Anything, []storage.Record{
+		{Name: "jobs_total", Value: metrics.Counter(0), Timestamp: ts},
+	}).Return([]storage.Record{}, nil).Once()
+	sm.On("PushList", mock.Anything, []storage.Record{
+		{Name: "jobs_total", Value: metrics.Counter(2), Timestamp: ts.Add(time.Second)},
+	}).Return([]storage.Record{}, nil).Once()
+
+	code := remoteWriteRequest(t, router, marshalWriteRequest(t, &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			promSeries("jobs_total", nil,
+				&prompb.Sample{Value: 40, Timestamp: ts.UnixMilli()},
+				&prompb.Sample{Value: 42, Timestamp: ts.Add(time.Second).UnixMilli()},
+			),
+		},
+	}))
+	require.Equal(t, http.StatusNoContent, code)
+
+	sm.AssertExpectations(t)
+}
+
+func TestRemoteWrite_PartiallyStored(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	router, sm, _ := createMetricTestBackend()
+
+	sm.On("Metadata", "jobs_total").Return(metrics.Metadata{Kind: metrics.KindCounter}, true)
+	sm.On("Get", "jobs_total", metrics.KindCounter, metrics.Labels(nil)).
+		Return(storage.Record{}, entities.ErrRecordNotFound).Once()
+	sm.On("PushList", mock.Anything, []storage.Record{
+		{Name: "jobs_total", Value: metrics.Counter(40), Timestamp: ts},
+	}).Return([]storage.Record{}, nil).Once()
+	sm.On("PushList", mock.Anything, []storage.Record{
+		{Name: "jobs_total", Value: metrics.Counter(2), Timestamp: ts.Add(time.Second)},
+	}).Return([]storage.Record{}, entities.ErrUnexpected).Once()
+
+	payload := marshalWriteRequest(t, &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			promSeries("jobs_total", nil,
+				&prompb.Sample{Value: 40, Timestamp: ts.UnixMilli()},
+				&prompb.Sample{Value: 42, Timestamp: ts.Add(time.Second).UnixMilli()},
+			),
+		},
+	})
+
+	require.Equal(t, http.StatusInternalServerError, remoteWriteRequest(t, router, payload))
+
+	// retry counts only the round which was not stored
+	sm.On("PushList", mock.Anything, []storage.Record{
+		{Name: "jobs_total", Value: metrics.Counter(0), Timestamp: ts},
+	}).Return([]storage.Record{}, nil).Once()
+	sm.On("PushList", mock.Anything, []storage.Record{
+		{Name: "jobs_total", Value: metrics.Counter(2), Timestamp: ts.Add(time.Second)},
+	}).Return([]storage.Record{}, nil).Once()
+
+	require.Equal(t, http.StatusNoContent, remoteWriteRequest(t, router, payload))
+
+	sm.AssertExpectations(t)
+}
+
+func TestCumulativeCounters(t *testing.T) {
+	c := newCumulativeCounters()
+
+	c.Lock()
+	inc, first := c.advance("a", 10, false)
+	require.Equal(t, int64(10), inc)
+
+	inc, second := c.advance("a", 15, false)
+	require.Equal(t, int64(5), inc)
+
+	inc, stored := c.advance("b", 7, true)
+	require.Equal(t, int64(0), inc)
+	c.Unlock()
+
+	// chain of changes is rolled back to the state before the first one
+	c.rollback([]cumulativeChange{first, second, stored})
+	require.Zero(t, c.values.Len())
+
+	// counter advanced by other request is left as is
+	c.Lock()
+	_, change := c.advance("a", 10, false)
+	c.advance("a", 12, false)
+	c.Unlock()
+
+	c.rollback([]cumulativeChange{change})
+
+	value, ok := c.values.Get("a")
+	require.True(t, ok)
+	require.Equal(t, int64(12), value)
+	require.Equal(t, 1, c.values.Len())
+}
+
+func TestRemoteWrite_ConcurrentRequests(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	router, sm, _ := createMetricTestBackend()
+
+	sm.On("Metadata", "jobs_total").Return(metrics.Metadata{Kind: metrics.KindCounter}, true)
+	sm.On("Metadata", "up").Return(metrics.Metadata{}, false)
+	sm.On("Get", "jobs_total", metrics.KindCounter, metrics.Labels(nil)).
+		Return(storage.Record{}, entities.ErrRecordNotFound).Once()
+
+	// slow push does not block other requests
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	sm.On("PushList", mock.Anything, []storage.Record{
+		{Name: "jobs_total", Value: metrics.Counter(40), Timestamp: ts},
+	}).Run(func(mock.Arguments) {
+		close(entered)
+		<-release
+	}).Return([]storage.Record{}, nil).Once()
+	sm.On("PushList", mock.Anything, []storage.Record{
+		{Name: "up", Value: metrics.Gauge(1), Timestamp: ts},
+	}).Return([]storage.Record{}, nil).Once()
+
+	// increment is counted against value reserved by request in flight
+	sm.On("PushList", mock.Anything, []storage.Record{
+		{Name: "jobs_total", Value: metrics.Counter(2), Timestamp: ts.Add(time.Second)},
+	}).Return([]storage.Record{}, nil).Once()
+
+	slow := make(chan int)
+	go func() {
+		slow <- remoteWriteRequest(t, router, marshalWriteRequest(t, &prompb.WriteRequest{
+			Timeseries: []*prompb.TimeSeries{promSeries("jobs_total", nil, &prompb.Sample{Value: 40, Timestamp: ts.UnixMilli()})},
+		}))
+	}()
+
+	<-entered
+
+	require.Equal(t, http.StatusNoContent, remoteWriteRequest(t, router, marshalWriteRequest(t, &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{promSeries("up", nil, &prompb.Sample{Value: 1, Timestamp: ts.UnixMilli()})},
+	})))
+
+	require.Equal(t, http.StatusNoContent, remoteWriteRequest(t, router, marshalWriteRequest(t, &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{promSeries("jobs_total", nil, &prompb.Sample{Value: 42, Timestamp: ts.Add(time.Second).UnixMilli()})},
+	})))
+
+	close(release)
+	require.Equal(t, http.StatusNoContent, <-slow)
+
+	sm.AssertExpectations(t)
+}
+
+func TestSnappyOnlyOnRemoteWrite(t *testing.T) {
+	router, _, _ := createMetricTestBackend()
+
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/updates", bytes.NewReader(snappy.Encode(nil, []byte("[]"))))
+	require.NoError(t, err)
+
+	req.Header.Set("Content-Encoding", "snappy")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Contains(t, string(body), entities.ErrEncodingUnsupported.Error())
+}
+
+func TestRemoteWrite_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		mock    func(m *services.MetricServiceMock)
+		want    int
+	}{
+		{
+			name:    "Should fail on malformed protobuf",
+			payload: []byte("not a protobuf"),
+			want:    http.StatusBadRequest,
+		},
+		{
+			name: "Should fail on invalid name",
+			payload: marshalWriteRequest(t, &prompb.WriteRequest{
+				Timeseries: []*prompb.TimeSeries{promSeries("inva!id", nil, &prompb.Sample{Value: 1})},
+			}),
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "Should fail on missing name",
+			payload: marshalWriteRequest(t, &prompb.WriteRequest{
+				Timeseries: []*prompb.TimeSeries{promSeries("", nil, &prompb.Sample{Value: 1})},
+			}),
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "Should fail on service error",
+			payload: marshalWriteRequest(t, &prompb.WriteRequest{
+				Timeseries: []*prompb.TimeSeries{promSeries("up", nil, &prompb.Sample{Value: 1})},
+			}),
+			mock: func(m *services.MetricServiceMock) {
+				m.On("Metadata", "up").Return(metrics.Metadata{}, false)
+				m.On("PushList", mock.Anything, mock.Anything).Return([]storage.Record{}, entities.ErrUnexpected)
+			},
+			want: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, sm, _ := createMetricTestBackend()
+
+			if tt.mock != nil {
+				tt.mock(sm)
+			}
+
+			require.Equal(t, tt.want, remoteWriteRequest(t, router, tt.payload))
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/validators"
+)
+
+// Interval of comments keeping idle event streams (and proxies in between) alive.
+const defaultStreamHeartbeat = 15 * time.Second
+
+// Signals open event streams to finish, so they don't hold server shutdown.
+type streamsCloser struct {
+	once sync.Once
+	done chan struct{}
+}
+
+func newStreamsCloser() *streamsCloser {
+	return &streamsCloser{done: make(chan struct{})}
+}
+
+func (c *streamsCloser) close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// Close open event streams, to be called on server shutdown.
+func (r MetricResource) CloseStreams() {
+	r.streams.close()
+}
+
+// Stream godoc
+// @Tags Metrics
+// @Router /api/v1/stream [get]
+// @Summary Live stream of metric changes
+// @Description Server-Sent Events stream, each `metric` event carries metrics.MetricExchange JSON of the stored value.
+// @Description Reconnecting clients resume from `Last-Event-ID` while the events are kept in server memory.
+// @ID metrics_stream
+// @Produce text/event-stream
+// @Param name query string false "Stream only metrics with given name."
+// @Param kind query string false "Stream only metrics of given type."
+// @Param label query []string false "Stream only metrics having label, in `key=value` format." collectionFormat(multi)
+// @Param Last-Event-ID header string false "ID of the last received event."
+// @Success 200 {string} string
+// @Failure 400 {string} string http.StatusBadRequest
+// @Failure 500 {string} string http.StatusInternalServerError
+func (r MetricResource) Stream(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	filter, err := parseListFilter(req)
+	if err != nil {
+		writeErrorResponse(ctx, rw, errToStatus(err), err)
+		return
+	}
+
+	if len(filter.Kind) > 0 {
+		if err = validators.ValidateMetricKind(filter.Kind); err != nil {
+			writeErrorResponse(ctx, rw, errToStatus(err), err)
+			return
+		}
+	}
+
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		writeErrorResponse(ctx, rw, http.StatusInternalServerError, fmt.Errorf("streaming is not supported by response writer"))
+		return
+	}
+
+	var sub *services.Subscription
+	if lastEventID := req.Header.Get("Last-Event-ID"); len(lastEventID) > 0 {
+		lastID, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			writeErrorResponse(ctx, rw, http.StatusBadRequest, fmt.Errorf("invalid Last-Event-ID: %w", err))
+			return
+		}
+
+		sub = r.metricService.Resume(filter, lastID)
+	} else {
+		sub = r.metricService.Subscribe(filter)
+	}
+	defer sub.Close()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.Header().Set("X-Accel-Buffering", "no") // disable buffering in nginx
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(r.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-r.streams.done:
+			return
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(rw, ": heartbeat\n\n"); err != nil {
+				return
+			}
+
+		case change, ok := <-sub.C():
+			if !ok {
+				// client reconnects and catches up via Last-Event-ID
+				logging.LogWarnCtx(ctx, "event stream subscriber is too slow, disconnecting")
+				return
+			}
+
+			if err := writeEvent(ctx, rw, change); err != nil {
+				logging.LogErrorCtx(ctx, err)
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+// Write change as SSE event. Change which can't be encoded (e.g. NaN gauge) is skipped,
+// otherwise resuming client would get it replayed and disconnect again forever.
+func writeEvent(ctx context.Context, rw http.ResponseWriter, change services.Change) error {
+	mex, err := toMetricExchange(change.Record)
+	if err != nil {
+		logging.LogErrorCtx(ctx, err, fmt.Sprintf("skipping event %d", change.ID))
+		return nil
+	}
+
+	data, err := json.Marshal(mex)
+	if err != nil {
+		logging.LogErrorCtx(ctx, err, fmt.Sprintf("skipping event %d", change.ID))
+		return nil
+	}
+
+	_, err = fmt.Fprintf(rw, "id: %d\nevent: metric\ndata: %s\n\n", change.ID, data)
+
+	return err
+}
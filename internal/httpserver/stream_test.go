@@ -0,0 +1,156 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/otlp"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+type streamTestBackend struct {
+	server   *httptest.Server
+	resource *MetricResource
+	service  services.MetricService
+}
+
+// Backend with real metric service, so pushes reach subscribers.
+func createStreamTestBackend(t *testing.T, heartbeat time.Duration) *streamTestBackend {
+	service := services.NewMetricService(storage.NewMemStorage())
+
+	resource := NewMetricResource(service, otlp.NewTranslator(service))
+	resource.heartbeat = heartbeat
+	server := httptest.NewServer(NewBackend(WithSignSecret("secret"), WithMetricResource(resource)))
+	t.Cleanup(server.Close)
+
+	return &streamTestBackend{server: server, resource: resource, service: service}
+}
+
+func openStream(t *testing.T, b *streamTestBackend, path, lastEventID string) (*http.Response, *bufio.Reader) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.server.URL+path, nil)
+	require.NoError(t, err)
+
+	if len(lastEventID) > 0 {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	return resp, bufio.NewReader(resp.Body)
+}
+
+// Read lines of the next event or comment.
+func readEvent(t *testing.T, reader *bufio.Reader) []string {
+	var lines []string
+
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+
+		line = strings.TrimSuffix(line, "\n")
+		if len(line) == 0 {
+			return lines
+		}
+
+		lines = append(lines, line)
+	}
+}
+
+func TestStream(t *testing.T) {
+	b := createStreamTestBackend(t, defaultStreamHeartbeat)
+
+	resp, reader := openStream(t, b, "/api/v1/stream?kind=counter", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	require.Empty(t, resp.Header.Get("HashSHA256"))
+
+	_, err := b.service.PushList(context.Background(), []storage.Record{
+		{Name: "Alloc", Value: metrics.Gauge(1)},
+		{Name: "PollCount", Value: metrics.Counter(1)},
+	})
+	require.NoError(t, err)
+
+	// event is delivered while the stream is still open
+	require.Equal(t, []string{"id: 2", "event: metric", `data: {"id":"PollCount","type":"counter","delta":1}`}, readEvent(t, reader))
+}
+
+func TestStream_Resume(t *testing.T) {
+	b := createStreamTestBackend(t, defaultStreamHeartbeat)
+
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := b.service.Push(context.Background(), storage.Record{Name: name, Value: metrics.Gauge(1)})
+		require.NoError(t, err)
+	}
+
+	_, reader := openStream(t, b, "/api/v1/stream", "1")
+
+	require.Equal(t, "id: 2", readEvent(t, reader)[0])
+	require.Equal(t, "id: 3", readEvent(t, reader)[0])
+}
+
+func TestStream_SkipsUnencodable(t *testing.T) {
+	b := createStreamTestBackend(t, defaultStreamHeartbeat)
+
+	_, err := b.service.PushList(context.Background(), []storage.Record{
+		{Name: "a", Value: metrics.Gauge(math.NaN())},
+		{Name: "b", Value: metrics.Gauge(1)},
+	})
+	require.NoError(t, err)
+
+	_, reader := openStream(t, b, "/api/v1/stream", "0")
+
+	require.Equal(t, []string{"id: 2", "event: metric", `data: {"id":"b","type":"gauge","value":1}`}, readEvent(t, reader))
+}
+
+func TestStream_Heartbeat(t *testing.T) {
+	b := createStreamTestBackend(t, 10*time.Millisecond)
+
+	_, reader := openStream(t, b, "/api/v1/stream", "")
+
+	require.Equal(t, []string{": heartbeat"}, readEvent(t, reader))
+}
+
+func TestStream_Close(t *testing.T) {
+	b := createStreamTestBackend(t, defaultStreamHeartbeat)
+
+	_, reader := openStream(t, b, "/api/v1/stream", "")
+	b.resource.CloseStreams()
+
+	_, err := reader.ReadString('\n')
+	require.Error(t, err)
+}
+
+func TestStream_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		lastEventID string
+	}{
+		{name: "unknown kind", path: "/api/v1/stream?kind=unknown"},
+		{name: "invalid label filter", path: "/api/v1/stream?label=host"},
+		{name: "invalid Last-Event-ID", path: "/api/v1/stream", lastEventID: "abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := createStreamTestBackend(t, defaultStreamHeartbeat)
+
+			resp, _ := openStream(t, b, tt.path, tt.lastEventID)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
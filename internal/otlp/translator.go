@@ -0,0 +1,570 @@
+// Package otlp translates OpenTelemetry metrics export requests into metflix records.
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/internal/validators"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+var (
+	invalidNameChars  = regexp.MustCompile(`[^A-Za-z\d_:.\-]`)
+	invalidLabelChars = regexp.MustCompile(`[^A-Za-z\d_]`)
+)
+
+// Last cumulative value of a series, used to turn it into metflix increment.
+type cumulativeState struct {
+	seq       uint64 // version of the state, so rollback doesn't undo later exports
+	start     uint64 // start time of the series, change means reset
+	counter   float64
+	histogram metrics.Histogram
+}
+
+// Translates OTLP metrics into records and pushes them to metric service.
+//
+// Sums and histograms with cumulative temporality are converted into increments between exports,
+// non-monotonic sums and gauges are stored as gauges. Exponential histograms and summaries are rejected.
+// Cumulative values are advanced before increments are pushed, so concurrent exports don't count the same
+// increase twice, and rolled back if push fails. One translator must be shared by every OTLP transport.
+type Translator struct {
+	service services.MetricProvider
+
+	mutex      sync.Mutex // guards cumulative and seq only, storage is never called with it held
+	cumulative map[string]cumulativeState
+	seq        uint64
+}
+
+// Constructor.
+func NewTranslator(service services.MetricProvider) *Translator {
+	return &Translator{
+		service:    service,
+		cumulative: make(map[string]cumulativeState),
+	}
+}
+
+// Store metrics of export request. Returns response with partial success filled if some data points were rejected.
+func (t *Translator) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	batch := &exportBatch{}
+
+	for _, rm := range req.GetResourceMetrics() {
+		resourceLabels := attributesToLabels(nil, rm.GetResource().GetAttributes())
+
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				t.translateMetric(batch, m, resourceLabels)
+			}
+		}
+	}
+
+	stored, err := t.storedSeries(ctx, batch.cumulative)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := t.advance(batch, stored)
+
+	if records := batch.pushable(); len(records) > 0 {
+		if _, err := t.service.PushList(ctx, records); err != nil {
+			t.rollback(changes) // stored increments are not counted again on retry
+			return nil, err
+		}
+	}
+
+	resp := &colmetricspb.ExportMetricsServiceResponse{}
+	if batch.rejected > 0 {
+		resp.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{
+			RejectedDataPoints: batch.rejected,
+			ErrorMessage:       batch.String(),
+		}
+	}
+
+	return resp, nil
+}
+
+type exportBatch struct {
+	records    []storage.Record // cumulative records have no value until advance
+	cumulative []cumulativePoint
+	rejected   int64
+	errors     []string
+}
+
+// Cumulative data point waiting for its increment.
+type cumulativePoint struct {
+	record    int // index in batch records
+	id        string
+	name      string
+	kind      string
+	labels    metrics.Labels
+	start     uint64
+	counter   float64
+	histogram metrics.Histogram
+}
+
+type cumulativeChange struct {
+	id      string
+	prev    cumulativeState
+	hadPrev bool
+	seq     uint64
+}
+
+func (b *exportBatch) reject(count int, err error) {
+	b.rejected += int64(count)
+	b.errors = append(b.errors, err.Error())
+}
+
+// Records to push, points used as baseline or rejected while advancing have no value.
+func (b *exportBatch) pushable() []storage.Record {
+	records := make([]storage.Record, 0, len(b.records))
+	for _, record := range b.records {
+		if record.Value != nil {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+func (t *Translator) translateMetric(batch *exportBatch, m *metricspb.Metric, resourceLabels metrics.Labels) {
+	name := invalidNameChars.ReplaceAllString(m.GetName(), "_")
+
+	if err := validators.ValidateMetric(name, metrics.KindGauge); err != nil {
+		batch.reject(dataPointsCount(m), fmt.Errorf("%w: %q", err, m.GetName()))
+		return
+	}
+
+	if len(m.GetDescription()) > 0 {
+		t.service.Describe(name, metrics.Metadata{Kind: metricKind(m), Help: m.GetDescription()})
+	}
+
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		for _, dp := range data.Gauge.GetDataPoints() {
+			value := numberValue(dp)
+			if !finite(value) {
+				continue // no recorded value
+			}
+
+			labels, ok := pointLabels(batch, resourceLabels, dp.GetAttributes())
+			if !ok {
+				continue
+			}
+
+			batch.records = append(batch.records, storage.Record{
+				Name: name, Labels: labels, Value: metrics.Gauge(value), Timestamp: pointTime(dp.GetTimeUnixNano()),
+			})
+		}
+	case *metricspb.Metric_Sum:
+		for _, dp := range data.Sum.GetDataPoints() {
+			labels, ok := pointLabels(batch, resourceLabels, dp.GetAttributes())
+			if !ok {
+				continue
+			}
+
+			translateSum(batch, name, labels, data.Sum, dp)
+		}
+	case *metricspb.Metric_Histogram:
+		for _, dp := range data.Histogram.GetDataPoints() {
+			labels, ok := pointLabels(batch, resourceLabels, dp.GetAttributes())
+			if !ok {
+				continue
+			}
+
+			translateHistogram(batch, name, labels, data.Histogram.GetAggregationTemporality(), dp)
+		}
+	default:
+		batch.reject(dataPointsCount(m), fmt.Errorf("%w: %q has unsupported data type", entities.ErrMetricUnknown, m.GetName()))
+	}
+}
+
+func translateSum(batch *exportBatch, name string, labels metrics.Labels, sum *metricspb.Sum, dp *metricspb.NumberDataPoint) {
+	value := numberValue(dp)
+	if !finite(value) {
+		return // no recorded value
+	}
+
+	record := storage.Record{Name: name, Labels: labels, Timestamp: pointTime(dp.GetTimeUnixNano())}
+
+	switch {
+	case !sum.GetIsMonotonic() && sum.GetAggregationTemporality() == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA:
+		batch.reject(1, fmt.Errorf("%w: %q is non-monotonic delta sum", entities.ErrMetricUnknown, name))
+		return
+	case !sum.GetIsMonotonic():
+		record.Value = metrics.Gauge(value)
+	case sum.GetAggregationTemporality() == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA:
+		record.Value = metrics.Counter(math.Round(value))
+	default:
+		batch.cumulative = append(batch.cumulative, cumulativePoint{
+			record:  len(batch.records),
+			id:      storage.CalculateRecordID(name, metrics.KindCounter, labels),
+			name:    name,
+			kind:    metrics.KindCounter,
+			labels:  labels,
+			start:   dp.GetStartTimeUnixNano(),
+			counter: value,
+		})
+	}
+
+	batch.records = append(batch.records, record)
+}
+
+func translateHistogram(
+	batch *exportBatch,
+	name string,
+	labels metrics.Labels,
+	temporality metricspb.AggregationTemporality,
+	dp *metricspb.HistogramDataPoint,
+) {
+	histogram := metrics.Histogram{
+		Bounds: dp.GetExplicitBounds(),
+		Counts: dp.GetBucketCounts(),
+		Count:  dp.GetCount(),
+		Sum:    dp.GetSum(),
+	}
+
+	if !finite(histogram.Sum) {
+		return // no recorded value
+	}
+
+	if len(histogram.Counts) == 0 { // no buckets, single bucket holds everything
+		histogram.Counts = []uint64{histogram.Count}
+	}
+
+	if err := histogram.Validate(); err != nil {
+		batch.reject(1, fmt.Errorf("%w: %q", err, name))
+		return
+	}
+
+	record := storage.Record{Name: name, Labels: labels, Value: histogram, Timestamp: pointTime(dp.GetTimeUnixNano())}
+
+	if temporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		record.Value = nil
+
+		batch.cumulative = append(batch.cumulative, cumulativePoint{
+			record:    len(batch.records),
+			id:        storage.CalculateRecordID(name, metrics.KindHistogram, labels),
+			name:      name,
+			kind:      metrics.KindHistogram,
+			labels:    labels,
+			start:     dp.GetStartTimeUnixNano(),
+			histogram: histogram,
+		})
+	}
+
+	batch.records = append(batch.records, record)
+}
+
+// Find stored records of cumulative series unknown to translator (e.g. after restart).
+// Storage is queried without lock.
+func (t *Translator) storedSeries(ctx context.Context, points []cumulativePoint) (map[string]storage.Record, error) {
+	unknown := make([]cumulativePoint, 0)
+
+	t.mutex.Lock()
+	for _, p := range points {
+		if _, ok := t.cumulative[p.id]; !ok {
+			unknown = append(unknown, p)
+		}
+	}
+	t.mutex.Unlock()
+
+	stored := make(map[string]storage.Record, len(unknown))
+	checked := make(map[string]bool, len(unknown))
+
+	for _, p := range unknown {
+		if checked[p.id] {
+			continue
+		}
+
+		checked[p.id] = true
+
+		record, err := t.service.Get(ctx, p.name, p.kind, p.labels)
+
+		switch {
+		case err == nil:
+			stored[p.id] = record
+		case errors.Is(err, entities.ErrRecordNotFound):
+		default:
+			return nil, err
+		}
+	}
+
+	return stored, nil
+}
+
+// Turn cumulative points into increments in order, advancing cumulative state of their series.
+// Point of series which is already stored but unknown becomes a baseline and is not pushed.
+// Histogram which buckets don't match the series is rejected: storage can't merge it.
+func (t *Translator) advance(batch *exportBatch, stored map[string]storage.Record) []cumulativeChange {
+	changes := make([]cumulativeChange, 0, len(batch.cumulative))
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, p := range batch.cumulative {
+		last, known := t.cumulative[p.id]
+		record, isStored := stored[p.id]
+		baseline := !known && isStored
+
+		if p.kind == metrics.KindHistogram {
+			bounds, ok := seriesBounds(last, known, record, isStored)
+			if ok && !sameBounds(bounds, p.histogram.Bounds) {
+				batch.reject(1, fmt.Errorf("%w: %q", entities.ErrHistogramBucketsMismatch, p.name))
+				continue
+			}
+		}
+
+		reset := !known || last.start != p.start
+
+		switch p.kind {
+		case metrics.KindCounter:
+			switch {
+			case baseline:
+				batch.records[p.record].Value = metrics.Counter(0)
+			case reset:
+				batch.records[p.record].Value = metrics.Counter(math.Floor(p.counter))
+			default:
+				batch.records[p.record].Value = metrics.Counter(counterIncrement(last.counter, p.counter))
+			}
+		case metrics.KindHistogram:
+			switch {
+			case baseline:
+				// stored before restart, take point as baseline
+			case reset:
+				batch.records[p.record].Value = p.histogram
+			default:
+				// decreased counts mean reset, whole histogram is the increment then
+				batch.records[p.record].Value, _ = histogramIncrement(last.histogram, p.histogram)
+			}
+		}
+
+		t.seq++
+		t.cumulative[p.id] = cumulativeState{seq: t.seq, start: p.start, counter: p.counter, histogram: p.histogram}
+		changes = append(changes, cumulativeChange{id: p.id, prev: last, hadPrev: known, seq: t.seq})
+	}
+
+	return changes
+}
+
+// Roll back changes in reverse order, skipping series advanced by other exports since then.
+func (t *Translator) rollback(changes []cumulativeChange) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+
+		if t.cumulative[change.id].seq != change.seq {
+			continue
+		}
+
+		if change.hadPrev {
+			t.cumulative[change.id] = change.prev
+		} else {
+			delete(t.cumulative, change.id)
+		}
+	}
+}
+
+// Increment between two cumulative counter values, whole units only, so fractional parts are not lost
+// between exports. Decrease means counter reset.
+func counterIncrement(last, current float64) float64 {
+	if current < last {
+		return math.Floor(current)
+	}
+
+	return math.Floor(current) - math.Floor(last)
+}
+
+// Bucket bounds the series is stored with, if known.
+func seriesBounds(last cumulativeState, known bool, record storage.Record, stored bool) ([]float64, bool) {
+	if known && last.histogram.Counts != nil {
+		return last.histogram.Bounds, true
+	}
+
+	if stored {
+		if h, ok := record.Value.(metrics.Histogram); ok {
+			return h.Bounds, true
+		}
+	}
+
+	return nil, false
+}
+
+func sameBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Difference between two cumulative histograms. Returns false if buckets changed or counts decreased (reset).
+func histogramIncrement(last, current metrics.Histogram) (metrics.Histogram, bool) {
+	if len(last.Bounds) != len(current.Bounds) || len(last.Counts) != len(current.Counts) || current.Count < last.Count {
+		return current, false
+	}
+
+	for i := range current.Bounds {
+		if last.Bounds[i] != current.Bounds[i] {
+			return current, false
+		}
+	}
+
+	delta := metrics.Histogram{
+		Bounds: current.Bounds,
+		Counts: make([]uint64, len(current.Counts)),
+		Count:  current.Count - last.Count,
+		Sum:    current.Sum - last.Sum,
+	}
+
+	for i := range current.Counts {
+		if current.Counts[i] < last.Counts[i] {
+			return current, false
+		}
+
+		delta.Counts[i] = current.Counts[i] - last.Counts[i]
+	}
+
+	return delta, true
+}
+
+// Merge point attributes over resource labels. Rejects point with invalid labels.
+func pointLabels(batch *exportBatch, resourceLabels metrics.Labels, attrs []*commonpb.KeyValue) (metrics.Labels, bool) {
+	labels := attributesToLabels(resourceLabels, attrs)
+
+	if err := validators.ValidateLabels(labels); err != nil {
+		batch.reject(1, err)
+		return nil, false
+	}
+
+	return labels, true
+}
+
+func attributesToLabels(base metrics.Labels, attrs []*commonpb.KeyValue) metrics.Labels {
+	if len(base) == 0 && len(attrs) == 0 {
+		return nil
+	}
+
+	labels := make(metrics.Labels, len(base)+len(attrs))
+	for k, v := range base {
+		labels[k] = v
+	}
+
+	for _, attr := range attrs {
+		value, ok := attributeValue(attr.GetValue())
+		if !ok {
+			continue
+		}
+
+		labels[labelName(attr.GetKey())] = value
+	}
+
+	return labels
+}
+
+// Convert attribute key like "service.name" into label name service_name.
+func labelName(key string) string {
+	name := invalidLabelChars.ReplaceAllString(key, "_")
+	if len(name) > 0 && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+
+	return name
+}
+
+// Scalar attribute value as string, arrays, maps and bytes are skipped.
+func attributeValue(v *commonpb.AnyValue) (string, bool) {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue, true
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(value.BoolValue), true
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(value.IntValue, 10), true
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(value.DoubleValue, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+func numberValue(dp *metricspb.NumberDataPoint) float64 {
+	if v, ok := dp.GetValue().(*metricspb.NumberDataPoint_AsInt); ok {
+		return float64(v.AsInt)
+	}
+
+	return dp.GetAsDouble()
+}
+
+// Non-finite values mark missing points, they are skipped like in remote_write.
+func finite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+func pointTime(unixNano uint64) time.Time {
+	if unixNano == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(unixNano)).UTC()
+}
+
+func metricKind(m *metricspb.Metric) string {
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		return metrics.KindGauge
+	case *metricspb.Metric_Sum:
+		if data.Sum.GetIsMonotonic() {
+			return metrics.KindCounter
+		}
+
+		return metrics.KindGauge
+	case *metricspb.Metric_Histogram:
+		return metrics.KindHistogram
+	default:
+		return ""
+	}
+}
+
+func dataPointsCount(m *metricspb.Metric) int {
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		return len(data.Gauge.GetDataPoints())
+	case *metricspb.Metric_Sum:
+		return len(data.Sum.GetDataPoints())
+	case *metricspb.Metric_Histogram:
+		return len(data.Histogram.GetDataPoints())
+	case *metricspb.Metric_ExponentialHistogram:
+		return len(data.ExponentialHistogram.GetDataPoints())
+	case *metricspb.Metric_Summary:
+		return len(data.Summary.GetDataPoints())
+	default:
+		return 0
+	}
+}
+
+// Human readable summary of rejected points.
+func (b *exportBatch) String() string {
+	return fmt.Sprintf("%d data points rejected: %s", b.rejected, strings.Join(b.errors, "; "))
+}
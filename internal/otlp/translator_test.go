@@ -0,0 +1,349 @@
+package otlp
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+const (
+	testStart = uint64(1700000000000000000)
+	testTime  = uint64(1700000010000000000)
+)
+
+func strAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func exportRequest(ms ...*metricspb.Metric) *colmetricspb.ExportMetricsServiceRequest {
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource:     &resourcepb.Resource{Attributes: []*commonpb.KeyValue{strAttr("service.name", "api")}},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: ms}},
+		}},
+	}
+}
+
+func cumulativeSum(name string, start uint64, value float64) *metricspb.Metric {
+	return &metricspb.Metric{
+		Name: name,
+		Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			IsMonotonic:            true,
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			DataPoints: []*metricspb.NumberDataPoint{{
+				StartTimeUnixNano: start,
+				TimeUnixNano:      testTime,
+				Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+			}},
+		}},
+	}
+}
+
+func cumulativeHistogram(name string, counts []uint64, sum float64) *metricspb.Metric {
+	var count uint64
+	for _, c := range counts {
+		count += c
+	}
+
+	return &metricspb.Metric{
+		Name: name,
+		Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			DataPoints: []*metricspb.HistogramDataPoint{{
+				StartTimeUnixNano: testStart,
+				TimeUnixNano:      testTime,
+				ExplicitBounds:    []float64{0.1, 1},
+				BucketCounts:      counts,
+				Count:             count,
+				Sum:               &sum,
+			}},
+		}},
+	}
+}
+
+// Capture records of the last PushList call.
+func capturePush(m *services.MetricServiceMock, pushed *[]storage.Record) {
+	m.On("PushList", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*pushed = args.Get(1).([]storage.Record)
+	}).Return([]storage.Record{}, nil)
+}
+
+func TestTranslator_GaugeAndLabels(t *testing.T) {
+	m := new(services.MetricServiceMock)
+	m.On("Describe", "queue.size", metrics.Metadata{Kind: metrics.KindGauge, Help: "Queue size"}).Return()
+
+	var pushed []storage.Record
+	capturePush(m, &pushed)
+
+	req := exportRequest(&metricspb.Metric{
+		Name:        "queue.size",
+		Description: "Queue size",
+		Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{{
+			TimeUnixNano: testTime,
+			Attributes:   []*commonpb.KeyValue{strAttr("queue", "emails"), strAttr("service.name", "worker")},
+			Value:        &metricspb.NumberDataPoint_AsInt{AsInt: 42},
+		}}}},
+	})
+
+	resp, err := NewTranslator(m).Export(context.Background(), req)
+	require.NoError(t, err)
+	require.Nil(t, resp.GetPartialSuccess())
+
+	require.Equal(t, []storage.Record{{
+		Name:      "queue.size",
+		Labels:    metrics.Labels{"service_name": "worker", "queue": "emails"},
+		Value:     metrics.Gauge(42),
+		Timestamp: time.Unix(0, int64(testTime)).UTC(),
+	}}, pushed)
+
+	m.AssertExpectations(t)
+}
+
+func TestTranslator_SkipNonFinite(t *testing.T) {
+	m := new(services.MetricServiceMock)
+
+	var pushed []storage.Record
+	capturePush(m, &pushed)
+
+	req := exportRequest(
+		&metricspb.Metric{
+			Name: "temperature",
+			Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{
+				{TimeUnixNano: testTime, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: math.NaN()}},
+				{TimeUnixNano: testTime, Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 36.6}},
+			}}},
+		},
+		cumulativeSum("requests", testStart, math.Inf(1)),
+		cumulativeHistogram("latency", []uint64{1, 0, 0}, math.NaN()),
+	)
+
+	resp, err := NewTranslator(m).Export(context.Background(), req)
+	require.NoError(t, err)
+	require.Nil(t, resp.GetPartialSuccess())
+
+	require.Equal(t, []storage.Record{{
+		Name:      "temperature",
+		Labels:    metrics.Labels{"service_name": "api"},
+		Value:     metrics.Gauge(36.6),
+		Timestamp: time.Unix(0, int64(testTime)).UTC(),
+	}}, pushed)
+
+	m.AssertExpectations(t)
+}
+
+func TestTranslator_CumulativeSum(t *testing.T) {
+	ctx := context.Background()
+	m := new(services.MetricServiceMock)
+	labels := metrics.Labels{"service_name": "api"}
+
+	m.On("Get", "requests", metrics.KindCounter, labels).Return(storage.Record{}, entities.ErrRecordNotFound).Once()
+
+	var pushed []storage.Record
+	capturePush(m, &pushed)
+
+	tr := NewTranslator(m)
+
+	steps := []struct {
+		start uint64
+		value float64
+		want  metrics.Counter
+	}{
+		{start: testStart, value: 10.5, want: 10}, // new series, whole value
+		{start: testStart, value: 15.7, want: 5},  // increment
+		{start: testStart, value: 3, want: 3},     // value decreased - reset
+		{start: testStart + 1, value: 4, want: 4}, // start time changed - reset
+	}
+
+	for _, step := range steps {
+		_, err := tr.Export(ctx, exportRequest(cumulativeSum("requests", step.start, step.value)))
+		require.NoError(t, err)
+		require.Len(t, pushed, 1)
+		require.Equal(t, step.want, pushed[0].Value)
+	}
+
+	m.AssertExpectations(t)
+}
+
+func TestTranslator_CumulativeSumStoredBeforeRestart(t *testing.T) {
+	m := new(services.MetricServiceMock)
+	labels := metrics.Labels{"service_name": "api"}
+
+	m.On("Get", "requests", metrics.KindCounter, labels).Return(storage.Record{Name: "requests", Value: metrics.Counter(100)}, nil).Once()
+
+	var pushed []storage.Record
+	capturePush(m, &pushed)
+
+	_, err := NewTranslator(m).Export(context.Background(), exportRequest(cumulativeSum("requests", testStart, 50)))
+	require.NoError(t, err)
+	require.Equal(t, metrics.Counter(0), pushed[0].Value)
+}
+
+func TestTranslator_DeltaSum(t *testing.T) {
+	m := new(services.MetricServiceMock)
+
+	var pushed []storage.Record
+	capturePush(m, &pushed)
+
+	metric := cumulativeSum("requests", testStart, 7)
+	metric.GetSum().AggregationTemporality = metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+
+	_, err := NewTranslator(m).Export(context.Background(), exportRequest(metric))
+	require.NoError(t, err)
+	require.Equal(t, metrics.Counter(7), pushed[0].Value)
+
+	m.AssertNotCalled(t, "Get", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTranslator_CumulativeHistogram(t *testing.T) {
+	ctx := context.Background()
+	m := new(services.MetricServiceMock)
+	labels := metrics.Labels{"service_name": "api"}
+
+	m.On("Get", "latency", metrics.KindHistogram, labels).Return(storage.Record{}, entities.ErrRecordNotFound).Once()
+
+	var pushed []storage.Record
+	capturePush(m, &pushed)
+
+	tr := NewTranslator(m)
+
+	_, err := tr.Export(ctx, exportRequest(cumulativeHistogram("latency", []uint64{1, 2, 0}, 1.5)))
+	require.NoError(t, err)
+	require.Equal(t, metrics.Histogram{Bounds: []float64{0.1, 1}, Counts: []uint64{1, 2, 0}, Count: 3, Sum: 1.5}, pushed[0].Value)
+
+	_, err = tr.Export(ctx, exportRequest(cumulativeHistogram("latency", []uint64{1, 3, 1}, 4)))
+	require.NoError(t, err)
+	require.Equal(t, metrics.Histogram{Bounds: []float64{0.1, 1}, Counts: []uint64{0, 1, 1}, Count: 2, Sum: 2.5}, pushed[0].Value)
+
+	m.AssertExpectations(t)
+}
+
+func TestTranslator_HistogramBucketsChanged(t *testing.T) {
+	ctx := context.Background()
+	m := new(services.MetricServiceMock)
+	labels := metrics.Labels{"service_name": "api"}
+
+	m.On("Get", "latency", metrics.KindHistogram, labels).Return(storage.Record{}, entities.ErrRecordNotFound).Once()
+
+	var pushed []storage.Record
+	capturePush(m, &pushed)
+
+	tr := NewTranslator(m)
+
+	_, err := tr.Export(ctx, exportRequest(cumulativeHistogram("latency", []uint64{1, 2, 0}, 1.5)))
+	require.NoError(t, err)
+
+	changed := cumulativeHistogram("latency", []uint64{1, 3, 1}, 4)
+	changed.GetHistogram().GetDataPoints()[0].ExplicitBounds = []float64{0.5, 1}
+
+	gauge := &metricspb.Metric{
+		Name: "temperature",
+		Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{{
+			Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 36.6},
+		}}}},
+	}
+
+	// rest of the export is stored, series keeps working with original buckets
+	resp, err := tr.Export(ctx, exportRequest(changed, gauge))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), resp.GetPartialSuccess().GetRejectedDataPoints())
+	require.Len(t, pushed, 1)
+	require.Equal(t, "temperature", pushed[0].Name)
+
+	_, err = tr.Export(ctx, exportRequest(cumulativeHistogram("latency", []uint64{2, 2, 0}, 2)))
+	require.NoError(t, err)
+	require.Equal(t, metrics.Histogram{Bounds: []float64{0.1, 1}, Counts: []uint64{1, 0, 0}, Count: 1, Sum: 0.5}, pushed[0].Value)
+
+	m.AssertExpectations(t)
+}
+
+func TestTranslator_HistogramBucketsChangedBeforeRestart(t *testing.T) {
+	m := new(services.MetricServiceMock)
+	labels := metrics.Labels{"service_name": "api"}
+
+	stored := metrics.NewHistogram(0.5, 1)
+	m.On("Get", "latency", metrics.KindHistogram, labels).Return(storage.Record{Name: "latency", Value: stored}, nil).Once()
+
+	resp, err := NewTranslator(m).Export(context.Background(), exportRequest(cumulativeHistogram("latency", []uint64{1, 2, 0}, 1.5)))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), resp.GetPartialSuccess().GetRejectedDataPoints())
+
+	m.AssertNotCalled(t, "PushList", mock.Anything, mock.Anything)
+}
+
+func TestTranslator_PartialSuccess(t *testing.T) {
+	m := new(services.MetricServiceMock)
+
+	var pushed []storage.Record
+	capturePush(m, &pushed)
+
+	broken := cumulativeHistogram("broken", []uint64{1}, 1) // bucket counts do not match bounds
+	broken.GetHistogram().AggregationTemporality = metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+
+	summary := &metricspb.Metric{
+		Name: "rpc.duration",
+		Data: &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: []*metricspb.SummaryDataPoint{{}, {}}}},
+	}
+
+	gauge := &metricspb.Metric{
+		Name: "temperature",
+		Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{{
+			Value: &metricspb.NumberDataPoint_AsDouble{AsDouble: 36.6},
+		}}}},
+	}
+
+	resp, err := NewTranslator(m).Export(context.Background(), exportRequest(broken, summary, gauge))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), resp.GetPartialSuccess().GetRejectedDataPoints())
+	require.NotEmpty(t, resp.GetPartialSuccess().GetErrorMessage())
+
+	require.Len(t, pushed, 1)
+	require.Equal(t, "temperature", pushed[0].Name)
+}
+
+func TestTranslator_PushError(t *testing.T) {
+	m := new(services.MetricServiceMock)
+	m.On("PushList", mock.Anything, mock.Anything).Return([]storage.Record{}, entities.ErrStoragePush)
+
+	gauge := &metricspb.Metric{
+		Name: "temperature",
+		Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: []*metricspb.NumberDataPoint{{}}}},
+	}
+
+	_, err := NewTranslator(m).Export(context.Background(), exportRequest(gauge))
+	require.ErrorIs(t, err, entities.ErrStoragePush)
+}
+
+func TestTranslator_PushErrorRollsBack(t *testing.T) {
+	ctx := context.Background()
+	m := new(services.MetricServiceMock)
+	labels := metrics.Labels{"service_name": "api"}
+
+	m.On("Get", "requests", metrics.KindCounter, labels).Return(storage.Record{}, entities.ErrRecordNotFound)
+	m.On("PushList", mock.Anything, mock.Anything).Return([]storage.Record{}, entities.ErrStoragePush).Once()
+
+	var pushed []storage.Record
+	capturePush(m, &pushed)
+
+	tr := NewTranslator(m)
+
+	_, err := tr.Export(ctx, exportRequest(cumulativeSum("requests", testStart, 10)))
+	require.ErrorIs(t, err, entities.ErrStoragePush)
+
+	// retry counts the whole value again
+	_, err = tr.Export(ctx, exportRequest(cumulativeSum("requests", testStart, 10)))
+	require.NoError(t, err)
+	require.Equal(t, metrics.Counter(10), pushed[0].Value)
+}
@@ -13,28 +13,50 @@ import (
 
 // Backend config
 type Config struct {
-	Address         entities.Address  `env:"ADDRESS" json:"address"`
-	GRPCAddress     entities.Address  `env:"GRPC_ADDRESS" json:"grpc_address"`
-	StoreInterval   int               `env:"STORE_INTERVAL" json:"store_interval"`
-	StorePath       string            `env:"FILE_STORAGE_PATH" json:"store_file"`
-	RestoreOnStart  bool              `env:"RESTORE" json:"restore"`
-	DatabaseDSN     string            `env:"DATABASE_DSN" json:"database_dsn"`
-	Secret          entities.Secret   `env:"KEY" json:"key"`
-	ProfilerAddress entities.Address  `env:"PROFILER_ADDRESS" json:"profiler_address"`
-	PrivateKeyPath  entities.FilePath `env:"CRYPTO_KEY" json:"crypto_key"`
-	TrustedSubnet   *net.IPNet        `env:"TRUSTED_SUBNET" json:"trusted_subnet"`
-	ConfigFilePath  entities.FilePath `env:"CONFIG"`
+	Address             entities.Address  `env:"ADDRESS" json:"address"`
+	GRPCAddress         entities.Address  `env:"GRPC_ADDRESS" json:"grpc_address"`
+	StoreInterval       int               `env:"STORE_INTERVAL" json:"store_interval"`
+	StorePath           string            `env:"FILE_STORAGE_PATH" json:"store_file"`
+	RestoreOnStart      bool              `env:"RESTORE" json:"restore"`
+	StoreKeep           int               `env:"STORE_KEEP" json:"store_keep"`
+	StoreFormat         string            `env:"STORE_FORMAT" json:"store_format"`
+	WALSync             string            `env:"WAL_SYNC" json:"wal_sync"`
+	DatabaseDSN         string            `env:"DATABASE_DSN" json:"database_dsn"`
+	MigrationsSource    string            `env:"MIGRATIONS_SOURCE" json:"migrations_source"`
+	AutoMigrate         bool              `env:"AUTO_MIGRATE" json:"auto_migrate"`
+	DatabaseCopyFrom    int               `env:"DATABASE_COPY_FROM" json:"database_copy_from"`
+	Secret              entities.Secret   `env:"KEY" json:"key"`
+	ProfilerAddress     entities.Address  `env:"PROFILER_ADDRESS" json:"profiler_address"`
+	PrivateKeyPath      entities.FilePath `env:"CRYPTO_KEY" json:"crypto_key"`
+	TrustedSubnet       *net.IPNet        `env:"TRUSTED_SUBNET" json:"trusted_subnet"`
+	StatsDAddress       entities.Address  `env:"STATSD_ADDRESS" json:"statsd_address"`
+	StatsDFlushInterval int               `env:"STATSD_FLUSH_INTERVAL" json:"statsd_flush_interval"`
+	GraphiteAddress     entities.Address  `env:"GRAPHITE_ADDRESS" json:"graphite_address"`
+	GraphiteTemplates   string            `env:"GRAPHITE_TEMPLATES" json:"graphite_templates"`
+	GraphiteMaxConns    int               `env:"GRAPHITE_MAX_CONNECTIONS" json:"graphite_max_connections"`
+	Retention           string            `env:"RETENTION" json:"retention"`
+	RetentionInterval   int               `env:"RETENTION_INTERVAL" json:"retention_interval"`
+	ConfigFilePath      entities.FilePath `env:"CONFIG"`
 }
 
 func NewConfig() (*Config, error) {
 	var err error
 
 	config := &Config{
-		Address:         "0.0.0.0:8080",
-		GRPCAddress:     "0.0.0.0:50051",
-		StoreInterval:   300,
-		RestoreOnStart:  true,
-		ProfilerAddress: "0.0.0.0:8081",
+		Address:             "0.0.0.0:8080",
+		GRPCAddress:         "0.0.0.0:50051",
+		StoreInterval:       300,
+		RestoreOnStart:      true,
+		StoreKeep:           3,
+		StoreFormat:         "json",
+		WALSync:             "periodic",
+		AutoMigrate:         true,
+		DatabaseCopyFrom:    1000,
+		ProfilerAddress:     "0.0.0.0:8081",
+		StatsDFlushInterval: 10,
+		GraphiteMaxConns:    100,
+		Retention:           "*=24h:168h:720h",
+		RetentionInterval:   60,
 	}
 
 	err = config.parse()
@@ -75,6 +97,12 @@ func (c *Config) parseFlags(progname string, args []string) error {
 	secret := c.Secret
 	flags.VarP(&secret, "secret", "k", "a key to sign outgoing data")
 
+	statsdAddress := c.StatsDAddress
+	flags.VarP(&statsdAddress, "statsd-address", "", "address:port for StatsD UDP listener")
+
+	graphiteAddress := c.GraphiteAddress
+	flags.VarP(&graphiteAddress, "graphite-address", "", "address:port for Graphite plaintext TCP listener")
+
 	privateKeyPath := c.PrivateKeyPath
 	flags.VarP(&privateKeyPath, "crypto-key", "", "path to public key to encrypt agent -> server communications")
 
@@ -85,10 +113,21 @@ func (c *Config) parseFlags(progname string, args []string) error {
 	trustedSubnet := flags.IPNetP("trusted-subnet", "t", defaultSubnet, "trusted subnet in CIDR notation")
 
 	// define flags
-	flags.IntVarP(&c.StoreInterval, "store-interval", "i", c.StoreInterval, "interval (s) for dumping metrics to the disk, zero value means saving after each request")
+	flags.IntVarP(&c.StoreInterval, "store-interval", "i", c.StoreInterval, "interval (s) for dumping metrics to the disk, zero value means saving each request to fsynced WAL")
 	flags.StringVarP(&c.StorePath, "store-file", "f", c.StorePath, "path to file to store metrics")
 	flags.BoolVarP(&c.RestoreOnStart, "restore", "r", c.RestoreOnStart, "whether to restore state on startup")
+	flags.IntVarP(&c.StoreKeep, "store-keep", "", c.StoreKeep, "number of previous snapshots kept to restore from if the latest one is corrupted")
+	flags.StringVarP(&c.StoreFormat, "store-format", "", c.StoreFormat, "format of storage snapshots: json or binary, restore reads both")
+	flags.StringVarP(&c.WALSync, "wal-sync", "", c.WALSync, "fsync policy of file storage write-ahead log: always, periodic (every second) or never")
 	flags.StringVarP(&c.DatabaseDSN, "database", "d", c.DatabaseDSN, "PostgreSQL database DSN")
+	flags.StringVarP(&c.MigrationsSource, "migrations-source", "", c.MigrationsSource, "golang-migrate source URL of database migrations, e.g. file://db/migrate, empty to use embedded ones")
+	flags.IntVarP(&c.DatabaseCopyFrom, "database-copy-from", "", c.DatabaseCopyFrom, "batches of this size and bigger are written to database with COPY, 0 to disable")
+	flags.BoolVarP(&c.AutoMigrate, "auto-migrate", "", c.AutoMigrate, "whether to apply database migrations on startup")
+	flags.IntVarP(&c.StatsDFlushInterval, "statsd-flush-interval", "", c.StatsDFlushInterval, "interval (s) for flushing aggregated StatsD metrics")
+	flags.StringVarP(&c.GraphiteTemplates, "graphite-templates", "", c.GraphiteTemplates, "templates mapping Graphite paths to names and labels separated by ';', e.g. 'servers.* .host.measurement*'")
+	flags.IntVarP(&c.GraphiteMaxConns, "graphite-max-connections", "", c.GraphiteMaxConns, "max number of simultaneous Graphite connections")
+	flags.StringVarP(&c.Retention, "retention", "", c.Retention, "retention policies as pattern=raw:1m:1h separated by ';', e.g. 'Heap*=1h:24h:720h;*=24h:168h:0'")
+	flags.IntVarP(&c.RetentionInterval, "retention-interval", "", c.RetentionInterval, "interval (s) for applying retention policies")
 
 	pErr := flags.Parse(args)
 	if pErr != nil {
@@ -102,6 +141,10 @@ func (c *Config) parseFlags(progname string, args []string) error {
 			c.Address = address
 		case "secret":
 			c.Secret = secret
+		case "statsd-address":
+			c.StatsDAddress = statsdAddress
+		case "graphite-address":
+			c.GraphiteAddress = graphiteAddress
 		case "crypto-key":
 			c.PrivateKeyPath = privateKeyPath
 		case "trusted-subnet":
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/graphite"
+	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+)
+
+const (
+	graphiteIdleTimeout           = 5 * time.Minute
+	graphiteBatchSize             = 1000
+	graphiteMaxLineLength         = 64 << 10 // connection sending longer line is closed
+	defaultGraphiteMaxConnections = 100
+)
+
+// Graphite plaintext protocol TCP listener, stores received metrics as gauges.
+type GraphiteServer struct {
+	address        entities.Address
+	maxConnections int
+	parser         *graphite.Parser
+	metricService  services.MetricProvider
+	trustedSubnet  *net.IPNet
+
+	listener net.Listener
+	slots    chan struct{} // semaphore limiting active connections
+	notify   chan error
+
+	mutex sync.Mutex
+	conns map[net.Conn]struct{}
+	done  bool
+	wg    sync.WaitGroup
+}
+
+// Constructor. Empty address disables server, connections from outside of non-nil trusted subnet are rejected.
+func NewGraphiteServer(
+	metricService services.MetricProvider,
+	parser *graphite.Parser,
+	address entities.Address,
+	maxConnections int,
+	trustedSubnet *net.IPNet,
+) *GraphiteServer {
+	if maxConnections <= 0 {
+		maxConnections = defaultGraphiteMaxConnections
+	}
+
+	return &GraphiteServer{
+		address:        address,
+		maxConnections: maxConnections,
+		parser:         parser,
+		metricService:  metricService,
+		trustedSubnet:  trustedSubnet,
+		slots:          make(chan struct{}, maxConnections),
+		notify:         make(chan error, 1),
+		conns:          make(map[net.Conn]struct{}),
+	}
+}
+
+// Start accepting connections in a goroutine.
+func (s *GraphiteServer) Start() {
+	if len(s.address) == 0 {
+		return
+	}
+
+	listener, err := net.Listen("tcp", s.address.String())
+	if err != nil {
+		s.notify <- err
+		return
+	}
+
+	s.listener = listener
+
+	s.wg.Add(1)
+	go s.accept()
+}
+
+// Return channel to handle errors.
+func (s *GraphiteServer) Notify() <-chan error {
+	return s.notify
+}
+
+// Stop accepting connections and wait for active ones to finish.
+// Connections still active when ctx is done are closed forcibly.
+func (s *GraphiteServer) Shutdown(ctx context.Context) error {
+	if s.listener == nil {
+		return nil
+	}
+
+	err := s.listener.Close()
+
+	// unblock readers waiting for data, already read lines are still pushed
+	s.mutex.Lock()
+	s.done = true
+	for conn := range s.conns {
+		_ = conn.SetReadDeadline(time.Now())
+	}
+	s.mutex.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.mutex.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mutex.Unlock()
+
+		<-stopped
+
+		return ctx.Err()
+	}
+
+	return err
+}
+
+func (s *GraphiteServer) accept() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			s.notify <- err
+			return
+		}
+
+		if !trustedAddr(s.trustedSubnet, conn.RemoteAddr()) {
+			conn.Close()
+			continue
+		}
+
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			logging.LogWarn(fmt.Sprintf("graphite: connection limit %d reached, rejecting %s", s.maxConnections, conn.RemoteAddr()))
+			conn.Close()
+
+			continue
+		}
+
+		if !s.track(conn) {
+			<-s.slots
+			conn.Close()
+
+			return
+		}
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *GraphiteServer) track(conn net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.done {
+		return false
+	}
+
+	s.conns[conn] = struct{}{}
+
+	return true
+}
+
+func (s *GraphiteServer) untrack(conn net.Conn) {
+	s.mutex.Lock()
+	delete(s.conns, conn)
+	s.mutex.Unlock()
+
+	conn.Close()
+	<-s.slots
+}
+
+// Set idle timeout for the next read, unless server is shutting down.
+func (s *GraphiteServer) extendDeadline(conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.done {
+		return
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(graphiteIdleTimeout))
+}
+
+func (s *GraphiteServer) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.untrack(conn)
+
+	reader := bufio.NewReaderSize(conn, graphiteMaxLineLength)
+	records := make([]storage.Record, 0, graphiteBatchSize)
+
+	for {
+		s.extendDeadline(conn)
+
+		// line is limited by buffer size, so client can't exhaust memory
+		data, err := reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			err = fmt.Errorf("line from %s exceeds %d bytes", conn.RemoteAddr(), graphiteMaxLineLength)
+			data = nil
+		}
+
+		// partial line is accepted only at the end of stream
+		complete := err == nil || errors.Is(err, io.EOF)
+
+		if line := strings.TrimSpace(string(data)); complete && len(line) > 0 {
+			record, parseErr := s.parser.ParseLine(line)
+			if parseErr != nil {
+				logging.LogDebug(fmt.Sprintf("graphite: skipping %q: %v", line, parseErr))
+			} else {
+				records = append(records, record)
+			}
+		}
+
+		// push when batch is full or there is nothing more to read right now
+		if len(records) >= graphiteBatchSize || (len(records) > 0 && (reader.Buffered() == 0 || err != nil)) {
+			s.push(records)
+			records = records[:0]
+		}
+
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && !errors.Is(err, os.ErrDeadlineExceeded) {
+				logging.LogError(err, "graphite: read failed")
+			}
+
+			return
+		}
+	}
+}
+
+func (s *GraphiteServer) push(records []storage.Record) {
+	// points of the same series are kept as separate samples
+	for _, round := range services.SeriesRounds(records) {
+		if _, err := s.metricService.PushList(context.Background(), round); err != nil {
+			logging.LogError(err, "graphite: push failed")
+			return
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ex0rcist/metflix/internal/graphite"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+func startTestGraphiteServer(t *testing.T, maxConnections int, trustedSubnet *net.IPNet) (*GraphiteServer, *[]storage.Record, *sync.Mutex) {
+	t.Helper()
+
+	var (
+		mutex  sync.Mutex
+		pushed []storage.Record
+	)
+
+	m := new(services.MetricServiceMock)
+	m.On("PushList", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mutex.Lock()
+		pushed = append(pushed, args.Get(1).([]storage.Record)...)
+		mutex.Unlock()
+	}).Return([]storage.Record{}, nil)
+
+	templates, err := graphite.ParseTemplates("servers.* .host.measurement*")
+	require.NoError(t, err)
+
+	srv := NewGraphiteServer(m, graphite.NewParser(templates), "127.0.0.1:0", maxConnections, trustedSubnet)
+	srv.Start()
+
+	return srv, &pushed, &mutex
+}
+
+func TestGraphiteServer_Ingest(t *testing.T) {
+	srv, pushed, mutex := startTestGraphiteServer(t, 10, nil)
+
+	conn, err := net.Dial("tcp", srv.listener.Addr().String())
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("servers.web01.cpu.load 0.5 1700000000\nbroken line here too\napp.rps 10 1700000000\n"))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return len(*pushed) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// idle connection is closed on shutdown
+	require.NoError(t, srv.Shutdown(context.Background()))
+
+	_, err = bufio.NewReader(conn).ReadByte()
+	require.Error(t, err)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	require.Equal(t, "cpu_load", (*pushed)[0].Name)
+	require.Equal(t, metrics.Labels{"host": "web01"}, (*pushed)[0].Labels)
+	require.Equal(t, metrics.Gauge(10), (*pushed)[1].Value)
+}
+
+func TestGraphiteServer_ConnectionLimit(t *testing.T) {
+	srv, _, _ := startTestGraphiteServer(t, 1, nil)
+	defer srv.Shutdown(context.Background()) //nolint:errcheck
+
+	first, err := net.Dial("tcp", srv.listener.Addr().String())
+	require.NoError(t, err)
+	defer first.Close()
+
+	// make sure first connection is accepted before the second one
+	_, err = first.Write([]byte("warmup 1\n"))
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	second, err := net.Dial("tcp", srv.listener.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+
+	_ = second.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = bufio.NewReader(second).ReadByte()
+	require.Error(t, err)
+	require.NotErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestGraphiteServer_Disabled(t *testing.T) {
+	srv := NewGraphiteServer(new(services.MetricServiceMock), graphite.NewParser(nil), "", 0, nil)
+	srv.Start()
+
+	require.NoError(t, srv.Shutdown(context.Background()))
+}
+
+func TestGraphiteServer_LongLine(t *testing.T) {
+	srv, pushed, mutex := startTestGraphiteServer(t, 10, nil)
+	defer srv.Shutdown(context.Background()) //nolint:errcheck
+
+	conn, err := net.Dial("tcp", srv.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("app.rps 10 1700000000\n"))
+	require.NoError(t, err)
+
+	// server closes connection instead of buffering endless line
+	_, _ = conn.Write(bytes.Repeat([]byte("a"), graphiteMaxLineLength+1))
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = bufio.NewReader(conn).ReadByte()
+	require.Error(t, err)
+	require.NotErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	require.Len(t, *pushed, 1)
+}
+
+func TestGraphiteServer_UntrustedSubnet(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	srv, pushed, mutex := startTestGraphiteServer(t, 10, subnet)
+	defer srv.Shutdown(context.Background()) //nolint:errcheck
+
+	conn, err := net.Dial("tcp", srv.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, _ = conn.Write([]byte("app.rps 10 1700000000\n"))
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = bufio.NewReader(conn).ReadByte()
+	require.Error(t, err)
+	require.NotErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	require.Empty(t, *pushed)
+}
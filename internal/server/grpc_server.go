@@ -1,22 +1,31 @@
 package server
 
 import (
+	"context"
 	"net"
+	"time"
 
 	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/grpcserver"
 	"google.golang.org/grpc"
 )
 
+// Time given to in-flight calls to finish on shutdown,
+// long-living streams (e.g. Watch) are cut after it.
+const grpcGracePeriod = 3 * time.Second
+
 type GRPCServer struct {
 	address entities.Address
 	server  *grpc.Server
+	health  *grpcserver.HealthReporter
 	notify  chan error
 }
 
-func NewGRPCServer(server *grpc.Server, address entities.Address) *GRPCServer {
+func NewGRPCServer(server *grpc.Server, health *grpcserver.HealthReporter, address entities.Address) *GRPCServer {
 	s := &GRPCServer{
 		address: address,
 		server:  server,
+		health:  health,
 		notify:  make(chan error, 1),
 	}
 
@@ -24,6 +33,10 @@ func NewGRPCServer(server *grpc.Server, address entities.Address) *GRPCServer {
 }
 
 func (s *GRPCServer) Start() {
+	if s.health != nil {
+		s.health.Start()
+	}
+
 	go func() {
 		listen, err := net.Listen("tcp", s.address.String())
 		if err != nil {
@@ -40,10 +53,28 @@ func (s *GRPCServer) Notify() <-chan error {
 	return s.notify
 }
 
-func (s *GRPCServer) Shutdown() {
+func (s *GRPCServer) Shutdown(ctx context.Context) {
 	if s.server == nil {
 		return
 	}
 
-	s.server.GracefulStop()
+	// let health watchers know before connections are closed
+	if s.health != nil {
+		s.health.Shutdown()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, grpcGracePeriod)
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.server.Stop()
+	}
 }
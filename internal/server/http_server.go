@@ -39,6 +39,11 @@ func (s *HTTPServer) Notify() <-chan error {
 	return s.notify
 }
 
+// Register function to call when shutdown starts, e.g. to finish long-living responses.
+func (s *HTTPServer) OnShutdown(f func()) {
+	s.server.RegisterOnShutdown(f)
+}
+
 // Shutdown server.
 func (s *HTTPServer) Shutdown(ctx context.Context) error {
 	if s.server == nil {
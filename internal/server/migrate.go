@@ -0,0 +1,130 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// Usage of "server migrate" subcommand.
+const MigrateUsage = "usage: server migrate up [N] | down [N|all] | status | force VERSION [flags]"
+
+// Database schema operations available via "server migrate".
+type Migrator interface {
+	Up(steps int) error
+	Down(steps int) error
+	Status() (storage.MigrationStatus, error)
+	Force(version int) error
+}
+
+var _ Migrator = storage.PostgresMigrator{}
+
+// Run "server migrate" subcommand against database from config, args follow "migrate".
+func Migrate(args []string, out io.Writer) error {
+	config, err := NewConfig()
+	if err != nil {
+		return err
+	}
+
+	if len(config.DatabaseDSN) == 0 {
+		return fmt.Errorf("%w: database DSN is required", entities.ErrBadMigrateCommand)
+	}
+
+	return runMigrate(storage.NewPostgresMigrator(config.DatabaseDSN, config.MigrationsSource, 1), args, out)
+}
+
+func runMigrate(migrator Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: %s", entities.ErrBadMigrateCommand, MigrateUsage)
+	}
+
+	command, params := args[0], positionalArgs(args[1:])
+
+	switch command {
+	case "up":
+		steps, err := parseMigrateSteps(params, 0)
+		if err != nil {
+			return err
+		}
+
+		return reportMigrate(out, migrator.Up(steps))
+	case "down":
+		steps, err := parseMigrateSteps(params, 1) // rolling back everything must be asked explicitly
+		if err != nil {
+			return err
+		}
+
+		return reportMigrate(out, migrator.Down(steps))
+	case "force":
+		if len(params) == 0 {
+			return fmt.Errorf("%w: force requires VERSION", entities.ErrBadMigrateCommand)
+		}
+
+		version, err := strconv.Atoi(params[0])
+		if err != nil {
+			return fmt.Errorf("%w: bad version %q", entities.ErrBadMigrateCommand, params[0])
+		}
+
+		return reportMigrate(out, migrator.Force(version))
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(out, "version: %d\nlatest: %d\ndirty: %v\n", status.Version, status.Latest, status.Dirty)
+
+		return err
+	default:
+		return fmt.Errorf("%w: unknown command %q, %s", entities.ErrBadMigrateCommand, command, MigrateUsage)
+	}
+}
+
+// Number of steps, "all" or zero means every migration.
+func parseMigrateSteps(params []string, defaultSteps int) (int, error) {
+	if len(params) == 0 {
+		return defaultSteps, nil
+	}
+
+	if params[0] == "all" {
+		return 0, nil
+	}
+
+	steps, err := strconv.Atoi(params[0])
+	if err != nil || steps < 0 {
+		return 0, fmt.Errorf("%w: bad number of steps %q", entities.ErrBadMigrateCommand, params[0])
+	}
+
+	return steps, nil
+}
+
+func reportMigrate(out io.Writer, err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		_, err = fmt.Fprintln(out, "no change")
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, "done")
+
+	return err
+}
+
+// Leading arguments before flags.
+func positionalArgs(args []string) []string {
+	for i, arg := range args {
+		if len(arg) > 0 && arg[0] == '-' {
+			return args[:i]
+		}
+	}
+
+	return args
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ Migrator = (*MigratorMock)(nil)
+
+type MigratorMock struct {
+	mock.Mock
+}
+
+func (m *MigratorMock) Up(steps int) error {
+	args := m.Called(steps)
+	return args.Error(0)
+}
+
+func (m *MigratorMock) Down(steps int) error {
+	args := m.Called(steps)
+	return args.Error(0)
+}
+
+func (m *MigratorMock) Status() (storage.MigrationStatus, error) {
+	args := m.Called()
+	return args.Get(0).(storage.MigrationStatus), args.Error(1)
+}
+
+func (m *MigratorMock) Force(version int) error {
+	args := m.Called(version)
+	return args.Error(0)
+}
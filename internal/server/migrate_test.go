@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunMigrate(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		setup  func(m *MigratorMock)
+		output string
+	}{
+		{
+			name:   "up all",
+			args:   []string{"up", "--database=postgres://localhost"},
+			setup:  func(m *MigratorMock) { m.On("Up", 0).Return(nil) },
+			output: "done\n",
+		},
+		{
+			name:   "up steps",
+			args:   []string{"up", "2"},
+			setup:  func(m *MigratorMock) { m.On("Up", 2).Return(nil) },
+			output: "done\n",
+		},
+		{
+			name:   "up no change",
+			args:   []string{"up"},
+			setup:  func(m *MigratorMock) { m.On("Up", 0).Return(migrate.ErrNoChange) },
+			output: "no change\n",
+		},
+		{
+			name:   "down one step by default",
+			args:   []string{"down"},
+			setup:  func(m *MigratorMock) { m.On("Down", 1).Return(nil) },
+			output: "done\n",
+		},
+		{
+			name:   "down all",
+			args:   []string{"down", "all"},
+			setup:  func(m *MigratorMock) { m.On("Down", 0).Return(nil) },
+			output: "done\n",
+		},
+		{
+			name:   "force",
+			args:   []string{"force", "5"},
+			setup:  func(m *MigratorMock) { m.On("Force", 5).Return(nil) },
+			output: "done\n",
+		},
+		{
+			name: "status",
+			args: []string{"status"},
+			setup: func(m *MigratorMock) {
+				m.On("Status").Return(storage.MigrationStatus{Version: 5, Latest: 6, Dirty: true}, nil)
+			},
+			output: "version: 5\nlatest: 6\ndirty: true\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := new(MigratorMock)
+			tt.setup(m)
+
+			var out bytes.Buffer
+			require.NoError(t, runMigrate(m, tt.args, &out))
+			require.Equal(t, tt.output, out.String())
+
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRunMigrateBadArgs(t *testing.T) {
+	tests := [][]string{
+		nil,
+		{"sideways"},
+		{"up", "many"},
+		{"down", "x"},
+		{"force"},
+		{"force", "v5"},
+	}
+
+	for _, args := range tests {
+		err := runMigrate(new(MigratorMock), args, &bytes.Buffer{})
+		require.ErrorIs(t, err, entities.ErrBadMigrateCommand, "args %v", args)
+	}
+}
@@ -9,12 +9,16 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/ex0rcist/metflix/internal/graphite"
 	"github.com/ex0rcist/metflix/internal/grpcserver"
 	"github.com/ex0rcist/metflix/internal/httpserver"
 	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/internal/otlp"
 	"github.com/ex0rcist/metflix/internal/security"
 	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/statsd"
 	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/internal/utils"
 )
 
 const shutdownTimeout = 60 * time.Second
@@ -25,6 +29,8 @@ type Server struct {
 	httpServer     *HTTPServer
 	grpcServer     *GRPCServer
 	profilerServer *ProfilerServer
+	statsdServer   *StatsDServer
+	graphiteServer *GraphiteServer
 	storage        storage.MetricsStorage
 	privateKey     security.PrivateKey
 }
@@ -49,15 +55,26 @@ func New() (*Server, error) {
 	metricService := services.NewMetricService(dataStorage)
 	healthService := services.NewHealthCheckService(dataStorage)
 
-	httpServer := setupHTTPServer(config, metricService, healthService, privateKey)
-	grpcServer := setupGRPCServer(config, metricService, healthService, privateKey)
+	// shared by OTLP/HTTP and OTLP/gRPC, so cumulative series are tracked once
+	otlpTranslator := otlp.NewTranslator(metricService)
+
+	httpServer := setupHTTPServer(config, metricService, healthService, otlpTranslator, privateKey)
+	grpcServer := setupGRPCServer(config, metricService, healthService, otlpTranslator, privateKey)
 	profilerServer := setupProfilerServer(config)
+	statsdServer := setupStatsDServer(config, metricService)
+
+	graphiteServer, err := setupGraphiteServer(config, metricService)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Server{
 		config:         config,
 		httpServer:     httpServer,
 		grpcServer:     grpcServer,
 		profilerServer: profilerServer,
+		statsdServer:   statsdServer,
+		graphiteServer: graphiteServer,
 		storage:        dataStorage,
 		privateKey:     privateKey,
 	}, nil
@@ -71,6 +88,8 @@ func (s *Server) Start() {
 	s.httpServer.Start()
 	s.grpcServer.Start()
 	s.profilerServer.Start()
+	s.statsdServer.Start()
+	s.graphiteServer.Start()
 
 	logging.LogInfo(s.String())
 	logging.LogInfo("server ready")
@@ -84,6 +103,10 @@ func (s *Server) Start() {
 		logging.LogError(err, "Server -> Start() -> s.grpcServer.Notify")
 	case err := <-s.profilerServer.Notify():
 		logging.LogError(err, "Server -> Start() - s.profilerServer.Notify")
+	case err := <-s.statsdServer.Notify():
+		logging.LogError(err, "Server -> Start() - s.statsdServer.Notify")
+	case err := <-s.graphiteServer.Notify():
+		logging.LogError(err, "Server -> Start() - s.graphiteServer.Notify")
 	}
 
 	logging.LogInfo("shutting down...")
@@ -128,6 +151,18 @@ func (s *Server) String() string {
 		str = append(str, fmt.Sprintf("trusted-subnet=%v", s.config.TrustedSubnet.String()))
 	}
 
+	if len(s.config.StatsDAddress) > 0 {
+		str = append(str, fmt.Sprintf("statsd-address=%s", s.config.StatsDAddress))
+	}
+
+	if len(s.config.GraphiteAddress) > 0 {
+		str = append(str, fmt.Sprintf("graphite-address=%s", s.config.GraphiteAddress))
+	}
+
+	if len(s.config.Retention) > 0 {
+		str = append(str, fmt.Sprintf("retention=%s", s.config.Retention))
+	}
+
 	return "server config: " + strings.Join(str, "; ")
 }
 
@@ -138,7 +173,17 @@ func (s *Server) shutdown(ctx context.Context) {
 	}
 
 	logging.LogInfo("shutting down gRPC API")
-	s.grpcServer.Shutdown()
+	s.grpcServer.Shutdown(ctx)
+
+	logging.LogInfo("shutting down StatsD listener")
+	if err := s.statsdServer.Shutdown(ctx); err != nil {
+		logging.LogError(err)
+	}
+
+	logging.LogInfo("shutting down Graphite listener")
+	if err := s.graphiteServer.Shutdown(ctx); err != nil {
+		logging.LogError(err)
+	}
 
 	logging.LogInfo("shutting down storage")
 	if err := s.storage.Close(ctx); err != nil {
@@ -155,10 +200,11 @@ func setupHTTPServer(
 	config *Config,
 	metricService services.MetricProvider,
 	healthService services.HealthChecker,
+	otlpTranslator *otlp.Translator,
 	privateKey security.PrivateKey,
 ) *HTTPServer {
 	healthResource := httpserver.NewHealthResource(healthService)
-	metricResource := httpserver.NewMetricResource(metricService)
+	metricResource := httpserver.NewMetricResource(metricService, otlpTranslator)
 
 	handler := httpserver.NewBackend(
 		httpserver.WithTrustedSubnet(config.TrustedSubnet),
@@ -168,35 +214,72 @@ func setupHTTPServer(
 		httpserver.WithMetricResource(metricResource),
 	)
 
-	return NewHTTPServer(handler, config.Address)
+	httpServer := NewHTTPServer(handler, config.Address)
+	httpServer.OnShutdown(metricResource.CloseStreams)
+
+	return httpServer
 }
 
 func setupGRPCServer(
 	config *Config,
 	metricService services.MetricProvider,
 	healthService services.HealthChecker,
+	otlpTranslator *otlp.Translator,
 	privateKey security.PrivateKey,
 ) *GRPCServer {
+	healthReporter := grpcserver.NewHealthReporter(healthService, 0)
+
 	srv := grpcserver.NewBackend(
 		grpcserver.WithTrustedSubnet(config.TrustedSubnet),
 		grpcserver.WithPrivateKey(privateKey),
 		grpcserver.WithHealthService(healthService),
+		grpcserver.WithHealthReporter(healthReporter),
 		grpcserver.WithMetricService(metricService),
+		grpcserver.WithOTLPTranslator(otlpTranslator),
 	)
 
-	return NewGRPCServer(srv, config.GRPCAddress)
+	return NewGRPCServer(srv, healthReporter, config.GRPCAddress)
 }
 
 func setupProfilerServer(config *Config) *ProfilerServer {
 	return NewProfilerServer(config)
 }
 
+func setupStatsDServer(config *Config, metricService services.MetricProvider) *StatsDServer {
+	aggregator := statsd.NewAggregator(metricService)
+
+	return NewStatsDServer(aggregator, config.StatsDAddress, utils.IntToDuration(config.StatsDFlushInterval), config.TrustedSubnet)
+}
+
+func setupGraphiteServer(config *Config, metricService services.MetricProvider) (*GraphiteServer, error) {
+	templates, err := graphite.ParseTemplates(config.GraphiteTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := graphite.NewParser(templates)
+
+	return NewGraphiteServer(metricService, parser, config.GraphiteAddress, config.GraphiteMaxConns, config.TrustedSubnet), nil
+}
+
 func setupStorage(config *Config) (storage.MetricsStorage, error) {
+	policies, err := storage.ParseRetentionPolicies(config.Retention)
+	if err != nil {
+		return nil, err
+	}
+
 	return storage.NewStorage(
 		config.DatabaseDSN,
 		config.StorePath,
 		config.StoreInterval,
 		config.RestoreOnStart,
+		storage.WithRetention(policies, utils.IntToDuration(config.RetentionInterval)),
+		storage.WithWALSync(config.WALSync),
+		storage.WithSnapshotsKept(config.StoreKeep),
+		storage.WithSnapshotFormat(config.StoreFormat),
+		storage.WithMigrations(config.MigrationsSource, config.AutoMigrate),
+		storage.WithCopyThreshold(config.DatabaseCopyFrom),
+		storage.WithPartitionMaintenance(true),
 	)
 }
 
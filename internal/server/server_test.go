@@ -13,6 +13,10 @@ func TestNew(t *testing.T) {
 	if server.storage == nil {
 		t.Fatal("expected storage to not be nil")
 	}
+
+	if server.config.Retention == "" {
+		t.Fatal("expected history to be limited by default retention policy")
+	}
 }
 
 func TestParseFlags(t *testing.T) {
@@ -34,6 +38,54 @@ func TestParseFlags(t *testing.T) {
 			want:    Config{Address: "127.0.0.1:81"},
 			wantErr: false,
 		},
+		{
+			name:    "retention",
+			args:    []string{"--retention=*=24h:168h:0", "--retention-interval=30"},
+			want:    Config{Address: "default", Retention: "*=24h:168h:0", RetentionInterval: 30},
+			wantErr: false,
+		},
+		{
+			name:    "wal sync",
+			args:    []string{"--wal-sync=always"},
+			want:    Config{Address: "default", WALSync: "always"},
+			wantErr: false,
+		},
+		{
+			name:    "store keep",
+			args:    []string{"--store-keep=5"},
+			want:    Config{Address: "default", StoreKeep: 5},
+			wantErr: false,
+		},
+		{
+			name:    "store format",
+			args:    []string{"--store-format=binary"},
+			want:    Config{Address: "default", StoreFormat: "binary"},
+			wantErr: false,
+		},
+		{
+			name:    "migrations",
+			args:    []string{"--migrations-source=file://db/migrate", "--auto-migrate"},
+			want:    Config{Address: "default", MigrationsSource: "file://db/migrate", AutoMigrate: true},
+			wantErr: false,
+		},
+		{
+			name:    "database copy",
+			args:    []string{"--database-copy-from=5000"},
+			want:    Config{Address: "default", DatabaseCopyFrom: 5000},
+			wantErr: false,
+		},
+		{
+			name:    "statsd",
+			args:    []string{"--statsd-address=127.0.0.1:9125", "--statsd-flush-interval=5"},
+			want:    Config{Address: "default", StatsDAddress: "127.0.0.1:9125", StatsDFlushInterval: 5},
+			wantErr: false,
+		},
+		{
+			name:    "graphite",
+			args:    []string{"--graphite-address=127.0.0.1:2003", "--graphite-templates=servers.* .host.measurement*", "--graphite-max-connections=5"},
+			want:    Config{Address: "default", GraphiteAddress: "127.0.0.1:2003", GraphiteTemplates: "servers.* .host.measurement*", GraphiteMaxConns: 5},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
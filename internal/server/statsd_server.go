@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/internal/statsd"
+)
+
+const (
+	statsdPacketSize           = 65535 // max size of UDP datagram
+	defaultStatsDFlushInterval = 10 * time.Second
+)
+
+// StatsD UDP listener, aggregates received metrics and flushes them periodically.
+type StatsDServer struct {
+	address       entities.Address
+	flushInterval time.Duration
+	aggregator    *statsd.Aggregator
+	trustedSubnet *net.IPNet
+
+	conn   net.PacketConn
+	notify chan error
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// Constructor. Empty address disables server, packets from outside of non-nil trusted subnet are dropped.
+func NewStatsDServer(
+	aggregator *statsd.Aggregator,
+	address entities.Address,
+	flushInterval time.Duration,
+	trustedSubnet *net.IPNet,
+) *StatsDServer {
+	if flushInterval <= 0 {
+		flushInterval = defaultStatsDFlushInterval
+	}
+
+	return &StatsDServer{
+		address:       address,
+		flushInterval: flushInterval,
+		aggregator:    aggregator,
+		trustedSubnet: trustedSubnet,
+		notify:        make(chan error, 1),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start listening and flushing in goroutines.
+func (s *StatsDServer) Start() {
+	if len(s.address) == 0 {
+		return
+	}
+
+	conn, err := net.ListenPacket("udp", s.address.String())
+	if err != nil {
+		s.notify <- err
+		return
+	}
+
+	s.conn = conn
+
+	s.wg.Add(2)
+	go s.listen()
+	go s.flushLoop()
+}
+
+// Return channel to handle errors.
+func (s *StatsDServer) Notify() <-chan error {
+	return s.notify
+}
+
+// Stop listening and flush collected metrics.
+func (s *StatsDServer) Shutdown(ctx context.Context) error {
+	if s.conn == nil {
+		return nil
+	}
+
+	close(s.done)
+
+	err := s.conn.Close()
+	s.wg.Wait()
+
+	if flushErr := s.aggregator.Flush(ctx); flushErr != nil {
+		return flushErr
+	}
+
+	return err
+}
+
+func (s *StatsDServer) listen() {
+	defer s.wg.Done()
+
+	buf := make([]byte, statsdPacketSize)
+
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			s.notify <- err
+			return
+		}
+
+		if !s.trusted(addr) {
+			continue
+		}
+
+		s.aggregator.AddPacket(buf[:n])
+	}
+}
+
+func (s *StatsDServer) trusted(addr net.Addr) bool {
+	return trustedAddr(s.trustedSubnet, addr)
+}
+
+// Check that UDP or TCP client address belongs to trusted subnet, nil subnet trusts everyone.
+func trustedAddr(trustedSubnet *net.IPNet, addr net.Addr) bool {
+	if trustedSubnet == nil {
+		return true
+	}
+
+	var clientIP net.IP
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		clientIP = a.IP
+	case *net.TCPAddr:
+		clientIP = a.IP
+	}
+
+	if !trustedSubnet.Contains(clientIP) {
+		logging.LogError(entities.UntrustedSubnetError(clientIP))
+		return false
+	}
+
+	return true
+}
+
+func (s *StatsDServer) flushLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.aggregator.Flush(context.Background()); err != nil {
+				logging.LogError(err)
+			}
+		}
+	}
+}
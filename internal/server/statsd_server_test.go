@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/statsd"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+func TestStatsDServer_FlushOnShutdown(t *testing.T) {
+	m := new(services.MetricServiceMock)
+
+	pushed := make(chan []storage.Record, 1)
+	m.On("PushList", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		pushed <- args.Get(1).([]storage.Record)
+	}).Return([]storage.Record{}, nil)
+
+	srv := NewStatsDServer(statsd.NewAggregator(m), "127.0.0.1:0", time.Hour, nil)
+	srv.Start()
+
+	conn, err := net.Dial("udp", srv.conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("requests:3|c"))
+	require.NoError(t, err)
+
+	// datagram is delivered asynchronously
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, srv.Shutdown(context.Background()))
+
+	records := <-pushed
+	require.Len(t, records, 1)
+	require.Equal(t, "requests", records[0].Name)
+	require.Equal(t, metrics.Counter(3), records[0].Value)
+}
+
+func TestStatsDServer_Disabled(t *testing.T) {
+	srv := NewStatsDServer(statsd.NewAggregator(new(services.MetricServiceMock)), "", time.Second, nil)
+	srv.Start()
+
+	require.NoError(t, srv.Shutdown(context.Background()))
+}
+
+func TestStatsDServer_UntrustedSubnet(t *testing.T) {
+	m := new(services.MetricServiceMock)
+
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	srv := NewStatsDServer(statsd.NewAggregator(m), "127.0.0.1:0", time.Hour, subnet)
+	srv.Start()
+
+	conn, err := net.Dial("udp", srv.conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("requests:3|c"))
+	require.NoError(t, err)
+
+	// datagram is delivered asynchronously
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, srv.Shutdown(context.Background()))
+
+	m.AssertNotCalled(t, "PushList", mock.Anything, mock.Anything)
+}
+
+func TestStatsDServer_Trusted(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("127.0.0.0/8")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		subnet *net.IPNet
+		addr   net.Addr
+		want   bool
+	}{
+		{name: "no subnet", addr: &net.UDPAddr{IP: net.ParseIP("10.0.0.1")}, want: true},
+		{name: "inside subnet", subnet: subnet, addr: &net.UDPAddr{IP: net.ParseIP("127.0.0.1")}, want: true},
+		{name: "outside subnet", subnet: subnet, addr: &net.UDPAddr{IP: net.ParseIP("10.0.0.1")}, want: false},
+		{name: "TCP inside subnet", subnet: subnet, addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1")}, want: true},
+		{name: "unknown address", subnet: subnet, addr: &net.IPAddr{IP: net.ParseIP("127.0.0.1")}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewStatsDServer(nil, "", time.Second, tt.subnet)
+			require.Equal(t, tt.want, srv.trusted(tt.addr))
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+// Supported aggregation functions for range queries.
+const (
+	AggAvg  = "avg"
+	AggMin  = "min"
+	AggMax  = "max"
+	AggSum  = "sum"
+	AggLast = "last"
+)
+
+// Aggregated value of the series for a single step, timestamp is the beginning of the step.
+type Point struct {
+	Timestamp time.Time `json:"ts"`
+	Value     float64   `json:"value"`
+}
+
+// Group samples into step-wide buckets starting at from and aggregate every non-empty bucket.
+// Samples must be ordered by time, as returned by storage Range().
+func Aggregate(samples []storage.Sample, from time.Time, step time.Duration, agg string) ([]Point, error) {
+	rollups := make([]storage.Rollup, 0, len(samples))
+
+	for _, sample := range samples {
+		rollup, ok := storage.SampleToRollup(sample)
+		if !ok {
+			if _, isGauge := sample.Value.(metrics.Gauge); isGauge {
+				continue // non-finite value
+			}
+
+			return nil, entities.ErrRangeUnsupportedKind
+		}
+
+		rollups = append(rollups, rollup)
+	}
+
+	return AggregateRollups(rollups, from, step, agg)
+}
+
+// Same as Aggregate() for downsampled data. Averages are weighted by rollup counts.
+func AggregateRollups(rollups []storage.Rollup, from time.Time, step time.Duration, agg string) ([]Point, error) {
+	if step <= 0 {
+		return nil, entities.ErrRangeInvalid
+	}
+
+	reduce, err := reducer(agg)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, 0)
+	bucket := make([]storage.Rollup, 0)
+	bucketStart := time.Time{}
+
+	flush := func() {
+		if len(bucket) > 0 {
+			points = append(points, Point{Timestamp: bucketStart, Value: reduce(bucket)})
+			bucket = bucket[:0]
+		}
+	}
+
+	for _, rollup := range rollups {
+		if rollup.Timestamp.Before(from) {
+			continue
+		}
+
+		start := from.Add(rollup.Timestamp.Sub(from) / step * step)
+		if !start.Equal(bucketStart) {
+			flush()
+			bucketStart = start
+		}
+
+		bucket = append(bucket, rollup)
+	}
+
+	flush()
+
+	return points, nil
+}
+
+func reducer(agg string) (func([]storage.Rollup) float64, error) {
+	switch agg {
+	case AggAvg:
+		return func(rollups []storage.Rollup) float64 {
+			total := storage.Rollup{}
+			for _, r := range rollups {
+				total.Sum += r.Sum
+				total.Count += r.Count
+			}
+
+			return total.Avg()
+		}, nil
+	case AggMin:
+		return func(rollups []storage.Rollup) float64 {
+			result := math.Inf(1)
+			for _, r := range rollups {
+				result = math.Min(result, r.Min)
+			}
+
+			return result
+		}, nil
+	case AggMax:
+		return func(rollups []storage.Rollup) float64 {
+			result := math.Inf(-1)
+			for _, r := range rollups {
+				result = math.Max(result, r.Max)
+			}
+
+			return result
+		}, nil
+	case AggSum:
+		return func(rollups []storage.Rollup) float64 {
+			var sum float64
+			for _, r := range rollups {
+				sum += r.Sum
+			}
+
+			return sum
+		}, nil
+	case AggLast:
+		return func(rollups []storage.Rollup) float64 {
+			return rollups[len(rollups)-1].Avg()
+		}, nil
+	default:
+		return nil, entities.ErrRangeUnknownAggregation
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregate(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	samples := []storage.Sample{
+		{Timestamp: from.Add(-time.Second), Value: metrics.Gauge(100)}, // before range, ignored
+		{Timestamp: from, Value: metrics.Gauge(1)},
+		{Timestamp: from.Add(20 * time.Second), Value: metrics.Gauge(5)},
+		{Timestamp: from.Add(40 * time.Second), Value: metrics.Gauge(3)},
+		{Timestamp: from.Add(60 * time.Second), Value: metrics.Gauge(10)},
+		{Timestamp: from.Add(185 * time.Second), Value: metrics.Gauge(7)},
+	}
+
+	tests := []struct {
+		agg  string
+		want []float64
+	}{
+		{agg: AggAvg, want: []float64{3, 10, 7}},
+		{agg: AggMin, want: []float64{1, 10, 7}},
+		{agg: AggMax, want: []float64{5, 10, 7}},
+		{agg: AggSum, want: []float64{9, 10, 7}},
+		{agg: AggLast, want: []float64{3, 10, 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.agg, func(t *testing.T) {
+			points, err := Aggregate(samples, from, time.Minute, tt.agg)
+			require.NoError(t, err)
+
+			require.Equal(t, []Point{
+				{Timestamp: from, Value: tt.want[0]},
+				{Timestamp: from.Add(time.Minute), Value: tt.want[1]},
+				{Timestamp: from.Add(3 * time.Minute), Value: tt.want[2]},
+			}, points)
+		})
+	}
+}
+
+func TestAggregateCounters(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	samples := []storage.Sample{
+		{Timestamp: from, Value: metrics.Counter(1)},
+		{Timestamp: from.Add(time.Second), Value: metrics.Counter(2)},
+	}
+
+	points, err := Aggregate(samples, from, time.Minute, AggLast)
+	require.NoError(t, err)
+	require.Equal(t, []Point{{Timestamp: from, Value: 2}}, points)
+}
+
+func TestAggregateSkipsNonFinite(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	samples := []storage.Sample{
+		{Timestamp: from, Value: metrics.Gauge(1)},
+		{Timestamp: from.Add(time.Second), Value: metrics.Gauge(math.NaN())},
+		{Timestamp: from.Add(2 * time.Second), Value: metrics.Gauge(3)},
+	}
+
+	points, err := Aggregate(samples, from, time.Minute, AggAvg)
+	require.NoError(t, err)
+	require.Equal(t, []Point{{Timestamp: from, Value: 2}}, points)
+}
+
+func TestAggregateRollups(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	rollups := []storage.Rollup{
+		{Timestamp: from, Min: 1, Max: 5, Sum: 6, Count: 2},
+		{Timestamp: from.Add(time.Minute), Min: 0, Max: 3, Sum: 3, Count: 4},
+		{Timestamp: from.Add(time.Hour), Min: 8, Max: 8, Sum: 8, Count: 1},
+	}
+
+	tests := []struct {
+		agg  string
+		want []float64
+	}{
+		{agg: AggAvg, want: []float64{1.5, 8}}, // weighted by count
+		{agg: AggMin, want: []float64{0, 8}},
+		{agg: AggMax, want: []float64{5, 8}},
+		{agg: AggSum, want: []float64{9, 8}},
+		{agg: AggLast, want: []float64{0.75, 8}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.agg, func(t *testing.T) {
+			points, err := AggregateRollups(rollups, from, time.Hour, tt.agg)
+			require.NoError(t, err)
+
+			require.Equal(t, []Point{
+				{Timestamp: from, Value: tt.want[0]},
+				{Timestamp: from.Add(time.Hour), Value: tt.want[1]},
+			}, points)
+		})
+	}
+}
+
+func TestAggregateErrors(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		samples []storage.Sample
+		step    time.Duration
+		agg     string
+		wantErr error
+	}{
+		{name: "zero step", step: 0, agg: AggAvg, wantErr: entities.ErrRangeInvalid},
+		{name: "unknown aggregation", step: time.Minute, agg: "median", wantErr: entities.ErrRangeUnknownAggregation},
+		{
+			name:    "histogram",
+			samples: []storage.Sample{{Timestamp: from, Value: metrics.NewHistogram()}},
+			step:    time.Minute,
+			agg:     AggAvg,
+			wantErr: entities.ErrRangeUnsupportedKind,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Aggregate(tt.samples, from, tt.step, tt.agg)
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestAggregateEmpty(t *testing.T) {
+	points, err := Aggregate(nil, time.Now(), time.Minute, AggAvg)
+	require.NoError(t, err)
+	require.Empty(t, points)
+}
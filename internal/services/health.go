@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/ex0rcist/metflix/internal/entities"
@@ -11,14 +12,44 @@ import (
 
 const defaultTimeout = 5 * time.Second
 
+// Statuses of health checks and reports.
+const (
+	HealthPass = "pass"
+	HealthFail = "fail"
+)
+
 var _ HealthChecker = HealthCheckService{}
 
 type HealthChecker interface {
 	Ping(ctx context.Context) error
+	Liveness(ctx context.Context) HealthReport
+	Readiness(ctx context.Context) HealthReport
+	Storage(ctx context.Context) HealthReport
+}
+
+// Result of a single check, last error is kept after the check recovers.
+type CheckResult struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Latency     float64    `json:"latency_ms"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Aggregated report, passes if every check passes.
+type HealthReport struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Check if every check passed.
+func (r HealthReport) Passed() bool {
+	return r.Status == HealthPass
 }
 
 type HealthCheckService struct {
-	storage storage.MetricsStorage
+	storage    storage.MetricsStorage
+	lastErrors *lastErrors
 }
 
 // Interface to check if storage supports healthcheck
@@ -28,7 +59,7 @@ type PingableStorage interface {
 
 // Pinger constructor.
 func NewHealthCheckService(storage storage.MetricsStorage) *HealthCheckService {
-	return &HealthCheckService{storage: storage}
+	return &HealthCheckService{storage: storage, lastErrors: newLastErrors()}
 }
 
 // Ping-pong.
@@ -47,3 +78,103 @@ func (s HealthCheckService) Ping(ctx context.Context) error {
 
 	return nil
 }
+
+// Report whether process is able to serve requests, regardless of its dependencies.
+func (s HealthCheckService) Liveness(ctx context.Context) HealthReport {
+	return s.run(ctx, []storage.HealthCheck{
+		{Name: "process", Check: func(context.Context) error { return nil }},
+	})
+}
+
+// Report whether process and its dependencies are ready to serve requests.
+// Storage is the only dependency for now.
+func (s HealthCheckService) Readiness(ctx context.Context) HealthReport {
+	return s.run(ctx, s.storageChecks())
+}
+
+// Report whether storage is available, using checks provided by the storage itself.
+func (s HealthCheckService) Storage(ctx context.Context) HealthReport {
+	return s.run(ctx, s.storageChecks())
+}
+
+func (s HealthCheckService) storageChecks() []storage.HealthCheck {
+	var checks []storage.HealthCheck
+
+	switch strg := s.storage.(type) {
+	case storage.CheckableStorage:
+		checks = strg.HealthChecks()
+	case PingableStorage:
+		checks = []storage.HealthCheck{{Name: "storage", Check: strg.Ping}}
+	default:
+		checks = []storage.HealthCheck{{Name: "storage", Check: func(context.Context) error {
+			return entities.ErrStorageUnpingable
+		}}}
+	}
+
+	return checks
+}
+
+func (s HealthCheckService) run(ctx context.Context, checks []storage.HealthCheck) HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	report := HealthReport{Status: HealthPass, Checks: make([]CheckResult, 0, len(checks))}
+
+	for _, check := range checks {
+		start := time.Now()
+		err := check.Check(ctx)
+
+		result := CheckResult{
+			Name:    check.Name,
+			Status:  HealthPass,
+			Latency: float64(time.Since(start).Microseconds()) / 1000,
+		}
+
+		if err != nil {
+			result.Status = HealthFail
+			report.Status = HealthFail
+
+			s.lastErrors.set(check.Name, err.Error(), start)
+		}
+
+		if msg, at, ok := s.lastErrors.get(check.Name); ok {
+			result.LastError = msg
+			result.LastErrorAt = &at
+		}
+
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report
+}
+
+// Last failure of each check.
+type lastErrors struct {
+	sync.Mutex
+	data map[string]lastError
+}
+
+type lastError struct {
+	msg string
+	at  time.Time
+}
+
+func newLastErrors() *lastErrors {
+	return &lastErrors{data: make(map[string]lastError)}
+}
+
+func (e *lastErrors) set(name, msg string, at time.Time) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.data[name] = lastError{msg: msg, at: at}
+}
+
+func (e *lastErrors) get(name string) (string, time.Time, bool) {
+	e.Lock()
+	defer e.Unlock()
+
+	le, ok := e.data[name]
+
+	return le.msg, le.at, ok
+}
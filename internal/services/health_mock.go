@@ -16,3 +16,18 @@ func (m *HealthCheckServiceMock) Ping(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
 }
+
+func (m *HealthCheckServiceMock) Liveness(ctx context.Context) HealthReport {
+	args := m.Called(ctx)
+	return args.Get(0).(HealthReport)
+}
+
+func (m *HealthCheckServiceMock) Readiness(ctx context.Context) HealthReport {
+	args := m.Called(ctx)
+	return args.Get(0).(HealthReport)
+}
+
+func (m *HealthCheckServiceMock) Storage(ctx context.Context) HealthReport {
+	args := m.Called(ctx)
+	return args.Get(0).(HealthReport)
+}
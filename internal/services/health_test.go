@@ -8,6 +8,7 @@ import (
 	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/internal/storage"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPing(t *testing.T) {
@@ -36,7 +37,7 @@ func TestPing(t *testing.T) {
 }
 
 func TestPingOnUnpingableStorage(t *testing.T) {
-	store := storage.NewMemStorage()
+	store := new(storage.StorageMock)
 	pinger := NewHealthCheckService(store)
 
 	err := pinger.Ping(context.Background())
@@ -44,3 +45,52 @@ func TestPingOnUnpingableStorage(t *testing.T) {
 		t.Fatalf("expected error to be %v, got %v", entities.ErrStorageUnpingable, err)
 	}
 }
+
+func TestLiveness(t *testing.T) {
+	report := NewHealthCheckService(new(storage.StorageMock)).Liveness(context.Background())
+
+	require.True(t, report.Passed())
+	require.Len(t, report.Checks, 1)
+	require.Equal(t, "process", report.Checks[0].Name)
+}
+
+func TestReadiness(t *testing.T) {
+	pm := storage.NewPGXPoolMock()
+	pm.On("Ping", mock.Anything).Return(entities.ErrUnexpected).Once()
+	pm.On("Ping", mock.Anything).Return(nil)
+
+	row := new(storage.PGXRowMock)
+	row.On("Scan", mock.Anything, mock.Anything).Return(nil)
+	pm.On("QueryRow", mock.Anything, mock.Anything, mock.Anything).Return(row)
+
+	service := NewHealthCheckService(&storage.PostgresStorage{Pool: pm})
+
+	report := service.Readiness(context.Background())
+	require.False(t, report.Passed())
+	require.Equal(t, HealthFail, report.Checks[0].Status)
+	require.Equal(t, "database", report.Checks[0].Name)
+	require.Contains(t, report.Checks[0].LastError, entities.ErrUnexpected.Error())
+	require.Equal(t, HealthPass, report.Checks[1].Status)
+	require.Equal(t, "migrations", report.Checks[1].Name)
+
+	// recovered check keeps last error
+	report = service.Readiness(context.Background())
+	require.True(t, report.Passed())
+	require.Equal(t, HealthPass, report.Checks[0].Status)
+	require.NotEmpty(t, report.Checks[0].LastError)
+	require.NotNil(t, report.Checks[0].LastErrorAt)
+}
+
+func TestReadinessOnUnpingableStorage(t *testing.T) {
+	report := NewHealthCheckService(new(storage.StorageMock)).Readiness(context.Background())
+
+	require.False(t, report.Passed())
+	require.Equal(t, entities.ErrStorageUnpingable.Error(), report.Checks[0].LastError)
+}
+
+func TestStorage(t *testing.T) {
+	report := NewHealthCheckService(storage.NewMemStorage()).Storage(context.Background())
+
+	require.True(t, report.Passed())
+	require.Equal(t, "memory", report.Checks[0].Name)
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/ex0rcist/metflix/internal/storage"
+)
+
+const (
+	defaultSubscriptionBuffer = 256  // capacity of subscriber's queue
+	defaultChangeHistory      = 1024 // number of recent changes kept to resume subscriptions
+)
+
+// Change of a stored record, IDs grow monotonically since the start of the service.
+type Change struct {
+	ID     uint64
+	Record storage.Record
+}
+
+// Subscription to metric changes, delivered via C().
+// The channel is closed if subscriber falls behind or closes subscription.
+type Subscription struct {
+	hub     *changeHub
+	filter  storage.ListFilter
+	ch      chan Change
+	dropped bool
+}
+
+// Channel of changes.
+func (s *Subscription) C() <-chan Change {
+	return s.ch
+}
+
+// Report whether subscription was closed because subscriber was too slow.
+func (s *Subscription) Dropped() bool {
+	s.hub.Lock()
+	defer s.hub.Unlock()
+
+	return s.dropped
+}
+
+// Stop receiving changes, safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.Lock()
+	defer s.hub.Unlock()
+
+	s.hub.remove(s)
+}
+
+// Fan-out of changes to subscribers with a ring buffer of recent changes.
+// Publishing never blocks: subscribers with full queues are dropped.
+type changeHub struct {
+	sync.Mutex
+	subs    map[*Subscription]struct{}
+	history []Change // ring, change with ID n is stored at (n-1) % len(history)
+	lastID  uint64
+}
+
+func newChangeHub(historySize int) *changeHub {
+	return &changeHub{
+		subs:    make(map[*Subscription]struct{}),
+		history: make([]Change, historySize),
+	}
+}
+
+// Subscribe to changes published from now on.
+func (h *changeHub) subscribe(filter storage.ListFilter, buffer int) *Subscription {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.add(filter, buffer, nil)
+}
+
+// Subscribe to changes published after lastID, replaying ones still kept in history.
+// IDs ahead of the hub (e.g. issued before restart) replay whole history.
+func (h *changeHub) resume(filter storage.ListFilter, buffer int, lastID uint64) *Subscription {
+	h.Lock()
+	defer h.Unlock()
+
+	if lastID > h.lastID {
+		lastID = 0
+	}
+
+	from := lastID + 1
+	if kept := uint64(len(h.history)); h.lastID > kept && from <= h.lastID-kept {
+		from = h.lastID - kept + 1
+	}
+
+	var replay []Change
+	for id := from; id <= h.lastID; id++ {
+		change := h.history[(id-1)%uint64(len(h.history))]
+		if filter.Match(change.Record) {
+			replay = append(replay, change)
+		}
+	}
+
+	return h.add(filter, buffer, replay)
+}
+
+func (h *changeHub) add(filter storage.ListFilter, buffer int, replay []Change) *Subscription {
+	if buffer <= 0 {
+		buffer = defaultSubscriptionBuffer
+	}
+
+	sub := &Subscription{hub: h, filter: filter, ch: make(chan Change, buffer+len(replay))}
+	for _, change := range replay {
+		sub.ch <- change
+	}
+
+	h.subs[sub] = struct{}{}
+
+	return sub
+}
+
+func (h *changeHub) publish(records ...storage.Record) {
+	h.Lock()
+	defer h.Unlock()
+
+	changes := make([]Change, len(records))
+	for i, record := range records {
+		h.lastID++
+
+		changes[i] = Change{ID: h.lastID, Record: record}
+		h.history[(h.lastID-1)%uint64(len(h.history))] = changes[i]
+	}
+
+	for sub := range h.subs {
+		for _, change := range changes {
+			if !sub.filter.Match(change.Record) {
+				continue
+			}
+
+			select {
+			case sub.ch <- change:
+				continue
+			default:
+			}
+
+			sub.dropped = true
+			h.remove(sub)
+
+			break
+		}
+	}
+}
+
+func (h *changeHub) remove(sub *Subscription) {
+	if _, ok := h.subs[sub]; !ok {
+		return
+	}
+
+	delete(h.subs, sub)
+	close(sub.ch)
+}
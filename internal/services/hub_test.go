@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeHub_Filter(t *testing.T) {
+	hub := newChangeHub(10)
+
+	sub := hub.subscribe(storage.ListFilter{NamePrefix: "cpu", Kind: metrics.KindGauge}, 10)
+	defer sub.Close()
+
+	hub.publish(
+		storage.Record{Name: "cpu_load", Value: metrics.Gauge(1)},
+		storage.Record{Name: "cpu_ticks", Value: metrics.Counter(1)},
+		storage.Record{Name: "mem", Value: metrics.Gauge(1)},
+	)
+
+	require.Len(t, sub.C(), 1)
+	require.Equal(t, "cpu_load", (<-sub.C()).Record.Name)
+}
+
+func TestChangeHub_DropSlowSubscriber(t *testing.T) {
+	hub := newChangeHub(10)
+
+	slow := hub.subscribe(storage.ListFilter{}, 1)
+	fast := hub.subscribe(storage.ListFilter{}, 10)
+	defer fast.Close()
+
+	hub.publish(storage.Record{Name: "a", Value: metrics.Gauge(1)}, storage.Record{Name: "b", Value: metrics.Gauge(2)})
+
+	require.True(t, slow.Dropped())
+	require.False(t, fast.Dropped())
+	require.Len(t, fast.C(), 2)
+
+	// buffered record is still delivered, then channel is closed
+	<-slow.C()
+	_, ok := <-slow.C()
+	require.False(t, ok)
+
+	// closing dropped subscription is no-op
+	slow.Close()
+}
+
+func TestChangeHub_Close(t *testing.T) {
+	hub := newChangeHub(10)
+
+	sub := hub.subscribe(storage.ListFilter{}, 1)
+	sub.Close()
+
+	hub.publish(storage.Record{Name: "a", Value: metrics.Gauge(1)})
+
+	_, ok := <-sub.C()
+	require.False(t, ok)
+	require.False(t, sub.Dropped())
+}
+
+func TestChangeHub_Resume(t *testing.T) {
+	hub := newChangeHub(3)
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		hub.publish(storage.Record{Name: name, Value: metrics.Gauge(1)})
+	}
+
+	tests := []struct {
+		name     string
+		filter   storage.ListFilter
+		lastID   uint64
+		expected []uint64
+	}{
+		{name: "replay after known id", lastID: 3, expected: []uint64{4, 5}},
+		{name: "replay is limited by history", lastID: 1, expected: []uint64{3, 4, 5}},
+		{name: "nothing to replay", lastID: 5, expected: nil},
+		{name: "id ahead of hub replays history", lastID: 100, expected: []uint64{3, 4, 5}},
+		{name: "replay is filtered", filter: storage.ListFilter{Name: "d"}, expected: []uint64{4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := hub.resume(tt.filter, 1, tt.lastID)
+			defer sub.Close()
+
+			var ids []uint64
+			for len(sub.C()) > 0 {
+				ids = append(ids, (<-sub.C()).ID)
+			}
+
+			require.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func TestService_PublishesChanges(t *testing.T) {
+	service := NewMetricService(storage.NewMemStorage())
+
+	sub := service.Subscribe(storage.ListFilter{})
+	defer sub.Close()
+
+	_, err := service.Push(context.Background(), storage.Record{Name: "test", Value: metrics.Counter(1)})
+	require.NoError(t, err)
+
+	_, err = service.PushList(context.Background(), []storage.Record{
+		{Name: "test", Value: metrics.Counter(2)},
+		{Name: "other", Value: metrics.Gauge(3)},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, metrics.Counter(1), (<-sub.C()).Record.Value)
+	require.Equal(t, "other", (<-sub.C()).Record.Name)
+	require.Equal(t, metrics.Counter(3), (<-sub.C()).Record.Value)
+}
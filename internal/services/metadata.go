@@ -0,0 +1,51 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/cache"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+// How long described metadata is kept without updates or lookups and how many metrics at most.
+// Senders describe metrics periodically, so metadata of active metrics is refreshed well before expiration.
+const (
+	metadataTTL   = 24 * time.Hour
+	metadataLimit = 100000
+)
+
+// Thread-safe in-memory registry of metric metadata, keyed by metric name.
+// Defaults are kept forever, described metadata expires.
+type metadataRegistry struct {
+	sync.Mutex
+	defaults map[string]metrics.Metadata
+	data     *cache.LRU[string, metrics.Metadata]
+}
+
+func newMetadataRegistry(defaults map[string]metrics.Metadata) *metadataRegistry {
+	return &metadataRegistry{
+		defaults: defaults,
+		data:     cache.NewLRU[string, metrics.Metadata](metadataLimit, metadataTTL),
+	}
+}
+
+func (r *metadataRegistry) set(name string, md metrics.Metadata) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.data.Set(name, md)
+}
+
+func (r *metadataRegistry) get(name string) (metrics.Metadata, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if md, ok := r.data.Get(name); ok {
+		return md, true
+	}
+
+	md, ok := r.defaults[name]
+
+	return md, ok
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/internal/storage"
@@ -12,27 +13,40 @@ import (
 )
 
 type MetricProvider interface {
-	List(ctx context.Context) ([]storage.Record, error)
+	List(ctx context.Context, filter storage.ListFilter) ([]storage.Record, error)
 	Push(ctx context.Context, record storage.Record) (storage.Record, error)
 	PushList(ctx context.Context, records []storage.Record) ([]storage.Record, error)
-	Get(ctx context.Context, name, kind string) (storage.Record, error)
+	Get(ctx context.Context, name, kind string, labels metrics.Labels) (storage.Record, error)
+	Delete(ctx context.Context, name, kind string, labels metrics.Labels) error
+	Range(ctx context.Context, name, kind string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error)
+	RangeRollups(ctx context.Context, name, kind string, labels metrics.Labels, resolution time.Duration, from, to time.Time) ([]storage.Rollup, error)
+	Describe(name string, metadata metrics.Metadata)
+	Metadata(name string) (metrics.Metadata, bool)
+	Subscribe(filter storage.ListFilter) *Subscription
+	Resume(filter storage.ListFilter, lastID uint64) *Subscription
 }
 
 var _ MetricProvider = MetricService{}
 
 // Service struct, containing storage
 type MetricService struct {
-	storage storage.MetricsStorage
+	storage  storage.MetricsStorage
+	metadata *metadataRegistry
+	hub      *changeHub
 }
 
-// Service constructor
+// Service constructor, metadata of agent metrics is known from the start
 func NewMetricService(storage storage.MetricsStorage) MetricService {
-	return MetricService{storage: storage}
+	return MetricService{
+		storage:  storage,
+		metadata: newMetadataRegistry(metrics.AgentMetadata),
+		hub:      newChangeHub(defaultChangeHistory),
+	}
 }
 
 // Get record from bound storage
-func (s MetricService) Get(ctx context.Context, name, kind string) (storage.Record, error) {
-	id := storage.CalculateRecordID(name, kind)
+func (s MetricService) Get(ctx context.Context, name, kind string, labels metrics.Labels) (storage.Record, error) {
+	id := storage.CalculateRecordID(name, kind, labels)
 
 	record, err := s.storage.Get(ctx, id)
 	if err != nil {
@@ -42,35 +56,146 @@ func (s MetricService) Get(ctx context.Context, name, kind string) (storage.Reco
 	return record, nil
 }
 
+// Delete metric and its history from bound storage
+func (s MetricService) Delete(ctx context.Context, name, kind string, labels metrics.Labels) error {
+	id := storage.CalculateRecordID(name, kind, labels)
+
+	return s.storage.Delete(ctx, id)
+}
+
+// Get history of the series within [from, to] from bound storage
+func (s MetricService) Range(ctx context.Context, name, kind string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error) {
+	if to.Before(from) {
+		return nil, entities.ErrRangeInvalid
+	}
+
+	id := storage.CalculateRecordID(name, kind, labels)
+
+	samples, err := s.storage.Range(ctx, id, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+// Get downsampled history of the series within [from, to] from bound storage
+func (s MetricService) RangeRollups(
+	ctx context.Context,
+	name, kind string,
+	labels metrics.Labels,
+	resolution time.Duration,
+	from, to time.Time,
+) ([]storage.Rollup, error) {
+	if to.Before(from) {
+		return nil, entities.ErrRangeInvalid
+	}
+
+	id := storage.CalculateRecordID(name, kind, labels)
+
+	rollups, err := s.storage.RangeRollups(ctx, id, resolution, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return rollups, nil
+}
+
+// Set metadata of the metric, replacing previous one
+func (s MetricService) Describe(name string, metadata metrics.Metadata) {
+	s.metadata.set(name, metadata)
+}
+
+// Get metadata of the metric if known
+func (s MetricService) Metadata(name string) (metrics.Metadata, bool) {
+	return s.metadata.get(name)
+}
+
+// Subscribe to changes of records matching filter, published after successful writes.
+// Subscription is dropped if subscriber doesn't keep up, caller must Close() it when done.
+func (s MetricService) Subscribe(filter storage.ListFilter) *Subscription {
+	return s.hub.subscribe(filter, defaultSubscriptionBuffer)
+}
+
+// Subscribe to changes published after lastID, recent changes are replayed from in-memory history.
+func (s MetricService) Resume(filter storage.ListFilter, lastID uint64) *Subscription {
+	return s.hub.resume(filter, defaultSubscriptionBuffer, lastID)
+}
+
 // Push record to bound storage
 func (s MetricService) Push(ctx context.Context, record storage.Record) (storage.Record, error) {
+	id := record.CalculateRecordID()
+
+	if incrementer, ok := s.incrementer(record); ok {
+		if id == "" {
+			return storage.Record{}, entities.ErrMetricMissingName
+		}
+
+		result, err := incrementer.Increment(ctx, map[string]storage.Record{id: record})
+		if err != nil {
+			return storage.Record{}, err
+		}
+
+		record = result[id]
+		s.hub.publish(record)
+
+		return record, nil
+	}
+
 	newValue, err := s.calculateNewValue(ctx, record)
 	if err != nil {
 		return storage.Record{}, err
 	}
 
 	record.Value = newValue
-	err = s.storage.Push(ctx, record.CalculateRecordID(), record)
+	err = s.storage.Push(ctx, id, record)
 
 	if err != nil {
 		return storage.Record{}, err
 	}
 
+	s.hub.publish(record)
+
 	return record, nil
 }
 
-// Push list of records to bound storage
+// Push list of records to bound storage.
+// Counters are incremented by storage itself if it's able to, other values are calculated here.
+// Non-counter values are written first, as rewriting them on retry is harmless. If incrementing
+// counters fails after that, write is partial: records stored so far are returned and published
+// along with the error.
 func (s MetricService) PushList(ctx context.Context, records []storage.Record) ([]storage.Record, error) {
+	var incrementer storage.IncrementingStorage
+
 	data := make(map[string]storage.Record)
+	increments := make(map[string]storage.Record)
 
 	for _, record := range records {
 		id := record.CalculateRecordID()
 
-		if prev, ok := data[id]; ok {
-			if record.Value.Kind() == metrics.KindCounter {
+		if inc, ok := s.incrementer(record); ok {
+			if id == "" {
+				return nil, entities.ErrMetricMissingName
+			}
+
+			incrementer = inc
+
+			if prev, ok := increments[id]; ok {
 				record.Value = prev.Value.(metrics.Counter) + record.Value.(metrics.Counter)
 			}
 
+			increments[id] = record
+
+			continue
+		}
+
+		if prev, ok := data[id]; ok {
+			newValue, err := accumulate(prev.Value, record.Value)
+			if err != nil {
+				return nil, fmt.Errorf("unable to calculate new value: %w", err)
+			}
+
+			record.Value = newValue
 			data[id] = record
 
 			continue
@@ -85,38 +210,99 @@ func (s MetricService) PushList(ctx context.Context, records []storage.Record) (
 		data[id] = record
 	}
 
-	if err := s.storage.PushList(ctx, data); err != nil {
-		return nil, fmt.Errorf("unable to PushList(): %w", err)
+	if len(data) > 0 {
+		if err := s.storage.PushList(ctx, data); err != nil {
+			return nil, fmt.Errorf("unable to PushList(): %w", err)
+		}
 	}
 
+	if len(increments) > 0 {
+		incremented, err := incrementer.Increment(ctx, increments)
+		if err != nil {
+			return s.publishStored(data), fmt.Errorf("unable to Increment(): %w", err)
+		}
+
+		for id, record := range incremented {
+			data[id] = record
+		}
+	}
+
+	return s.publishStored(data), nil
+}
+
+// Publish stored records and return them sorted.
+func (s MetricService) publishStored(data map[string]storage.Record) []storage.Record {
 	result := make([]storage.Record, 0, len(data))
 	for _, v := range data {
 		result = append(result, v)
 	}
 
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].Name < result[j].Name
-	})
+	sortRecords(result)
+	s.hub.publish(result...)
+
+	return result
+}
+
+// Split records into rounds to push one after another: k-th round holds k-th record of every series
+// in timestamp order, so each sample is stored instead of being merged with the rest of its series by PushList.
+func SeriesRounds(records []storage.Record) [][]storage.Record {
+	series := make(map[string][]storage.Record)
+	order := make([]string, 0)
+
+	for _, record := range records {
+		id := record.CalculateRecordID()
+		if _, ok := series[id]; !ok {
+			order = append(order, id)
+		}
+
+		series[id] = append(series[id], record)
+	}
+
+	var rounds [][]storage.Record
+
+	for _, id := range order {
+		samples := series[id]
+		sort.SliceStable(samples, func(i, j int) bool {
+			return samples[i].Timestamp.Before(samples[j].Timestamp)
+		})
+
+		for k, record := range samples {
+			if k == len(rounds) {
+				rounds = append(rounds, nil)
+			}
+
+			rounds[k] = append(rounds[k], record)
+		}
+	}
 
-	return result, nil
+	return rounds
 }
 
-// List records from bound storage
-func (s MetricService) List(ctx context.Context) ([]storage.Record, error) {
-	records, err := s.storage.List(ctx)
+// List records matching filter from bound storage
+func (s MetricService) List(ctx context.Context, filter storage.ListFilter) ([]storage.Record, error) {
+	records, err := s.storage.List(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	sort.Slice(records, func(i, j int) bool {
-		return records[i].Name < records[j].Name
-	})
+	sortRecords(records)
 
 	return records, nil
 }
 
-func (s MetricService) calculateNewValue(ctx context.Context, record storage.Record) (metrics.Metric, error) {
+// Storage incrementing the record atomically: only counters are incremented, if storage supports it.
+func (s MetricService) incrementer(record storage.Record) (storage.IncrementingStorage, bool) {
 	if record.Value.Kind() != metrics.KindCounter {
+		return nil, false
+	}
+
+	incrementer, ok := s.storage.(storage.IncrementingStorage)
+
+	return incrementer, ok
+}
+
+func (s MetricService) calculateNewValue(ctx context.Context, record storage.Record) (metrics.Metric, error) {
+	if record.Value.Kind() == metrics.KindGauge {
 		return record.Value, nil
 	}
 
@@ -132,5 +318,33 @@ func (s MetricService) calculateNewValue(ctx context.Context, record storage.Rec
 		return nil, err
 	}
 
-	return storedRecord.Value.(metrics.Counter) + record.Value.(metrics.Counter), nil
+	return accumulate(storedRecord.Value, record.Value)
+}
+
+// Combine previous value with a new one: counters are summed, histograms merged, gauges replaced.
+func accumulate(prev, next metrics.Metric) (metrics.Metric, error) {
+	switch value := next.(type) {
+	case metrics.Counter:
+		return prev.(metrics.Counter) + value, nil
+	case metrics.Histogram:
+		merged, err := prev.(metrics.Histogram).Merge(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return merged, nil
+	default:
+		return next, nil
+	}
+}
+
+// Sort records by name, records with the same name are ordered by ID (kind and labels).
+func sortRecords(records []storage.Record) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+
+		return records[i].CalculateRecordID() < records[j].CalculateRecordID()
+	})
 }
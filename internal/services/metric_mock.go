@@ -2,8 +2,10 @@ package services
 
 import (
 	"context"
+	"time"
 
 	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -15,11 +17,17 @@ type MetricServiceMock struct {
 }
 
 // Get record
-func (m *MetricServiceMock) Get(ctx context.Context, name, kind string) (storage.Record, error) {
-	args := m.Called(name, kind)
+func (m *MetricServiceMock) Get(ctx context.Context, name, kind string, labels metrics.Labels) (storage.Record, error) {
+	args := m.Called(name, kind, labels)
 	return args.Get(0).(storage.Record), args.Error(1)
 }
 
+// Delete record
+func (m *MetricServiceMock) Delete(ctx context.Context, name, kind string, labels metrics.Labels) error {
+	args := m.Called(name, kind, labels)
+	return args.Error(0)
+}
+
 // Push record
 func (m *MetricServiceMock) Push(ctx context.Context, record storage.Record) (storage.Record, error) {
 	args := m.Called(record)
@@ -33,8 +41,8 @@ func (m *MetricServiceMock) PushList(ctx context.Context, records []storage.Reco
 }
 
 // Get list of records
-func (m *MetricServiceMock) List(ctx context.Context) ([]storage.Record, error) {
-	args := m.Called()
+func (m *MetricServiceMock) List(ctx context.Context, filter storage.ListFilter) ([]storage.Record, error) {
+	args := m.Called(filter)
 
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -42,3 +50,54 @@ func (m *MetricServiceMock) List(ctx context.Context) ([]storage.Record, error)
 
 	return args.Get(0).([]storage.Record), args.Error(1)
 }
+
+// Get history of the series
+func (m *MetricServiceMock) Range(ctx context.Context, name, kind string, labels metrics.Labels, from, to time.Time) ([]storage.Sample, error) {
+	args := m.Called(name, kind, labels, from, to)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]storage.Sample), args.Error(1)
+}
+
+// Get downsampled history of the series
+func (m *MetricServiceMock) RangeRollups(
+	ctx context.Context,
+	name, kind string,
+	labels metrics.Labels,
+	resolution time.Duration,
+	from, to time.Time,
+) ([]storage.Rollup, error) {
+	args := m.Called(name, kind, labels, resolution, from, to)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]storage.Rollup), args.Error(1)
+}
+
+// Set metric metadata
+func (m *MetricServiceMock) Describe(name string, metadata metrics.Metadata) {
+	m.Called(name, metadata)
+}
+
+// Get metric metadata
+func (m *MetricServiceMock) Metadata(name string) (metrics.Metadata, bool) {
+	args := m.Called(name)
+	return args.Get(0).(metrics.Metadata), args.Bool(1)
+}
+
+// Subscribe to changes
+func (m *MetricServiceMock) Subscribe(filter storage.ListFilter) *Subscription {
+	args := m.Called(filter)
+	return args.Get(0).(*Subscription)
+}
+
+// Resume subscription to changes
+func (m *MetricServiceMock) Resume(filter storage.ListFilter, lastID uint64) *Subscription {
+	args := m.Called(filter, lastID)
+	return args.Get(0).(*Subscription)
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/internal/storage"
@@ -13,8 +15,9 @@ import (
 
 func TestService_Get(t *testing.T) {
 	type args struct {
-		name string
-		kind string
+		name   string
+		kind   string
+		labels metrics.Labels
 	}
 
 	tests := []struct {
@@ -34,6 +37,16 @@ func TestService_Get(t *testing.T) {
 			wantErr:  false,
 		},
 
+		{
+			name: "existing record with labels",
+			mock: func(m *storage.StorageMock) {
+				m.On("Get", mock.Anything, `test_gauge{host="a"}`).Return(storage.Record{Name: "test", Value: metrics.Gauge(1), Labels: metrics.Labels{"host": "a"}}, nil)
+			},
+			args:     args{name: "test", kind: metrics.KindGauge, labels: metrics.Labels{"host": "a"}},
+			expected: storage.Record{Name: "test", Value: metrics.Gauge(1), Labels: metrics.Labels{"host": "a"}},
+			wantErr:  false,
+		},
+
 		{
 			name: "non-existing storage.Record",
 			mock: func(m *storage.StorageMock) {
@@ -55,13 +68,11 @@ func TestService_Get(t *testing.T) {
 				tt.mock(m)
 			}
 
-			result, err := service.Get(ctx, tt.args.name, tt.args.kind)
+			result, err := service.Get(ctx, tt.args.name, tt.args.kind, tt.args.labels)
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("expected error: %v, got %v", tt.wantErr, err)
 			}
-			if result != tt.expected {
-				t.Errorf("expected %v, got %v", tt.expected, result)
-			}
+			require.Equal(t, tt.expected, result)
 		})
 	}
 }
@@ -164,9 +175,7 @@ func TestService_Push(t *testing.T) {
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("expected error: %v, got %v", tt.wantErr, err)
 			}
-			if result != tt.expected {
-				t.Errorf("expected %v, got %v", tt.expected, result)
-			}
+			require.Equal(t, tt.expected, result)
 		})
 	}
 }
@@ -199,6 +208,39 @@ func TestService_PushList(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "should keep series with different labels apart",
+			mock: func(m *storage.StorageMock) {
+				m.On("Get", mock.Anything, `hits_counter{host="a"}`).Return(storage.Record{Name: "hits", Value: metrics.Counter(1), Labels: metrics.Labels{"host": "a"}}, nil)
+				m.On("Get", mock.Anything, `hits_counter{host="b"}`).Return(storage.Record{}, entities.ErrRecordNotFound)
+				m.On("PushList", mock.Anything, mock.AnythingOfType("map[string]storage.Record")).Return(nil)
+			},
+			records: []storage.Record{
+				{Name: "hits", Value: metrics.Counter(2), Labels: metrics.Labels{"host": "a"}},
+				{Name: "hits", Value: metrics.Counter(3), Labels: metrics.Labels{"host": "b"}},
+				{Name: "hits", Value: metrics.Counter(4), Labels: metrics.Labels{"host": "b"}},
+			},
+			expected: []storage.Record{
+				{Name: "hits", Value: metrics.Counter(3), Labels: metrics.Labels{"host": "a"}},
+				{Name: "hits", Value: metrics.Counter(7), Labels: metrics.Labels{"host": "b"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should merge histograms within list",
+			mock: func(m *storage.StorageMock) {
+				m.On("Get", mock.Anything, "latency_histogram").Return(storage.Record{}, entities.ErrRecordNotFound)
+				m.On("PushList", mock.Anything, mock.AnythingOfType("map[string]storage.Record")).Return(nil)
+			},
+			records: []storage.Record{
+				{Name: "latency", Value: testHistogram(0.5)},
+				{Name: "latency", Value: testHistogram(3)},
+			},
+			expected: []storage.Record{
+				{Name: "latency", Value: testHistogram(0.5, 3)},
+			},
+			wantErr: false,
+		},
 	}
 
 	ctx := context.Background()
@@ -231,7 +273,7 @@ func TestService_List(t *testing.T) {
 		{
 			name: "normal list",
 			mock: func(m *storage.StorageMock) {
-				m.On("List", mock.Anything).Return([]storage.Record{
+				m.On("List", mock.Anything, storage.ListFilter{}).Return([]storage.Record{
 					{Name: "metricX", Value: metrics.Counter(42)},
 					{Name: "metricA", Value: metrics.Gauge(42.42)},
 				}, nil)
@@ -246,7 +288,7 @@ func TestService_List(t *testing.T) {
 		{
 			name: "had error",
 			mock: func(m *storage.StorageMock) {
-				m.On("List", mock.Anything).Return([]storage.Record{}, entities.ErrUnexpected)
+				m.On("List", mock.Anything, storage.ListFilter{}).Return([]storage.Record{}, entities.ErrUnexpected)
 			},
 			expected: []storage.Record{},
 			wantErr:  true,
@@ -263,7 +305,7 @@ func TestService_List(t *testing.T) {
 				tt.mock(m)
 			}
 
-			result, err := service.List(ctx)
+			result, err := service.List(ctx, storage.ListFilter{})
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("expected error: %v, got %v", tt.wantErr, err)
 			}
@@ -273,9 +315,7 @@ func TestService_List(t *testing.T) {
 			}
 
 			for i, record := range result {
-				if record != tt.expected[i] {
-					t.Errorf("expected %v, got %v", tt.expected[i], record)
-				}
+				require.Equal(t, tt.expected[i], record)
 			}
 
 		})
@@ -327,6 +367,33 @@ func TestService_calculateNewValue(t *testing.T) {
 			expected: metrics.Gauge(43.43),
 			wantErr:  false,
 		},
+		{
+			name: "new histogram record",
+			mock: func(m *storage.StorageMock) {
+				m.On("Get", mock.Anything, "test_histogram").Return(storage.Record{}, entities.ErrRecordNotFound)
+			},
+			record:   storage.Record{Name: "test", Value: testHistogram(0.5)},
+			expected: testHistogram(0.5),
+			wantErr:  false,
+		},
+		{
+			name: "existing histogram record",
+			mock: func(m *storage.StorageMock) {
+				m.On("Get", mock.Anything, "test_histogram").Return(storage.Record{Name: "test", Value: testHistogram(0.5)}, nil)
+			},
+			record:   storage.Record{Name: "test", Value: testHistogram(1.5, 3)},
+			expected: testHistogram(0.5, 1.5, 3),
+			wantErr:  false,
+		},
+		{
+			name: "histogram with other buckets",
+			mock: func(m *storage.StorageMock) {
+				m.On("Get", mock.Anything, "test_histogram").Return(storage.Record{Name: "test", Value: metrics.NewHistogram(5)}, nil)
+			},
+			record:   storage.Record{Name: "test", Value: testHistogram(1.5)},
+			expected: nil,
+			wantErr:  true,
+		},
 		{
 			name: "underlying error",
 			mock: func(m *storage.StorageMock) {
@@ -353,9 +420,222 @@ func TestService_calculateNewValue(t *testing.T) {
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("expected error: %v, got %v", tt.wantErr, err)
 			}
-			if result != tt.expected {
-				t.Errorf("expected %v, got %v", tt.expected, result)
+
+			require.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func testHistogram(observations ...float64) metrics.Histogram {
+	h := metrics.NewHistogram(1, 2)
+	for _, v := range observations {
+		h.Observe(v)
+	}
+
+	return h
+}
+
+func TestService_Range(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	samples := []storage.Sample{{Timestamp: from, Value: metrics.Gauge(1)}}
+
+	tests := []struct {
+		name     string
+		mock     func(m *storage.StorageMock)
+		from, to time.Time
+		expected []storage.Sample
+		wantErr  error
+	}{
+		{
+			name: "labeled series",
+			mock: func(m *storage.StorageMock) {
+				m.On("Range", mock.Anything, `Alloc_gauge{host="a"}`, from, to).Return(samples, nil)
+			},
+			from: from, to: to,
+			expected: samples,
+		},
+		{
+			name: "storage error",
+			mock: func(m *storage.StorageMock) {
+				m.On("Range", mock.Anything, `Alloc_gauge{host="a"}`, from, to).Return(nil, entities.ErrUnexpected)
+			},
+			from: from, to: to,
+			wantErr: entities.ErrUnexpected,
+		},
+		{
+			name: "inverted range",
+			from: to, to: from,
+			wantErr: entities.ErrRangeInvalid,
+		},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := new(storage.StorageMock)
+			service := NewMetricService(m)
+
+			if tt.mock != nil {
+				tt.mock(m)
+			}
+
+			result, err := service.Range(ctx, "Alloc", metrics.KindGauge, metrics.Labels{"host": "a"}, tt.from, tt.to)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
 			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result)
 		})
 	}
 }
+
+func TestService_Delete(t *testing.T) {
+	ctx := context.Background()
+
+	m := new(storage.StorageMock)
+	service := NewMetricService(m)
+	m.On("Delete", mock.Anything, `Alloc_gauge{host="a"}`).Return(nil)
+	m.On("Delete", mock.Anything, "Missing_counter").Return(entities.ErrRecordNotFound)
+
+	require.NoError(t, service.Delete(ctx, "Alloc", metrics.KindGauge, metrics.Labels{"host": "a"}))
+	require.ErrorIs(t, service.Delete(ctx, "Missing", metrics.KindCounter, nil), entities.ErrRecordNotFound)
+}
+
+func TestService_RangeRollups(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	rollups := []storage.Rollup{{Timestamp: from, Min: 1, Max: 1, Sum: 1, Count: 1}}
+
+	ctx := context.Background()
+
+	m := new(storage.StorageMock)
+	service := NewMetricService(m)
+	m.On("RangeRollups", mock.Anything, `Alloc_gauge{host="a"}`, storage.ResolutionMinute, from, to).Return(rollups, nil)
+
+	result, err := service.RangeRollups(ctx, "Alloc", metrics.KindGauge, metrics.Labels{"host": "a"}, storage.ResolutionMinute, from, to)
+	require.NoError(t, err)
+	require.Equal(t, rollups, result)
+
+	_, err = service.RangeRollups(ctx, "Alloc", metrics.KindGauge, nil, storage.ResolutionMinute, to, from)
+	require.ErrorIs(t, err, entities.ErrRangeInvalid)
+}
+
+func TestService_Metadata(t *testing.T) {
+	service := NewMetricService(new(storage.StorageMock))
+
+	md, ok := service.Metadata("Alloc")
+	require.True(t, ok)
+	require.Equal(t, metrics.AgentMetadata["Alloc"], md)
+
+	_, ok = service.Metadata("Custom")
+	require.False(t, ok)
+
+	service.Describe("Custom", metrics.Metadata{Help: "Custom metric."})
+
+	md, ok = service.Metadata("Custom")
+	require.True(t, ok)
+	require.Equal(t, "Custom metric.", md.Help)
+}
+
+func TestService_ConcurrentCounterPushes(t *testing.T) {
+	const (
+		agents  = 20
+		reports = 50
+	)
+
+	ctx := context.Background()
+	service := NewMetricService(storage.NewMemStorage())
+	record := storage.Record{Name: "PollCount", Value: metrics.Counter(1)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < agents; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < reports; j++ {
+				if j%2 == 0 {
+					_, err := service.Push(ctx, record)
+					require.NoError(t, err)
+				} else {
+					_, err := service.PushList(ctx, []storage.Record{record, record})
+					require.NoError(t, err)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	stored, err := service.Get(ctx, record.Name, metrics.KindCounter, nil)
+	require.NoError(t, err)
+	require.Equal(t, metrics.Counter(agents*reports*3/2), stored.Value, "no increment should be lost")
+}
+
+func TestService_PushListMixedKinds(t *testing.T) {
+	ctx := context.Background()
+	service := NewMetricService(storage.NewMemStorage())
+
+	counter := storage.Record{Name: "PollCount", Value: metrics.Counter(2)}
+	gauge := storage.Record{Name: "Alloc", Value: metrics.Gauge(1.5)}
+
+	_, err := service.Push(ctx, counter)
+	require.NoError(t, err)
+
+	result, err := service.PushList(ctx, []storage.Record{counter, gauge, counter})
+	require.NoError(t, err)
+	require.Equal(t, []storage.Record{
+		{Name: "Alloc", Value: metrics.Gauge(1.5)},
+		{Name: "PollCount", Value: metrics.Counter(6)},
+	}, result)
+}
+
+func TestSeriesRounds(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	late := storage.Record{Name: "Alloc", Value: metrics.Gauge(2), Timestamp: ts.Add(time.Minute)}
+	early := storage.Record{Name: "Alloc", Value: metrics.Gauge(1), Timestamp: ts}
+	counter := storage.Record{Name: "PollCount", Value: metrics.Counter(1), Timestamp: ts}
+	labeled := storage.Record{Name: "Alloc", Value: metrics.Gauge(3), Labels: metrics.Labels{"host": "a"}, Timestamp: ts}
+
+	require.Equal(t, [][]storage.Record{
+		{early, counter, labeled},
+		{late, counter},
+	}, SeriesRounds([]storage.Record{late, counter, early, labeled, counter}))
+
+	require.Nil(t, SeriesRounds(nil))
+}
+
+// Memory storage failing to increment counters.
+type failingIncrementStorage struct {
+	*storage.MemStorage
+}
+
+func (s failingIncrementStorage) Increment(context.Context, map[string]storage.Record) (map[string]storage.Record, error) {
+	return nil, entities.ErrUnexpected
+}
+
+func TestService_PushListPartialWrite(t *testing.T) {
+	ctx := context.Background()
+	strg := failingIncrementStorage{storage.NewMemStorage()}
+	service := NewMetricService(strg)
+
+	sub := service.Subscribe(storage.ListFilter{})
+	defer sub.Close()
+
+	gauge := storage.Record{Name: "Alloc", Value: metrics.Gauge(1.5)}
+
+	result, err := service.PushList(ctx, []storage.Record{{Name: "PollCount", Value: metrics.Counter(2)}, gauge})
+	require.ErrorIs(t, err, entities.ErrUnexpected)
+	require.Equal(t, []storage.Record{gauge}, result)
+
+	stored, err := strg.Get(ctx, gauge.CalculateRecordID())
+	require.NoError(t, err)
+	require.Equal(t, gauge.Value, stored.Value)
+
+	require.Equal(t, "Alloc", (<-sub.C()).Record.Name)
+}
@@ -0,0 +1,246 @@
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+type counterState struct {
+	name   string
+	labels metrics.Labels
+	value  float64
+}
+
+type gaugeState struct {
+	name     string
+	labels   metrics.Labels
+	value    float64
+	absolute bool // value was set explicitly, not only shifted
+}
+
+type timerState struct {
+	name      string
+	labels    metrics.Labels
+	histogram metrics.Histogram
+}
+
+// Accumulates StatsD samples between flushes. Counters are summed, gauges keep last value, timers are
+// collected into histograms (observations are converted from milliseconds to seconds).
+type Aggregator struct {
+	mutex   sync.Mutex
+	service services.MetricProvider
+
+	counters map[string]*counterState
+	gauges   map[string]*gaugeState
+	timers   map[string]*timerState
+
+	lastGauges map[string]float64 // flushed gauge values, base for relative updates
+}
+
+// Constructor.
+func NewAggregator(service services.MetricProvider) *Aggregator {
+	a := &Aggregator{
+		service:    service,
+		lastGauges: make(map[string]float64),
+	}
+
+	a.reset()
+
+	return a
+}
+
+// Parse and add every line of the packet, malformed lines are skipped. Returns number of skipped lines.
+func (a *Aggregator) AddPacket(packet []byte) int {
+	skipped := 0
+
+	for _, line := range bytes.Split(packet, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		sample, err := ParseLine(string(line))
+		if err != nil {
+			logging.LogDebug(fmt.Sprintf("statsd: skipping %q: %v", line, err))
+			skipped++
+
+			continue
+		}
+
+		a.Add(sample)
+	}
+
+	return skipped
+}
+
+// Add single sample.
+func (a *Aggregator) Add(sample Sample) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	switch sample.Type {
+	case TypeCounter:
+		id := storage.CalculateRecordID(sample.Name, metrics.KindCounter, sample.Labels)
+
+		state, ok := a.counters[id]
+		if !ok {
+			state = &counterState{name: sample.Name, labels: sample.Labels}
+			a.counters[id] = state
+		}
+
+		state.value += sample.Value / sample.Rate
+	case TypeGauge:
+		id := storage.CalculateRecordID(sample.Name, metrics.KindGauge, sample.Labels)
+
+		state, ok := a.gauges[id]
+		if !ok {
+			state = &gaugeState{name: sample.Name, labels: sample.Labels}
+			a.gauges[id] = state
+		}
+
+		if sample.Relative {
+			state.value += sample.Value
+		} else {
+			state.value = sample.Value
+			state.absolute = true
+		}
+	case TypeTimer, TypeHisto:
+		id := storage.CalculateRecordID(sample.Name, metrics.KindHistogram, sample.Labels)
+
+		state, ok := a.timers[id]
+		if !ok {
+			state = &timerState{name: sample.Name, labels: sample.Labels, histogram: metrics.NewHistogram()}
+			a.timers[id] = state
+		}
+
+		// sampled observation stands for 1/rate ones
+		state.histogram.ObserveN(sample.Value/1000, uint64(math.Round(1/sample.Rate)))
+	}
+}
+
+// Push everything collected since previous flush to metric service. Data of failed push is dropped,
+// relative gauges whose base value is unavailable are kept for the next flush.
+func (a *Aggregator) Flush(ctx context.Context) error {
+	a.mutex.Lock()
+	counters, gauges, timers := a.counters, a.gauges, a.timers
+	a.reset()
+	a.mutex.Unlock()
+
+	now := time.Now()
+	records := make([]storage.Record, 0, len(counters)+len(gauges)+len(timers))
+
+	for _, c := range counters {
+		records = append(records, storage.Record{
+			Name: c.name, Labels: c.labels, Value: metrics.Counter(math.Round(c.value)), Timestamp: now,
+		})
+	}
+
+	var (
+		flushedGauges = make(map[string]float64, len(gauges))
+		pendingGauges = make(map[string]*gaugeState)
+		baseErr       error
+	)
+
+	for id, g := range gauges {
+		value := g.value
+
+		if !g.absolute {
+			base, err := a.gaugeBase(ctx, id, g)
+			if err != nil {
+				pendingGauges[id] = g
+				baseErr = err
+
+				continue
+			}
+
+			value += base
+		}
+
+		flushedGauges[id] = value
+		records = append(records, storage.Record{Name: g.name, Labels: g.labels, Value: metrics.Gauge(value), Timestamp: now})
+	}
+
+	for _, t := range timers {
+		records = append(records, storage.Record{Name: t.name, Labels: t.labels, Value: t.histogram, Timestamp: now})
+	}
+
+	a.requeueGauges(pendingGauges)
+
+	if len(records) == 0 {
+		return baseErr
+	}
+
+	if _, err := a.service.PushList(ctx, records); err != nil {
+		return fmt.Errorf("statsd flush failed: %w", err)
+	}
+
+	a.mutex.Lock()
+	for id, value := range flushedGauges {
+		a.lastGauges[id] = value
+	}
+	a.mutex.Unlock()
+
+	return baseErr
+}
+
+// Return relative gauges to the current window, updates received since flush are applied on top.
+func (a *Aggregator) requeueGauges(gauges map[string]*gaugeState) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for id, g := range gauges {
+		state, ok := a.gauges[id]
+		if !ok {
+			a.gauges[id] = g
+			continue
+		}
+
+		// absolute value set since flush overrides older relative updates
+		if !state.absolute {
+			state.value += g.value
+		}
+	}
+}
+
+// Value relative gauge updates are applied to: last flushed one or stored in service.
+func (a *Aggregator) gaugeBase(ctx context.Context, id string, g *gaugeState) (float64, error) {
+	a.mutex.Lock()
+	base, ok := a.lastGauges[id]
+	a.mutex.Unlock()
+
+	if ok {
+		return base, nil
+	}
+
+	record, err := a.service.Get(ctx, g.name, metrics.KindGauge, g.labels)
+
+	switch {
+	case errors.Is(err, entities.ErrRecordNotFound):
+		return 0, nil
+	case err != nil:
+		return 0, fmt.Errorf("statsd flush failed: %w", err)
+	}
+
+	if value, ok := record.Value.(metrics.Gauge); ok {
+		return float64(value), nil
+	}
+
+	return 0, nil
+}
+
+func (a *Aggregator) reset() {
+	a.counters = make(map[string]*counterState)
+	a.gauges = make(map[string]*gaugeState)
+	a.timers = make(map[string]*timerState)
+}
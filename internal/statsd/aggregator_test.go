@@ -0,0 +1,139 @@
+package statsd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/services"
+	"github.com/ex0rcist/metflix/internal/storage"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+// Capture pushed records by id, timestamps are dropped.
+func capturePush(m *services.MetricServiceMock, pushed *map[string]storage.Record) {
+	m.On("PushList", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*pushed = make(map[string]storage.Record)
+		for _, r := range args.Get(1).([]storage.Record) {
+			(*pushed)[r.CalculateRecordID()] = storage.Record{Name: r.Name, Value: r.Value, Labels: r.Labels}
+		}
+	}).Return([]storage.Record{}, nil)
+}
+
+func TestAggregator_Flush(t *testing.T) {
+	ctx := context.Background()
+	m := new(services.MetricServiceMock)
+	a := NewAggregator(m)
+
+	skipped := a.AddPacket([]byte("requests:1|c\nrequests:2|c|@0.5\nqueue:10|g\nqueue:+5|g\nlatency:200|ms\nlatency:2000|ms\nbroken\n"))
+	require.Equal(t, 1, skipped)
+
+	a.AddPacket([]byte("requests:1|c|#host:a"))
+
+	var pushed map[string]storage.Record
+	capturePush(m, &pushed)
+
+	require.NoError(t, a.Flush(ctx))
+
+	latency := metrics.NewHistogram()
+	latency.Observe(0.2)
+	latency.Observe(2)
+
+	require.Equal(t, map[string]storage.Record{
+		"requests_counter":           {Name: "requests", Value: metrics.Counter(5)},
+		`requests_counter{host="a"}`: {Name: "requests", Value: metrics.Counter(1), Labels: metrics.Labels{"host": "a"}},
+		"queue_gauge":                {Name: "queue", Value: metrics.Gauge(15)},
+		"latency_histogram":          {Name: "latency", Value: latency},
+	}, pushed)
+
+	// nothing collected - nothing pushed
+	pushed = nil
+	require.NoError(t, a.Flush(ctx))
+	require.Nil(t, pushed)
+
+	// relative update after flush is applied to flushed value
+	a.AddPacket([]byte("queue:-3|g"))
+	require.NoError(t, a.Flush(ctx))
+	require.Equal(t, map[string]storage.Record{"queue_gauge": {Name: "queue", Value: metrics.Gauge(12)}}, pushed)
+
+	m.AssertNotCalled(t, "Get", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAggregator_RelativeGaugeUsesStoredValue(t *testing.T) {
+	ctx := context.Background()
+	m := new(services.MetricServiceMock)
+	a := NewAggregator(m)
+
+	m.On("Get", "queue", metrics.KindGauge, metrics.Labels(nil)).Return(storage.Record{Name: "queue", Value: metrics.Gauge(7)}, nil).Once()
+	m.On("Get", "fresh", metrics.KindGauge, metrics.Labels(nil)).Return(storage.Record{}, entities.ErrRecordNotFound).Once()
+
+	var pushed map[string]storage.Record
+	capturePush(m, &pushed)
+
+	a.AddPacket([]byte("queue:+1|g\nfresh:-2|g"))
+	require.NoError(t, a.Flush(ctx))
+
+	require.Equal(t, map[string]storage.Record{
+		"queue_gauge": {Name: "queue", Value: metrics.Gauge(8)},
+		"fresh_gauge": {Name: "fresh", Value: metrics.Gauge(-2)},
+	}, pushed)
+
+	m.AssertExpectations(t)
+}
+
+func TestAggregator_FlushError(t *testing.T) {
+	m := new(services.MetricServiceMock)
+	a := NewAggregator(m)
+
+	m.On("PushList", mock.Anything, mock.Anything).Return([]storage.Record{}, entities.ErrUnexpected)
+
+	a.AddPacket([]byte("requests:1|c"))
+	require.ErrorIs(t, a.Flush(context.Background()), entities.ErrUnexpected)
+}
+
+func TestAggregator_GaugeBaseError(t *testing.T) {
+	ctx := context.Background()
+	m := new(services.MetricServiceMock)
+	a := NewAggregator(m)
+
+	m.On("Get", "queue", metrics.KindGauge, metrics.Labels(nil)).Return(storage.Record{}, entities.ErrUnexpected).Once()
+
+	var pushed map[string]storage.Record
+	capturePush(m, &pushed)
+
+	a.AddPacket([]byte("queue:+1|g\nrequests:1|c"))
+	require.ErrorIs(t, a.Flush(ctx), entities.ErrUnexpected)
+
+	// rest of the window is pushed
+	require.Equal(t, map[string]storage.Record{"requests_counter": {Name: "requests", Value: metrics.Counter(1)}}, pushed)
+
+	// failed gauge is retried with updates received since
+	m.On("Get", "queue", metrics.KindGauge, metrics.Labels(nil)).Return(storage.Record{Name: "queue", Value: metrics.Gauge(7)}, nil).Once()
+
+	a.AddPacket([]byte("queue:+2|g"))
+	require.NoError(t, a.Flush(ctx))
+	require.Equal(t, map[string]storage.Record{"queue_gauge": {Name: "queue", Value: metrics.Gauge(10)}}, pushed)
+
+	m.AssertExpectations(t)
+}
+
+func TestAggregator_SampledTimer(t *testing.T) {
+	m := new(services.MetricServiceMock)
+	a := NewAggregator(m)
+
+	var pushed map[string]storage.Record
+	capturePush(m, &pushed)
+
+	// tiny rate is weighted in one step instead of looping over observations
+	a.AddPacket([]byte("latency:1|ms|@0.000000001\nlatency:1|ms|@0.25"))
+	require.NoError(t, a.Flush(context.Background()))
+
+	latency := metrics.NewHistogram()
+	latency.ObserveN(0.001, 1000000000)
+	latency.ObserveN(0.001, 4)
+
+	require.Equal(t, map[string]storage.Record{"latency_histogram": {Name: "latency", Value: latency}}, pushed)
+}
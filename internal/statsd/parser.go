@@ -0,0 +1,111 @@
+// Package statsd implements StatsD protocol parsing and in-memory aggregation of received metrics.
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/validators"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+// StatsD metric types.
+const (
+	TypeCounter = "c"
+	TypeGauge   = "g"
+	TypeTimer   = "ms"
+	TypeHisto   = "h" // alias of timer used by some clients
+)
+
+// Single metric update parsed from StatsD line.
+type Sample struct {
+	Name     string
+	Type     string
+	Value    float64
+	Relative bool    // gauge update like +1 or -1
+	Rate     float64 // sample rate in (0, 1]
+	Labels   metrics.Labels
+}
+
+// Parse line in format name:value|type[|@rate][|#tag:value,...].
+// Tags are DogStatsD extension, mapped to labels.
+func ParseLine(line string) (Sample, error) {
+	sample := Sample{Rate: 1}
+
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok {
+		return sample, fmt.Errorf("%w: missing value", entities.ErrMetricMissingValue)
+	}
+
+	parts := strings.Split(rest, "|")
+	if len(parts) < 2 {
+		return sample, fmt.Errorf("%w: missing type", entities.ErrMetricUnknown)
+	}
+
+	sample.Name = name
+	sample.Type = parts[1]
+
+	switch sample.Type {
+	case TypeCounter, TypeGauge, TypeTimer, TypeHisto:
+	default:
+		return sample, fmt.Errorf("%w: %s", entities.ErrMetricUnknown, sample.Type)
+	}
+
+	raw := parts[0]
+	if sample.Type == TypeGauge && (strings.HasPrefix(raw, "+") || strings.HasPrefix(raw, "-")) {
+		sample.Relative = true
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return sample, entities.ErrMetricInvalidValue
+	}
+
+	sample.Value = value
+
+	for _, ext := range parts[2:] {
+		switch {
+		case strings.HasPrefix(ext, "@"):
+			rate, err := strconv.ParseFloat(ext[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return sample, fmt.Errorf("%w: bad sample rate %q", entities.ErrMetricInvalidValue, ext)
+			}
+
+			sample.Rate = rate
+		case strings.HasPrefix(ext, "#"):
+			sample.Labels = parseTags(ext[1:])
+		}
+	}
+
+	kind := metrics.KindGauge
+	if sample.Type == TypeCounter {
+		kind = metrics.KindCounter
+	}
+
+	if err := validators.ValidateMetric(sample.Name, kind); err != nil {
+		return sample, err
+	}
+
+	if err := validators.ValidateLabels(sample.Labels); err != nil {
+		return sample, err
+	}
+
+	return sample, nil
+}
+
+func parseTags(src string) metrics.Labels {
+	labels := make(metrics.Labels)
+
+	for _, tag := range strings.Split(src, ",") {
+		if len(tag) == 0 {
+			continue
+		}
+
+		k, v, _ := strings.Cut(tag, ":")
+		labels[k] = v
+	}
+
+	return labels
+}
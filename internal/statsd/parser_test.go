@@ -0,0 +1,83 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    Sample
+		wantErr error
+	}{
+		{
+			name: "counter",
+			line: "requests:1|c",
+			want: Sample{Name: "requests", Type: TypeCounter, Value: 1, Rate: 1},
+		},
+		{
+			name: "counter with sample rate and tags",
+			line: "requests:2|c|@0.5|#host:a,env:prod",
+			want: Sample{Name: "requests", Type: TypeCounter, Value: 2, Rate: 0.5, Labels: metrics.Labels{"host": "a", "env": "prod"}},
+		},
+		{
+			name: "gauge",
+			line: "queue:42.5|g",
+			want: Sample{Name: "queue", Type: TypeGauge, Value: 42.5, Rate: 1},
+		},
+		{
+			name: "relative gauge",
+			line: "queue:-3|g",
+			want: Sample{Name: "queue", Type: TypeGauge, Value: -3, Relative: true, Rate: 1},
+		},
+		{
+			name: "timer",
+			line: "latency:320|ms",
+			want: Sample{Name: "latency", Type: TypeTimer, Value: 320, Rate: 1},
+		},
+		{
+			name:    "missing value",
+			line:    "requests",
+			wantErr: entities.ErrMetricMissingValue,
+		},
+		{
+			name:    "unknown type",
+			line:    "users:1|s",
+			wantErr: entities.ErrMetricUnknown,
+		},
+		{
+			name:    "bad value",
+			line:    "requests:abc|c",
+			wantErr: entities.ErrMetricInvalidValue,
+		},
+		{
+			name:    "bad sample rate",
+			line:    "requests:1|c|@2",
+			wantErr: entities.ErrMetricInvalidValue,
+		},
+		{
+			name:    "bad name",
+			line:    "req uests:1|c",
+			wantErr: entities.ErrMetricInvalidName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
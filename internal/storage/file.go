@@ -2,10 +2,11 @@ package storage
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ex0rcist/metflix/internal/logging"
@@ -14,7 +15,15 @@ import (
 
 var _ MetricsStorage = (*FileStorage)(nil)
 
+var _ CheckableStorage = (*FileStorage)(nil)
+
+var _ IncrementingStorage = (*FileStorage)(nil)
+
 // File-backed storage.
+// Snapshots are written atomically with checksum, up to snapshotsKept previous ones are kept as <storePath>.<n>.
+// Every change is appended to write-ahead log <storePath>.wal.<n> first,
+// log is replayed on restore after the snapshot and truncated by the next dump.
+// With zero store interval every write is fsynced to the log and snapshot is taken every syncSnapshotInterval.
 type FileStorage struct {
 	*MemStorage
 	sync.Mutex
@@ -23,15 +32,33 @@ type FileStorage struct {
 	storeInterval  int
 	restoreOnStart bool
 	dumpTicker     *time.Ticker
+	snapshotsKept  int
+	snapshotFormat string
+	dumpErr        atomic.Pointer[error] // result of the last dump, nil before the first one
+
+	wal     *wal
+	walLock sync.RWMutex // writes hold it shared, dump exclusively to rotate WAL and snapshot consistently
 }
 
+// Snapshot interval when every change is saved synchronously: log is fsynced per write,
+// snapshot only bounds its length and replay time.
+const syncSnapshotInterval = 5 * time.Minute
+
 // FileStorage constructor.
-func NewFileStorage(storePath string, storeInterval int, restoreOnStart bool) (*FileStorage, error) {
+func NewFileStorage(storePath string, storeInterval int, restoreOnStart bool, opts ...Option) (*FileStorage, error) {
+	o := newOptions(opts...)
+
 	fs := &FileStorage{
 		MemStorage:     NewMemStorage(),
 		storePath:      storePath,
 		storeInterval:  storeInterval,
 		restoreOnStart: restoreOnStart,
+		snapshotsKept:  o.snapshotsKept,
+		snapshotFormat: o.snapshotFormat,
+	}
+
+	if err := validateSnapshotFormat(fs.snapshotFormat); err != nil {
+		return nil, err
 	}
 
 	if fs.restoreOnStart {
@@ -40,9 +67,22 @@ func NewFileStorage(storePath string, storeInterval int, restoreOnStart bool) (*
 		}
 	}
 
-	if fs.storeInterval > 0 {
-		fs.dumpTicker = time.NewTicker(utils.IntToDuration(fs.storeInterval))
-		go fs.startStorageDumping(fs.dumpTicker)
+	walSync, snapshotInterval := o.walSync, utils.IntToDuration(fs.storeInterval)
+	if fs.storeInterval <= 0 {
+		// change is saved once it is durable in the log, not after rewriting the whole snapshot
+		walSync, snapshotInterval = WALSyncAlways, syncSnapshotInterval
+	}
+
+	if err := fs.openWAL(walSync); err != nil {
+		return nil, err
+	}
+
+	fs.dumpTicker = time.NewTicker(snapshotInterval)
+	go fs.startStorageDumping(fs.dumpTicker)
+
+	// started after restore so restored samples are retained too
+	if o.retentionEnabled() {
+		fs.MemStorage.retention = startRetention(fs.MemStorage, o.retention, o.retentionInterval)
 	}
 
 	return fs, nil
@@ -50,61 +90,134 @@ func NewFileStorage(storePath string, storeInterval int, restoreOnStart bool) (*
 
 // Push a record to the storage.
 func (s *FileStorage) Push(ctx context.Context, id string, record Record) error {
-	if err := s.MemStorage.Push(ctx, id, record); err != nil {
+	return s.PushList(ctx, map[string]Record{id: record})
+}
+
+// Push list of records to the storage.
+func (s *FileStorage) PushList(_ context.Context, data map[string]Record) error {
+	now := time.Now()
+
+	s.walLock.RLock()
+	defer s.walLock.RUnlock()
+
+	if err := s.wal.append(newWALPushEntry(data, now)); err != nil {
 		return err
 	}
 
-	if s.storeInterval == 0 {
-		return s.dump()
-	}
+	s.MemStorage.pushList(data, now)
 
 	return nil
 }
 
-// Push list of records to the storage.
-func (s *FileStorage) PushList(ctx context.Context, data map[string]Record) error {
-	if err := s.MemStorage.PushList(ctx, data); err != nil {
+// Add counter deltas to stored values atomically.
+func (s *FileStorage) Increment(_ context.Context, data map[string]Record) (map[string]Record, error) {
+	now := time.Now()
+
+	s.walLock.RLock()
+	defer s.walLock.RUnlock()
+
+	// resulting values are logged under storage lock, so replay order matches order of increments
+	return s.MemStorage.increment(data, now, func(result map[string]Record) error {
+		return s.wal.append(newWALPushEntry(result, now))
+	})
+}
+
+// Delete record from the storage.
+func (s *FileStorage) Delete(ctx context.Context, id string) error {
+	s.walLock.RLock()
+	defer s.walLock.RUnlock()
+
+	if _, err := s.MemStorage.Get(ctx, id); err != nil {
 		return err
 	}
 
-	if s.storeInterval == 0 {
-		return s.dump()
+	if err := s.wal.append(walEntry{Op: walOpDelete, Time: time.Now(), ID: id}); err != nil {
+		return err
 	}
 
-	return nil
+	return s.MemStorage.Delete(ctx, id)
 }
 
 // Close storage (dump to disk)
-func (s *FileStorage) Close(_ context.Context) error {
-	if s.dumpTicker != nil {
-		s.dumpTicker.Stop()
+func (s *FileStorage) Close(ctx context.Context) error {
+	s.dumpTicker.Stop()
+
+	if err := s.MemStorage.Close(ctx); err != nil {
+		return err
 	}
 
-	return s.dump()
+	dumpErr := s.dump()
+
+	// WAL is not needed anymore if everything got into snapshot
+	if err := s.wal.close(dumpErr == nil); err != nil {
+		logging.LogError(err, "failed to close WAL")
+	}
+
+	return dumpErr
+}
+
+// Ensure storage is open and the last dump succeeded.
+func (s *FileStorage) Ping(ctx context.Context) error {
+	if err := s.MemStorage.Ping(ctx); err != nil {
+		return err
+	}
+
+	return s.checkDump(ctx)
+}
+
+// Readiness checks of the storage.
+func (s *FileStorage) HealthChecks() []HealthCheck {
+	return []HealthCheck{
+		{Name: "memory", Check: s.MemStorage.Ping},
+		{Name: "file_dump", Check: s.checkDump},
+	}
+}
+
+func (s *FileStorage) checkDump(_ context.Context) error {
+	if err := s.dumpErr.Load(); err != nil && *err != nil {
+		return fmt.Errorf("last dump to %s failed: %w", s.storePath, *err)
+	}
+
+	return nil
 }
 
 func (s *FileStorage) dump() (err error) {
+	defer func() {
+		s.dumpErr.Store(&err)
+	}()
+
 	logging.LogInfo("dumping storage to file " + s.storePath)
 
 	s.Lock()
 	defer s.Unlock()
 
-	file, err := os.OpenFile(s.storePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	// changes logged after rotation are not in the snapshot and stay in the new segment
+	s.walLock.Lock()
+	segment, err := s.wal.rotate()
+	snapshot := s.Snapshot()
+	s.walLock.Unlock()
+
 	if err != nil {
-		return fmt.Errorf("error during FileStorage.Dump()/os.OpenFile(): %w", err)
+		return fmt.Errorf("error during FileStorage.Dump()/wal.rotate(): %w", err)
 	}
 
-	defer func() {
-		if closeErr := file.Close(); err == nil && closeErr != nil {
-			err = fmt.Errorf("error during FileStorage.Dump()/file.Close(): %w", closeErr)
-		}
-	}()
+	if err := s.writeSnapshot(snapshot); err != nil {
+		return err
+	}
 
-	encoder := json.NewEncoder(file)
-	snapshot := s.Snapshot()
+	if err := s.wal.removeBefore(segment); err != nil {
+		return fmt.Errorf("error during FileStorage.Dump()/wal.removeBefore(): %w", err)
+	}
 
-	if err := encoder.Encode(snapshot); err != nil {
-		return fmt.Errorf("error during FileStorage.Dump()/encoder.Encode(): %w", err)
+	return nil
+}
+
+func (s *FileStorage) writeSnapshot(snapshot *MemStorage) error {
+	err := writeSnapshotFile(s.storePath, s.snapshotsKept, s.snapshotFormat, func(w io.Writer) error {
+		return encodeSnapshot(s.snapshotFormat, w, snapshot)
+	})
+	if err != nil {
+		return fmt.Errorf("error during FileStorage.Dump()/writeSnapshotFile(): %w", err)
 	}
 
 	return nil
@@ -116,32 +229,113 @@ func (s *FileStorage) restore() (err error) {
 	s.Lock()
 	defer s.Unlock()
 
-	file, err := os.Open(s.storePath)
+	if err := s.restoreSnapshot(); err != nil {
+		return err
+	}
+
+	replayed, err := replayWAL(s.walPrefix(), s.applyWALEntry)
 	if err != nil {
+		return fmt.Errorf("error during FileStorage.Restore()/replayWAL(): %w", err)
+	}
+
+	if replayed > 0 {
+		logging.LogInfoF("replayed %d WAL entries", replayed)
+	}
+
+	return nil
+}
+
+// Restore from the newest valid snapshot, falling back to older ones.
+func (s *FileStorage) restoreSnapshot() error {
+	candidates, err := snapshotCandidates(s.storePath)
+	if err != nil {
+		return fmt.Errorf("error during FileStorage.Restore()/snapshotCandidates(): %w", err)
+	}
+
+	var (
+		found   bool
+		lastErr error
+	)
+
+	for i, path := range candidates {
+		restored := NewMemStorage()
+
+		err := readSnapshotFile(path, func(format string, r io.Reader) error {
+			return decodeSnapshot(format, r, restored)
+		})
+
 		if os.IsNotExist(err) {
-			logging.LogWarn("no storage dump found to restore")
-			return nil
+			continue
+		}
+
+		found = true
+
+		if err != nil {
+			logging.LogError(err, "failed to restore snapshot "+path)
+			lastErr = err
+
+			continue
+		}
+
+		s.Data = restored.Data
+		s.History = restored.History
+		s.Minutes = restored.Minutes
+		s.Hours = restored.Hours
+
+		if i > 0 {
+			logging.LogWarn("storage data was restored from previous snapshot " + path + ", WAL may not cover changes since then")
+		} else {
+			logging.LogInfo("storage data was restored")
 		}
 
-		return fmt.Errorf("error during FileStorage.Restore()/os.Open(): %w", err)
+		return nil
 	}
 
-	defer func() {
-		if closeErr := file.Close(); err == nil && closeErr != nil {
-			err = fmt.Errorf("error during FileStorage.Restore()/file.Close(): %w", closeErr)
+	if !found {
+		logging.LogWarn("no storage dump found to restore")
+		return nil
+	}
+
+	return fmt.Errorf("error during FileStorage.Restore(): no valid snapshot found: %w", lastErr)
+}
+
+func (s *FileStorage) openWAL(policy string) error {
+	// state is built from scratch, old log must not be replayed on top of it later
+	if !s.restoreOnStart {
+		if err := removeWAL(s.walPrefix()); err != nil {
+			return err
 		}
-	}()
+	}
 
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(s.MemStorage); err != nil {
-		return fmt.Errorf("error during FileStorage.Restore()/decoder.Decode(): %w", err)
+	w, err := openWAL(s.walPrefix(), policy)
+	if err != nil {
+		return err
 	}
 
-	logging.LogInfo("storage data was restored")
+	s.wal = w
 
 	return nil
 }
 
+func (s *FileStorage) applyWALEntry(entry walEntry) {
+	switch entry.Op {
+	case walOpPush:
+		data := make(map[string]Record, len(entry.Records))
+		for _, wr := range entry.Records {
+			wr.Record.Timestamp = wr.Observed
+			data[wr.ID] = wr.Record
+		}
+
+		s.MemStorage.pushList(data, entry.Time)
+	case walOpDelete:
+		_ = s.MemStorage.Delete(context.Background(), entry.ID) // may be already absent in snapshot
+	}
+}
+
+func (s *FileStorage) walPrefix() string {
+	return s.storePath + ".wal"
+}
+
 func (s *FileStorage) startStorageDumping(ticker *time.Ticker) {
 	defer ticker.Stop()
 
@@ -2,8 +2,10 @@ package storage
 
 import (
 	"context"
-	"encoding/json"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -22,9 +24,19 @@ func removeFile(t *testing.T, path string) {
 	}
 }
 
+func readSnapshot(t *testing.T, path string) *MemStorage {
+	ms := NewMemStorage()
+
+	err := readSnapshotFile(path, func(format string, r io.Reader) error {
+		return decodeSnapshot(format, r, ms)
+	})
+	checkNoError(t, err, "failed to read storage snapshot")
+
+	return ms
+}
+
 func TestNewFileStorage(t *testing.T) {
-	storePath := "test_store.json"
-	defer removeFile(t, storePath)
+	storePath := filepath.Join(t.TempDir(), "test_store.json")
 
 	fs, err := NewFileStorage(storePath, 0, false)
 	checkNoError(t, err, "failed to create new FileStorage")
@@ -45,39 +57,43 @@ func TestNewFileStorage(t *testing.T) {
 
 func TestSyncPushAndDump(t *testing.T) {
 	ctx := context.Background()
-	storePath := "test_store.json"
-	defer removeFile(t, storePath)
+	storePath := filepath.Join(t.TempDir(), "test_store.json")
 
 	fs, err := NewFileStorage(storePath, 0, false)
 	checkNoError(t, err, "failed to create new FileStorage")
 
+	if fs.wal.policy != WALSyncAlways {
+		t.Errorf("expected WAL policy %q, got %q", WALSyncAlways, fs.wal.policy)
+	}
+
 	record := Record{Name: "test", Value: metrics.Counter(42)}
 	err = fs.Push(ctx, record.CalculateRecordID(), record)
 	checkNoError(t, err, "failed to push record")
 
-	data, err := os.ReadFile(storePath)
-	checkNoError(t, err, "failed to read storage file")
+	// change is in the log, snapshot is written on close
+	if _, err := os.Stat(storePath); !os.IsNotExist(err) {
+		t.Fatalf("expected no snapshot before close, got %v", err)
+	}
+
+	checkNoError(t, fs.Close(ctx), "failed to close fs")
 
-	err = json.Unmarshal(data, &fs.MemStorage)
-	checkNoError(t, err, "failed to unmarshal storage file")
+	fs.MemStorage = readSnapshot(t, storePath)
 
-	if got, err := fs.Get(ctx, record.CalculateRecordID()); err != nil || got != record {
+	if got, err := fs.Get(ctx, record.CalculateRecordID()); err != nil || !reflect.DeepEqual(got, record) {
 		t.Errorf("expected record %v, got %v", record, got)
 	}
 }
 
 func TestRestore(t *testing.T) {
 	ctx := context.Background()
-
-	storePath := "test_store.json"
-	defer removeFile(t, storePath)
+	storePath := filepath.Join(t.TempDir(), "test_store.json")
 
 	// create dump
 	fs1, err := NewFileStorage(storePath, 0, false)
 	checkNoError(t, err, "failed to create new FileStorage")
 
 	record := Record{Name: "test", Value: metrics.Counter(42)}
-	err = fs1.Push(ctx, record.CalculateRecordID(), record) // dumped
+	err = fs1.Push(ctx, record.CalculateRecordID(), record) // logged
 	checkNoError(t, err, "failed to push to FileStorage")
 
 	// new storage from dump
@@ -88,7 +104,7 @@ func TestRestore(t *testing.T) {
 	if err != nil {
 		checkNoError(t, err, "expected to find restored record, but did not")
 	}
-	if restoredRecord != record {
+	if !reflect.DeepEqual(restoredRecord, record) {
 		t.Errorf("expected restored record %v, got %v", record, restoredRecord)
 	}
 }
@@ -111,14 +127,69 @@ func TestAsyncDumping(t *testing.T) {
 	err = fs.Close(ctx)
 	checkNoError(t, err, "failed to close fs")
 
-	data, err := os.ReadFile(storePath)
-	checkNoError(t, err, "failed to read storage file")
-
-	ms := NewMemStorage()
-	err = json.Unmarshal(data, &ms)
-	checkNoError(t, err, "failed to unmarshal storage file")
+	ms := readSnapshot(t, storePath)
 
-	if restored, err := ms.Get(ctx, record.CalculateRecordID()); err != nil || restored != record {
+	if restored, err := ms.Get(ctx, record.CalculateRecordID()); err != nil || !reflect.DeepEqual(restored, record) {
 		t.Errorf("expected record %v, got %v", record, restored)
 	}
 }
+
+func TestRestoreHistory(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "test_store.json")
+
+	fs1, err := NewFileStorage(storePath, 0, false)
+	checkNoError(t, err, "failed to create new FileStorage")
+
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		record := Record{Name: "Alloc", Value: metrics.Gauge(i), Timestamp: ts.Add(time.Duration(i) * time.Minute)}
+		checkNoError(t, fs1.Push(ctx, record.CalculateRecordID(), record), "failed to push to FileStorage")
+	}
+
+	fs2, err := NewFileStorage(storePath, 0, true)
+	checkNoError(t, err, "failed to create new FileStorage")
+
+	samples, err := fs2.Range(ctx, "Alloc_gauge", ts, ts.Add(time.Hour))
+	checkNoError(t, err, "failed to get range")
+
+	if len(samples) != 3 {
+		t.Fatalf("expected 3 restored samples, got %d", len(samples))
+	}
+
+	for i, sample := range samples {
+		if !sample.Timestamp.Equal(ts.Add(time.Duration(i)*time.Minute)) || sample.Value != metrics.Gauge(i) {
+			t.Errorf("unexpected restored sample %d: %v", i, sample)
+		}
+	}
+}
+
+func TestFileStorage_Ping(t *testing.T) {
+	ctx := context.Background()
+
+	fs, err := NewFileStorage(filepath.Join(t.TempDir(), "store.json"), 0, false)
+	checkNoError(t, err, "failed to create new FileStorage")
+
+	// no dumps yet
+	checkNoError(t, fs.Ping(ctx), "expected no error before first dump")
+
+	checkNoError(t, fs.Push(ctx, "test_counter", Record{Name: "test", Value: metrics.Counter(1)}), "failed to push record")
+	checkNoError(t, fs.dump(), "failed to dump")
+	checkNoError(t, fs.Ping(ctx), "expected no error after successful dump")
+
+	// make next dump fail
+	fs.storePath = filepath.Join(t.TempDir(), "missing", "store.json")
+
+	if err := fs.dump(); err == nil {
+		t.Fatalf("expected dump to fail")
+	}
+
+	if err := fs.Ping(ctx); err == nil {
+		t.Fatalf("expected Ping to report failed dump")
+	}
+
+	checks := fs.HealthChecks()
+	if len(checks) != 2 || checks[1].Name != "file_dump" || checks[1].Check(ctx) == nil {
+		t.Fatalf("expected file_dump check to fail, got %v", checks)
+	}
+}
@@ -2,24 +2,52 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/pkg/metrics"
 )
 
 var _ MetricsStorage = (*MemStorage)(nil)
 
+var _ RetentionStorage = (*MemStorage)(nil)
+
+var _ CheckableStorage = (*MemStorage)(nil)
+
+var _ IncrementingStorage = (*MemStorage)(nil)
+
+// How often Ping retries to acquire the lock.
+const pingPollInterval = 10 * time.Millisecond
+
 // In-memory storage.
 type MemStorage struct {
 	sync.Mutex
-	Data map[string]Record `json:"records"`
+	Data    map[string]Record   `json:"records"`
+	History map[string][]Sample `json:"history,omitempty"`
+	Minutes map[string][]Rollup `json:"rollups_1m,omitempty"`
+	Hours   map[string][]Rollup `json:"rollups_1h,omitempty"`
+
+	retention *periodicWorker
+	closed    bool
 }
 
 // MemoryStorage constructor.
-func NewMemStorage() *MemStorage {
-	return &MemStorage{
-		Data: make(map[string]Record),
+func NewMemStorage(opts ...Option) *MemStorage {
+	s := &MemStorage{
+		Data:    make(map[string]Record),
+		History: make(map[string][]Sample),
+		Minutes: make(map[string][]Rollup),
+		Hours:   make(map[string][]Rollup),
+	}
+
+	if o := newOptions(opts...); o.retentionEnabled() {
+		s.retention = startRetention(s, o.retention, o.retentionInterval)
 	}
+
+	return s
 }
 
 // Push a record to the storage.
@@ -27,18 +55,161 @@ func (s *MemStorage) Push(_ context.Context, id string, record Record) error {
 	s.Lock()
 	defer s.Unlock()
 
-	s.Data[id] = record
+	s.push(id, record, time.Now())
 
 	return nil
 }
 
 // Push list of records to the storage.
 func (s *MemStorage) PushList(_ context.Context, data map[string]Record) error {
+	s.pushList(data, time.Now())
+
+	return nil
+}
+
+// Add counter deltas to stored values under the storage lock.
+func (s *MemStorage) Increment(_ context.Context, data map[string]Record) (map[string]Record, error) {
+	return s.increment(data, time.Now(), nil)
+}
+
+// Get samples of the series within [from, to], ordered by time.
+func (s *MemStorage) Range(_ context.Context, id string, from, to time.Time) ([]Sample, error) {
 	s.Lock()
 	defer s.Unlock()
 
-	for id, record := range data {
-		s.Data[id] = record
+	history := s.History[id]
+
+	start := sort.Search(len(history), func(i int) bool {
+		return !history[i].Timestamp.Before(from)
+	})
+
+	result := make([]Sample, 0)
+	for _, sample := range history[start:] {
+		if sample.Timestamp.After(to) {
+			break
+		}
+
+		result = append(result, sample)
+	}
+
+	return result, nil
+}
+
+// Get rollups of the series with given resolution within [from, to], ordered by time.
+func (s *MemStorage) RangeRollups(_ context.Context, id string, resolution time.Duration, from, to time.Time) ([]Rollup, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var rollups []Rollup
+
+	switch resolution {
+	case ResolutionMinute:
+		rollups = s.Minutes[id]
+	case ResolutionHour:
+		rollups = s.Hours[id]
+	default:
+		return nil, entities.ErrRangeInvalid
+	}
+
+	start := sort.Search(len(rollups), func(i int) bool {
+		return !rollups[i].Timestamp.Before(from)
+	})
+
+	result := make([]Rollup, 0)
+	for _, rollup := range rollups[start:] {
+		if rollup.Timestamp.After(to) {
+			break
+		}
+
+		result = append(result, rollup)
+	}
+
+	return result, nil
+}
+
+// Drop expired samples and rollups, rolling them up into coarser resolution first.
+func (s *MemStorage) ApplyRetention(_ context.Context, now time.Time, policies RetentionPolicies) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for id, history := range s.History {
+		policy, ok := s.policyFor(id, policies)
+		if !ok {
+			continue
+		}
+
+		cutoff := now.Add(-policy.Raw)
+		idx := sort.Search(len(history), func(i int) bool {
+			return !history[i].Timestamp.Before(cutoff)
+		})
+
+		if idx == 0 {
+			continue
+		}
+
+		if target := rawRollupTarget(policy); target != ResolutionRaw {
+			for _, sample := range history[:idx] {
+				if rollup, ok := SampleToRollup(sample); ok {
+					s.addRollup(target, id, rollup)
+				}
+			}
+		}
+
+		if idx == len(history) {
+			delete(s.History, id)
+		} else {
+			s.History[id] = append([]Sample(nil), history[idx:]...)
+		}
+	}
+
+	for id, rollups := range s.Minutes {
+		policy, ok := s.policyFor(id, policies)
+		if !ok {
+			continue
+		}
+
+		cutoff := now.Add(-policy.Minute)
+		idx := sort.Search(len(rollups), func(i int) bool {
+			return !rollups[i].Timestamp.Before(cutoff)
+		})
+
+		if idx == 0 {
+			continue
+		}
+
+		if policy.Hour > 0 {
+			for _, rollup := range rollups[:idx] {
+				s.addRollup(ResolutionHour, id, rollup)
+			}
+		}
+
+		if idx == len(rollups) {
+			delete(s.Minutes, id)
+		} else {
+			s.Minutes[id] = append([]Rollup(nil), rollups[idx:]...)
+		}
+	}
+
+	for id, rollups := range s.Hours {
+		policy, ok := s.policyFor(id, policies)
+		if !ok {
+			continue
+		}
+
+		cutoff := now.Add(-policy.Hour)
+		idx := sort.Search(len(rollups), func(i int) bool {
+			return !rollups[i].Timestamp.Before(cutoff)
+		})
+
+		if idx == 0 {
+			continue
+		}
+
+		if idx == len(rollups) {
+			delete(s.Hours, id)
+		} else {
+			s.Hours[id] = append([]Rollup(nil), rollups[idx:]...)
+		}
 	}
 
 	return nil
@@ -58,21 +229,38 @@ func (s *MemStorage) Get(_ context.Context, id string) (Record, error) {
 }
 
 // Get list of records from the storage.
-func (s *MemStorage) List(_ context.Context) ([]Record, error) {
+func (s *MemStorage) List(_ context.Context, filter ListFilter) ([]Record, error) {
 	s.Lock()
 	defer s.Unlock()
 
-	arr := make([]Record, len(s.Data))
+	arr := make([]Record, 0, len(s.Data))
 
-	i := 0
 	for _, record := range s.Data {
-		arr[i] = record
-		i++
+		if filter.Match(record) {
+			arr = append(arr, record)
+		}
 	}
 
 	return arr, nil
 }
 
+// Delete record and its history from the storage.
+func (s *MemStorage) Delete(_ context.Context, id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.Data[id]; !ok {
+		return entities.ErrRecordNotFound
+	}
+
+	delete(s.Data, id)
+	delete(s.History, id)
+	delete(s.Minutes, id)
+	delete(s.Hours, id)
+
+	return nil
+}
+
 // Take snapshot of records.
 func (s *MemStorage) Snapshot() *MemStorage {
 	s.Lock()
@@ -84,14 +272,195 @@ func (s *MemStorage) Snapshot() *MemStorage {
 		snapshot[k] = v
 	}
 
-	return &MemStorage{Data: snapshot}
+	history := make(map[string][]Sample, len(s.History))
+
+	for k, v := range s.History {
+		history[k] = append([]Sample(nil), v...)
+	}
+
+	return &MemStorage{
+		Data:    snapshot,
+		History: history,
+		Minutes: copyRollups(s.Minutes),
+		Hours:   copyRollups(s.Hours),
+	}
 }
 
-// Close storage (does nothing for in-memory).
+// Close storage (stops retention if any).
 func (s *MemStorage) Close(_ context.Context) error {
-	return nil // do nothing
+	s.retention.stop()
+	s.retention = nil
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.closed = true
+
+	return nil
+}
+
+// Ensure storage is open and not stuck under lock.
+func (s *MemStorage) Ping(ctx context.Context) error {
+	ticker := time.NewTicker(pingPollInterval)
+	defer ticker.Stop()
+
+	for !s.TryLock() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("memory storage is locked: %w", ctx.Err())
+		}
+	}
+	defer s.Unlock()
+
+	if s.closed {
+		return entities.ErrStorageClosed
+	}
+
+	return nil
+}
+
+// Readiness checks of the storage.
+func (s *MemStorage) HealthChecks() []HealthCheck {
+	return []HealthCheck{{Name: "memory", Check: s.Ping}}
 }
 
 func (s *MemStorage) String() string {
 	return "storage=memory"
 }
+
+// Push records stamped with given time.
+func (s *MemStorage) pushList(data map[string]Record, now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	for id, record := range data {
+		s.push(id, record, now)
+	}
+}
+
+// Read-modify-write counters under lock. Resulting records are passed to beforeStore (if any) before being stored,
+// storing is cancelled if it fails.
+func (s *MemStorage) increment(data map[string]Record, now time.Time, beforeStore func(result map[string]Record) error) (map[string]Record, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	result := make(map[string]Record, len(data))
+
+	for id, record := range data {
+		delta, ok := record.Value.(metrics.Counter)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not a counter", entities.ErrMetricInvalidValue, id)
+		}
+
+		if prev, ok := s.Data[id].Value.(metrics.Counter); ok {
+			record.Value = prev + delta
+		}
+
+		result[id] = record
+	}
+
+	if beforeStore != nil {
+		if err := beforeStore(result); err != nil {
+			return nil, err
+		}
+	}
+
+	for id, record := range result {
+		s.push(id, record, now)
+	}
+
+	return result, nil
+}
+
+// Store record as latest value and append it to series history keeping it sorted by time.
+// Must be called under lock.
+func (s *MemStorage) push(id string, record Record, now time.Time) {
+	s.Data[id] = record
+
+	if s.History == nil {
+		s.History = make(map[string][]Sample)
+	}
+
+	sample := newSample(record, now)
+	history := s.History[id]
+
+	// fast path: samples mostly arrive in order
+	if len(history) == 0 || !sample.Timestamp.Before(history[len(history)-1].Timestamp) {
+		s.History[id] = append(history, sample)
+		return
+	}
+
+	idx := sort.Search(len(history), func(i int) bool {
+		return history[i].Timestamp.After(sample.Timestamp)
+	})
+
+	history = append(history, Sample{})
+	copy(history[idx+1:], history[idx:])
+	history[idx] = sample
+
+	s.History[id] = history
+}
+
+// Find retention policy by series name. Must be called under lock.
+func (s *MemStorage) policyFor(id string, policies RetentionPolicies) (RetentionPolicy, bool) {
+	record, ok := s.Data[id]
+	if !ok {
+		return RetentionPolicy{}, false
+	}
+
+	return policies.Match(record.Name)
+}
+
+// Merge rollup into series rollups of given resolution keeping them sorted by time.
+// Must be called under lock.
+func (s *MemStorage) addRollup(resolution time.Duration, id string, rollup Rollup) {
+	target := &s.Minutes
+	if resolution == ResolutionHour {
+		target = &s.Hours
+	}
+
+	if *target == nil {
+		*target = make(map[string][]Rollup)
+	}
+
+	rollup.Timestamp = rollup.Timestamp.Truncate(resolution)
+	rollups := (*target)[id]
+
+	idx := sort.Search(len(rollups), func(i int) bool {
+		return !rollups[i].Timestamp.Before(rollup.Timestamp)
+	})
+
+	if idx < len(rollups) && rollups[idx].Timestamp.Equal(rollup.Timestamp) {
+		rollups[idx] = rollups[idx].Merge(rollup)
+		return
+	}
+
+	rollups = append(rollups, Rollup{})
+	copy(rollups[idx+1:], rollups[idx:])
+	rollups[idx] = rollup
+
+	(*target)[id] = rollups
+}
+
+// Resolution expired raw samples are rolled up into.
+func rawRollupTarget(policy RetentionPolicy) time.Duration {
+	switch {
+	case policy.Minute > 0:
+		return ResolutionMinute
+	case policy.Hour > 0:
+		return ResolutionHour
+	default:
+		return ResolutionRaw
+	}
+}
+
+func copyRollups(src map[string][]Rollup) map[string][]Rollup {
+	dst := make(map[string][]Rollup, len(src))
+
+	for k, v := range src {
+		dst[k] = append([]Rollup(nil), v...)
+	}
+
+	return dst
+}
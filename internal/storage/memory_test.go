@@ -2,8 +2,13 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
+	"math"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/pkg/metrics"
 	"github.com/stretchr/testify/require"
 )
@@ -25,9 +30,8 @@ func TestMemStorage_Push(t *testing.T) {
 			t.Fatalf("expected no error, got %v", err)
 		}
 
-		if s, _ := strg.Get(ctx, id); r != s {
-			t.Fatalf("expected record %v, got %v", r, s)
-		}
+		s, _ := strg.Get(ctx, id)
+		require.Equal(t, r, s)
 	}
 }
 
@@ -46,9 +50,8 @@ func TestMemStorage_PushList(t *testing.T) {
 	}
 
 	for id, r := range records {
-		if s, _ := strg.Get(ctx, id); r != s {
-			t.Fatalf("expected record %v, got %v", r, s)
-		}
+		s, _ := strg.Get(ctx, id)
+		require.Equal(t, r, s)
 	}
 }
 
@@ -82,13 +85,8 @@ func TestMemStorage_Push_WithSameName(t *testing.T) {
 		t.Fatalf("expected no error, got %v", err)
 	}
 
-	if storedCounter != records[0] {
-		t.Fatalf("expected stored %v, got %v", records[0], storedCounter)
-	}
-
-	if storedGauge != records[1] {
-		t.Fatalf("expected stored %v, got %v", records[1], storedGauge)
-	}
+	require.Equal(t, records[0], storedCounter)
+	require.Equal(t, records[1], storedGauge)
 }
 
 func TestMemStorage_Get(t *testing.T) {
@@ -116,9 +114,7 @@ func TestMemStorage_Get(t *testing.T) {
 			if (err != nil) != tt.wantError {
 				t.Fatalf("expected error: %v, got %v", tt.wantError, err)
 			}
-			if got != tt.want {
-				t.Fatalf("expected record %v, got %v", tt.want, got)
-			}
+			require.Equal(t, tt.want, got)
 		})
 	}
 }
@@ -142,7 +138,7 @@ func TestMemStorage_List(t *testing.T) {
 		t.Fatalf("expected no error, got %v", err)
 	}
 
-	got, err := storage.List(ctx)
+	got, err := storage.List(ctx, ListFilter{})
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -153,3 +149,315 @@ func TestMemStorage_List(t *testing.T) {
 
 	require.ElementsMatch(t, records, got)
 }
+
+func TestMemStorage_ListFiltered(t *testing.T) {
+	ctx := context.Background()
+	strg := NewMemStorage()
+
+	records := []Record{
+		{Name: "Alloc", Value: metrics.Gauge(1), Labels: metrics.Labels{"host": "a", "env": "prod"}},
+		{Name: "Alloc", Value: metrics.Gauge(2), Labels: metrics.Labels{"host": "b", "env": "prod"}},
+		{Name: "Alloc", Value: metrics.Gauge(3)},
+		{Name: "PollCount", Value: metrics.Counter(1), Labels: metrics.Labels{"host": "a"}},
+	}
+
+	for _, r := range records {
+		require.NoError(t, strg.Push(ctx, r.CalculateRecordID(), r))
+	}
+
+	tests := []struct {
+		name   string
+		filter ListFilter
+		want   []Record
+	}{
+		{name: "empty filter", filter: ListFilter{}, want: records},
+		{name: "by name", filter: ListFilter{Name: "Alloc"}, want: records[:3]},
+		{name: "by kind", filter: ListFilter{Kind: metrics.KindCounter}, want: records[3:]},
+		{name: "by name prefix", filter: ListFilter{NamePrefix: "Poll"}, want: records[3:]},
+		{name: "by label", filter: ListFilter{Labels: metrics.Labels{"host": "a"}}, want: []Record{records[0], records[3]}},
+		{name: "by several labels", filter: ListFilter{Labels: metrics.Labels{"host": "b", "env": "prod"}}, want: records[1:2]},
+		{name: "nothing matches", filter: ListFilter{Name: "Alloc", Labels: metrics.Labels{"host": "c"}}, want: []Record{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := strg.List(ctx, tt.filter)
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
+
+func TestMemStorage_Delete(t *testing.T) {
+	ctx := context.Background()
+	strg := NewMemStorage()
+
+	record := Record{Name: "Alloc", Value: metrics.Gauge(1)}
+	id := record.CalculateRecordID()
+
+	require.NoError(t, strg.Push(ctx, id, record))
+	strg.Minutes[id] = []Rollup{{Timestamp: time.Now(), Count: 1}}
+
+	require.NoError(t, strg.Delete(ctx, id))
+
+	_, err := strg.Get(ctx, id)
+	require.ErrorIs(t, err, entities.ErrRecordNotFound)
+	require.NotContains(t, strg.History, id)
+	require.NotContains(t, strg.Minutes, id)
+
+	require.ErrorIs(t, strg.Delete(ctx, id), entities.ErrRecordNotFound)
+}
+
+func TestMemStorage_Range(t *testing.T) {
+	ctx := context.Background()
+	strg := NewMemStorage()
+
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Name: "Alloc", Value: metrics.Gauge(1), Timestamp: base},
+		{Name: "Alloc", Value: metrics.Gauge(3), Timestamp: base.Add(2 * time.Minute)},
+		{Name: "Alloc", Value: metrics.Gauge(2), Timestamp: base.Add(time.Minute)}, // out of order
+		{Name: "Alloc", Value: metrics.Gauge(4), Timestamp: base.Add(3 * time.Minute)},
+	}
+
+	for _, r := range records {
+		require.NoError(t, strg.Push(ctx, r.CalculateRecordID(), r))
+	}
+
+	latest, err := strg.Get(ctx, "Alloc_gauge")
+	require.NoError(t, err)
+	require.Equal(t, records[3], latest)
+
+	tests := []struct {
+		name     string
+		id       string
+		from, to time.Time
+		want     []Sample
+	}{
+		{
+			name: "whole history sorted",
+			id:   "Alloc_gauge",
+			from: base, to: base.Add(time.Hour),
+			want: []Sample{
+				{Timestamp: base, Value: metrics.Gauge(1)},
+				{Timestamp: base.Add(time.Minute), Value: metrics.Gauge(2)},
+				{Timestamp: base.Add(2 * time.Minute), Value: metrics.Gauge(3)},
+				{Timestamp: base.Add(3 * time.Minute), Value: metrics.Gauge(4)},
+			},
+		},
+		{
+			name: "bounds are inclusive",
+			id:   "Alloc_gauge",
+			from: base.Add(time.Minute), to: base.Add(2 * time.Minute),
+			want: []Sample{
+				{Timestamp: base.Add(time.Minute), Value: metrics.Gauge(2)},
+				{Timestamp: base.Add(2 * time.Minute), Value: metrics.Gauge(3)},
+			},
+		},
+		{
+			name: "empty range",
+			id:   "Alloc_gauge",
+			from: base.Add(time.Hour), to: base.Add(2 * time.Hour),
+			want: []Sample{},
+		},
+		{
+			name: "unknown series",
+			id:   "unknown_gauge",
+			from: base, to: base.Add(time.Hour),
+			want: []Sample{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := strg.Range(ctx, tt.id, tt.from, tt.to)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMemStorage_RangeStampsPushTime(t *testing.T) {
+	ctx := context.Background()
+	strg := NewMemStorage()
+
+	before := time.Now()
+	require.NoError(t, strg.PushList(ctx, map[string]Record{
+		"PollCount_counter": {Name: "PollCount", Value: metrics.Counter(1)},
+	}))
+	after := time.Now()
+
+	got, err := strg.Range(ctx, "PollCount_counter", before, after)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, metrics.Counter(1), got[0].Value)
+}
+
+func TestMemStorage_ApplyRetention(t *testing.T) {
+	ctx := context.Background()
+	strg := NewMemStorage()
+
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Name: "Alloc", Value: metrics.Gauge(1), Timestamp: now.Add(-3 * time.Hour)},
+		{Name: "Alloc", Value: metrics.Gauge(3), Timestamp: now.Add(-3*time.Hour + 10*time.Second)},
+		{Name: "Alloc", Value: metrics.Gauge(5), Timestamp: now.Add(-2 * time.Hour)},
+		{Name: "Alloc", Value: metrics.Gauge(7), Timestamp: now.Add(-time.Minute)},
+		{Name: "PollCount", Value: metrics.Counter(1), Timestamp: now.Add(-3 * time.Hour)},
+	}
+
+	for _, r := range records {
+		require.NoError(t, strg.Push(ctx, r.CalculateRecordID(), r))
+	}
+
+	policies, err := ParseRetentionPolicies("Alloc=1h:90m:24h;*=1h:0:0")
+	require.NoError(t, err)
+	require.NoError(t, strg.ApplyRetention(ctx, now, policies))
+
+	samples, err := strg.Range(ctx, "Alloc_gauge", time.Time{}, now)
+	require.NoError(t, err)
+	require.Equal(t, []Sample{{Timestamp: now.Add(-time.Minute), Value: metrics.Gauge(7)}}, samples)
+
+	// rollups older than 90m are moved to hourly resolution
+	minutes, err := strg.RangeRollups(ctx, "Alloc_gauge", ResolutionMinute, time.Time{}, now)
+	require.NoError(t, err)
+	require.Empty(t, minutes)
+
+	hours, err := strg.RangeRollups(ctx, "Alloc_gauge", ResolutionHour, time.Time{}, now)
+	require.NoError(t, err)
+	require.Equal(t, []Rollup{
+		{Timestamp: now.Add(-3 * time.Hour), Min: 1, Max: 3, Sum: 4, Count: 2},
+		{Timestamp: now.Add(-2 * time.Hour), Min: 5, Max: 5, Sum: 5, Count: 1},
+	}, hours)
+
+	// no rollups kept for catch-all policy, latest value survives
+	samples, err = strg.Range(ctx, "PollCount_counter", time.Time{}, now)
+	require.NoError(t, err)
+	require.Empty(t, samples)
+
+	latest, err := strg.Get(ctx, "PollCount_counter")
+	require.NoError(t, err)
+	require.Equal(t, records[4], latest)
+}
+
+func TestMemStorage_ApplyRetentionSkipsNonFinite(t *testing.T) {
+	ctx := context.Background()
+	strg := NewMemStorage()
+
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Name: "Alloc", Value: metrics.Gauge(math.NaN()), Timestamp: now.Add(-3 * time.Hour)},
+		{Name: "Alloc", Value: metrics.Gauge(2), Timestamp: now.Add(-3*time.Hour + 10*time.Second)},
+	}
+
+	for _, r := range records {
+		require.NoError(t, strg.Push(ctx, r.CalculateRecordID(), r))
+	}
+
+	policies, err := ParseRetentionPolicies("*=1h:24h:0")
+	require.NoError(t, err)
+	require.NoError(t, strg.ApplyRetention(ctx, now, policies))
+
+	minutes, err := strg.RangeRollups(ctx, "Alloc_gauge", ResolutionMinute, time.Time{}, now)
+	require.NoError(t, err)
+	require.Equal(t, []Rollup{{Timestamp: now.Add(-3 * time.Hour), Min: 2, Max: 2, Sum: 2, Count: 1}}, minutes)
+
+	// snapshot must stay encodable
+	_, err = json.Marshal(strg)
+	require.NoError(t, err)
+}
+
+func TestMemStorage_ApplyRetentionKeepsMinutes(t *testing.T) {
+	ctx := context.Background()
+	strg := NewMemStorage()
+
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	record := Record{Name: "Alloc", Value: metrics.Gauge(2), Timestamp: now.Add(-2*time.Hour + 30*time.Second)}
+	require.NoError(t, strg.Push(ctx, record.CalculateRecordID(), record))
+
+	policies, err := ParseRetentionPolicies("*=1h:24h:0")
+	require.NoError(t, err)
+	require.NoError(t, strg.ApplyRetention(ctx, now, policies))
+
+	minutes, err := strg.RangeRollups(ctx, "Alloc_gauge", ResolutionMinute, time.Time{}, now)
+	require.NoError(t, err)
+	require.Equal(t, []Rollup{{Timestamp: now.Add(-2 * time.Hour), Min: 2, Max: 2, Sum: 2, Count: 1}}, minutes)
+
+	_, err = strg.RangeRollups(ctx, "Alloc_gauge", ResolutionRaw, time.Time{}, now)
+	require.ErrorIs(t, err, entities.ErrRangeInvalid)
+}
+
+func TestMemStorage_Ping(t *testing.T) {
+	ctx := context.Background()
+	strg := NewMemStorage()
+
+	require.NoError(t, strg.Ping(ctx))
+
+	// stuck lock is reported once context expires
+	strg.Lock()
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, strg.Ping(timeoutCtx), context.DeadlineExceeded)
+
+	// lock released while polling
+	time.AfterFunc(3*pingPollInterval, strg.Unlock)
+	require.NoError(t, strg.Ping(ctx))
+
+	require.NoError(t, strg.Close(ctx))
+	require.ErrorIs(t, strg.Ping(ctx), entities.ErrStorageClosed)
+}
+
+func TestMemStorage_IncrementConcurrent(t *testing.T) {
+	const (
+		writers    = 20
+		increments = 100
+	)
+
+	ctx := context.Background()
+	strg := NewMemStorage()
+	record := Record{Name: "PollCount", Value: metrics.Counter(1)}
+	id := record.CalculateRecordID()
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < increments; j++ {
+				_, err := strg.Increment(ctx, map[string]Record{id: record})
+				require.NoError(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	stored, err := strg.Get(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, metrics.Counter(writers*increments), stored.Value)
+	require.Len(t, strg.History[id], writers*increments)
+}
+
+func TestMemStorage_IncrementResult(t *testing.T) {
+	ctx := context.Background()
+	strg := NewMemStorage()
+
+	record := Record{Name: "PollCount", Value: metrics.Counter(5)}
+	id := record.CalculateRecordID()
+
+	require.NoError(t, strg.Push(ctx, id, Record{Name: "PollCount", Value: metrics.Counter(10)}))
+
+	result, err := strg.Increment(ctx, map[string]Record{id: record})
+	require.NoError(t, err)
+	require.Equal(t, metrics.Counter(15), result[id].Value)
+
+	_, err = strg.Increment(ctx, map[string]Record{"Alloc_gauge": {Name: "Alloc", Value: metrics.Gauge(1)}})
+	require.ErrorIs(t, err, entities.ErrMetricInvalidValue)
+
+	stored, err := strg.Get(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, metrics.Counter(15), stored.Value)
+}
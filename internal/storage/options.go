@@ -0,0 +1,92 @@
+package storage
+
+import "time"
+
+type options struct {
+	retention          RetentionPolicies
+	retentionInterval  time.Duration
+	walSync            string
+	snapshotsKept      int
+	snapshotFormat     string
+	migrationsSource   string
+	autoMigrate        bool
+	copyThreshold      int
+	maintainPartitions bool
+}
+
+// Storage option.
+type Option func(*options)
+
+// Enable retention with given policies, applied every interval.
+func WithRetention(policies RetentionPolicies, interval time.Duration) Option {
+	return func(o *options) {
+		o.retention = policies
+		o.retentionInterval = interval
+	}
+}
+
+// Set fsync policy of FileStorage write-ahead log, see WALSync* constants.
+func WithWALSync(policy string) Option {
+	return func(o *options) {
+		o.walSync = policy
+	}
+}
+
+// Keep n previous snapshots of FileStorage to fall back to when the newest one is corrupted.
+func WithSnapshotsKept(n int) Option {
+	return func(o *options) {
+		o.snapshotsKept = max(n, 0)
+	}
+}
+
+// Set payload encoding of FileStorage snapshots, see SnapshotFormat* constants.
+// Restore reads snapshots of any format.
+func WithSnapshotFormat(format string) Option {
+	return func(o *options) {
+		o.snapshotFormat = format
+	}
+}
+
+// Set source of PostgresStorage migrations (empty for embedded ones) and whether to apply them on start.
+func WithMigrations(source string, auto bool) Option {
+	return func(o *options) {
+		o.migrationsSource = source
+		o.autoMigrate = auto
+	}
+}
+
+// Write PostgresStorage batches of n records and bigger with COPY into staging table, zero disables it.
+func WithCopyThreshold(n int) Option {
+	return func(o *options) {
+		o.copyThreshold = max(n, 0)
+	}
+}
+
+// Create and drop daily PostgresStorage sample partitions in background. Only the server needs it,
+// replicas coordinate with advisory lock so only one of them maintains partitions at a time.
+func WithPartitionMaintenance(enabled bool) Option {
+	return func(o *options) {
+		o.maintainPartitions = enabled
+	}
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		retentionInterval: time.Minute,
+		walSync:           WALSyncPeriodic,
+		snapshotFormat:    SnapshotFormatJSON,
+		autoMigrate:       true,
+		copyThreshold:     defaultCopyThreshold,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// Whether background retention should be started.
+func (o options) retentionEnabled() bool {
+	return len(o.retention) > 0 && o.retentionInterval > 0
+}
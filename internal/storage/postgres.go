@@ -2,13 +2,18 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/internal/logging"
 	"github.com/ex0rcist/metflix/pkg/metrics"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -16,10 +21,44 @@ import (
 
 var _ MetricsStorage = PostgresStorage{}
 
+var _ CheckableStorage = PostgresStorage{}
+
+var _ IncrementingStorage = PostgresStorage{}
+
+const upsertSQL = "INSERT INTO metrics(id, name, kind, delta, value, histogram, labels) values ($1, $2, $3, $4, $5, $6, $7) " +
+	"ON CONFLICT (id) DO UPDATE SET delta = $4, value = $5, histogram = $6"
+
+// Counter is added to stored value under row lock, resulting value is recorded as sample and returned.
+const incrementSQL = "WITH upserted AS (" +
+	"INSERT INTO metrics(id, name, kind, delta, labels) values ($1, $2, $3, $4, $5) " +
+	"ON CONFLICT (id) DO UPDATE SET delta = metrics.delta + EXCLUDED.delta RETURNING id, kind, delta" +
+	"), sampled AS (INSERT INTO metric_samples(id, ts, kind, value) SELECT id, $6, kind, delta FROM upserted) " +
+	"SELECT delta FROM upserted"
+
+const selectSQL = "SELECT name, kind, delta, value, histogram, labels FROM metrics"
+
+const insertSampleSQL = "INSERT INTO metric_samples(id, ts, kind, value, histogram) values ($1, $2, $3, $4, $5)"
+
+const deleteRecordSQL = "DELETE FROM metrics WHERE id = $1"
+
+const deleteRecordSamplesSQL = "DELETE FROM metric_samples WHERE id = $1"
+
+const deleteRecordRollupsSQL = "DELETE FROM metric_rollups WHERE id = $1"
+
+const migrationsSQL = "SELECT version, dirty FROM schema_migrations LIMIT 1"
+
+const rangeSQL = "SELECT ts, kind, value, histogram FROM metric_samples WHERE id = $1 AND ts BETWEEN $2 AND $3 ORDER BY ts"
+
 // PostgresStorage
 type PostgresStorage struct {
 	Pool PGXPool
 	dsn  string
+
+	copyThreshold   int  // batches of this size and bigger are written with COPY, zero disables it
+	latestMigration uint // latest schema version in migrations source, readiness requires it to be applied
+
+	retention  *periodicWorker
+	partitions *periodicWorker
 }
 
 type dbQueryTracer struct {
@@ -38,11 +77,22 @@ func (tracer *dbQueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn,
 }
 
 // DatabseStorage constructor
-func NewPostgresStorage(dsn string) (*PostgresStorage, error) {
-	migrator := NewPostgresMigrator(dsn, "file://db/migrate", 5)
+func NewPostgresStorage(dsn string, opts ...Option) (*PostgresStorage, error) {
+	o := newOptions(opts...)
+
+	migrator := NewPostgresMigrator(dsn, o.migrationsSource, 5)
+
+	if o.autoMigrate {
+		if err := migrator.Run(); err != nil {
+			return nil, fmt.Errorf("migrations run failed: %w", err)
+		}
+	} else {
+		logging.LogInfo("migrations: automatic migration is disabled")
+	}
 
-	if err := migrator.Run(); err != nil {
-		return nil, fmt.Errorf("migrations run failed: %w", err)
+	latestMigration, err := migrator.Latest()
+	if err != nil {
+		return nil, fmt.Errorf("migrations source failed: %w", err)
 	}
 
 	config, err := pgxpool.ParseConfig(dsn)
@@ -58,7 +108,17 @@ func NewPostgresStorage(dsn string) (*PostgresStorage, error) {
 		return nil, fmt.Errorf("pgxpool init failed: %w", err)
 	}
 
-	return &PostgresStorage{Pool: pool, dsn: dsn}, nil
+	storage := &PostgresStorage{Pool: pool, dsn: dsn, copyThreshold: o.copyThreshold, latestMigration: latestMigration}
+
+	if o.retentionEnabled() {
+		storage.retention = startRetention(storage, o.retention, o.retentionInterval)
+	}
+
+	if o.maintainPartitions {
+		storage.partitions = startPartitionMaintenance(*storage, o.retention, o.retentionInterval)
+	}
+
+	return storage, nil
 }
 
 // Push record to storage
@@ -68,8 +128,15 @@ func (d PostgresStorage) Push(ctx context.Context, key string, record Record) er
 		return fmt.Errorf("db storage Push() -> Begin() error: %w", err)
 	}
 
-	sql := "INSERT INTO metrics(id, name, kind, value) values ($1, $2, $3, $4) ON CONFLICT (id) DO UPDATE SET value = $4"
-	_, err = tx.Exec(ctx, sql, key, record.Name, record.Value.Kind(), record.Value.String())
+	delta, value, histogram := recordToRow(record)
+	sampleValue, sampleHistogram := sampleToRow(record)
+	sample := newSample(record, time.Now())
+
+	_, err = tx.Exec(ctx, upsertSQL, key, record.Name, record.Value.Kind(), delta, value, histogram, labelsToRow(record.Labels))
+	if err == nil {
+		_, err = tx.Exec(ctx, insertSampleSQL, key, sample.Timestamp, record.Value.Kind(), sampleValue, sampleHistogram)
+	}
+
 	if err != nil {
 		rErr := tx.Rollback(ctx)
 		if rErr != nil {
@@ -83,10 +150,21 @@ func (d PostgresStorage) Push(ctx context.Context, key string, record Record) er
 
 // Push list of records to storage
 func (d PostgresStorage) PushList(ctx context.Context, data map[string]Record) error {
+	if d.shouldCopy(len(data)) {
+		return d.copyPushList(ctx, data)
+	}
+
+	now := time.Now()
+
 	batch := new(pgx.Batch)
-	sql := "INSERT INTO metrics(id, name, kind, value) values ($1, $2, $3, $4) ON CONFLICT (id) DO UPDATE SET value = $4"
-	for id, record := range data {
-		batch.Queue(sql, id, record.Name, record.Value.Kind(), record.Value.String())
+	for _, id := range sortedIDs(data) {
+		record := data[id]
+		delta, value, histogram := recordToRow(record)
+		sampleValue, sampleHistogram := sampleToRow(record)
+		sample := newSample(record, now)
+
+		batch.Queue(upsertSQL, id, record.Name, record.Value.Kind(), delta, value, histogram, labelsToRow(record.Labels))
+		batch.Queue(insertSampleSQL, id, sample.Timestamp, record.Value.Kind(), sampleValue, sampleHistogram)
 	}
 
 	batchResp := d.Pool.SendBatch(ctx, batch)
@@ -96,7 +174,7 @@ func (d PostgresStorage) PushList(ctx context.Context, data map[string]Record) e
 		}
 	}()
 
-	for i := 0; i < len(data); i++ {
+	for i := 0; i < batch.Len(); i++ {
 		if _, err := batchResp.Exec(); err != nil {
 			return fmt.Errorf("db storage PushBatch() Exec error: %w", err)
 		}
@@ -105,42 +183,85 @@ func (d PostgresStorage) PushList(ctx context.Context, data map[string]Record) e
 	return nil
 }
 
+// Add counter deltas to stored values, statements of the batch run in single transaction.
+// Rows are locked in id order, so concurrent batches with overlapping counters do not deadlock.
+func (d PostgresStorage) Increment(ctx context.Context, data map[string]Record) (map[string]Record, error) {
+	for id, record := range data {
+		if _, ok := record.Value.(metrics.Counter); !ok {
+			return nil, fmt.Errorf("%w: %s is not a counter", entities.ErrMetricInvalidValue, id)
+		}
+	}
+
+	if d.shouldCopy(len(data)) {
+		return d.copyIncrement(ctx, data)
+	}
+
+	now := time.Now()
+
+	ids := sortedIDs(data)
+	batch := new(pgx.Batch)
+
+	for _, id := range ids {
+		record := data[id]
+		delta, _, _ := recordToRow(record)
+		sample := newSample(record, now)
+
+		batch.Queue(incrementSQL, id, record.Name, record.Value.Kind(), delta, labelsToRow(record.Labels), sample.Timestamp)
+	}
+
+	batchResp := d.Pool.SendBatch(ctx, batch)
+	defer func() {
+		if err := batchResp.Close(); err != nil {
+			logging.LogErrorCtx(ctx, err, "failed to close batchResp")
+		}
+	}()
+
+	result := make(map[string]Record, len(data))
+
+	for _, id := range ids {
+		var value int64
+		if err := batchResp.QueryRow().Scan(&value); err != nil {
+			return nil, fmt.Errorf("db storage Increment() QueryRow error: %w", err)
+		}
+
+		record := data[id]
+		record.Value = metrics.Counter(value)
+		result[id] = record
+	}
+
+	return result, nil
+}
+
 // Get a record from storage
 func (d PostgresStorage) Get(ctx context.Context, key string) (Record, error) {
 	var (
-		name   string
-		kind   string
-		value  float64
-		record Record
-		err    error
+		name      string
+		kind      string
+		delta     *int64
+		value     *float64
+		histogram *string
+		labels    metrics.Labels
 	)
 
-	sql := "SELECT name, kind, value FROM metrics WHERE id=$1"
-	err = d.Pool.QueryRow(ctx, sql, string(key)).Scan(&name, &kind, &value)
+	sql := selectSQL + " WHERE id=$1"
+	err := d.Pool.QueryRow(ctx, sql, string(key)).Scan(&name, &kind, &delta, &value, &histogram, &labels)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return Record{}, entities.ErrRecordNotFound
 		}
 
-		return record, fmt.Errorf("db storage Get() error: %w", err)
+		return Record{}, fmt.Errorf("db storage Get() error: %w", err)
 	}
 
-	switch kind {
-	case metrics.KindCounter:
-		record, err = Record{Name: name, Value: metrics.Counter(value)}, nil
-	case metrics.KindGauge:
-		record, err = Record{Name: name, Value: metrics.Gauge(value)}, nil
-	default:
-		err = fmt.Errorf("db storage kind=%s unknown", kind)
-	}
-
-	return record, err
+	return typedRowToRecord(name, kind, delta, value, histogram, labels)
 }
 
 // Get list of records from storage
-func (d PostgresStorage) List(ctx context.Context) ([]Record, error) {
-	rows, err := d.Pool.Query(ctx, "SELECT name, kind, value FROM metrics")
+func (d PostgresStorage) List(ctx context.Context, filter ListFilter) ([]Record, error) {
+	where, args := filterToSQL(filter)
+
+	rows, err := d.Pool.Query(ctx, selectSQL+where, args...)
 	if err != nil {
 		return nil, fmt.Errorf("db storage List() error: %w", err)
 	}
@@ -152,29 +273,98 @@ func (d PostgresStorage) List(ctx context.Context) ([]Record, error) {
 	defer rows.Close()
 
 	var (
-		name  string
-		kind  string
-		value float64
+		name      string
+		kind      string
+		delta     *int64
+		value     *float64
+		histogram *string
+		labels    metrics.Labels
 	)
 
 	result := make([]Record, 0)
-	_, err = pgx.ForEachRow(rows, []any{&name, &kind, &value}, func() error {
-		switch kind {
-		case metrics.KindCounter:
-			result = append(result, Record{Name: name, Value: metrics.Counter(value)})
-			return nil
+	_, err = pgx.ForEachRow(rows, []any{&name, &kind, &delta, &value, &histogram, &labels}, func() error {
+		record, err := typedRowToRecord(name, kind, delta, value, histogram, labels)
+		labels = nil // do not leak labels into next row
+		if err != nil {
+			return err
+		}
+
+		result = append(result, record)
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("db storage List() error: %w", err)
+	}
+
+	return result, nil
+}
+
+// Delete a record and its history from storage, statements of the batch run in single transaction
+func (d PostgresStorage) Delete(ctx context.Context, id string) error {
+	batch := new(pgx.Batch)
+	batch.Queue(deleteRecordSamplesSQL, id)
+	batch.Queue(deleteRecordRollupsSQL, id)
+	batch.Queue(deleteRecordSQL, id)
+
+	batchResp := d.Pool.SendBatch(ctx, batch)
+	defer func() {
+		if err := batchResp.Close(); err != nil {
+			logging.LogErrorCtx(ctx, err, "failed to close batchResp")
+		}
+	}()
+
+	var (
+		tag pgconn.CommandTag
+		err error
+	)
+
+	for i := 0; i < batch.Len(); i++ {
+		if tag, err = batchResp.Exec(); err != nil {
+			return fmt.Errorf("db storage Delete() Exec error: %w", err)
+		}
+	}
+
+	if tag.RowsAffected() == 0 {
+		return entities.ErrRecordNotFound
+	}
 
-		case metrics.KindGauge:
-			result = append(result, Record{Name: name, Value: metrics.Gauge(value)})
-			return nil
+	return nil
+}
+
+// Get samples of the series within [from, to], ordered by time
+func (d PostgresStorage) Range(ctx context.Context, id string, from, to time.Time) ([]Sample, error) {
+	rows, err := d.Pool.Query(ctx, rangeSQL, id, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("db storage Range() error: %w", err)
+	}
+
+	if rowErr := rows.Err(); rowErr != nil {
+		return nil, fmt.Errorf("db storage Range() error: %w", rowErr)
+	}
+
+	defer rows.Close()
 
-		default:
-			return fmt.Errorf("db storage kind=%s unknown", kind)
+	var (
+		ts        time.Time
+		kind      string
+		value     float64
+		histogram *string
+	)
+
+	result := make([]Sample, 0)
+	_, err = pgx.ForEachRow(rows, []any{&ts, &kind, &value, &histogram}, func() error {
+		record, err := rowToRecord(id, kind, value, histogram, nil)
+		if err != nil {
+			return err
 		}
+
+		result = append(result, Sample{Timestamp: ts, Value: record.Value})
+		return nil
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("db storage List() error: %w", err)
+		return nil, fmt.Errorf("db storage Range() error: %w", err)
 	}
 
 	return result, nil
@@ -189,8 +379,45 @@ func (d PostgresStorage) Ping(ctx context.Context) error {
 	return nil
 }
 
+// Readiness checks of the storage.
+func (d PostgresStorage) HealthChecks() []HealthCheck {
+	return []HealthCheck{
+		{Name: "database", Check: d.Ping},
+		{Name: "migrations", Check: d.checkMigrations},
+	}
+}
+
+// Ensure migrations were applied completely.
+func (d PostgresStorage) checkMigrations(ctx context.Context) error {
+	var (
+		version int64
+		dirty   bool
+	)
+
+	err := d.Pool.QueryRow(ctx, migrationsSQL).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return entities.ErrMigrationsMissing
+	}
+
+	if err != nil {
+		return fmt.Errorf("db storage checkMigrations() error: %w", err)
+	}
+
+	if dirty {
+		return fmt.Errorf("%w: version %d", entities.ErrMigrationsDirty, version)
+	}
+
+	if version < int64(d.latestMigration) {
+		return fmt.Errorf("%w: version %d of %d", entities.ErrMigrationsPending, version, d.latestMigration)
+	}
+
+	return nil
+}
+
 // Close storage pool
 func (d PostgresStorage) Close(ctx context.Context) error {
+	d.retention.stop()
+	d.partitions.stop()
 	d.Pool.Close()
 	return nil
 }
@@ -198,3 +425,139 @@ func (d PostgresStorage) Close(ctx context.Context) error {
 func (d PostgresStorage) String() string {
 	return fmt.Sprintf("storage=%s", d.dsn)
 }
+
+// Split record value into typed columns of metrics table: counters go to delta, gauges to value,
+// histograms keep sum in value and buckets in jsonb.
+func recordToRow(record Record) (delta, value, histogram any) {
+	switch v := record.Value.(type) {
+	case metrics.Counter:
+		return int64(v), nil, nil
+	case metrics.Gauge:
+		return nil, float64(v), nil
+	case metrics.Histogram:
+		return nil, v.Sum, v.String()
+	default:
+		return nil, nil, nil
+	}
+}
+
+// Split record value into columns of metric_samples table, every value is stored as double precision.
+func sampleToRow(record Record) (float64, any) {
+	switch v := record.Value.(type) {
+	case metrics.Counter:
+		return float64(v), nil
+	case metrics.Gauge:
+		return float64(v), nil
+	case metrics.Histogram:
+		return v.Sum, v.String()
+	default:
+		return 0, nil
+	}
+}
+
+// Labels are always stored as json object, never as null.
+func labelsToRow(labels metrics.Labels) string {
+	if len(labels) == 0 {
+		return "{}"
+	}
+
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return "{}"
+	}
+
+	return string(data)
+}
+
+// Build WHERE clause for List(), labels are matched with jsonb containment to use GIN index.
+func filterToSQL(filter ListFilter) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+
+	if len(filter.Name) > 0 {
+		args = append(args, filter.Name)
+		conds = append(conds, fmt.Sprintf("name = $%d", len(args)))
+	}
+
+	if len(filter.NamePrefix) > 0 {
+		args = append(args, filter.NamePrefix)
+		conds = append(conds, fmt.Sprintf("starts_with(name, $%d)", len(args)))
+	}
+
+	if len(filter.Kind) > 0 {
+		args = append(args, filter.Kind)
+		conds = append(conds, fmt.Sprintf("kind::text = $%d", len(args)))
+	}
+
+	if len(filter.Labels) > 0 {
+		args = append(args, labelsToRow(filter.Labels))
+		conds = append(conds, fmt.Sprintf("labels @> $%d::jsonb", len(args)))
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// Build record from typed columns of metrics table.
+func typedRowToRecord(name, kind string, delta *int64, value *float64, histogram *string, labels metrics.Labels) (Record, error) {
+	if kind == metrics.KindCounter {
+		if delta == nil {
+			return Record{}, fmt.Errorf("db storage counter=%s has no delta", name)
+		}
+
+		if len(labels) == 0 {
+			labels = nil
+		}
+
+		return Record{Name: name, Value: metrics.Counter(*delta), Labels: labels}, nil
+	}
+
+	if value == nil {
+		return Record{}, fmt.Errorf("db storage %s=%s has no value", kind, name)
+	}
+
+	return rowToRecord(name, kind, *value, histogram, labels)
+}
+
+func rowToRecord(name, kind string, value float64, histogram *string, labels metrics.Labels) (Record, error) {
+	if len(labels) == 0 {
+		labels = nil
+	}
+
+	switch kind {
+	case metrics.KindCounter:
+		return Record{Name: name, Value: metrics.Counter(value), Labels: labels}, nil
+	case metrics.KindGauge:
+		return Record{Name: name, Value: metrics.Gauge(value), Labels: labels}, nil
+	case metrics.KindHistogram:
+		if histogram == nil {
+			return Record{}, fmt.Errorf("db storage histogram=%s has no buckets", name)
+		}
+
+		h, err := metrics.ToHistogram(*histogram)
+		if err != nil {
+			return Record{}, fmt.Errorf("db storage histogram=%s is broken: %w", name, err)
+		}
+
+		return Record{Name: name, Value: h, Labels: labels}, nil
+	default:
+		return Record{}, fmt.Errorf("db storage kind=%s unknown", kind)
+	}
+}
+
+// Return ids of records in sorted order, rows are upserted in this order to avoid deadlocks.
+func sortedIDs(data map[string]Record) []string {
+	ids := make([]string, 0, len(data))
+	for id := range data {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/jackc/pgx/v5"
+)
+
+// Big batches are copied into temporary staging table and merged with single statement,
+// instead of queueing a statement per record.
+const (
+	stagingTable = "metrics_staging"
+
+	// kind is text, pgx has no binary encoding for metricKind enum
+	createStagingSQL = "CREATE TEMP TABLE " + stagingTable + "(" +
+		"id text, name text, kind text, delta bigint, value double precision, histogram jsonb, labels jsonb, " +
+		"ts timestamptz, sample_value double precision) ON COMMIT DROP"
+
+	mergeStagingSQL = "INSERT INTO metrics(id, name, kind, delta, value, histogram, labels) " +
+		"SELECT id, name, kind::metricKind, delta, value, histogram, labels FROM " + stagingTable + " ORDER BY id " +
+		"ON CONFLICT (id) DO UPDATE SET delta = EXCLUDED.delta, value = EXCLUDED.value, histogram = EXCLUDED.histogram"
+
+	insertStagedSamplesSQL = "INSERT INTO metric_samples(id, ts, kind, value, histogram) " +
+		"SELECT id, ts, kind::metricKind, sample_value, histogram FROM " + stagingTable
+
+	// counters are added to stored values as in incrementSQL; rows are inserted
+	// in id order, as in batch path, to avoid deadlocks between concurrent writers
+	incrementStagingSQL = "WITH upserted AS (" +
+		"INSERT INTO metrics(id, name, kind, delta, labels) " +
+		"SELECT id, name, kind::metricKind, delta, labels FROM " + stagingTable + " ORDER BY id " +
+		"ON CONFLICT (id) DO UPDATE SET delta = metrics.delta + EXCLUDED.delta RETURNING id, kind, delta" +
+		"), sampled AS (INSERT INTO metric_samples(id, ts, kind, value) " +
+		"SELECT u.id, s.ts, u.kind, u.delta FROM upserted u JOIN " + stagingTable + " s USING (id)) " +
+		"SELECT id, delta FROM upserted"
+
+	defaultCopyThreshold = 1000
+)
+
+var stagingColumns = []string{"id", "name", "kind", "delta", "value", "histogram", "labels", "ts", "sample_value"}
+
+// Write records via staging table, merging them into stored ones with merge statement. Runs in single transaction.
+func (d PostgresStorage) copyThroughStaging(ctx context.Context, data map[string]Record, merge func(tx pgx.Tx) error) error {
+	tx, err := d.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("db storage copy -> Begin() error: %w", err)
+	}
+
+	if err := d.stage(ctx, tx, data, merge); err != nil {
+		if rErr := tx.Rollback(ctx); rErr != nil {
+			return fmt.Errorf("db storage copy -> Rollback() error: %w", rErr)
+		}
+
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("db storage copy -> Commit() error: %w", err)
+	}
+
+	return nil
+}
+
+func (d PostgresStorage) stage(ctx context.Context, tx pgx.Tx, data map[string]Record, merge func(tx pgx.Tx) error) error {
+	if _, err := tx.Exec(ctx, createStagingSQL); err != nil {
+		return fmt.Errorf("db storage copy staging error: %w", err)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{stagingTable}, stagingColumns, newStagingRows(data, time.Now())); err != nil {
+		return fmt.Errorf("db storage CopyFrom() error: %w", err)
+	}
+
+	if err := merge(tx); err != nil {
+		return fmt.Errorf("db storage copy merge error: %w", err)
+	}
+
+	return nil
+}
+
+// Replace stored values with copied ones.
+func (d PostgresStorage) copyPushList(ctx context.Context, data map[string]Record) error {
+	return d.copyThroughStaging(ctx, data, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, mergeStagingSQL); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, insertStagedSamplesSQL)
+
+		return err
+	})
+}
+
+// Add copied counters to stored values.
+func (d PostgresStorage) copyIncrement(ctx context.Context, data map[string]Record) (map[string]Record, error) {
+	result := make(map[string]Record, len(data))
+
+	err := d.copyThroughStaging(ctx, data, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, incrementStagingSQL)
+		if err != nil {
+			return err
+		}
+
+		var (
+			id    string
+			value int64
+		)
+
+		_, err = pgx.ForEachRow(rows, []any{&id, &value}, func() error {
+			record := data[id]
+			record.Value = metrics.Counter(value)
+			result[id] = record
+
+			return nil
+		})
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Whether batch is big enough to be copied.
+func (d PostgresStorage) shouldCopy(size int) bool {
+	return d.copyThreshold > 0 && size >= d.copyThreshold
+}
+
+// Streams records to CopyFrom without building intermediate slice of rows.
+type stagingRows struct {
+	ids     []string
+	data    map[string]Record
+	now     time.Time
+	current int
+}
+
+var _ pgx.CopyFromSource = (*stagingRows)(nil)
+
+func newStagingRows(data map[string]Record, now time.Time) *stagingRows {
+	return &stagingRows{ids: sortedIDs(data), data: data, now: now, current: -1}
+}
+
+func (r *stagingRows) Next() bool {
+	r.current++
+	return r.current < len(r.ids)
+}
+
+func (r *stagingRows) Values() ([]any, error) {
+	id := r.ids[r.current]
+	record := r.data[id]
+
+	delta, value, histogram := recordToRow(record)
+	sampleValue, _ := sampleToRow(record)
+
+	return []any{
+		id, record.Name, record.Value.Kind(), delta, value, histogram, labelsToRow(record.Labels),
+		newSample(record, r.now).Timestamp, sampleValue,
+	}, nil
+}
+
+func (r *stagingRows) Err() error {
+	return nil
+}
@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresStorage_PushListCopy(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool, copyThreshold: 2}
+
+	ctx := context.Background()
+	data := map[string]Record{
+		"name1_counter": {Name: "name1", Value: metrics.Counter(123)},
+		"name2_gauge":   {Name: "name2", Value: metrics.Gauge(456)},
+	}
+
+	txMock := new(PGXTxMock)
+	mockPool.On("Begin", ctx).Return(txMock, nil)
+	txMock.On("Exec", ctx, createStagingSQL).Return(pgconn.CommandTag{}, nil)
+	txMock.On("CopyFrom", ctx, pgx.Identifier{stagingTable}).Return(int64(2), nil)
+	txMock.On("Exec", ctx, mergeStagingSQL).Return(pgconn.CommandTag{}, nil)
+	txMock.On("Exec", ctx, insertStagedSamplesSQL).Return(pgconn.CommandTag{}, nil)
+	txMock.On("Commit", ctx).Return(nil)
+
+	require.NoError(t, storage.PushList(ctx, data))
+
+	mockPool.AssertExpectations(t)
+	txMock.AssertExpectations(t)
+	mockPool.AssertNotCalled(t, "SendBatch", mock.Anything, mock.Anything)
+}
+
+func TestPostgresStorage_PushListBelowCopyThreshold(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool, copyThreshold: 3}
+
+	ctx := context.Background()
+	data := map[string]Record{
+		"name1_counter": {Name: "name1", Value: metrics.Counter(123)},
+		"name2_gauge":   {Name: "name2", Value: metrics.Gauge(456)},
+	}
+
+	mockBatchResults := new(PGXBatchResultsMock)
+	mockPool.On("SendBatch", ctx, mock.Anything).Return(mockBatchResults)
+	mockBatchResults.On("Exec").Return(pgconn.CommandTag{}, nil).Times(4)
+	mockBatchResults.On("Close").Return(nil)
+
+	require.NoError(t, storage.PushList(ctx, data))
+
+	mockPool.AssertNotCalled(t, "Begin", mock.Anything)
+	mockBatchResults.AssertExpectations(t)
+}
+
+func TestPostgresStorage_IncrementCopy(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool, copyThreshold: 1}
+
+	ctx := context.Background()
+	record := Record{Name: "PollCount", Value: metrics.Counter(5)}
+	id := record.CalculateRecordID()
+
+	mockRows := new(PGXRowsMock)
+	mockRows.On("Next").Return(true).Once()
+	mockRows.On("Next").Return(false)
+	mockRows.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*string) = id
+		*args.Get(1).(*int64) = 15 // stored value was 10
+	}).Return(nil)
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return(nil)
+	mockRows.On("CommandTag").Return(pgconn.NewCommandTag("select"))
+
+	txMock := new(PGXTxMock)
+	mockPool.On("Begin", ctx).Return(txMock, nil)
+	txMock.On("Exec", ctx, createStagingSQL).Return(pgconn.CommandTag{}, nil)
+	txMock.On("CopyFrom", ctx, pgx.Identifier{stagingTable}).Return(int64(1), nil)
+	txMock.On("Query", ctx, incrementStagingSQL, []any(nil)).Return(mockRows, nil)
+	txMock.On("Commit", ctx).Return(nil)
+
+	result, err := storage.Increment(ctx, map[string]Record{id: record})
+	require.NoError(t, err)
+	require.Equal(t, map[string]Record{id: {Name: "PollCount", Value: metrics.Counter(15)}}, result)
+
+	txMock.AssertExpectations(t)
+}
+
+func TestPostgresStorage_CopyRollback(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool, copyThreshold: 1}
+
+	ctx := context.Background()
+	copyErr := errors.New("copy failed")
+
+	txMock := new(PGXTxMock)
+	mockPool.On("Begin", ctx).Return(txMock, nil)
+	txMock.On("Exec", ctx, createStagingSQL).Return(pgconn.CommandTag{}, nil)
+	txMock.On("CopyFrom", ctx, pgx.Identifier{stagingTable}).Return(int64(0), copyErr)
+	txMock.On("Rollback", ctx).Return(nil)
+
+	err := storage.PushList(ctx, map[string]Record{"name_gauge": {Name: "name", Value: metrics.Gauge(1)}})
+	require.ErrorIs(t, err, copyErr)
+
+	txMock.AssertExpectations(t)
+	txMock.AssertNotCalled(t, "Commit", mock.Anything)
+}
+
+func TestStagingRows(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	ts := now.Add(-time.Minute)
+
+	histogram := metrics.NewHistogram(1)
+	histogram.Observe(0.5)
+
+	tests := []struct {
+		name   string
+		record Record
+		want   []any
+	}{
+		{
+			name:   "counter",
+			record: Record{Name: "PollCount", Value: metrics.Counter(5), Labels: metrics.Labels{"host": "a"}},
+			want:   []any{"id", "PollCount", metrics.KindCounter, int64(5), nil, nil, `{"host":"a"}`, now, float64(5)},
+		},
+		{
+			name:   "gauge with timestamp",
+			record: Record{Name: "Alloc", Value: metrics.Gauge(1.5), Timestamp: ts},
+			want:   []any{"id", "Alloc", metrics.KindGauge, nil, 1.5, nil, "{}", ts, 1.5},
+		},
+		{
+			name:   "histogram",
+			record: Record{Name: "Latency", Value: histogram},
+			want:   []any{"id", "Latency", metrics.KindHistogram, nil, 0.5, histogram.String(), "{}", now, 0.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := newStagingRows(map[string]Record{"id": tt.record}, now)
+
+			require.True(t, rows.Next())
+
+			values, err := rows.Values()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, values)
+
+			require.False(t, rows.Next())
+			require.NoError(t, rows.Err())
+		})
+	}
+}
+
+// Compares batch and COPY paths against real database, run with DATABASE_DSN set:
+// DATABASE_DSN=postgres://... go test -run=^$ -bench=PushList ./internal/storage/
+// Gauges are written with PushList, counters with Increment (incrementSQL or incrementStagingSQL).
+func BenchmarkPostgresStorage_PushList(b *testing.B) {
+	dsn := os.Getenv("DATABASE_DSN")
+	if len(dsn) == 0 {
+		b.Skip("DATABASE_DSN is not set")
+	}
+
+	ctx := context.Background()
+
+	kinds := []struct {
+		name  string
+		value func(i int) metrics.Metric
+		push  func(s *PostgresStorage, data map[string]Record) error
+	}{
+		{
+			name:  "gauge",
+			value: func(i int) metrics.Metric { return metrics.Gauge(float64(i)) },
+			push: func(s *PostgresStorage, data map[string]Record) error {
+				return s.PushList(ctx, data)
+			},
+		},
+		{
+			name:  "counter",
+			value: func(i int) metrics.Metric { return metrics.Counter(i + 1) },
+			push: func(s *PostgresStorage, data map[string]Record) error {
+				_, err := s.Increment(ctx, data)
+				return err
+			},
+		},
+	}
+
+	paths := []struct {
+		name      string
+		threshold int
+	}{
+		{name: "batch", threshold: 0},
+		{name: "copy", threshold: 1},
+	}
+
+	for _, kind := range kinds {
+		for _, size := range []int{100, 10000, 50000} {
+			data := make(map[string]Record, size)
+			for i := 0; i < size; i++ {
+				record := Record{Name: fmt.Sprintf("bench_%d", i), Value: kind.value(i)}
+				data[record.CalculateRecordID()] = record
+			}
+
+			for _, path := range paths {
+				b.Run(fmt.Sprintf("%s/%s/%d", kind.name, path.name, size), func(b *testing.B) {
+					storage, err := NewPostgresStorage(dsn, WithCopyThreshold(path.threshold))
+					if err != nil {
+						b.Fatal(err)
+					}
+
+					defer func() { _ = storage.Close(ctx) }()
+
+					b.ResetTimer()
+
+					for i := 0; i < b.N; i++ {
+						if err := kind.push(storage, data); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		}
+	}
+}
@@ -3,44 +3,153 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
 	"time"
 
+	"github.com/ex0rcist/metflix/db"
 	"github.com/ex0rcist/metflix/internal/logging"
 	"github.com/ex0rcist/metflix/internal/retrier"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
+// State of database schema.
+type MigrationStatus struct {
+	Version uint // applied version, zero if none
+	Latest  uint // latest version available in source
+	Dirty   bool // last migration failed and must be fixed manually, see Force()
+}
+
 // Service to migrate database
 type PostgresMigrator struct {
 	dsn     string
 	retries int
 	source  string
-
-	migrator *migrate.Migrate
-	err      error
 }
 
-// DatabaseMigrator Constructor
+// DatabaseMigrator Constructor.
+// Empty source means migrations embedded into binary, otherwise it's golang-migrate source URL, e.g. file://db/migrate.
 func NewPostgresMigrator(dsn string, source string, retries int) PostgresMigrator {
 	return PostgresMigrator{dsn: dsn, source: source, retries: retries}
 }
 
 // Run migrations if any (with retries)
 func (m PostgresMigrator) Run() error {
+	err := m.Up(0)
+
+	if err == nil {
+		logging.LogInfo("migrations: success")
+		return nil
+	}
+
+	if errors.Is(err, migrate.ErrNoChange) {
+		logging.LogInfo("migrations: no change")
+		return nil
+	}
+
+	return err
+}
+
+// Apply given number of migrations, all pending ones if steps is zero.
+func (m PostgresMigrator) Up(steps int) error {
+	return m.with(func(migrator *migrate.Migrate, _ source.Driver) error {
+		if steps == 0 {
+			return migrator.Up()
+		}
+
+		return migrator.Steps(steps)
+	})
+}
+
+// Roll back given number of migrations, all applied ones if steps is zero.
+func (m PostgresMigrator) Down(steps int) error {
+	return m.with(func(migrator *migrate.Migrate, _ source.Driver) error {
+		if steps == 0 {
+			return migrator.Down()
+		}
+
+		return migrator.Steps(-steps)
+	})
+}
+
+// Set schema version without running migrations and clear dirty flag.
+func (m PostgresMigrator) Force(version int) error {
+	return m.with(func(migrator *migrate.Migrate, _ source.Driver) error {
+		return migrator.Force(version)
+	})
+}
+
+// Get applied and latest available versions.
+func (m PostgresMigrator) Status() (MigrationStatus, error) {
+	var status MigrationStatus
+
+	err := m.with(func(migrator *migrate.Migrate, src source.Driver) error {
+		version, dirty, err := migrator.Version()
+		if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+			return err
+		}
+
+		latest, err := latestVersion(src)
+		if err != nil {
+			return err
+		}
+
+		status = MigrationStatus{Version: version, Latest: latest, Dirty: dirty}
+
+		return nil
+	})
+
+	return status, err
+}
+
+// Get the latest version available in source, database is not queried.
+func (m PostgresMigrator) Latest() (uint, error) {
+	src, err := m.openSource()
+	if err != nil {
+		return 0, fmt.Errorf("migrations: %w", err)
+	}
+
+	defer func() {
+		if err := src.Close(); err != nil {
+			logging.LogError(err, "failed closing migrations source")
+		}
+	}()
+
+	return latestVersion(src)
+}
+
+// Connect to database (with retries) and run f.
+func (m PostgresMigrator) with(f func(migrator *migrate.Migrate, src source.Driver) error) error {
+	var (
+		migrator *migrate.Migrate
+		src      source.Driver
+	)
+
 	delays := []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second}
+	if m.retries < len(delays) {
+		delays = delays[:max(m.retries, 0)]
+	}
 
-	m.err = retrier.New(
+	err := retrier.New(
 		func() error {
 			logging.LogInfo("migrations: connecting to " + m.dsn)
 
-			migrator, err := migrate.New(m.source, m.dsn)
-			m.migrator = migrator
+			var err error
+
+			src, err = m.openSource()
+			if err != nil {
+				return err
+			}
 
+			migrator, err = migrate.NewWithSourceInstance("metflix", src, m.dsn)
 			if err != nil {
 				logging.LogError(err)
+				_ = src.Close()
 			}
 
 			return err
@@ -51,14 +160,12 @@ func (m PostgresMigrator) Run() error {
 		retrier.WithDelays(delays),
 	).Run(context.Background())
 
-	if m.err != nil {
-		return m.err
+	if err != nil {
+		return fmt.Errorf("migrations: %w", err)
 	}
 
-	m.err = m.migrator.Up()
-
 	defer func() {
-		srcErr, dbErr := m.migrator.Close()
+		srcErr, dbErr := migrator.Close()
 
 		if srcErr != nil {
 			logging.LogError(srcErr, "failed closing migrator", srcErr.Error())
@@ -69,15 +176,38 @@ func (m PostgresMigrator) Run() error {
 		}
 	}()
 
-	if m.err == nil {
-		logging.LogInfo("migrations: success")
-		return nil
+	return f(migrator, src)
+}
+
+func (m PostgresMigrator) openSource() (source.Driver, error) {
+	if m.source == "" {
+		return iofs.New(db.Migrations, db.MigrationsDir)
 	}
 
-	if errors.Is(m.err, migrate.ErrNoChange) {
-		logging.LogInfo("migrations: no change")
-		return nil
+	return source.Open(m.source)
+}
+
+// Find the last migration version in source.
+func latestVersion(src source.Driver) (uint, error) {
+	version, err := src.First()
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
 	}
 
-	return m.err
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		version = next
+	}
 }
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/ex0rcist/metflix/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresMigrator_EmbeddedSource(t *testing.T) {
+	src, err := NewPostgresMigrator("", "", 0).openSource()
+	require.NoError(t, err)
+
+	defer func() { require.NoError(t, src.Close()) }()
+
+	ups, err := fs.Glob(db.Migrations, db.MigrationsDir+"/*.up.sql")
+	require.NoError(t, err)
+
+	latest, err := latestVersion(src)
+	require.NoError(t, err)
+	require.Equal(t, uint(len(ups)), latest, "migrations are numbered sequentially")
+
+	_, _, err = src.ReadDown(latest)
+	require.NoError(t, err, "every migration must be reversible")
+
+	embedded, err := NewPostgresMigrator("", "", 0).Latest()
+	require.NoError(t, err)
+	require.Equal(t, latest, embedded)
+}
+
+func TestPostgresMigrator_FileSource(t *testing.T) {
+	src, err := NewPostgresMigrator("", "file://"+t.TempDir(), 0).openSource()
+	require.NoError(t, err)
+
+	latest, err := latestVersion(src)
+	require.NoError(t, err)
+	require.Zero(t, latest)
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/jackc/pgx/v5"
+)
+
+// metric_samples is partitioned by day, samples outside of existing partitions go to metric_samples_default.
+const (
+	samplesPartitionPrefix = "metric_samples_p"
+	samplesPartitionLayout = "20060102"
+
+	partitionsAhead              = 2 // days after today to create partitions for
+	partitionMaintenanceInterval = time.Hour
+
+	partitionMaintenanceLockID int64 = 0x6d6574666c6978 // "metflix"
+)
+
+const tryPartitionMaintenanceLockSQL = "SELECT pg_try_advisory_xact_lock($1)"
+
+const listSamplePartitionsSQL = "SELECT c.relname FROM pg_inherits i " +
+	"JOIN pg_class c ON c.oid = i.inhrelid JOIN pg_class p ON p.oid = i.inhparent " +
+	"WHERE p.relname = 'metric_samples'"
+
+// Create partitions of metric_samples for today and next days, drop partitions which samples are expired.
+// Partitions are dropped only if retention policies cover every series, once retention had a chance to roll them up.
+func (d PostgresStorage) MaintainPartitions(ctx context.Context, now time.Time, policies RetentionPolicies, retentionInterval time.Duration) error {
+	existing, err := d.samplePartitions(ctx)
+	if err != nil {
+		return err
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+
+	for i := 0; i <= partitionsAhead; i++ {
+		day := today.AddDate(0, 0, i)
+
+		if _, ok := existing[day]; ok {
+			continue
+		}
+
+		if err := d.createSamplePartition(ctx, day); err != nil {
+			return err
+		}
+	}
+
+	horizon, ok := policies.RawHorizon()
+	if !ok {
+		return nil
+	}
+
+	cutoff := now.Add(-horizon - retentionInterval)
+
+	for day, name := range existing {
+		if day.AddDate(0, 0, 1).After(cutoff) {
+			continue
+		}
+
+		if _, err := d.Pool.Exec(ctx, "DROP TABLE IF EXISTS "+pgx.Identifier{name}.Sanitize()); err != nil {
+			return fmt.Errorf("db storage MaintainPartitions() drop error: %w", err)
+		}
+
+		logging.LogInfo("dropped expired samples partition " + name)
+	}
+
+	return nil
+}
+
+// Maintain partitions unless another replica is doing it, returns false in that case.
+// Transaction-level advisory lock is held until maintenance is done and released with the transaction.
+func (d PostgresStorage) maintainPartitionsExclusively(ctx context.Context, now time.Time, policies RetentionPolicies, retentionInterval time.Duration) (bool, error) {
+	tx, err := d.Pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("db storage maintainPartitionsExclusively() -> Begin() error: %w", err)
+	}
+
+	defer func() {
+		// nothing is written in the transaction, it only holds the lock
+		if err := tx.Rollback(ctx); err != nil {
+			logging.LogError(fmt.Errorf("db storage maintainPartitionsExclusively() -> Rollback() error: %w", err))
+		}
+	}()
+
+	var locked bool
+	if err := tx.QueryRow(ctx, tryPartitionMaintenanceLockSQL, partitionMaintenanceLockID).Scan(&locked); err != nil {
+		return false, fmt.Errorf("db storage maintainPartitionsExclusively() lock error: %w", err)
+	}
+
+	if !locked {
+		return false, nil
+	}
+
+	return true, d.MaintainPartitions(ctx, now, policies, retentionInterval)
+}
+
+// Existing daily partitions by their day.
+func (d PostgresStorage) samplePartitions(ctx context.Context) (map[time.Time]string, error) {
+	rows, err := d.Pool.Query(ctx, listSamplePartitionsSQL)
+	if err != nil {
+		return nil, fmt.Errorf("db storage samplePartitions() error: %w", err)
+	}
+
+	defer rows.Close()
+
+	var name string
+
+	result := make(map[time.Time]string)
+	_, err = pgx.ForEachRow(rows, []any{&name}, func() error {
+		if day, ok := partitionDay(name); ok {
+			result[day] = name
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("db storage samplePartitions() error: %w", err)
+	}
+
+	return result, nil
+}
+
+// Create partition for the day moving its samples from default partition, which otherwise prevents attaching.
+func (d PostgresStorage) createSamplePartition(ctx context.Context, day time.Time) error {
+	name := pgx.Identifier{partitionName(day)}.Sanitize()
+	from, to := day.Format(time.RFC3339), day.AddDate(0, 0, 1).Format(time.RFC3339)
+
+	tx, err := d.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("db storage createSamplePartition() -> Begin() error: %w", err)
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE %s (LIKE metric_samples INCLUDING DEFAULTS)", name),
+		fmt.Sprintf("WITH moved AS (DELETE FROM metric_samples_default WHERE ts >= '%s' AND ts < '%s' RETURNING *) "+
+			"INSERT INTO %s SELECT * FROM moved", from, to, name),
+		fmt.Sprintf("ALTER TABLE metric_samples ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')", name, from, to),
+	}
+
+	for _, sql := range statements {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			if rErr := tx.Rollback(ctx); rErr != nil {
+				return fmt.Errorf("db storage createSamplePartition() -> Rollback() error: %w", rErr)
+			}
+
+			return fmt.Errorf("db storage createSamplePartition() error: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("db storage createSamplePartition() -> Commit() error: %w", err)
+	}
+
+	logging.LogInfo("created samples partition " + partitionName(day))
+
+	return nil
+}
+
+func startPartitionMaintenance(d PostgresStorage, policies RetentionPolicies, retentionInterval time.Duration) *periodicWorker {
+	maintain := func(now time.Time) {
+		locked, err := d.maintainPartitionsExclusively(context.Background(), now, policies, retentionInterval)
+
+		switch {
+		case err != nil:
+			logging.LogError(fmt.Errorf("error during MaintainPartitions(): %w", err))
+		case !locked:
+			logging.LogDebug("partitions are maintained by another replica, skipped")
+		}
+	}
+
+	maintain(time.Now()) // today's partition is needed right away
+
+	return startPeriodic(partitionMaintenanceInterval, maintain)
+}
+
+func partitionName(day time.Time) string {
+	return samplesPartitionPrefix + day.Format(samplesPartitionLayout)
+}
+
+func partitionDay(name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, samplesPartitionPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	day, err := time.Parse(samplesPartitionLayout, suffix)
+
+	return day, err == nil
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func mockSamplePartitions(mockPool *PGXPoolMock, names ...string) {
+	mockRows := new(PGXRowsMock)
+	mockPool.On("Query", mock.Anything, listSamplePartitionsSQL, []any(nil)).Return(mockRows, nil)
+
+	for _, name := range names {
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*string) = name
+		}).Return(nil).Once()
+	}
+
+	mockRows.On("Next").Return(false)
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return(nil)
+	mockRows.On("CommandTag").Return(pgconn.NewCommandTag("select"))
+}
+
+func TestPostgresStorage_MaintainPartitions(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	policies := RetentionPolicies{{Pattern: "Heap*", Raw: 240 * time.Hour}, {Pattern: "*", Raw: 24 * time.Hour}}
+
+	mockSamplePartitions(mockPool,
+		"metric_samples_default",
+		"metric_samples_p20240225", // older than the longest raw retention, dropped
+		"metric_samples_p20240229", // may still contain Heap* samples
+		"metric_samples_p20240310",
+	)
+
+	txMock := new(PGXTxMock)
+	mockPool.On("Begin", ctx).Return(txMock, nil)
+
+	for _, day := range []string{"20240311", "20240312"} {
+		name := `"metric_samples_p` + day + `"`
+
+		txMock.On("Exec", ctx, mock.MatchedBy(func(sql string) bool {
+			return strings.HasPrefix(sql, "CREATE TABLE "+name)
+		})).Return(pgconn.CommandTag{}, nil).Once()
+		txMock.On("Exec", ctx, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "DELETE FROM metric_samples_default") && strings.HasSuffix(sql, "INSERT INTO "+name+" SELECT * FROM moved")
+		})).Return(pgconn.CommandTag{}, nil).Once()
+		txMock.On("Exec", ctx, mock.MatchedBy(func(sql string) bool {
+			return strings.HasPrefix(sql, "ALTER TABLE metric_samples ATTACH PARTITION "+name)
+		})).Return(pgconn.CommandTag{}, nil).Once()
+	}
+
+	txMock.On("Commit", ctx).Return(nil).Twice()
+	mockPool.On("Exec", ctx, `DROP TABLE IF EXISTS "metric_samples_p20240225"`, []any(nil)).Return(pgconn.CommandTag{}, nil).Once()
+
+	err := storage.MaintainPartitions(ctx, now, policies, time.Minute)
+	require.NoError(t, err)
+
+	mockPool.AssertExpectations(t)
+	txMock.AssertExpectations(t)
+}
+
+func TestPostgresStorage_MaintainPartitionsWithoutRetention(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+
+	mockSamplePartitions(mockPool, "metric_samples_p20200101", "metric_samples_p20240310", "metric_samples_p20240311", "metric_samples_p20240312")
+
+	// some series aren't covered by policies, so nothing is dropped
+	policies := RetentionPolicies{{Pattern: "Heap*", Raw: time.Hour}}
+
+	err := storage.MaintainPartitions(ctx, now, policies, time.Minute)
+	require.NoError(t, err)
+
+	mockPool.AssertNotCalled(t, "Exec", mock.Anything, mock.Anything, mock.Anything)
+	mockPool.AssertNotCalled(t, "Begin", mock.Anything)
+}
+
+func mockPartitionMaintenanceLock(mockPool *PGXPoolMock, locked bool) *PGXTxMock {
+	ctx := context.Background()
+
+	mockRow := new(PGXRowMock)
+	mockRow.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*bool) = locked
+	}).Return(nil)
+
+	lockTx := new(PGXTxMock)
+	lockTx.On("QueryRow", ctx, tryPartitionMaintenanceLockSQL, []any{partitionMaintenanceLockID}).Return(mockRow)
+	lockTx.On("Rollback", ctx).Return(nil)
+	mockPool.On("Begin", ctx).Return(lockTx, nil).Once()
+
+	return lockTx
+}
+
+func TestPostgresStorage_MaintainPartitionsExclusively(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+
+	lockTx := mockPartitionMaintenanceLock(mockPool, true)
+	mockSamplePartitions(mockPool, "metric_samples_p20240310", "metric_samples_p20240311", "metric_samples_p20240312")
+
+	locked, err := storage.maintainPartitionsExclusively(ctx, now, nil, time.Minute)
+	require.NoError(t, err)
+	require.True(t, locked)
+
+	mockPool.AssertExpectations(t)
+	lockTx.AssertExpectations(t)
+}
+
+func TestPostgresStorage_MaintainPartitionsLockedByOtherReplica(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	lockTx := mockPartitionMaintenanceLock(mockPool, false)
+
+	locked, err := storage.maintainPartitionsExclusively(ctx, time.Now(), nil, time.Minute)
+	require.NoError(t, err)
+	require.False(t, locked)
+
+	lockTx.AssertExpectations(t)
+	mockPool.AssertNotCalled(t, "Query", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPartitionDay(t *testing.T) {
+	day, ok := partitionDay(partitionName(time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)))
+	require.True(t, ok)
+	require.Equal(t, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), day)
+
+	_, ok = partitionDay("metric_samples_default")
+	require.False(t, ok)
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var _ RetentionStorage = PostgresStorage{}
+
+// Series are matched by name via metrics table, earlier policies take precedence.
+const retentionMatchSQL = "m.id = %[1]s.id AND m.name ~ $%[2]d AND NOT (m.name ~ ANY($%[3]d))"
+
+const rollupMergeSQL = "ON CONFLICT (id, resolution, ts) DO UPDATE SET " +
+	"min = LEAST(metric_rollups.min, EXCLUDED.min), max = GREATEST(metric_rollups.max, EXCLUDED.max), " +
+	"sum = metric_rollups.sum + EXCLUDED.sum, count = metric_rollups.count + EXCLUDED.count"
+
+// $1 - target resolution (s), $2 - cutoff, $3 - name pattern, $4 - patterns of previous policies
+var rollupSamplesSQL = "INSERT INTO metric_rollups(id, resolution, ts, min, max, sum, count) " +
+	"SELECT s.id, $1::integer, to_timestamp(floor(extract(epoch FROM s.ts) / $1::integer) * $1::integer), " +
+	"min(s.value), max(s.value), sum(s.value), count(*) " +
+	"FROM metric_samples s, metrics m WHERE s.ts < $2 AND s.kind <> 'histogram' AND " +
+	fmt.Sprintf(retentionMatchSQL, "s", 3, 4) + " GROUP BY s.id, 3 " + rollupMergeSQL
+
+// $1 - cutoff, $2 - name pattern, $3 - patterns of previous policies
+var deleteSamplesSQL = "DELETE FROM metric_samples s USING metrics m WHERE s.ts < $1 AND " +
+	fmt.Sprintf(retentionMatchSQL, "s", 2, 3)
+
+// $1 - target resolution (s), $2 - cutoff, $3 - name pattern, $4 - patterns of previous policies, $5 - source resolution (s)
+var rollupRollupsSQL = "INSERT INTO metric_rollups(id, resolution, ts, min, max, sum, count) " +
+	"SELECT r.id, $1::integer, to_timestamp(floor(extract(epoch FROM r.ts) / $1::integer) * $1::integer), " +
+	"min(r.min), max(r.max), sum(r.sum), sum(r.count) " +
+	"FROM metric_rollups r, metrics m WHERE r.resolution = $5 AND r.ts < $2 AND " +
+	fmt.Sprintf(retentionMatchSQL, "r", 3, 4) + " GROUP BY r.id, 3 " + rollupMergeSQL
+
+// $1 - cutoff, $2 - name pattern, $3 - patterns of previous policies, $4 - resolution (s)
+var deleteRollupsSQL = "DELETE FROM metric_rollups r USING metrics m WHERE r.resolution = $4 AND r.ts < $1 AND " +
+	fmt.Sprintf(retentionMatchSQL, "r", 2, 3)
+
+const rangeRollupsSQL = "SELECT ts, min, max, sum, count FROM metric_rollups " +
+	"WHERE id = $1 AND resolution = $2 AND ts BETWEEN $3 AND $4 ORDER BY ts"
+
+// Drop expired samples and rollups, rolling them up into coarser resolution first. Runs in single transaction.
+func (d PostgresStorage) ApplyRetention(ctx context.Context, now time.Time, policies RetentionPolicies) error {
+	batch := new(pgx.Batch)
+	previous := make([]string, 0, len(policies))
+
+	for _, policy := range policies {
+		pattern := globToRegexp(policy.Pattern)
+		minute, hour := int(ResolutionMinute.Seconds()), int(ResolutionHour.Seconds())
+
+		rawCutoff := now.Add(-policy.Raw)
+		if target := rawRollupTarget(policy); target != ResolutionRaw {
+			batch.Queue(rollupSamplesSQL, int(target.Seconds()), rawCutoff, pattern, previous)
+		}
+		batch.Queue(deleteSamplesSQL, rawCutoff, pattern, previous)
+
+		minuteCutoff := now.Add(-policy.Minute)
+		if policy.Minute > 0 && policy.Hour > 0 {
+			batch.Queue(rollupRollupsSQL, hour, minuteCutoff, pattern, previous, minute)
+		}
+		batch.Queue(deleteRollupsSQL, minuteCutoff, pattern, previous, minute)
+
+		batch.Queue(deleteRollupsSQL, now.Add(-policy.Hour), pattern, previous, hour)
+
+		previous = append(previous, pattern)
+	}
+
+	tx, err := d.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("db storage ApplyRetention() -> Begin() error: %w", err)
+	}
+
+	if err := execBatch(ctx, tx, batch); err != nil {
+		if rErr := tx.Rollback(ctx); rErr != nil {
+			return fmt.Errorf("db storage ApplyRetention() -> Rollback() error: %w", rErr)
+		}
+
+		return fmt.Errorf("db storage ApplyRetention() error: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Get rollups of the series with given resolution within [from, to], ordered by time
+func (d PostgresStorage) RangeRollups(ctx context.Context, id string, resolution time.Duration, from, to time.Time) ([]Rollup, error) {
+	rows, err := d.Pool.Query(ctx, rangeRollupsSQL, id, int(resolution.Seconds()), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("db storage RangeRollups() error: %w", err)
+	}
+
+	if rowErr := rows.Err(); rowErr != nil {
+		return nil, fmt.Errorf("db storage RangeRollups() error: %w", rowErr)
+	}
+
+	defer rows.Close()
+
+	var rollup Rollup
+
+	result := make([]Rollup, 0)
+	_, err = pgx.ForEachRow(rows, []any{&rollup.Timestamp, &rollup.Min, &rollup.Max, &rollup.Sum, &rollup.Count}, func() error {
+		result = append(result, rollup)
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("db storage RangeRollups() error: %w", err)
+	}
+
+	return result, nil
+}
+
+func execBatch(ctx context.Context, tx pgx.Tx, batch *pgx.Batch) (err error) {
+	batchResp := tx.SendBatch(ctx, batch)
+	defer func() {
+		if closeErr := batchResp.Close(); err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := batchResp.Exec(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Convert shell glob used in retention policies to anchored POSIX regexp.
+func globToRegexp(pattern string) string {
+	var b strings.Builder
+
+	b.WriteString("^")
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case r == '*':
+			b.WriteString(".*")
+		case r == '?':
+			b.WriteString(".")
+		case r == '[':
+			i = writeGlobClass(&b, runes, i)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	b.WriteString("$")
+
+	return b.String()
+}
+
+// Copy character class starting at runes[start] as is, except negation "!" becoming "^".
+// Returns index of the closing bracket.
+func writeGlobClass(b *strings.Builder, runes []rune, start int) int {
+	b.WriteRune('[')
+
+	i := start + 1
+	if i < len(runes) && (runes[i] == '!' || runes[i] == '^') {
+		b.WriteRune('^')
+		i++
+	}
+
+	for ; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == ']':
+			b.WriteRune(r)
+			return i
+		case r == '\\' && i+1 < len(runes):
+			b.WriteRune(r)
+			i++
+			b.WriteRune(runes[i])
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return i
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresStorage_ApplyRetention(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	policies, err := ParseRetentionPolicies("Heap*=1h:24h:720h;*=24h:0:0")
+	assert.NoError(t, err)
+
+	txMock := new(PGXTxMock)
+	batchResults := new(PGXBatchResultsMock)
+	mockPool.On("Begin", ctx).Return(txMock, nil)
+
+	// Heap*: rollup samples, delete samples, rollup minutes, delete minutes, delete hours
+	// *: delete samples, delete minutes, delete hours
+	txMock.On("SendBatch", ctx, mock.MatchedBy(func(b *pgx.Batch) bool {
+		return b.Len() == 8 &&
+			b.QueuedQueries[0].SQL == rollupSamplesSQL &&
+			b.QueuedQueries[5].SQL == deleteSamplesSQL &&
+			assert.ObjectsAreEqual([]string{"^Heap.*$"}, b.QueuedQueries[5].Arguments[2])
+	})).Return(batchResults)
+	batchResults.On("Exec").Return(pgconn.CommandTag{}, nil).Times(8)
+	batchResults.On("Close").Return(nil)
+	txMock.On("Commit", ctx).Return(nil)
+
+	err = storage.ApplyRetention(ctx, now, policies)
+	assert.NoError(t, err)
+
+	txMock.AssertExpectations(t)
+	batchResults.AssertExpectations(t)
+}
+
+func TestPostgresStorage_ApplyRetentionRollback(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	policies := RetentionPolicies{{Pattern: "*", Raw: time.Hour}}
+
+	txMock := new(PGXTxMock)
+	batchResults := new(PGXBatchResultsMock)
+	mockPool.On("Begin", ctx).Return(txMock, nil)
+	txMock.On("SendBatch", ctx, mock.Anything).Return(batchResults)
+	batchResults.On("Exec").Return(pgconn.CommandTag{}, entities.ErrUnexpected).Once()
+	batchResults.On("Close").Return(nil)
+	txMock.On("Rollback", ctx).Return(nil)
+
+	err := storage.ApplyRetention(ctx, time.Now(), policies)
+	assert.ErrorIs(t, err, entities.ErrUnexpected)
+
+	txMock.AssertExpectations(t)
+}
+
+func TestPostgresStorage_RangeRollups(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	expected := []Rollup{
+		{Timestamp: from, Min: 1, Max: 3, Sum: 4, Count: 2},
+		{Timestamp: from.Add(time.Minute), Min: 5, Max: 5, Sum: 5, Count: 1},
+	}
+
+	mockRows := new(PGXRowsMock)
+	mockPool.On("Query", ctx, rangeRollupsSQL, []any{"Alloc_gauge", 60, from, to}).Return(mockRows, nil)
+	mockRows.On("Next").Return(true).Twice()
+	mockRows.On("Next").Return(false)
+
+	counter := 0
+	mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*time.Time) = expected[counter].Timestamp
+		*args.Get(1).(*float64) = expected[counter].Min
+		*args.Get(2).(*float64) = expected[counter].Max
+		*args.Get(3).(*float64) = expected[counter].Sum
+		*args.Get(4).(*uint64) = expected[counter].Count
+
+		counter++
+	}).Twice().Return(nil)
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return(nil)
+	mockRows.On("CommandTag").Return(pgconn.NewCommandTag("select"))
+
+	rollups, err := storage.RangeRollups(ctx, "Alloc_gauge", ResolutionMinute, from, to)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, rollups)
+
+	mockPool.AssertExpectations(t)
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	assert.Equal(t, "^Heap.*$", globToRegexp("Heap*"))
+	assert.Equal(t, "^Gc.\\.count$", globToRegexp("Gc?.count"))
+	assert.Equal(t, "^[a-c]x$", globToRegexp("[a-c]x"))
+	assert.Equal(t, "^[^a-c]x$", globToRegexp("[!a-c]x"))
+}
+
+// Postgres storage must select the same series as in-memory one.
+func TestGlobToRegexp_MatchesPolicies(t *testing.T) {
+	names := []string{"HeapAlloc", "Heap", "GcSys", "Gc1.count", "Gc1xcount", "ax", "dx", "!x", "^x", "a*b", "ab", "a-b", "a]"}
+
+	tests := []string{
+		"*",
+		"Heap*",
+		"Gc?.count",
+		"[a-c]x",
+		"[!a-c]x",
+		"[^a-c]x",
+		"[!!]x",
+		"a\\*b",
+		"a[\\-]b",
+		"a\\]",
+		"*[!y]",
+	}
+
+	for _, pattern := range tests {
+		t.Run(pattern, func(t *testing.T) {
+			policies, err := ParseRetentionPolicies(pattern + "=1h:0s:0s")
+			require.NoError(t, err)
+
+			re := regexp.MustCompile(globToRegexp(pattern))
+
+			for _, name := range names {
+				_, matched := policies.Match(name)
+				assert.Equal(t, matched, re.MatchString(name), name)
+			}
+		})
+	}
+}
@@ -4,8 +4,11 @@ package storage
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/stretchr/testify/assert"
@@ -23,7 +26,10 @@ func TestPostgresStorage_Push(t *testing.T) {
 	txMock := new(PGXTxMock)
 	mockPool.On("Begin", mock.Anything).Return(txMock, nil)
 	txMock.
-		On("Exec", mock.Anything, mock.Anything, key, record.Name, record.Value.Kind(), record.Value.String()).
+		On("Exec", mock.Anything, mock.Anything, key, record.Name, record.Value.Kind(), int64(123), nil, nil, "{}").
+		Return(pgconn.CommandTag{}, nil)
+	txMock.
+		On("Exec", mock.Anything, insertSampleSQL, key, mock.AnythingOfType("time.Time"), record.Value.Kind(), float64(123), nil).
 		Return(pgconn.CommandTag{}, nil)
 
 	txMock.On("Commit", mock.Anything).Return(nil)
@@ -46,7 +52,7 @@ func TestPostgresStorage_PushList(t *testing.T) {
 
 	mockBatchResults := new(PGXBatchResultsMock)
 	mockPool.On("SendBatch", ctx, mock.Anything).Return(mockBatchResults)
-	mockBatchResults.On("Exec").Return(pgconn.CommandTag{}, nil).Twice()
+	mockBatchResults.On("Exec").Return(pgconn.CommandTag{}, nil).Times(4) // latest values and samples
 	mockBatchResults.On("Close").Return(nil)
 
 	err := storage.PushList(ctx, data)
@@ -56,6 +62,71 @@ func TestPostgresStorage_PushList(t *testing.T) {
 	mockBatchResults.AssertExpectations(t)
 }
 
+func TestPostgresStorage_Increment(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	record := Record{Name: "PollCount", Value: metrics.Counter(5)}
+	id := record.CalculateRecordID()
+
+	mockRow := new(PGXRowMock)
+	mockRow.On("Scan", mock.Anything).Run(func(mArgs mock.Arguments) {
+		*mArgs.Get(0).(*int64) = 15 // stored value was 10
+	}).Return(nil)
+
+	mockBatchResults := new(PGXBatchResultsMock)
+	mockPool.On("SendBatch", ctx, mock.MatchedBy(func(b *pgx.Batch) bool {
+		return b.Len() == 1 && b.QueuedQueries[0].SQL == incrementSQL && b.QueuedQueries[0].Arguments[3] == int64(5)
+	})).Return(mockBatchResults)
+	mockBatchResults.On("QueryRow").Return(mockRow)
+	mockBatchResults.On("Close").Return(nil)
+
+	result, err := storage.Increment(ctx, map[string]Record{id: record})
+	assert.NoError(t, err)
+	assert.Equal(t, Record{Name: "PollCount", Value: metrics.Counter(15)}, result[id])
+
+	_, err = storage.Increment(ctx, map[string]Record{"Alloc_gauge": {Name: "Alloc", Value: metrics.Gauge(1)}})
+	assert.ErrorIs(t, err, entities.ErrMetricInvalidValue)
+
+	mockPool.AssertExpectations(t)
+	mockBatchResults.AssertExpectations(t)
+}
+
+func TestPostgresStorage_IncrementLockOrder(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	data := map[string]Record{}
+	for _, name := range []string{"c", "a", "d", "b", "e"} {
+		record := Record{Name: name, Value: metrics.Counter(1)}
+		data[record.CalculateRecordID()] = record
+	}
+
+	mockRow := new(PGXRowMock)
+	mockRow.On("Scan", mock.Anything).Return(nil)
+
+	mockBatchResults := new(PGXBatchResultsMock)
+	mockPool.On("SendBatch", ctx, mock.MatchedBy(func(b *pgx.Batch) bool {
+		ids := make([]string, 0, b.Len())
+		for _, q := range b.QueuedQueries {
+			ids = append(ids, q.Arguments[0].(string))
+		}
+
+		return assert.ObjectsAreEqual(
+			[]string{"a_counter", "b_counter", "c_counter", "d_counter", "e_counter"}, ids,
+		)
+	})).Return(mockBatchResults)
+	mockBatchResults.On("QueryRow").Return(mockRow)
+	mockBatchResults.On("Close").Return(nil)
+
+	_, err := storage.Increment(ctx, data)
+	assert.NoError(t, err)
+
+	mockPool.AssertExpectations(t)
+}
+
 func TestPostgresStorage_Get(t *testing.T) {
 	mockPool := NewPGXPoolMock()
 	storage := PostgresStorage{Pool: mockPool}
@@ -66,10 +137,12 @@ func TestPostgresStorage_Get(t *testing.T) {
 
 	mockRow := new(PGXRowMock)
 	mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
-	mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything).Run(func(mArgs mock.Arguments) {
+	mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(mArgs mock.Arguments) {
+		delta := int64(123)
+
 		*mArgs.Get(0).(*string) = expectedRecord.Name
 		*mArgs.Get(1).(*string) = expectedRecord.Value.Kind()
-		*mArgs.Get(2).(*float64) = 123
+		*mArgs.Get(2).(**int64) = &delta
 	}).Return(nil)
 
 	record, err := storage.Get(ctx, key)
@@ -80,6 +153,206 @@ func TestPostgresStorage_Get(t *testing.T) {
 	mockRow.AssertExpectations(t)
 }
 
+func TestPostgresStorage_LargeCounter(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	record := Record{Name: "Bytes", Value: metrics.Counter(1<<62 + 1)} // not representable as float64
+	key := record.CalculateRecordID()
+
+	txMock := new(PGXTxMock)
+	mockPool.On("Begin", mock.Anything).Return(txMock, nil)
+	txMock.On("Exec", mock.Anything, upsertSQL, key, record.Name, metrics.KindCounter, int64(1<<62+1), nil, nil, "{}").
+		Return(pgconn.CommandTag{}, nil)
+	txMock.On("Exec", mock.Anything, insertSampleSQL, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(pgconn.CommandTag{}, nil)
+	txMock.On("Commit", mock.Anything).Return(nil)
+
+	assert.NoError(t, storage.Push(ctx, key, record))
+	txMock.AssertExpectations(t)
+
+	mockRow := new(PGXRowMock)
+	mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+	mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(mArgs mock.Arguments) {
+		delta := int64(1<<62 + 1)
+
+		*mArgs.Get(0).(*string) = record.Name
+		*mArgs.Get(1).(*string) = metrics.KindCounter
+		*mArgs.Get(2).(**int64) = &delta
+	}).Return(nil)
+
+	stored, err := storage.Get(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, record, stored)
+}
+
+func TestTypedRowToRecord_MissingColumn(t *testing.T) {
+	value := 1.5
+	delta := int64(1)
+
+	_, err := typedRowToRecord("PollCount", metrics.KindCounter, nil, &value, nil, nil)
+	assert.Error(t, err)
+
+	_, err = typedRowToRecord("Alloc", metrics.KindGauge, &delta, nil, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestPostgresStorage_GetHistogram(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	histogram := metrics.NewHistogram(1)
+	histogram.Observe(0.5)
+	expectedRecord := Record{Name: "latency", Value: histogram}
+
+	mockRow := new(PGXRowMock)
+	mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+	mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(mArgs mock.Arguments) {
+		buckets := histogram.String()
+
+		*mArgs.Get(0).(*string) = expectedRecord.Name
+		*mArgs.Get(1).(*string) = metrics.KindHistogram
+		*mArgs.Get(3).(**float64) = &histogram.Sum
+		*mArgs.Get(4).(**string) = &buckets
+	}).Return(nil)
+
+	record, err := storage.Get(ctx, expectedRecord.CalculateRecordID())
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRecord, record)
+}
+
+func TestPostgresStorage_PushWithLabels(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	record := Record{Name: "Alloc", Value: metrics.Gauge(1.5), Labels: metrics.Labels{"host": "a"}}
+	key := record.CalculateRecordID()
+
+	txMock := new(PGXTxMock)
+	mockPool.On("Begin", mock.Anything).Return(txMock, nil)
+	txMock.
+		On("Exec", mock.Anything, mock.Anything, `Alloc_gauge{host="a"}`, record.Name, record.Value.Kind(), nil, 1.5, nil, `{"host":"a"}`).
+		Return(pgconn.CommandTag{}, nil)
+	txMock.
+		On("Exec", mock.Anything, insertSampleSQL, `Alloc_gauge{host="a"}`, mock.AnythingOfType("time.Time"), record.Value.Kind(), 1.5, nil).
+		Return(pgconn.CommandTag{}, nil)
+
+	txMock.On("Commit", mock.Anything).Return(nil)
+
+	err := storage.Push(ctx, key, record)
+	assert.NoError(t, err)
+	txMock.AssertExpectations(t)
+}
+
+func TestPostgresStorage_GetWithLabels(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	expectedRecord := Record{Name: "Alloc", Value: metrics.Gauge(1.5), Labels: metrics.Labels{"host": "a"}}
+
+	mockRow := new(PGXRowMock)
+	mockPool.On("QueryRow", ctx, mock.Anything, mock.Anything).Return(mockRow)
+	mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(mArgs mock.Arguments) {
+		value := 1.5
+
+		*mArgs.Get(0).(*string) = expectedRecord.Name
+		*mArgs.Get(1).(*string) = metrics.KindGauge
+		*mArgs.Get(3).(**float64) = &value
+		*mArgs.Get(5).(*metrics.Labels) = metrics.Labels{"host": "a"}
+	}).Return(nil)
+
+	record, err := storage.Get(ctx, expectedRecord.CalculateRecordID())
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRecord, record)
+}
+
+func TestPostgresStorage_ListFiltered(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	filter := ListFilter{Name: "Alloc", Kind: metrics.KindGauge, Labels: metrics.Labels{"host": "a"}}
+
+	mockRows := new(PGXRowsMock)
+	mockPool.
+		On("Query", ctx, selectSQL+" WHERE name = $1 AND kind::text = $2 AND labels @> $3::jsonb", []any{"Alloc", metrics.KindGauge, `{"host":"a"}`}).
+		Return(mockRows, nil)
+	mockRows.On("Next").Return(false)
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return(nil)
+	mockRows.On("CommandTag").Return(pgconn.NewCommandTag("select"))
+
+	records, err := storage.List(ctx, filter)
+
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	mockPool.AssertExpectations(t)
+}
+
+func TestPostgresStorage_ListByNamePrefix(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+
+	mockRows := new(PGXRowsMock)
+	mockPool.On("Query", ctx, selectSQL+" WHERE starts_with(name, $1)", []any{"Heap"}).Return(mockRows, nil)
+	mockRows.On("Next").Return(false)
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return(nil)
+	mockRows.On("CommandTag").Return(pgconn.NewCommandTag("select"))
+
+	records, err := storage.List(ctx, ListFilter{NamePrefix: "Heap"})
+
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	mockPool.AssertExpectations(t)
+}
+
+func TestPostgresStorage_Delete(t *testing.T) {
+	tests := []struct {
+		name    string
+		tag     pgconn.CommandTag
+		wantErr error
+	}{
+		{name: "deleted", tag: pgconn.NewCommandTag("DELETE 1")},
+		{name: "not found", tag: pgconn.NewCommandTag("DELETE 0"), wantErr: entities.ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPool := NewPGXPoolMock()
+			storage := PostgresStorage{Pool: mockPool}
+
+			ctx := context.Background()
+
+			mockBatchResults := new(PGXBatchResultsMock)
+			mockPool.On("SendBatch", ctx, mock.MatchedBy(func(b *pgx.Batch) bool {
+				return b.Len() == 3 && b.QueuedQueries[2].SQL == deleteRecordSQL
+			})).Return(mockBatchResults)
+			mockBatchResults.On("Exec").Return(pgconn.NewCommandTag("DELETE 5"), nil).Twice()
+			mockBatchResults.On("Exec").Return(tt.tag, nil).Once()
+			mockBatchResults.On("Close").Return(nil)
+
+			err := storage.Delete(ctx, "Alloc_gauge")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockPool.AssertExpectations(t)
+			mockBatchResults.AssertExpectations(t)
+		})
+	}
+}
+
 func TestPostgresStorage_List(t *testing.T) {
 	mockPool := NewPGXPoolMock()
 	storage := PostgresStorage{Pool: mockPool}
@@ -96,18 +369,20 @@ func TestPostgresStorage_List(t *testing.T) {
 	mockRows.On("Next").Return(false)
 
 	counter := 0
-	mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+	mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
 		rec := expectedRecords[counter]
 		*args.Get(0).(*string) = rec.Name
 		*args.Get(1).(*string) = rec.Value.Kind()
 
 		switch expectedRecords[counter].Value.Kind() {
 		case metrics.KindCounter:
-			value, _ := rec.Value.(metrics.Counter)
-			*args.Get(2).(*float64) = float64(value)
+			value := int64(rec.Value.(metrics.Counter))
+			*args.Get(2).(**int64) = &value
+			*args.Get(3).(**float64) = nil
 		case metrics.KindGauge:
-			value, _ := rec.Value.(metrics.Gauge)
-			*args.Get(2).(*float64) = float64(value)
+			value := float64(rec.Value.(metrics.Gauge))
+			*args.Get(2).(**int64) = nil
+			*args.Get(3).(**float64) = &value
 		}
 
 		counter++
@@ -116,7 +391,7 @@ func TestPostgresStorage_List(t *testing.T) {
 	mockRows.On("Close").Return(nil)
 	mockRows.On("CommandTag").Return(pgconn.NewCommandTag("select"))
 
-	records, err := storage.List(ctx)
+	records, err := storage.List(ctx, ListFilter{})
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedRecords, records)
@@ -125,6 +400,87 @@ func TestPostgresStorage_List(t *testing.T) {
 	mockRows.AssertExpectations(t)
 }
 
+func TestPostgresStorage_PushWithTimestamp(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	record := Record{Name: "Alloc", Value: metrics.Gauge(1.5), Timestamp: ts}
+	key := record.CalculateRecordID()
+
+	txMock := new(PGXTxMock)
+	mockPool.On("Begin", mock.Anything).Return(txMock, nil)
+	txMock.On("Exec", mock.Anything, upsertSQL, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(pgconn.CommandTag{}, nil)
+	txMock.On("Exec", mock.Anything, insertSampleSQL, key, ts, metrics.KindGauge, 1.5, nil).
+		Return(pgconn.CommandTag{}, nil)
+	txMock.On("Commit", mock.Anything).Return(nil)
+
+	err := storage.Push(ctx, key, record)
+	assert.NoError(t, err)
+	txMock.AssertExpectations(t)
+}
+
+func TestPostgresStorage_PushRollback(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	record := Record{Name: "Alloc", Value: metrics.Gauge(1.5)}
+	key := record.CalculateRecordID()
+
+	txMock := new(PGXTxMock)
+	mockPool.On("Begin", mock.Anything).Return(txMock, nil)
+	txMock.On("Exec", mock.Anything, upsertSQL, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(pgconn.CommandTag{}, nil)
+	txMock.On("Exec", mock.Anything, insertSampleSQL, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(pgconn.CommandTag{}, entities.ErrUnexpected)
+	txMock.On("Rollback", mock.Anything).Return(nil)
+
+	err := storage.Push(ctx, key, record)
+	assert.ErrorIs(t, err, entities.ErrUnexpected)
+	txMock.AssertExpectations(t)
+}
+
+func TestPostgresStorage_Range(t *testing.T) {
+	mockPool := NewPGXPoolMock()
+	storage := PostgresStorage{Pool: mockPool}
+
+	ctx := context.Background()
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	expected := []Sample{
+		{Timestamp: from.Add(time.Minute), Value: metrics.Gauge(1.5)},
+		{Timestamp: from.Add(2 * time.Minute), Value: metrics.Gauge(2.5)},
+	}
+
+	mockRows := new(PGXRowsMock)
+	mockPool.On("Query", ctx, rangeSQL, []any{"Alloc_gauge", from, to}).Return(mockRows, nil)
+	mockRows.On("Next").Return(true).Twice()
+	mockRows.On("Next").Return(false)
+
+	counter := 0
+	mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*time.Time) = expected[counter].Timestamp
+		*args.Get(1).(*string) = metrics.KindGauge
+		*args.Get(2).(*float64) = float64(expected[counter].Value.(metrics.Gauge))
+
+		counter++
+	}).Twice().Return(nil)
+	mockRows.On("Err").Return(nil)
+	mockRows.On("Close").Return(nil)
+	mockRows.On("CommandTag").Return(pgconn.NewCommandTag("select"))
+
+	samples, err := storage.Range(ctx, "Alloc_gauge", from, to)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, samples)
+
+	mockPool.AssertExpectations(t)
+}
+
 func TestPostgresStorage_Ping(t *testing.T) {
 	mockPool := NewPGXPoolMock()
 	storage := PostgresStorage{Pool: mockPool}
@@ -138,6 +494,41 @@ func TestPostgresStorage_Ping(t *testing.T) {
 	mockPool.AssertExpectations(t)
 }
 
+func TestPostgresStorage_CheckMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		latest  uint
+		dirty   bool
+		scanErr error
+		wantErr error
+	}{
+		{name: "applied", latest: 3},
+		{name: "dirty", latest: 3, dirty: true, wantErr: entities.ErrMigrationsDirty},
+		{name: "not applied", latest: 3, scanErr: pgx.ErrNoRows, wantErr: entities.ErrMigrationsMissing},
+		{name: "pending", latest: 4, wantErr: entities.ErrMigrationsPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPool := NewPGXPoolMock()
+			storage := PostgresStorage{Pool: mockPool, latestMigration: tt.latest}
+
+			mockRow := new(PGXRowMock)
+			mockPool.On("QueryRow", mock.Anything, migrationsSQL, mock.Anything).Return(mockRow)
+			mockRow.On("Scan", mock.Anything, mock.Anything).Run(func(mArgs mock.Arguments) {
+				*mArgs.Get(0).(*int64) = 3
+				*mArgs.Get(1).(*bool) = tt.dirty
+			}).Return(tt.scanErr)
+
+			checks := storage.HealthChecks()
+			assert.Equal(t, "migrations", checks[1].Name)
+
+			err := checks[1].Check(context.Background())
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
 func TestPostgresStorage_Close(t *testing.T) {
 	mockPool := NewPGXPoolMock()
 	storage := PostgresStorage{Pool: mockPool}
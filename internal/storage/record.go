@@ -3,37 +3,51 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
-	"github.com/ex0rcist/metflix/internal/entities"
 	"github.com/ex0rcist/metflix/pkg/metrics"
 )
 
 // Struct to store metrics in storage
 type Record struct {
-	Name  string
-	Value metrics.Metric
+	Name   string
+	Value  metrics.Metric
+	Labels metrics.Labels
+
+	// Time of observation, zero means time of push. Not part of record identity.
+	Timestamp time.Time
 }
 
-// Calculate record ID for ease of store and search
-func CalculateRecordID(name, kind string) string {
+// JSON representation of record, every value is stringified
+type recordJSON struct {
+	Name   string         `json:"name"`
+	Kind   string         `json:"kind"`
+	Value  string         `json:"value"`
+	Labels metrics.Labels `json:"labels,omitempty"`
+}
+
+// Calculate record ID for ease of store and search.
+// Labels are part of identity: name_kind{env="prod",host="a"}.
+func CalculateRecordID(name, kind string, labels metrics.Labels) string {
 	if len(name) == 0 || len(kind) == 0 {
 		return ""
 	}
 
-	return name + "_" + kind
+	return name + "_" + kind + labels.String()
 }
 
 // Calculate record ID for ease of store and search
 func (r Record) CalculateRecordID() string {
-	return CalculateRecordID(r.Name, r.Value.Kind())
+	return CalculateRecordID(r.Name, r.Value.Kind(), r.Labels)
 }
 
 // Serialize to JSON
 func (r Record) MarshalJSON() ([]byte, error) {
-	jv, err := json.Marshal(map[string]string{
-		"name":  r.Name,
-		"kind":  r.Value.Kind(),
-		"value": r.Value.String(),
+	jv, err := json.Marshal(recordJSON{
+		Name:   r.Name,
+		Kind:   r.Value.Kind(),
+		Value:  r.Value.String(),
+		Labels: r.Labels,
 	})
 
 	if err != nil {
@@ -45,32 +59,21 @@ func (r Record) MarshalJSON() ([]byte, error) {
 
 // Deserialize from JSON
 func (r *Record) UnmarshalJSON(src []byte) error {
-	var data map[string]string
+	var data recordJSON
 
 	if err := json.Unmarshal(src, &data); err != nil {
 		return fmt.Errorf("record unmarshaling failed: %w", err)
 	}
 
-	r.Name = data["name"]
-
-	switch data["kind"] {
-	case metrics.KindCounter:
-		value, err := metrics.ToCounter(data["value"])
-		if err != nil {
-			return fmt.Errorf("record unmarshaling failed: %w", err)
-		}
-
-		r.Value = value
-	case metrics.KindGauge:
-		value, err := metrics.ToGauge(data["value"])
-		if err != nil {
-			return fmt.Errorf("record unmarshaling failed: %w", err)
-		}
-
-		r.Value = value
-	default:
-		return fmt.Errorf("record unmarshaling failed: %w", entities.ErrMetricUnknown)
+	r.Name = data.Name
+	r.Labels = data.Labels
+
+	value, err := parseMetric(data.Kind, data.Value)
+	if err != nil {
+		return fmt.Errorf("record unmarshaling failed: %w", err)
 	}
 
+	r.Value = value
+
 	return nil
 }
@@ -15,17 +15,21 @@ func TestCalculateRecordID(t *testing.T) {
 		test     string
 		name     string
 		kind     string
+		labels   metrics.Labels
 		expected string
 	}{
 		{test: "valid inputs", name: "metricName", kind: "metricKind", expected: "metricName_metricKind"},
+		{test: "empty labels", name: "metricName", kind: "metricKind", labels: metrics.Labels{}, expected: "metricName_metricKind"},
+		{test: "with labels", name: "metricName", kind: "metricKind", labels: metrics.Labels{"host": "a", "env": "prod"}, expected: `metricName_metricKind{env="prod",host="a"}`},
+		{test: "with escaped labels", name: "metricName", kind: "metricKind", labels: metrics.Labels{"host": `a",b="c`}, expected: `metricName_metricKind{host="a\",b=\"c"}`},
 		{test: "empty name", name: "", kind: "metricKind", expected: ""},
 		{test: "empty kind", name: "metricName", kind: "", expected: ""},
 		{test: "both empty", name: "", kind: "", expected: ""},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := CalculateRecordID(tt.name, tt.kind)
+		t.Run(tt.test, func(t *testing.T) {
+			result := CalculateRecordID(tt.name, tt.kind, tt.labels)
 			if result != tt.expected {
 				t.Errorf("expected %q, got %q", tt.expected, result)
 			}
@@ -42,6 +46,7 @@ func TestRecord_CalculateRecordID(t *testing.T) {
 		{name: "valid record with counter", record: Record{Name: "metricName", Value: metrics.Counter(100)}, expected: "metricName_counter"},
 		{name: "valid record with gauge", record: Record{Name: "metricName", Value: metrics.Gauge(100.0)}, expected: "metricName_gauge"},
 		{name: "empty name", record: Record{Name: "", Value: metrics.Counter(100)}, expected: ""},
+		{name: "valid record with labels", record: Record{Name: "metricName", Value: metrics.Gauge(1), Labels: metrics.Labels{"host": "a"}}, expected: `metricName_gauge{host="a"}`},
 	}
 
 	for _, tt := range tests {
@@ -61,6 +66,7 @@ func TestRecordMarshalingNormalData(t *testing.T) {
 	}{
 		{name: "Should convert counter", source: Record{Name: "PollCount", Value: metrics.Counter(10)}},
 		{name: "Should convert gauge", source: Record{Name: "Alloc", Value: metrics.Gauge(42.0)}},
+		{name: "Should convert labels", source: Record{Name: "Alloc", Value: metrics.Gauge(42.0), Labels: metrics.Labels{"host": "a"}}},
 	}
 
 	for _, tt := range tests {
@@ -76,13 +82,33 @@ func TestRecordMarshalingNormalData(t *testing.T) {
 				t.Fatalf("expected no error unmarshaling json, got: %v", err)
 			}
 
-			if tt.source != *target {
-				t.Fatal("expected records to be equal:", tt.source, target)
-			}
+			require.Equal(t, tt.source, *target)
 		})
 	}
 }
 
+func TestRecordMarshalingHistogram(t *testing.T) {
+	histogram := metrics.NewHistogram(0.1, 1)
+	histogram.Observe(0.05)
+	histogram.Observe(5)
+
+	source := Record{Name: "Latency", Value: histogram}
+
+	json, err := source.MarshalJSON()
+	require.NoError(t, err)
+
+	target := new(Record)
+	require.NoError(t, target.UnmarshalJSON(json))
+	require.Equal(t, source, *target)
+}
+
+func TestRecordUnmarshalingLegacyData(t *testing.T) {
+	target := new(Record)
+
+	require.NoError(t, target.UnmarshalJSON([]byte(`{"name": "Alloc", "kind": "gauge", "value": "42.5"}`)))
+	require.Equal(t, Record{Name: "Alloc", Value: metrics.Gauge(42.5)}, *target)
+}
+
 func TestRecordUnmarshalingCorruptedData(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -92,6 +118,7 @@ func TestRecordUnmarshalingCorruptedData(t *testing.T) {
 		{name: "Should fail on broken json", data: `{"name": "xxx",`, expected: &json.SyntaxError{}},
 		{name: "Should fail on invalid counter", data: `{"name": "xxx", "kind": "counter", "value": "12.345"}`, expected: strconv.ErrSyntax},
 		{name: "Should fail on invalid gauge", data: `{"name": "xxx", "kind": "gauge", "value": "12.)"}`, expected: strconv.ErrSyntax},
+		{name: "Should fail on invalid histogram", data: `{"name": "xxx", "kind": "histogram", "value": "{}"}`, expected: entities.ErrMetricInvalidValue},
 		{name: "Should fail on unknown kind", data: `{"name": "xxx", "kind": "unknown", "value": "12"}`, expected: entities.ErrMetricUnknown},
 	}
 
@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/logging"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+// Rollup resolutions kept by storages.
+const (
+	ResolutionRaw    time.Duration = 0
+	ResolutionMinute               = time.Minute
+	ResolutionHour                 = time.Hour
+)
+
+// Aggregate of counter or gauge samples within one resolution-wide interval.
+type Rollup struct {
+	Timestamp time.Time `json:"ts"` // interval start
+	Min       float64   `json:"min"`
+	Max       float64   `json:"max"`
+	Sum       float64   `json:"sum"`
+	Count     uint64    `json:"count"`
+}
+
+// Average value within interval.
+func (r Rollup) Avg() float64 {
+	if r.Count == 0 {
+		return 0
+	}
+
+	return r.Sum / float64(r.Count)
+}
+
+// Combine two rollups of the same interval.
+func (r Rollup) Merge(other Rollup) Rollup {
+	return Rollup{
+		Timestamp: r.Timestamp,
+		Min:       math.Min(r.Min, other.Min),
+		Max:       math.Max(r.Max, other.Max),
+		Sum:       r.Sum + other.Sum,
+		Count:     r.Count + other.Count,
+	}
+}
+
+// Build single-value rollup from sample. Only counters and finite gauges can be rolled up:
+// NaN or Inf would poison the aggregates and can't be encoded to JSON.
+func SampleToRollup(sample Sample) (Rollup, bool) {
+	var value float64
+
+	switch v := sample.Value.(type) {
+	case metrics.Counter:
+		value = float64(v)
+	case metrics.Gauge:
+		value = float64(v)
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return Rollup{}, false
+		}
+	default:
+		return Rollup{}, false
+	}
+
+	return Rollup{Timestamp: sample.Timestamp, Min: value, Max: value, Sum: value, Count: 1}, true
+}
+
+// Retention rules for series which names match Pattern (shell glob, e.g. "Heap*").
+// Raw samples are kept for Raw, then rolled up to minutes kept for Minute, then to hours kept for Hour.
+// Zero Minute or Hour disables that resolution.
+type RetentionPolicy struct {
+	Pattern string
+	Raw     time.Duration
+	Minute  time.Duration
+	Hour    time.Duration
+}
+
+// Ordered list of retention policies, first matching policy wins.
+type RetentionPolicies []RetentionPolicy
+
+// Parse policies from spec like "Heap*=1h:24h:720h;*=24h:168h:0".
+// Every rule is pattern=raw:minute:hour, durations are in Go format.
+func ParseRetentionPolicies(spec string) (RetentionPolicies, error) {
+	policies := make(RetentionPolicies, 0)
+
+	for _, rule := range strings.Split(spec, ";") {
+		rule = strings.TrimSpace(rule)
+		if len(rule) == 0 {
+			continue
+		}
+
+		policy, err := parseRetentionPolicy(rule)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, rule)
+		}
+
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+func parseRetentionPolicy(rule string) (RetentionPolicy, error) {
+	var policy RetentionPolicy
+
+	pattern, tiers, ok := strings.Cut(rule, "=")
+	if !ok || len(pattern) == 0 {
+		return policy, entities.ErrBadRetentionPolicy
+	}
+
+	pattern = normalizeGlob(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return policy, entities.ErrBadRetentionPolicy
+	}
+
+	durations := strings.Split(tiers, ":")
+	if len(durations) != 3 {
+		return policy, entities.ErrBadRetentionPolicy
+	}
+
+	parsed := make([]time.Duration, len(durations))
+	for i, raw := range durations {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return policy, entities.ErrBadRetentionPolicy
+		}
+
+		parsed[i] = d
+	}
+
+	policy = RetentionPolicy{Pattern: pattern, Raw: parsed[0], Minute: parsed[1], Hour: parsed[2]}
+	if policy.Raw == 0 {
+		return policy, entities.ErrBadRetentionPolicy
+	}
+
+	return policy, nil
+}
+
+// Rewrite shell negated classes "[!...]" to "[^...]" understood by path.Match.
+func normalizeGlob(pattern string) string {
+	runes := []rune(pattern)
+
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case '[':
+			if i+1 < len(runes) && runes[i+1] == '!' {
+				runes[i+1] = '^'
+			}
+
+			for i++; i < len(runes) && runes[i] != ']'; i++ {
+				if runes[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+
+	return string(runes)
+}
+
+// Find policy for series name.
+func (p RetentionPolicies) Match(name string) (RetentionPolicy, bool) {
+	for _, policy := range p {
+		if ok, _ := path.Match(policy.Pattern, name); ok {
+			return policy, true
+		}
+	}
+
+	return RetentionPolicy{}, false
+}
+
+// How long raw samples of any series may be kept: the longest raw retention of policies up to catch-all "*" one.
+// Not ok if some series match no policy, so their samples are kept forever.
+func (p RetentionPolicies) RawHorizon() (time.Duration, bool) {
+	var horizon time.Duration
+
+	for _, policy := range p {
+		horizon = max(horizon, policy.Raw)
+
+		if policy.Pattern == "*" {
+			return horizon, true
+		}
+	}
+
+	return 0, false
+}
+
+// Storage which is able to drop and downsample old samples.
+type RetentionStorage interface {
+	ApplyRetention(ctx context.Context, now time.Time, policies RetentionPolicies) error
+}
+
+// Background job running periodically until stopped.
+type periodicWorker struct {
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+// Periodically applies retention policies to storage.
+func startRetention(target RetentionStorage, policies RetentionPolicies, interval time.Duration) *periodicWorker {
+	return startPeriodic(interval, func(now time.Time) {
+		if err := target.ApplyRetention(context.Background(), now, policies); err != nil {
+			logging.LogError(fmt.Errorf("error during ApplyRetention(): %w", err))
+		}
+	})
+}
+
+func startPeriodic(interval time.Duration, job func(now time.Time)) *periodicWorker {
+	w := &periodicWorker{
+		ticker: time.NewTicker(interval),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer w.ticker.Stop()
+
+		for {
+			select {
+			case <-w.done:
+				return
+			case now := <-w.ticker.C:
+				job(now)
+			}
+		}
+	}()
+
+	return w
+}
+
+func (w *periodicWorker) stop() {
+	if w != nil {
+		close(w.done)
+	}
+}
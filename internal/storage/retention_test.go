@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetentionPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    RetentionPolicies
+		wantErr bool
+	}{
+		{
+			name: "empty spec",
+			spec: "",
+			want: RetentionPolicies{},
+		},
+		{
+			name: "several policies",
+			spec: "Heap*=1h:24h:720h; *=24h:168h:0",
+			want: RetentionPolicies{
+				{Pattern: "Heap*", Raw: time.Hour, Minute: 24 * time.Hour, Hour: 720 * time.Hour},
+				{Pattern: "*", Raw: 24 * time.Hour, Minute: 168 * time.Hour},
+			},
+		},
+		{
+			name:    "missing pattern",
+			spec:    "=1h:24h:720h",
+			wantErr: true,
+		},
+		{
+			name:    "missing tier",
+			spec:    "*=1h:24h",
+			wantErr: true,
+		},
+		{
+			name:    "bad duration",
+			spec:    "*=1h:day:0",
+			wantErr: true,
+		},
+		{
+			name:    "zero raw retention",
+			spec:    "*=0:24h:0",
+			wantErr: true,
+		},
+		{
+			name:    "bad pattern",
+			spec:    "[=1h:24h:0",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRetentionPolicies(tt.spec)
+			if tt.wantErr {
+				require.ErrorIs(t, err, entities.ErrBadRetentionPolicy)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRetentionPolicies_Match(t *testing.T) {
+	policies, err := ParseRetentionPolicies("Heap*=1h:24h:0;*=24h:0:0")
+	require.NoError(t, err)
+
+	policy, ok := policies.Match("HeapAlloc")
+	require.True(t, ok)
+	require.Equal(t, "Heap*", policy.Pattern)
+
+	policy, ok = policies.Match("PollCount")
+	require.True(t, ok)
+	require.Equal(t, "*", policy.Pattern)
+
+	_, ok = policies[:1].Match("PollCount")
+	require.False(t, ok)
+}
+
+func TestRetentionPolicies_RawHorizon(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies RetentionPolicies
+		want     time.Duration
+		wantOk   bool
+	}{
+		{name: "empty", policies: RetentionPolicies{}},
+		{name: "no catch-all", policies: RetentionPolicies{{Pattern: "Heap*", Raw: time.Hour}}},
+		{
+			name:     "catch-all",
+			policies: RetentionPolicies{{Pattern: "Heap*", Raw: 48 * time.Hour}, {Pattern: "*", Raw: time.Hour}},
+			want:     48 * time.Hour,
+			wantOk:   true,
+		},
+		{
+			name:     "policies after catch-all never match",
+			policies: RetentionPolicies{{Pattern: "*", Raw: time.Hour}, {Pattern: "Heap*", Raw: 48 * time.Hour}},
+			want:     time.Hour,
+			wantOk:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			horizon, ok := tt.policies.RawHorizon()
+			require.Equal(t, tt.wantOk, ok)
+			require.Equal(t, tt.want, horizon)
+		})
+	}
+}
+
+func TestSampleToRollup(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	rollup, ok := SampleToRollup(Sample{Timestamp: ts, Value: metrics.Counter(3)})
+	require.True(t, ok)
+	require.Equal(t, Rollup{Timestamp: ts, Min: 3, Max: 3, Sum: 3, Count: 1}, rollup)
+
+	_, ok = SampleToRollup(Sample{Timestamp: ts, Value: metrics.NewHistogram(1)})
+	require.False(t, ok)
+
+	_, ok = SampleToRollup(Sample{Timestamp: ts, Value: metrics.Gauge(math.NaN())})
+	require.False(t, ok)
+
+	_, ok = SampleToRollup(Sample{Timestamp: ts, Value: metrics.Gauge(math.Inf(1))})
+	require.False(t, ok)
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+// Single timestamped value of a series
+type Sample struct {
+	Timestamp time.Time
+	Value     metrics.Metric
+}
+
+// JSON representation of sample, value is stringified as in Record
+type sampleJSON struct {
+	Timestamp time.Time `json:"ts"`
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+}
+
+// Build sample from pushed record, records without timestamp are stamped with now
+func newSample(record Record, now time.Time) Sample {
+	ts := record.Timestamp
+	if ts.IsZero() {
+		ts = now
+	}
+
+	return Sample{Timestamp: ts, Value: record.Value}
+}
+
+// Serialize to JSON
+func (s Sample) MarshalJSON() ([]byte, error) {
+	jv, err := json.Marshal(sampleJSON{
+		Timestamp: s.Timestamp,
+		Kind:      s.Value.Kind(),
+		Value:     s.Value.String(),
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("sample marshaling fail: %w", err)
+	}
+
+	return jv, nil
+}
+
+// Deserialize from JSON
+func (s *Sample) UnmarshalJSON(src []byte) error {
+	var data sampleJSON
+
+	if err := json.Unmarshal(src, &data); err != nil {
+		return fmt.Errorf("sample unmarshaling failed: %w", err)
+	}
+
+	value, err := parseMetric(data.Kind, data.Value)
+	if err != nil {
+		return fmt.Errorf("sample unmarshaling failed: %w", err)
+	}
+
+	s.Timestamp = data.Timestamp
+	s.Value = value
+
+	return nil
+}
+
+// Convert stringified value of given kind back to metric
+func parseMetric(kind, value string) (metrics.Metric, error) {
+	switch kind {
+	case metrics.KindCounter:
+		return metrics.ToCounter(value)
+	case metrics.KindGauge:
+		return metrics.ToGauge(value)
+	case metrics.KindHistogram:
+		return metrics.ToHistogram(value)
+	default:
+		return nil, entities.ErrMetricUnknown
+	}
+}
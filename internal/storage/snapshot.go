@@ -0,0 +1,329 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+)
+
+// Snapshot file layout:
+//
+//	metflix-snapshot v1 format=<json|binary>\n
+//	<payload>
+//	\ncrc32c=XXXXXXXX length=XXXXXXXXXXXXXXXX\n
+//
+// Fixed-size trailer allows to stream payload both ways and verify it after decoding.
+// Files without header are JSON snapshots written before versioning, they are read as is.
+const (
+	snapshotMagic   = "metflix-snapshot"
+	snapshotVersion = 1
+
+	snapshotTrailerFormat = "\ncrc32c=%08x length=%016x\n"
+)
+
+// Snapshot payload encodings.
+const (
+	SnapshotFormatJSON   = "json"   // single JSON document, human-readable
+	SnapshotFormatBinary = "binary" // compact varint encoding, see snapshot_binary.go
+)
+
+var (
+	snapshotTrailerSize = len(fmt.Sprintf(snapshotTrailerFormat, 0, 0))
+	snapshotCRCTable    = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// Write snapshot atomically: into temp file which is synced and renamed over the previous one.
+// Up to keep previous snapshots are preserved as path.1 (newest) ... path.<keep>.
+func writeSnapshotFile(path string, keep int, format string, encode func(w io.Writer) error) (err error) {
+	tmpPath := path + ".tmp"
+
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("snapshot create failed: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err = fmt.Fprintf(file, "%s v%d format=%s\n", snapshotMagic, snapshotVersion, format); err != nil {
+		return fmt.Errorf("snapshot write failed: %w", err)
+	}
+
+	payload := &checksumWriter{w: file, crc: crc32.New(snapshotCRCTable)}
+	if err = encode(payload); err != nil {
+		return fmt.Errorf("snapshot encode failed: %w", err)
+	}
+
+	if _, err = fmt.Fprintf(file, snapshotTrailerFormat, payload.crc.Sum32(), payload.n); err != nil {
+		return fmt.Errorf("snapshot write failed: %w", err)
+	}
+
+	if err = file.Sync(); err != nil {
+		return fmt.Errorf("snapshot fsync failed: %w", err)
+	}
+
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("snapshot close failed: %w", err)
+	}
+
+	if err = rotateSnapshots(path, keep); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("snapshot rename failed: %w", err)
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// Read snapshot verifying its header and checksum.
+// Payload is streamed to decode along with its format,
+// decode must not keep decoded data if checksum mismatches.
+func readSnapshotFile(path string, decode func(format string, r io.Reader) error) (err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("snapshot close failed: %w", closeErr)
+		}
+	}()
+
+	reader := bufio.NewReader(file)
+
+	header, err := reader.Peek(len(snapshotMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("snapshot read failed: %w", err)
+	}
+
+	if !bytes.Equal(header, []byte(snapshotMagic)) {
+		return decode(SnapshotFormatJSON, reader) // legacy snapshot without header
+	}
+
+	headerLine, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("%w: incomplete header", entities.ErrSnapshotCorrupted)
+	}
+
+	format, err := parseSnapshotHeader(headerLine)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("snapshot stat failed: %w", err)
+	}
+
+	payloadSize := info.Size() - int64(len(headerLine)) - int64(snapshotTrailerSize)
+	if payloadSize < 0 {
+		return fmt.Errorf("%w: file is truncated", entities.ErrSnapshotCorrupted)
+	}
+
+	trailer := make([]byte, snapshotTrailerSize)
+	if _, err = file.ReadAt(trailer, info.Size()-int64(snapshotTrailerSize)); err != nil {
+		return fmt.Errorf("snapshot read failed: %w", err)
+	}
+
+	var (
+		checksum uint32
+		length   int64
+	)
+
+	if _, err = fmt.Sscanf(string(trailer), snapshotTrailerFormat, &checksum, &length); err != nil || length != payloadSize {
+		return fmt.Errorf("%w: bad trailer", entities.ErrSnapshotCorrupted)
+	}
+
+	crc := crc32.New(snapshotCRCTable)
+	payload := io.TeeReader(io.LimitReader(reader, payloadSize), crc)
+
+	if err = decode(format, payload); err != nil {
+		return fmt.Errorf("%w: %w", entities.ErrSnapshotCorrupted, err)
+	}
+
+	// decoder may stop before the end of payload
+	if _, err = io.Copy(io.Discard, payload); err != nil {
+		return fmt.Errorf("snapshot read failed: %w", err)
+	}
+
+	if crc.Sum32() != checksum {
+		return fmt.Errorf("%w: checksum mismatch", entities.ErrSnapshotCorrupted)
+	}
+
+	return nil
+}
+
+// Parse "metflix-snapshot v1 format=binary" header line, snapshots without format are JSON.
+func parseSnapshotHeader(line string) (string, error) {
+	fields := strings.Fields(line)
+
+	if len(fields) < 2 || fields[1] != fmt.Sprintf("v%d", snapshotVersion) {
+		return "", fmt.Errorf("%w: unsupported header %q", entities.ErrSnapshotCorrupted, strings.TrimSpace(line))
+	}
+
+	format := SnapshotFormatJSON
+
+	for _, field := range fields[2:] {
+		if value, ok := strings.CutPrefix(field, "format="); ok {
+			format = value
+		}
+	}
+
+	if err := validateSnapshotFormat(format); err != nil {
+		return "", fmt.Errorf("%w: %w", entities.ErrSnapshotCorrupted, err)
+	}
+
+	return format, nil
+}
+
+func validateSnapshotFormat(format string) error {
+	switch format {
+	case SnapshotFormatJSON, SnapshotFormatBinary:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", entities.ErrBadSnapshotFormat, format)
+	}
+}
+
+// Encode storage content in given format.
+func encodeSnapshot(format string, w io.Writer, snapshot *MemStorage) error {
+	if format == SnapshotFormatBinary {
+		return encodeBinarySnapshot(w, snapshot)
+	}
+
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+// Decode storage content in given format into s.
+func decodeSnapshot(format string, r io.Reader, s *MemStorage) error {
+	if format == SnapshotFormatBinary {
+		return decodeBinarySnapshot(r, s)
+	}
+
+	return json.NewDecoder(r).Decode(s)
+}
+
+// Shift previous snapshots: path -> path.1 -> ... -> path.<keep>, older ones are removed.
+func rotateSnapshots(path string, keep int) error {
+	backups, err := snapshotBackups(path)
+	if err != nil {
+		return err
+	}
+
+	for i := len(backups) - 1; i >= 0; i-- {
+		n := backups[i]
+		if n >= keep {
+			if err := os.Remove(snapshotBackupPath(path, n)); err != nil {
+				return fmt.Errorf("snapshot removal failed: %w", err)
+			}
+
+			continue
+		}
+
+		if err := os.Rename(snapshotBackupPath(path, n), snapshotBackupPath(path, n+1)); err != nil {
+			return fmt.Errorf("snapshot rotation failed: %w", err)
+		}
+	}
+
+	if keep == 0 {
+		return nil
+	}
+
+	if err := os.Rename(path, snapshotBackupPath(path, 1)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("snapshot rotation failed: %w", err)
+	}
+
+	return nil
+}
+
+// Snapshot and its backups ordered from newest to oldest.
+func snapshotCandidates(path string) ([]string, error) {
+	backups, err := snapshotBackups(path)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{path}
+	for _, n := range backups {
+		candidates = append(candidates, snapshotBackupPath(path, n))
+	}
+
+	return candidates, nil
+}
+
+// Numbers of existing backups in ascending order.
+func snapshotBackups(path string) ([]int, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("snapshot lookup failed: %w", err)
+	}
+
+	backups := make([]int, 0, len(matches))
+	for _, match := range matches {
+		n, err := strconv.Atoi(strings.TrimPrefix(match, path+"."))
+		if err != nil || n <= 0 {
+			continue // WAL segment, temp file, etc
+		}
+
+		backups = append(backups, n)
+	}
+
+	sort.Ints(backups)
+
+	return backups, nil
+}
+
+func snapshotBackupPath(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// Persist renames in directory.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("snapshot dir open failed: %w", err)
+	}
+
+	defer func() { _ = d.Close() }()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("snapshot dir fsync failed: %w", err)
+	}
+
+	return nil
+}
+
+// Writer counting and checksumming payload.
+type checksumWriter struct {
+	w   io.Writer
+	crc hash.Hash32
+	n   int64
+}
+
+func (w *checksumWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+
+	w.crc.Write(p[:n])
+	w.n += int64(n)
+
+	return n, err
+}
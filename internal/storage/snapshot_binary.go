@@ -0,0 +1,388 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
+
+// Binary snapshot payload is a stream of tagged sections terminated by binaryTagEnd:
+//
+//	record:  tag id name labels value
+//	samples: tag id count (ts-delta value)...
+//	rollups: tag id count (ts-delta min max sum count)...
+//
+// Strings are uvarint length-prefixed, integers are varints, floats are 8 bytes little-endian,
+// timestamps are varint seconds since previous timestamp of the series (since epoch for the first one)
+// followed by uvarint nanoseconds within the second, so any time.Time fits unlike UnixNano.
+const (
+	binaryTagEnd byte = iota
+	binaryTagRecord
+	binaryTagSamples
+	binaryTagMinutes
+	binaryTagHours
+)
+
+const (
+	binaryKindCounter byte = iota + 1
+	binaryKindGauge
+	binaryKindHistogram
+)
+
+// Upper bound of decoded collection lengths, protects from allocating garbage sizes.
+const binaryMaxLen = 1 << 24
+
+type binaryEncoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+// Stream storage content in binary format.
+func encodeBinarySnapshot(w io.Writer, s *MemStorage) error {
+	e := &binaryEncoder{w: bufio.NewWriter(w)}
+
+	for id, record := range s.Data {
+		e.byte(binaryTagRecord)
+		e.string(id)
+		e.string(record.Name)
+		e.labels(record.Labels)
+		e.metric(record.Value)
+	}
+
+	for id, samples := range s.History {
+		e.byte(binaryTagSamples)
+		e.string(id)
+		e.uvarint(uint64(len(samples)))
+
+		var prev int64
+		for _, sample := range samples {
+			prev = e.timestamp(sample.Timestamp, prev)
+			e.metric(sample.Value)
+		}
+	}
+
+	e.rollups(binaryTagMinutes, s.Minutes)
+	e.rollups(binaryTagHours, s.Hours)
+
+	e.byte(binaryTagEnd)
+
+	if e.err != nil {
+		return e.err
+	}
+
+	return e.w.Flush()
+}
+
+func (e *binaryEncoder) rollups(tag byte, series map[string][]Rollup) {
+	for id, rollups := range series {
+		e.byte(tag)
+		e.string(id)
+		e.uvarint(uint64(len(rollups)))
+
+		var prev int64
+		for _, r := range rollups {
+			prev = e.timestamp(r.Timestamp, prev)
+			e.float(r.Min)
+			e.float(r.Max)
+			e.float(r.Sum)
+			e.uvarint(r.Count)
+		}
+	}
+}
+
+func (e *binaryEncoder) metric(value metrics.Metric) {
+	switch v := value.(type) {
+	case metrics.Counter:
+		e.byte(binaryKindCounter)
+		e.varint(int64(v))
+	case metrics.Gauge:
+		e.byte(binaryKindGauge)
+		e.float(float64(v))
+	case metrics.Histogram:
+		e.byte(binaryKindHistogram)
+		e.uvarint(uint64(len(v.Bounds)))
+		for _, b := range v.Bounds {
+			e.float(b)
+		}
+
+		e.uvarint(uint64(len(v.Counts)))
+		for _, c := range v.Counts {
+			e.uvarint(c)
+		}
+
+		e.uvarint(v.Count)
+		e.float(v.Sum)
+	default:
+		e.fail(fmt.Errorf("%w: %T", entities.ErrMetricUnknown, value))
+	}
+}
+
+func (e *binaryEncoder) labels(labels metrics.Labels) {
+	e.uvarint(uint64(len(labels)))
+
+	for k, v := range labels {
+		e.string(k)
+		e.string(v)
+	}
+}
+
+func (e *binaryEncoder) timestamp(ts time.Time, prev int64) int64 {
+	seconds := ts.Unix()
+	e.varint(seconds - prev) // wraps for far apart extremes, restored by the same wrap on decode
+	e.uvarint(uint64(ts.Nanosecond()))
+
+	return seconds
+}
+
+func (e *binaryEncoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+
+	if e.err == nil {
+		_, e.err = e.w.WriteString(s)
+	}
+}
+
+func (e *binaryEncoder) float(f float64) {
+	if e.err == nil {
+		binary.LittleEndian.PutUint64(e.buf[:8], math.Float64bits(f))
+		_, e.err = e.w.Write(e.buf[:8])
+	}
+}
+
+func (e *binaryEncoder) varint(v int64) {
+	if e.err == nil {
+		_, e.err = e.w.Write(binary.AppendVarint(e.buf[:0], v))
+	}
+}
+
+func (e *binaryEncoder) uvarint(v uint64) {
+	if e.err == nil {
+		_, e.err = e.w.Write(binary.AppendUvarint(e.buf[:0], v))
+	}
+}
+
+func (e *binaryEncoder) byte(b byte) {
+	if e.err == nil {
+		e.err = e.w.WriteByte(b)
+	}
+}
+
+func (e *binaryEncoder) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+type binaryDecoder struct {
+	r   *bufio.Reader
+	buf [8]byte
+	err error
+}
+
+// Read storage content streamed in binary format.
+func decodeBinarySnapshot(r io.Reader, s *MemStorage) error {
+	d := &binaryDecoder{r: bufio.NewReader(r)}
+
+	for d.err == nil {
+		tag := d.byte()
+		if d.err != nil {
+			break
+		}
+
+		switch tag {
+		case binaryTagEnd:
+			return nil
+		case binaryTagRecord:
+			id := d.string()
+			record := Record{Name: d.string(), Labels: d.labels(), Value: d.metric()}
+			s.Data[id] = record
+		case binaryTagSamples:
+			id := d.string()
+			samples := make([]Sample, d.length())
+
+			var prev int64
+			for i := range samples {
+				samples[i].Timestamp, prev = d.timestamp(prev)
+				samples[i].Value = d.metric()
+			}
+
+			s.History[id] = samples
+		case binaryTagMinutes:
+			id := d.string()
+			s.Minutes[id] = d.rollups()
+		case binaryTagHours:
+			id := d.string()
+			s.Hours[id] = d.rollups()
+		default:
+			d.fail(fmt.Errorf("unknown section tag %d", tag))
+		}
+	}
+
+	if errors.Is(d.err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return d.err
+}
+
+func (d *binaryDecoder) rollups() []Rollup {
+	rollups := make([]Rollup, d.length())
+
+	var prev int64
+	for i := range rollups {
+		rollups[i].Timestamp, prev = d.timestamp(prev)
+		rollups[i].Min = d.float()
+		rollups[i].Max = d.float()
+		rollups[i].Sum = d.float()
+		rollups[i].Count = d.uvarint()
+	}
+
+	return rollups
+}
+
+func (d *binaryDecoder) metric() metrics.Metric {
+	switch kind := d.byte(); kind {
+	case binaryKindCounter:
+		return metrics.Counter(d.varint())
+	case binaryKindGauge:
+		return metrics.Gauge(d.float())
+	case binaryKindHistogram:
+		h := metrics.Histogram{Bounds: make([]float64, d.length())}
+		for i := range h.Bounds {
+			h.Bounds[i] = d.float()
+		}
+
+		h.Counts = make([]uint64, d.length())
+		for i := range h.Counts {
+			h.Counts[i] = d.uvarint()
+		}
+
+		h.Count = d.uvarint()
+		h.Sum = d.float()
+
+		if d.err == nil {
+			d.fail(h.Validate())
+		}
+
+		return h
+	default:
+		d.fail(fmt.Errorf("%w: kind %d", entities.ErrMetricUnknown, kind))
+		return nil
+	}
+}
+
+func (d *binaryDecoder) labels() metrics.Labels {
+	n := d.length()
+	if n == 0 {
+		return nil
+	}
+
+	labels := make(metrics.Labels, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		k := d.string()
+		labels[k] = d.string()
+	}
+
+	return labels
+}
+
+func (d *binaryDecoder) timestamp(prev int64) (time.Time, int64) {
+	seconds := prev + d.varint()
+
+	nanos := d.uvarint()
+	if nanos >= uint64(time.Second) {
+		d.fail(fmt.Errorf("nanoseconds %d are out of range", nanos))
+	}
+
+	return time.Unix(seconds, int64(nanos)).UTC(), seconds
+}
+
+func (d *binaryDecoder) string() string {
+	n := d.length()
+	if d.err != nil || n == 0 {
+		return ""
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.fail(err)
+		return ""
+	}
+
+	return string(b)
+}
+
+// Collection length, zero after error so loops over it stop.
+func (d *binaryDecoder) length() int {
+	n := d.uvarint()
+	if d.err != nil {
+		return 0
+	}
+
+	if n > binaryMaxLen {
+		d.fail(fmt.Errorf("length %d is out of range", n))
+		return 0
+	}
+
+	return int(n)
+}
+
+func (d *binaryDecoder) float() float64 {
+	if d.err != nil {
+		return 0
+	}
+
+	if _, err := io.ReadFull(d.r, d.buf[:]); err != nil {
+		d.fail(err)
+		return 0
+	}
+
+	return math.Float64frombits(binary.LittleEndian.Uint64(d.buf[:]))
+}
+
+func (d *binaryDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, err := binary.ReadVarint(d.r)
+	d.fail(err)
+
+	return v
+}
+
+func (d *binaryDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, err := binary.ReadUvarint(d.r)
+	d.fail(err)
+
+	return v
+}
+
+func (d *binaryDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+
+	b, err := d.r.ReadByte()
+	d.fail(err)
+
+	return b
+}
+
+func (d *binaryDecoder) fail(err error) {
+	if d.err == nil && err != nil {
+		d.err = err
+	}
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func newBinaryTestStorage() *MemStorage {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	histogram := metrics.NewHistogram(1, 5)
+	histogram.Observe(3)
+
+	s := NewMemStorage()
+	s.Data["PollCount_counter"] = Record{Name: "PollCount", Value: metrics.Counter(-5)}
+	s.Data["Alloc_gauge{host=\"a\"}"] = Record{Name: "Alloc", Value: metrics.Gauge(1.5), Labels: metrics.Labels{"host": "a"}}
+	s.Data["Latency_histogram"] = Record{Name: "Latency", Value: histogram}
+	s.History["PollCount_counter"] = []Sample{
+		{Timestamp: ts, Value: metrics.Counter(1)},
+		{Timestamp: ts.Add(time.Second), Value: metrics.Counter(-5)},
+	}
+	s.Minutes["PollCount_counter"] = []Rollup{{Timestamp: ts, Min: 1, Max: 2, Sum: 3, Count: 2}}
+	s.Hours["PollCount_counter"] = []Rollup{{Timestamp: ts, Min: -5, Max: 2, Sum: -2, Count: 3}}
+
+	return s
+}
+
+func TestBinarySnapshot_RoundTrip(t *testing.T) {
+	expected := newBinaryTestStorage()
+
+	var buf bytes.Buffer
+	require.NoError(t, encodeBinarySnapshot(&buf, expected))
+
+	restored := NewMemStorage()
+	require.NoError(t, decodeBinarySnapshot(&buf, restored))
+
+	require.Equal(t, expected.Data, restored.Data)
+	require.Equal(t, expected.History, restored.History)
+	require.Equal(t, expected.Minutes, restored.Minutes)
+	require.Equal(t, expected.Hours, restored.Hours)
+}
+
+func TestBinarySnapshot_TimestampRange(t *testing.T) {
+	// outside of 1678-2262 UnixNano is undefined
+	expected := NewMemStorage()
+	expected.History["PollCount_counter"] = []Sample{
+		{Timestamp: time.Date(1, 1, 1, 0, 0, 0, 1, time.UTC), Value: metrics.Counter(1)},
+		{Timestamp: time.Date(1600, 6, 1, 0, 0, 0, 0, time.UTC), Value: metrics.Counter(2)},
+		{Timestamp: time.Date(2024, 1, 1, 10, 0, 0, 999999999, time.UTC), Value: metrics.Counter(3)},
+		{Timestamp: time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC), Value: metrics.Counter(4)},
+	}
+	expected.Minutes["PollCount_counter"] = []Rollup{{Timestamp: time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC), Count: 1}}
+
+	var buf bytes.Buffer
+	require.NoError(t, encodeBinarySnapshot(&buf, expected))
+
+	restored := NewMemStorage()
+	require.NoError(t, decodeBinarySnapshot(&buf, restored))
+
+	require.Equal(t, expected.History, restored.History)
+	require.Equal(t, expected.Minutes, restored.Minutes)
+}
+
+func TestBinarySnapshot_Truncated(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, encodeBinarySnapshot(&buf, newBinaryTestStorage()))
+
+	data := buf.Bytes()
+	for _, size := range []int{0, 1, len(data) / 2, len(data) - 1} {
+		err := decodeBinarySnapshot(bytes.NewReader(data[:size]), NewMemStorage())
+		require.Error(t, err, "size %d", size)
+	}
+}
+
+func TestBinarySnapshot_BadKind(t *testing.T) {
+	// record "a" named "a" without labels and with unknown kind
+	data := []byte{binaryTagRecord, 1, 'a', 1, 'a', 0, 42}
+
+	err := decodeBinarySnapshot(bytes.NewReader(data), NewMemStorage())
+	require.ErrorIs(t, err, entities.ErrMetricUnknown)
+}
+
+func TestFileStorage_SnapshotFormat(t *testing.T) {
+	ctx := context.Background()
+	record := Record{Name: "Alloc", Value: metrics.Gauge(1.5), Labels: metrics.Labels{"host": "a"}}
+
+	tests := []struct {
+		name    string
+		written string
+		read    string
+	}{
+		{name: "binary", written: SnapshotFormatBinary, read: SnapshotFormatBinary},
+		{name: "json to binary", written: SnapshotFormatJSON, read: SnapshotFormatBinary},
+		{name: "binary to json", written: SnapshotFormatBinary, read: SnapshotFormatJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store.db")
+
+			fs1, err := NewFileStorage(path, 0, false, WithSnapshotFormat(tt.written))
+			require.NoError(t, err)
+			require.NoError(t, fs1.Push(ctx, record.CalculateRecordID(), record))
+
+			// format is detected from the snapshot itself
+			fs2, err := NewFileStorage(path, 0, true, WithSnapshotFormat(tt.read))
+			require.NoError(t, err)
+
+			restored, err := fs2.Get(ctx, record.CalculateRecordID())
+			require.NoError(t, err)
+			require.Equal(t, record.Value, restored.Value)
+			require.Equal(t, record.Labels, restored.Labels)
+		})
+	}
+}
+
+func TestFileStorage_BadSnapshotFormat(t *testing.T) {
+	_, err := NewFileStorage(filepath.Join(t.TempDir(), "store.db"), 0, false, WithSnapshotFormat("xml"))
+	require.ErrorIs(t, err, entities.ErrBadSnapshotFormat)
+}
+
+func BenchmarkSnapshotEncoding(b *testing.B) {
+	s := NewMemStorage()
+	now := time.Now()
+
+	for i := 0; i < 10000; i++ {
+		record := Record{Name: fmt.Sprintf("metric%d", i), Value: metrics.Gauge(float64(i) / 3)}
+		s.push(record.CalculateRecordID(), record, now)
+	}
+
+	formats := map[string]func(w io.Writer) error{
+		SnapshotFormatJSON:   func(w io.Writer) error { return json.NewEncoder(w).Encode(s) },
+		SnapshotFormatBinary: func(w io.Writer) error { return encodeBinarySnapshot(w, s) },
+	}
+
+	for format, encode := range formats {
+		b.Run(format, func(b *testing.B) {
+			var buf bytes.Buffer
+
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := encode(&buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(buf.Len()), "bytes/snapshot")
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestSnapshot(t *testing.T, path string, keep int, payload string) {
+	err := writeSnapshotFile(path, keep, SnapshotFormatJSON, func(w io.Writer) error {
+		_, err := io.WriteString(w, payload)
+		return err
+	})
+	require.NoError(t, err)
+}
+
+func readTestSnapshot(path string) (string, error) {
+	var payload strings.Builder
+
+	err := readSnapshotFile(path, func(_ string, r io.Reader) error {
+		_, err := io.Copy(&payload, r)
+		return err
+	})
+
+	return payload.String(), err
+}
+
+func TestSnapshotFile_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+
+	writeTestSnapshot(t, path, 0, `{"records":{}}`)
+
+	payload, err := readTestSnapshot(path)
+	require.NoError(t, err)
+	require.Equal(t, `{"records":{}}`, payload)
+
+	_, err = os.Stat(path + ".tmp")
+	require.True(t, os.IsNotExist(err), "temp file must be renamed")
+}
+
+func TestSnapshotFile_Corrupted(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+	}{
+		{
+			name:    "flipped byte",
+			corrupt: func(data []byte) []byte { data[bytes.IndexByte(data, '\n')+3] ^= 0xff; return data },
+		},
+		{
+			name:    "truncated",
+			corrupt: func(data []byte) []byte { return data[:len(data)-10] },
+		},
+		{
+			name:    "unsupported version",
+			corrupt: func(data []byte) []byte { return []byte(strings.Replace(string(data), " v1 ", " v9 ", 1)) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store.json")
+			writeTestSnapshot(t, path, 0, `{"records":{"a":1}}`)
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, tt.corrupt(data), 0600))
+
+			_, err = readTestSnapshot(path)
+			require.ErrorIs(t, err, entities.ErrSnapshotCorrupted)
+		})
+	}
+}
+
+func TestSnapshotFile_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+
+	for i := 1; i <= 5; i++ {
+		writeTestSnapshot(t, path, 2, fmt.Sprintf("snapshot %d", i))
+	}
+
+	candidates, err := snapshotCandidates(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{path, path + ".1", path + ".2"}, candidates)
+
+	for i, candidate := range candidates {
+		payload, err := readTestSnapshot(candidate)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("snapshot %d", 5-i), payload)
+	}
+}
+
+func TestFileStorage_RestoreFallback(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.json")
+
+	fs1, err := NewFileStorage(path, 0, false, WithSnapshotsKept(2))
+	require.NoError(t, err)
+
+	first := Record{Name: "first", Value: metrics.Counter(1)}
+	second := Record{Name: "second", Value: metrics.Counter(2)}
+
+	require.NoError(t, fs1.Push(ctx, first.CalculateRecordID(), first))
+	require.NoError(t, fs1.dump())
+	require.NoError(t, fs1.Push(ctx, second.CalculateRecordID(), second))
+	require.NoError(t, fs1.dump())
+
+	// newest snapshot is damaged, previous one contains only the first record
+	require.NoError(t, os.WriteFile(path, []byte(snapshotMagic+" v1\n{garbage"), 0600))
+
+	fs2, err := NewFileStorage(path, 0, true)
+	require.NoError(t, err)
+
+	_, err = fs2.Get(ctx, first.CalculateRecordID())
+	require.NoError(t, err)
+
+	_, err = fs2.Get(ctx, second.CalculateRecordID())
+	require.ErrorIs(t, err, entities.ErrRecordNotFound)
+}
+
+func TestFileStorage_RestoreNoValidSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	require.NoError(t, os.WriteFile(path, []byte(snapshotMagic+" v1\n{garbage"), 0600))
+
+	_, err := NewFileStorage(path, 0, true)
+	require.ErrorIs(t, err, entities.ErrSnapshotCorrupted)
+}
+
+func TestFileStorage_RestoreLegacySnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.json")
+
+	// snapshots were written as plain JSON before versioning
+	legacy := NewMemStorage()
+	record := Record{Name: "test", Value: metrics.Counter(42)}
+	require.NoError(t, legacy.Push(ctx, record.CalculateRecordID(), record))
+
+	data, err := json.Marshal(legacy)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	fs, err := NewFileStorage(path, 0, true)
+	require.NoError(t, err)
+
+	restored, err := fs.Get(ctx, record.CalculateRecordID())
+	require.NoError(t, err)
+	require.Equal(t, record.Value, restored.Value)
+}
@@ -1,6 +1,12 @@
 package storage
 
-import "context"
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
 
 // Kinds of storage
 const (
@@ -14,22 +20,69 @@ type MetricsStorage interface {
 	Push(ctx context.Context, id string, record Record) error
 	PushList(ctx context.Context, data map[string]Record) error
 	Get(ctx context.Context, id string) (Record, error)
-	List(ctx context.Context) ([]Record, error)
+	List(ctx context.Context, filter ListFilter) ([]Record, error)
+	Delete(ctx context.Context, id string) error
+	Range(ctx context.Context, id string, from, to time.Time) ([]Sample, error)
+	RangeRollups(ctx context.Context, id string, resolution time.Duration, from, to time.Time) ([]Rollup, error)
 	Close(ctx context.Context) error
 }
 
+// Storage applying counter increments atomically, so concurrent writers don't lose updates.
+type IncrementingStorage interface {
+	// Add counter deltas to stored values (missing records start from zero), returns resulting records.
+	Increment(ctx context.Context, data map[string]Record) (map[string]Record, error)
+}
+
+// Named check of storage readiness.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Storage reporting detailed readiness checks.
+type CheckableStorage interface {
+	HealthChecks() []HealthCheck
+}
+
+// Filter for List(), empty fields match any record.
+// Labels filter matches records having all given labels.
+type ListFilter struct {
+	Name       string
+	NamePrefix string
+	Kind       string
+	Labels     metrics.Labels
+}
+
+// Check if record satisfies filter
+func (f ListFilter) Match(record Record) bool {
+	if len(f.Name) > 0 && record.Name != f.Name {
+		return false
+	}
+
+	if len(f.NamePrefix) > 0 && !strings.HasPrefix(record.Name, f.NamePrefix) {
+		return false
+	}
+
+	if len(f.Kind) > 0 && record.Value.Kind() != f.Kind {
+		return false
+	}
+
+	return record.Labels.Match(f.Labels)
+}
+
 func NewStorage(
 	databaseDSN string,
 	storePath string,
 	storeInterval int,
 	restoreOnStart bool,
+	opts ...Option,
 ) (MetricsStorage, error) {
 	switch {
 	case databaseDSN != "":
-		return NewPostgresStorage(databaseDSN)
+		return NewPostgresStorage(databaseDSN, opts...)
 	case storePath != "":
-		return NewFileStorage(storePath, storeInterval, restoreOnStart)
+		return NewFileStorage(storePath, storeInterval, restoreOnStart, opts...)
 	default:
-		return NewMemStorage(), nil
+		return NewMemStorage(opts...), nil
 	}
 }
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -32,8 +33,8 @@ func (m *StorageMock) PushList(ctx context.Context, data map[string]Record) erro
 }
 
 // List records
-func (m *StorageMock) List(ctx context.Context) ([]Record, error) {
-	args := m.Called(ctx)
+func (m *StorageMock) List(ctx context.Context, filter ListFilter) ([]Record, error) {
+	args := m.Called(ctx, filter)
 
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -42,6 +43,34 @@ func (m *StorageMock) List(ctx context.Context) ([]Record, error) {
 	return args.Get(0).([]Record), args.Error(1)
 }
 
+// Delete record
+func (m *StorageMock) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// Range of samples
+func (m *StorageMock) Range(ctx context.Context, id string, from, to time.Time) ([]Sample, error) {
+	args := m.Called(ctx, id, from, to)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]Sample), args.Error(1)
+}
+
+// Range of rollups
+func (m *StorageMock) RangeRollups(ctx context.Context, id string, resolution time.Duration, from, to time.Time) ([]Rollup, error) {
+	args := m.Called(ctx, id, resolution, from, to)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]Rollup), args.Error(1)
+}
+
 // Close storage
 func (m *StorageMock) Close(ctx context.Context) error {
 	args := m.Called(ctx)
@@ -0,0 +1,343 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/internal/logging"
+)
+
+// Fsync policies of write-ahead log.
+const (
+	WALSyncAlways   = "always"   // fsync after every write, nothing is lost on power failure
+	WALSyncPeriodic = "periodic" // fsync every walSyncInterval, up to a second of writes may be lost on power failure
+	WALSyncNever    = "never"    // leave flushing to OS, survives process crash only
+)
+
+const walSyncInterval = time.Second
+
+// Operations logged to WAL.
+const (
+	walOpPush   = "push"
+	walOpDelete = "delete"
+)
+
+// Single WAL line: pushed records stamped with time of push or deleted id.
+type walEntry struct {
+	Op      string      `json:"op"`
+	Time    time.Time   `json:"ts"`
+	Records []walRecord `json:"records,omitempty"`
+	ID      string      `json:"id,omitempty"`
+}
+
+type walRecord struct {
+	ID       string    `json:"id"`
+	Record   Record    `json:"record"`
+	Observed time.Time `json:"observed"` // Record.Timestamp, not serialized by Record itself
+}
+
+func newWALPushEntry(data map[string]Record, now time.Time) walEntry {
+	entry := walEntry{Op: walOpPush, Time: now, Records: make([]walRecord, 0, len(data))}
+
+	for id, record := range data {
+		entry.Records = append(entry.Records, walRecord{ID: id, Record: record, Observed: record.Timestamp})
+	}
+
+	return entry
+}
+
+// Append-only log of storage changes split into numbered segments <prefix>.<n>.
+// Snapshot makes older segments obsolete: writer rotates to a new segment, dumps and removes previous ones.
+type wal struct {
+	sync.Mutex
+
+	prefix  string
+	policy  string
+	segment int
+	file    *os.File
+	dirty   bool // written since last fsync
+
+	done chan struct{}
+}
+
+// Open new segment after existing ones, existing segments are kept for replay.
+func openWAL(prefix, policy string) (*wal, error) {
+	switch policy {
+	case WALSyncAlways, WALSyncPeriodic, WALSyncNever:
+	default:
+		return nil, fmt.Errorf("%w: %q", entities.ErrBadWALSyncPolicy, policy)
+	}
+
+	segments, err := walSegments(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &wal{prefix: prefix, policy: policy, done: make(chan struct{})}
+
+	next := 1
+	if len(segments) > 0 {
+		next = segments[len(segments)-1] + 1
+	}
+
+	if err := w.openSegment(next); err != nil {
+		return nil, err
+	}
+
+	if policy == WALSyncPeriodic {
+		go w.startSyncing()
+	}
+
+	return w, nil
+}
+
+// Append entry, synced according to policy.
+func (w *wal) append(entry walEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("wal entry marshaling failed: %w", err)
+	}
+
+	line = append(line, '\n')
+
+	w.Lock()
+	defer w.Unlock()
+
+	if _, err := w.file.Write(line); err != nil {
+		return fmt.Errorf("wal write failed: %w", err)
+	}
+
+	if w.policy == WALSyncAlways {
+		return w.syncFile()
+	}
+
+	w.dirty = true
+
+	return nil
+}
+
+// Switch to a new segment, returns number of the new one: all before it may be removed once snapshot is written.
+func (w *wal) rotate() (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if err := w.closeSegment(); err != nil {
+		return 0, err
+	}
+
+	if err := w.openSegment(w.segment + 1); err != nil {
+		return 0, err
+	}
+
+	return w.segment, nil
+}
+
+// Remove segments preceding given one.
+func (w *wal) removeBefore(segment int) error {
+	segments, err := walSegments(w.prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range segments {
+		if n >= segment {
+			break
+		}
+
+		if err := os.Remove(walSegmentPath(w.prefix, n)); err != nil {
+			return fmt.Errorf("wal segment removal failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Stop syncing and close current segment, removing all segments if they are covered by snapshot.
+func (w *wal) close(covered bool) error {
+	close(w.done)
+
+	w.Lock()
+	err := w.closeSegment()
+	w.Unlock()
+
+	if err != nil || !covered {
+		return err
+	}
+
+	return w.removeBefore(w.segment + 1)
+}
+
+func (w *wal) startSyncing() {
+	ticker := time.NewTicker(walSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.Lock()
+			if w.dirty {
+				if err := w.syncFile(); err != nil {
+					logging.LogError(err)
+				}
+			}
+			w.Unlock()
+		}
+	}
+}
+
+// Must be called under lock.
+func (w *wal) openSegment(n int) error {
+	file, err := os.OpenFile(walSegmentPath(w.prefix, n), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("wal segment open failed: %w", err)
+	}
+
+	w.file = file
+	w.segment = n
+	w.dirty = false
+
+	return nil
+}
+
+// Must be called under lock.
+func (w *wal) closeSegment() error {
+	if err := w.syncFile(); err != nil {
+		return err
+	}
+
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("wal segment close failed: %w", err)
+	}
+
+	return nil
+}
+
+// Must be called under lock.
+func (w *wal) syncFile() error {
+	if w.policy == WALSyncNever {
+		return nil
+	}
+
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("wal fsync failed: %w", err)
+	}
+
+	w.dirty = false
+
+	return nil
+}
+
+// Replay entries of every segment in order.
+// Segment truncated in the middle of a line (crash during write) is replayed up to the broken line.
+func replayWAL(prefix string, apply func(walEntry)) (int, error) {
+	segments, err := walSegments(prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+
+	for _, n := range segments {
+		count, err := replayWALSegment(walSegmentPath(prefix, n), apply)
+		replayed += count
+
+		if err != nil {
+			return replayed, err
+		}
+	}
+
+	return replayed, nil
+}
+
+func replayWALSegment(path string, apply func(walEntry)) (count int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("wal segment open failed: %w", err)
+	}
+
+	defer func() {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("wal segment close failed: %w", closeErr)
+		}
+	}()
+
+	reader := bufio.NewReader(file)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				logging.LogWarn("wal segment " + path + " ends with incomplete entry, skipped")
+			}
+
+			return count, nil
+		}
+
+		if err != nil {
+			return count, fmt.Errorf("wal segment read failed: %w", err)
+		}
+
+		var entry walEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return count, fmt.Errorf("wal segment %s is corrupted: %w", path, err)
+		}
+
+		apply(entry)
+		count++
+	}
+}
+
+// Remove every segment, e.g. when state is not restored from them.
+func removeWAL(prefix string) error {
+	segments, err := walSegments(prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range segments {
+		if err := os.Remove(walSegmentPath(prefix, n)); err != nil {
+			return fmt.Errorf("wal segment removal failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Numbers of existing segments in ascending order.
+func walSegments(prefix string) ([]int, error) {
+	matches, err := filepath.Glob(prefix + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("wal segments lookup failed: %w", err)
+	}
+
+	segments := make([]int, 0, len(matches))
+	for _, match := range matches {
+		n, err := strconv.Atoi(strings.TrimPrefix(match, prefix+"."))
+		if err != nil {
+			continue // not a segment
+		}
+
+		segments = append(segments, n)
+	}
+
+	sort.Ints(segments)
+
+	return segments, nil
+}
+
+func walSegmentPath(prefix string, n int) string {
+	return fmt.Sprintf("%s.%06d", prefix, n)
+}
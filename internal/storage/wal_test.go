@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ex0rcist/metflix/internal/entities"
+	"github.com/ex0rcist/metflix/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+// Storage which never dumps by itself, so state survives only via WAL.
+func newWALFileStorage(t *testing.T, storePath string, restore bool) *FileStorage {
+	fs, err := NewFileStorage(storePath, 3600, restore, WithWALSync(WALSyncAlways))
+	require.NoError(t, err)
+
+	return fs
+}
+
+// Simulate crash: stop background work, nothing is dumped.
+func crash(fs *FileStorage) {
+	fs.dumpTicker.Stop()
+	_ = fs.wal.close(false)
+}
+
+func TestFileStorage_WALReplay(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "store.json")
+
+	fs1 := newWALFileStorage(t, storePath, true)
+
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	counter := Record{Name: "PollCount", Value: metrics.Counter(5), Timestamp: ts}
+	gauge := Record{Name: "Alloc", Value: metrics.Gauge(1.5)}
+	deleted := Record{Name: "Tmp", Value: metrics.Gauge(1)}
+
+	require.NoError(t, fs1.Push(ctx, counter.CalculateRecordID(), counter))
+	require.NoError(t, fs1.PushList(ctx, map[string]Record{
+		gauge.CalculateRecordID():   gauge,
+		deleted.CalculateRecordID(): deleted,
+	}))
+	require.NoError(t, fs1.Delete(ctx, deleted.CalculateRecordID()))
+	crash(fs1)
+
+	_, err := os.Stat(storePath)
+	require.True(t, os.IsNotExist(err), "nothing should be dumped yet")
+
+	fs2 := newWALFileStorage(t, storePath, true)
+	defer crash(fs2)
+
+	records, err := fs2.List(ctx, ListFilter{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []Record{counter, gauge}, records)
+
+	samples, err := fs2.Range(ctx, counter.CalculateRecordID(), ts, ts)
+	require.NoError(t, err)
+	require.Equal(t, []Sample{{Timestamp: ts, Value: metrics.Counter(5)}}, samples)
+}
+
+func TestFileStorage_DumpTruncatesWAL(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "store.json")
+
+	fs1 := newWALFileStorage(t, storePath, true)
+
+	first := Record{Name: "first", Value: metrics.Counter(1)}
+	require.NoError(t, fs1.Push(ctx, first.CalculateRecordID(), first))
+	require.NoError(t, fs1.dump())
+
+	segments, err := walSegments(fs1.walPrefix())
+	require.NoError(t, err)
+	require.Equal(t, []int{fs1.wal.segment}, segments, "only current segment is kept")
+
+	// logged after snapshot
+	second := Record{Name: "second", Value: metrics.Counter(2)}
+	require.NoError(t, fs1.Push(ctx, second.CalculateRecordID(), second))
+	crash(fs1)
+
+	fs2 := newWALFileStorage(t, storePath, true)
+
+	records, err := fs2.List(ctx, ListFilter{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []Record{first, second}, records)
+
+	// clean close leaves snapshot only
+	require.NoError(t, fs2.Close(ctx))
+
+	segments, err = walSegments(fs2.walPrefix())
+	require.NoError(t, err)
+	require.Empty(t, segments)
+}
+
+func TestFileStorage_WALDiscardedWithoutRestore(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "store.json")
+
+	fs1 := newWALFileStorage(t, storePath, true)
+
+	record := Record{Name: "test", Value: metrics.Counter(1)}
+	require.NoError(t, fs1.Push(ctx, record.CalculateRecordID(), record))
+	crash(fs1)
+
+	fs2 := newWALFileStorage(t, storePath, false)
+	crash(fs2)
+
+	fs3 := newWALFileStorage(t, storePath, true)
+	defer crash(fs3)
+
+	_, err := fs3.Get(ctx, record.CalculateRecordID())
+	require.ErrorIs(t, err, entities.ErrRecordNotFound)
+}
+
+func TestFileStorage_WALWithSyncDumps(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "store.json")
+
+	fs1 := newWALFileStorage(t, storePath, true)
+
+	record := Record{Name: "test", Value: metrics.Gauge(1)}
+	require.NoError(t, fs1.Push(ctx, record.CalculateRecordID(), record))
+	crash(fs1)
+
+	// switched to saving every change: log of previous run is replayed, new changes are logged without dumps
+	fs2, err := NewFileStorage(storePath, 0, true)
+	require.NoError(t, err)
+
+	restored, err := fs2.Get(ctx, record.CalculateRecordID())
+	require.NoError(t, err)
+	require.Equal(t, metrics.Gauge(1), restored.Value)
+
+	record.Value = metrics.Gauge(2)
+	require.NoError(t, fs2.Push(ctx, record.CalculateRecordID(), record))
+	crash(fs2)
+
+	_, err = os.Stat(storePath)
+	require.True(t, os.IsNotExist(err), "change must not be dumped")
+
+	fs3, err := NewFileStorage(storePath, 0, true)
+	require.NoError(t, err)
+
+	restored, err = fs3.Get(ctx, record.CalculateRecordID())
+	require.NoError(t, err)
+	require.Equal(t, metrics.Gauge(2), restored.Value)
+
+	// log is covered by snapshot on close and must not be replayed over newer snapshots
+	require.NoError(t, fs3.Close(ctx))
+
+	segments, err := walSegments(fs3.walPrefix())
+	require.NoError(t, err)
+	require.Empty(t, segments)
+}
+
+func TestReplayWAL_IncompleteEntry(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "store.json")
+
+	fs1 := newWALFileStorage(t, storePath, true)
+
+	record := Record{Name: "test", Value: metrics.Counter(1)}
+	require.NoError(t, fs1.Push(ctx, record.CalculateRecordID(), record))
+	crash(fs1)
+
+	// torn write at the end of segment
+	file, err := os.OpenFile(walSegmentPath(fs1.walPrefix(), fs1.wal.segment), os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"op":"push","ts":`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	fs2 := newWALFileStorage(t, storePath, true)
+	defer crash(fs2)
+
+	restored, err := fs2.Get(ctx, record.CalculateRecordID())
+	require.NoError(t, err)
+	require.Equal(t, record, restored)
+}
+
+func TestOpenWAL_BadPolicy(t *testing.T) {
+	_, err := NewFileStorage(filepath.Join(t.TempDir(), "store.json"), 300, false, WithWALSync("sometimes"))
+	require.ErrorIs(t, err, entities.ErrBadWALSyncPolicy)
+}
+
+func TestFileStorage_WALReplayIncrements(t *testing.T) {
+	ctx := context.Background()
+	storePath := filepath.Join(t.TempDir(), "store.json")
+
+	fs1 := newWALFileStorage(t, storePath, true)
+
+	record := Record{Name: "PollCount", Value: metrics.Counter(2)}
+	id := record.CalculateRecordID()
+
+	for i := 0; i < 3; i++ {
+		_, err := fs1.Increment(ctx, map[string]Record{id: record})
+		require.NoError(t, err)
+	}
+
+	crash(fs1)
+
+	fs2 := newWALFileStorage(t, storePath, true)
+	defer crash(fs2)
+
+	stored, err := fs2.Get(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, metrics.Counter(6), stored.Value)
+}
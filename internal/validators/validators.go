@@ -7,7 +7,10 @@ import (
 	"github.com/ex0rcist/metflix/pkg/metrics"
 )
 
-var nameRegexp = regexp.MustCompile(`^[A-Za-z\d]+$`)
+var (
+	nameRegexp  = regexp.MustCompile(`^[A-Za-z\d_:.\-]+$`)
+	labelRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z\d_]*$`)
+)
 
 // Ensure metric is valid
 func ValidateMetric(name, kind string) error {
@@ -15,13 +18,24 @@ func ValidateMetric(name, kind string) error {
 		return err
 	}
 
-	if err := validateMetricKind(kind); err != nil {
+	if err := ValidateMetricKind(kind); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// Ensure metric labels are valid: names are identifiers, values are not empty
+func ValidateLabels(labels metrics.Labels) error {
+	for name, value := range labels {
+		if !labelRegexp.MatchString(name) || len(value) == 0 {
+			return entities.ErrMetricInvalidLabel
+		}
+	}
+
+	return nil
+}
+
 func validateMetricName(name string) error {
 	if len(name) == 0 {
 		return entities.ErrMetricMissingName
@@ -34,9 +48,10 @@ func validateMetricName(name string) error {
 	return nil
 }
 
-func validateMetricKind(kind string) error {
+// Ensure metric kind is known
+func ValidateMetricKind(kind string) error {
 	switch kind {
-	case metrics.KindCounter, metrics.KindGauge:
+	case metrics.KindCounter, metrics.KindGauge, metrics.KindHistogram:
 		return nil
 
 	default:
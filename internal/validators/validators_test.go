@@ -19,6 +19,8 @@ func TestValidateMetric(t *testing.T) {
 	}{
 		{name: "correct counter", args: args{name: "testname", kind: metrics.KindCounter}, wantErr: false},
 		{name: "correct gauge", args: args{name: "testname", kind: metrics.KindGauge}, wantErr: false},
+		{name: "correct histogram", args: args{name: "testname", kind: metrics.KindHistogram}, wantErr: false},
+		{name: "correct dotted name", args: args{name: "http.server_requests:total-2", kind: metrics.KindCounter}, wantErr: false},
 
 		{name: "name not present", args: args{name: "", kind: metrics.KindCounter}, wantErr: true},
 		{name: "incorrect name", args: args{name: "некорректноеимя", kind: metrics.KindCounter}, wantErr: true},
@@ -34,3 +36,26 @@ func TestValidateMetric(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  metrics.Labels
+		wantErr bool
+	}{
+		{name: "no labels", labels: nil, wantErr: false},
+		{name: "correct labels", labels: metrics.Labels{"host": "a.example.com", "_env": "prod"}, wantErr: false},
+
+		{name: "empty value", labels: metrics.Labels{"host": ""}, wantErr: true},
+		{name: "empty name", labels: metrics.Labels{"": "a"}, wantErr: true},
+		{name: "incorrect name", labels: metrics.Labels{"1host": "a"}, wantErr: true},
+		{name: "incorrect name", labels: metrics.Labels{"host name": "a"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validators.ValidateLabels(tt.labels); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLabels() error = %v, wantErr %v", (err != nil), tt.wantErr)
+			}
+		})
+	}
+}
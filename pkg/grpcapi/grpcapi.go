@@ -1,6 +1,10 @@
 package grpcapi
 
-import "github.com/ex0rcist/metflix/pkg/metrics"
+import (
+	"strconv"
+
+	"github.com/ex0rcist/metflix/pkg/metrics"
+)
 
 func NewUpdateCounterMex(name string, value metrics.Counter) *MetricExchange {
 	return &MetricExchange{Id: name, Mtype: value.Kind(), Delta: int64(value)}
@@ -9,3 +13,33 @@ func NewUpdateCounterMex(name string, value metrics.Counter) *MetricExchange {
 func NewUpdateGaugeMex(name string, value metrics.Gauge) *MetricExchange {
 	return &MetricExchange{Id: name, Mtype: value.Kind(), Value: float64(value)}
 }
+
+func NewUpdateHistogramMex(name string, value metrics.Histogram) *MetricExchange {
+	return &MetricExchange{Id: name, Mtype: value.Kind(), Histogram: NewHistogram(value)}
+}
+
+// Convert metrics.Histogram to protobuf message.
+func NewHistogram(value metrics.Histogram) *Histogram {
+	return &Histogram{Bounds: value.Bounds, Counts: value.Counts, Count: value.Count, Sum: value.Sum}
+}
+
+// Convert protobuf message to metrics.Histogram.
+func (h *Histogram) ToMetric() metrics.Histogram {
+	return metrics.Histogram{Bounds: h.GetBounds(), Counts: h.GetCounts(), Count: h.GetCount(), Sum: h.GetSum()}
+}
+
+// Header sent by server when StreamUpdate stream is accepted,
+// lets clients tell it from servers not implementing the method.
+const StreamReadyHeader = "x-stream-ready"
+
+// Header with client-generated ID of StreamUpdate stream. Batches of the stream are identified by it
+// and their number (see BatchID), so server doesn't store batch resent in unary request twice.
+const StreamIDHeader = "x-stream-id"
+
+// Header with ID of batch sent in BatchUpdate or BatchUpdateEncrypted request, if it was sent into stream before.
+const BatchIDHeader = "x-batch-id"
+
+// ID of n-th batch of the stream, counting from 1.
+func BatchID(streamID string, n uint64) string {
+	return streamID + "/" + strconv.FormatUint(n, 10)
+}
@@ -0,0 +1,42 @@
+package metrics
+
+// Descriptive information about metric, shared by all its series.
+type Metadata struct {
+	Kind string `json:"type,omitempty"` // metric kind declared by source, if any
+	Help string `json:"help,omitempty"`
+}
+
+// Metadata of the metrics reported by agent.
+var AgentMetadata = map[string]Metadata{
+	"Alloc":         {Help: "Bytes of allocated heap objects."},
+	"BuckHashSys":   {Help: "Bytes of memory in profiling bucket hash tables."},
+	"Frees":         {Help: "Cumulative count of heap objects freed."},
+	"GCCPUFraction": {Help: "Fraction of available CPU time used by the GC since the program started."},
+	"GCSys":         {Help: "Bytes of memory in garbage collection metadata."},
+	"HeapAlloc":     {Help: "Bytes of allocated heap objects."},
+	"HeapIdle":      {Help: "Bytes in idle (unused) heap spans."},
+	"HeapInuse":     {Help: "Bytes in in-use heap spans."},
+	"HeapObjects":   {Help: "Number of allocated heap objects."},
+	"HeapReleased":  {Help: "Bytes of physical memory returned to the OS."},
+	"HeapSys":       {Help: "Bytes of heap memory obtained from the OS."},
+	"LastGC":        {Help: "Time the last garbage collection finished, as nanoseconds since 1970."},
+	"Lookups":       {Help: "Number of pointer lookups performed by the runtime."},
+	"MCacheInuse":   {Help: "Bytes of allocated mcache structures."},
+	"MCacheSys":     {Help: "Bytes of memory obtained from the OS for mcache structures."},
+	"MSpanInuse":    {Help: "Bytes of allocated mspan structures."},
+	"MSpanSys":      {Help: "Bytes of memory obtained from the OS for mspan structures."},
+	"Mallocs":       {Help: "Cumulative count of heap objects allocated."},
+	"NextGC":        {Help: "Target heap size of the next GC cycle."},
+	"NumForcedGC":   {Help: "Number of GC cycles that were forced by the application."},
+	"NumGC":         {Help: "Number of completed GC cycles."},
+	"OtherSys":      {Help: "Bytes of memory in miscellaneous off-heap runtime allocations."},
+	"PauseTotalNs":  {Help: "Cumulative nanoseconds in GC stop-the-world pauses."},
+	"StackInuse":    {Help: "Bytes in stack spans."},
+	"StackSys":      {Help: "Bytes of stack memory obtained from the OS."},
+	"Sys":           {Help: "Total bytes of memory obtained from the OS."},
+	"TotalAlloc":    {Help: "Cumulative bytes allocated for heap objects."},
+	"PollCount":     {Help: "Number of agent polls."},
+	"RandomValue":   {Help: "Random value updated on every poll."},
+	"TotalMemory":   {Help: "Total amount of system RAM in bytes."},
+	"FreeMemory":    {Help: "Amount of free system RAM in bytes."},
+}
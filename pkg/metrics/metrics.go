@@ -11,6 +11,11 @@ func NewUpdateGaugeMex(name string, value Gauge) MetricExchange {
 	return MetricExchange{ID: name, MType: value.Kind(), Value: &value}
 }
 
+// Create new MetricExchange struct with metrics.Histogram value to be used for updating metrics.
+func NewUpdateHistogramMex(name string, value Histogram) MetricExchange {
+	return MetricExchange{ID: name, MType: value.Kind(), Histogram: &value}
+}
+
 // Create new MetricExchange struct to be used for retrieving of counter metric.
 func NewGetCounterMex(name string) MetricExchange {
 	return MetricExchange{ID: name, MType: KindCounter}
@@ -20,3 +25,8 @@ func NewGetCounterMex(name string) MetricExchange {
 func NewGetGaugeMex(name string) MetricExchange {
 	return MetricExchange{ID: name, MType: KindGauge}
 }
+
+// Create new MetricExchange struct to be used for retrieving of histogram metric.
+func NewGetHistogramMex(name string) MetricExchange {
+	return MetricExchange{ID: name, MType: KindHistogram}
+}
@@ -1,8 +1,13 @@
 package metrics
 
 import (
+	"errors"
+	"math"
+	"reflect"
 	"strconv"
 	"testing"
+
+	"github.com/ex0rcist/metflix/internal/entities"
 )
 
 func TestToCounter(t *testing.T) {
@@ -138,3 +143,232 @@ func TestNewGetGaugeMex(t *testing.T) {
 		t.Errorf("expected Value to be nil, got: %v", mex.Value)
 	}
 }
+
+func TestHistogramMethods(t *testing.T) {
+	h := NewHistogram(1, 0.5, 2)
+
+	if h.Kind() != KindHistogram {
+		t.Errorf("expected: %v, got: %v", KindHistogram, h.Kind())
+	}
+
+	if !reflect.DeepEqual(h.Bounds, []float64{0.5, 1, 2}) {
+		t.Errorf("expected sorted bounds, got: %v", h.Bounds)
+	}
+
+	for _, v := range []float64{0.1, 0.5, 0.7, 3} {
+		h.Observe(v)
+	}
+
+	if !reflect.DeepEqual(h.Counts, []uint64{2, 1, 0, 1}) {
+		t.Errorf("expected counts: %v, got: %v", []uint64{2, 1, 0, 1}, h.Counts)
+	}
+
+	if h.Count != 4 || h.Sum != 4.3 {
+		t.Errorf("expected count=4 sum=4.3, got: count=%v sum=%v", h.Count, h.Sum)
+	}
+
+	if err := h.Validate(); err != nil {
+		t.Errorf("expected valid histogram, got: %v", err)
+	}
+}
+
+func TestHistogramObserveN(t *testing.T) {
+	h := NewHistogram(1)
+	h.ObserveN(0.5, 1000000000)
+	h.ObserveN(2, 0)
+
+	if !reflect.DeepEqual(h.Counts, []uint64{1000000000, 0}) {
+		t.Errorf("expected counts: %v, got: %v", []uint64{1000000000, 0}, h.Counts)
+	}
+
+	if h.Count != 1000000000 || h.Sum != 500000000 {
+		t.Errorf("expected count=1000000000 sum=500000000, got: count=%v sum=%v", h.Count, h.Sum)
+	}
+}
+
+func TestHistogramZeroValue(t *testing.T) {
+	var h Histogram
+	h.Observe(1)
+
+	if h.Count != 0 || h.Counts != nil {
+		t.Errorf("expected observation to be ignored, got: %v", h)
+	}
+
+	if err := h.Validate(); err == nil {
+		t.Errorf("expected zero value histogram to be invalid")
+	}
+}
+
+func TestNewHistogramDefaultBuckets(t *testing.T) {
+	h := NewHistogram()
+
+	if !reflect.DeepEqual(h.Bounds, DefaultBuckets) {
+		t.Errorf("expected: %v, got: %v", DefaultBuckets, h.Bounds)
+	}
+
+	if len(h.Counts) != len(DefaultBuckets)+1 {
+		t.Errorf("expected %d counts, got: %d", len(DefaultBuckets)+1, len(h.Counts))
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a := NewHistogram(1, 2)
+	a.Observe(0.5)
+
+	b := NewHistogram(1, 2)
+	b.Observe(1.5)
+	b.Observe(5)
+
+	merged, err := a.Merge(b)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if !reflect.DeepEqual(merged.Counts, []uint64{1, 1, 1}) {
+		t.Errorf("expected counts: %v, got: %v", []uint64{1, 1, 1}, merged.Counts)
+	}
+
+	if merged.Count != 3 || merged.Sum != 7 {
+		t.Errorf("expected count=3 sum=7, got: count=%v sum=%v", merged.Count, merged.Sum)
+	}
+
+	if a.Count != 1 {
+		t.Errorf("expected source histogram to stay untouched, got count=%v", a.Count)
+	}
+
+	if _, err := a.Merge(NewHistogram(1, 3)); !errors.Is(err, entities.ErrHistogramBucketsMismatch) {
+		t.Errorf("expected: %v, got: %v", entities.ErrHistogramBucketsMismatch, err)
+	}
+}
+
+func TestHistogramMergeWithoutBuckets(t *testing.T) {
+	a := Histogram{Bounds: []float64{}, Counts: []uint64{1}, Count: 1, Sum: 1}
+	b := Histogram{Counts: []uint64{2}, Count: 2, Sum: 3}
+
+	merged, err := a.Merge(b)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(merged.Bounds) != 0 || !reflect.DeepEqual(merged.Counts, []uint64{3}) {
+		t.Errorf("expected single bucket with count 3, got: %v", merged)
+	}
+
+	if merged.Count != 3 || merged.Sum != 4 {
+		t.Errorf("expected count=3 sum=4, got: count=%v sum=%v", merged.Count, merged.Sum)
+	}
+}
+
+func TestHistogramNonFinite(t *testing.T) {
+	h := NewHistogram(1)
+	h.Observe(math.Inf(1))
+	h.Observe(math.NaN())
+
+	if h.Count != 0 || h.Sum != 0 {
+		t.Errorf("expected non-finite observations to be ignored, got: %v", h)
+	}
+
+	invalid := []Histogram{
+		{Bounds: []float64{1}, Counts: []uint64{0, 0}, Sum: math.NaN()},
+		{Bounds: []float64{1}, Counts: []uint64{0, 0}, Sum: math.Inf(-1)},
+		{Bounds: []float64{1, math.Inf(1)}, Counts: []uint64{0, 0, 0}},
+	}
+
+	for _, h := range invalid {
+		if err := h.Validate(); !errors.Is(err, entities.ErrMetricInvalidValue) {
+			t.Errorf("expected: %v, got: %v", entities.ErrMetricInvalidValue, err)
+		}
+
+		if h.String() == "" {
+			t.Errorf("expected non-empty string for %v", h)
+		}
+	}
+}
+
+func TestToHistogram(t *testing.T) {
+	h := NewHistogram(1)
+	h.Observe(0.5)
+
+	tests := []struct {
+		input       string
+		expected    Histogram
+		expectError bool
+	}{
+		{h.String(), h, false},
+		{`{"bounds":[1],"counts":[1],"count":1,"sum":1}`, Histogram{}, true},
+		{`{"bounds":[1],"counts":[1,1],"count":1,"sum":1}`, Histogram{}, true},
+		{"not_a_histogram", Histogram{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ToHistogram(tt.input)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("expected error: %v, got: %v", tt.expectError, err)
+			}
+
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("expected: %v, got: %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestNewUpdateHistogramMex(t *testing.T) {
+	value := NewHistogram(1)
+	value.Observe(2)
+
+	mex := NewUpdateHistogramMex("test_histogram", value)
+
+	if mex.MType != KindHistogram {
+		t.Errorf("expected MType: %v, got: %v", KindHistogram, mex.MType)
+	}
+
+	if mex.Histogram == nil || !reflect.DeepEqual(*mex.Histogram, value) {
+		t.Errorf("expected Histogram: %v, got: %v", value, mex.Histogram)
+	}
+
+	if mex.Delta != nil || mex.Value != nil {
+		t.Errorf("expected Delta and Value to be nil, got: %v, %v", mex.Delta, mex.Value)
+	}
+}
+
+func TestLabelsString(t *testing.T) {
+	tests := []struct {
+		labels   Labels
+		expected string
+	}{
+		{nil, ""},
+		{Labels{}, ""},
+		{Labels{"host": "a"}, `{host="a"}`},
+		{Labels{"service": "api", "env": "prod", "host": "a"}, `{env="prod",host="a",service="api"}`},
+		{Labels{"path": `/a"b`}, `{path="/a\"b"}`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.labels.String(); got != tt.expected {
+			t.Errorf("Labels.String() = %v, want %v", got, tt.expected)
+		}
+	}
+}
+
+func TestLabelsMatch(t *testing.T) {
+	labels := Labels{"host": "a", "env": "prod"}
+
+	tests := []struct {
+		subset   Labels
+		expected bool
+	}{
+		{nil, true},
+		{Labels{"host": "a"}, true},
+		{Labels{"host": "a", "env": "prod"}, true},
+		{Labels{"host": "b"}, false},
+		{Labels{"dc": "eu"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := labels.Match(tt.subset); got != tt.expected {
+			t.Errorf("Labels.Match(%v) = %v, want %v", tt.subset, got, tt.expected)
+		}
+	}
+}
@@ -1,17 +1,26 @@
 package metrics
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/ex0rcist/metflix/internal/entities"
 )
 
 // Available metric types.
 const (
-	KindCounter = "counter"
-	KindGauge   = "gauge"
+	KindCounter   = "counter"
+	KindGauge     = "gauge"
+	KindHistogram = "histogram"
 )
 
+// Default histogram buckets, suitable for latencies measured in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
 // Metric interface
 type Metric interface {
 	Kind() string
@@ -44,6 +53,135 @@ func (g Gauge) String() string {
 	return strconv.FormatFloat(float64(g), 'f', -1, 64)
 }
 
+// Histogram metric type - distribution of observed values.
+// Counts has one more element than Bounds: the last bucket holds values above the highest bound (+Inf).
+// Use NewHistogram to create one, zero value has no buckets and ignores observations.
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Count  uint64    `json:"count"`
+	Sum    float64   `json:"sum"`
+}
+
+// Create empty histogram with given bucket upper bounds (DefaultBuckets if none given).
+func NewHistogram(bounds ...float64) Histogram {
+	if len(bounds) == 0 {
+		bounds = DefaultBuckets
+	}
+
+	sorted := make([]float64, len(bounds))
+	copy(sorted, bounds)
+	sort.Float64s(sorted)
+
+	return Histogram{
+		Bounds: sorted,
+		Counts: make([]uint64, len(sorted)+1),
+	}
+}
+
+// Return metric kind
+func (h Histogram) Kind() string {
+	return KindHistogram
+}
+
+// Stringer
+func (h Histogram) String() string {
+	data, err := json.Marshal(h)
+	if err != nil {
+		// only possible for histogram which doesn't pass Validate
+		return fmt.Sprintf("invalid histogram: %v", err)
+	}
+
+	return string(data)
+}
+
+// Record single observation.
+func (h *Histogram) Observe(value float64) {
+	h.ObserveN(value, 1)
+}
+
+// Record the same observation n times in one step, e.g. for sampled data.
+// Non-finite values are ignored as they would make Sum non-finite.
+func (h *Histogram) ObserveN(value float64, n uint64) {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return // no buckets, see Validate
+	}
+
+	if !isFinite(value) {
+		return
+	}
+
+	idx := sort.SearchFloat64s(h.Bounds, value)
+
+	h.Counts[idx] += n
+	h.Count += n
+	h.Sum += value * float64(n)
+}
+
+// Merge two histograms with the same buckets by adding their counts.
+func (h Histogram) Merge(other Histogram) (Histogram, error) {
+	if len(h.Bounds) != len(other.Bounds) || len(h.Counts) != len(other.Counts) {
+		return Histogram{}, entities.ErrHistogramBucketsMismatch
+	}
+
+	for i := range h.Bounds {
+		if h.Bounds[i] != other.Bounds[i] {
+			return Histogram{}, entities.ErrHistogramBucketsMismatch
+		}
+	}
+
+	// not NewHistogram: it falls back to DefaultBuckets for bucketless histogram
+	result := Histogram{
+		Bounds: append([]float64(nil), h.Bounds...),
+		Counts: make([]uint64, len(h.Counts)),
+	}
+
+	for i := range result.Counts {
+		result.Counts[i] = h.Counts[i] + other.Counts[i]
+	}
+
+	result.Count = h.Count + other.Count
+	result.Sum = h.Sum + other.Sum
+
+	return result, nil
+}
+
+// Ensure histogram is consistent.
+func (h Histogram) Validate() error {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return entities.ErrMetricInvalidValue
+	}
+
+	if !sort.Float64sAreSorted(h.Bounds) {
+		return entities.ErrMetricInvalidValue
+	}
+
+	if !isFinite(h.Sum) {
+		return entities.ErrMetricInvalidValue
+	}
+
+	for _, b := range h.Bounds {
+		if !isFinite(b) {
+			return entities.ErrMetricInvalidValue
+		}
+	}
+
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+
+	if total != h.Count {
+		return entities.ErrMetricInvalidValue
+	}
+
+	return nil
+}
+
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
 // Convert string value to metrics.Counter
 func ToCounter(value string) (Counter, error) {
 	rawValue, err := strconv.ParseInt(value, 10, 64)
@@ -64,10 +202,67 @@ func ToGauge(value string) (Gauge, error) {
 	return Gauge(rawValue), nil
 }
 
+// Convert string value (as returned by Histogram.String()) to metrics.Histogram
+func ToHistogram(value string) (Histogram, error) {
+	var h Histogram
+
+	if err := json.Unmarshal([]byte(value), &h); err != nil {
+		return Histogram{}, entities.ErrMetricInvalidValue
+	}
+
+	if err := h.Validate(); err != nil {
+		return Histogram{}, err
+	}
+
+	return h, nil
+}
+
+// Labels (tags) attached to metric, e.g. host, service, env.
+type Labels map[string]string
+
+// Return label names in sorted order.
+func (l Labels) Keys() []string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Check that all given labels are present with the same values.
+func (l Labels) Match(subset Labels) bool {
+	for k, v := range subset {
+		if value, ok := l[k]; !ok || value != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Stringer, returns canonical form: {env="prod",host="a"}. Empty labels give empty string.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(l))
+	for _, k := range l.Keys() {
+		pairs = append(pairs, k+"="+strconv.Quote(l[k]))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
 // Agent/Server exchange schema according to spec
 type MetricExchange struct {
-	ID    string   `json:"id"`
-	MType string   `json:"type"`
-	Delta *Counter `json:"delta,omitempty"`
-	Value *Gauge   `json:"value,omitempty"`
+	ID        string     `json:"id"`
+	MType     string     `json:"type"`
+	Delta     *Counter   `json:"delta,omitempty"`
+	Value     *Gauge     `json:"value,omitempty"`
+	Histogram *Histogram `json:"histogram,omitempty"`
+	Labels    Labels     `json:"labels,omitempty"`
 }